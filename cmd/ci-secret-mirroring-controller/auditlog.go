@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+)
+
+// verifyAuditLog checks that no entry of an audit log was edited, removed
+// or reordered since the controller wrote it, and that the log holds at
+// least as many entries as the controller reported.
+func verifyAuditLog(args []string) error {
+	flagSet := flag.NewFlagSet("verify-audit-log", flag.ExitOnError)
+	path := flagSet.String("file", "", "Path to the audit log to verify.")
+	keyFile := flagSet.String("key-file", "", "Path to the key the chain of the audit log is keyed with, the --audit-log-key-file of the controller.")
+	expected := flagSet.Int("entries", 0, "Number of entries the log must hold at least, e.g. the last secret_mirror_audit_log_entries reported by the controller, to detect logs that lost their last entries.")
+	flagSet.Parse(args)
+	if *path == "" || *keyFile == "" {
+		return errors.New("--file and --key-file are required")
+	}
+	key, err := readToken(*keyFile)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("--key-file %s holds no key", *keyFile)
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	entries, err := auditlog.Verify(file, []byte(key))
+	if err != nil {
+		return fmt.Errorf("%s is not intact: %v", *path, err)
+	}
+	if entries < *expected {
+		return fmt.Errorf("%s is not intact: it holds %d entries, but %d were written", *path, entries, *expected)
+	}
+	fmt.Printf("%s is intact with %d entries\n", *path, entries)
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/backup"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/version"
+)
+
+type importOptions struct {
+	options
+
+	archive      string
+	identityFile string
+	dryRun       bool
+}
+
+func bindImportOptions(flag *flag.FlagSet) *importOptions {
+	opt := &importOptions{}
+	flag.StringVar(&opt.configLocation, "config", "", "Path to configuration file. Only targets that a rule writes to are restored.")
+	flag.StringVar(&opt.archive, "archive", "", "Path to the backup archive to restore targets from.")
+	flag.StringVar(&opt.identityFile, "backup-identity-file", "", "Path to a file holding an age identity that the archive is encrypted to, as written by age-keygen.")
+	flag.Var(&opt.protectedTargets, "protected-target-pattern", fmt.Sprintf("A glob pattern matching the names of targets that must never be written to, in addition to %s. May be repeated.", strings.Join(controller.DefaultProtectedTargetPatterns, ", ")))
+	flag.BoolVar(&opt.dryRun, "dry-run", false, "Report which targets would be restored without creating them.")
+	bindClusterOptions(flag, &opt.options)
+	flag.StringVar(&opt.logLevel, "log-level", logrus.InfoLevel.String(), "Logging level.")
+	return opt
+}
+
+func (o *importOptions) Validate() error {
+	level, err := logrus.ParseLevel(o.logLevel)
+	if err != nil {
+		return fmt.Errorf("failed to parse --log-level: %v", err)
+	}
+	logrus.SetLevel(level)
+
+	if o.configLocation == "" || o.archive == "" || o.identityFile == "" {
+		return errors.New("--config, --archive and --backup-identity-file are required")
+	}
+
+	return o.validateClusterOptions()
+}
+
+// importArchive restores targets that are missing from the cluster from a
+// backup archive, e.g. when rebuilding a cluster, and prints what it did
+// with every target in the archive as JSON.
+func importArchive(args []string) error {
+	flagSet := flag.NewFlagSet("import", flag.ExitOnError)
+	opt := bindImportOptions(flagSet)
+	flagSet.Parse(args)
+	if err := opt.Validate(); err != nil {
+		return fmt.Errorf("invalid options specified: %v", err)
+	}
+
+	configuration, err := config.Load(opt.configLocation)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	identities, err := backup.LoadIdentities(opt.identityFile)
+	if err != nil {
+		return fmt.Errorf("failed to load backup identities: %v", err)
+	}
+	file, err := os.Open(opt.archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+	archive, err := backup.Read(file, identities)
+	if err != nil {
+		return err
+	}
+
+	clusterConfig, err := opt.loadClusterConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load cluster config: %v", err)
+	}
+	clusterConfig.UserAgent = version.UserAgent(opt.userAgentSuffix)
+	client, err := kubernetes.NewForConfig(clusterConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize kubernetes client: %v", err)
+	}
+
+	restored, restoreErr := controller.RestoreTargets(client, configuration, archive, opt.protectedTargets, opt.dryRun)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(restored); err != nil {
+		return err
+	}
+	return restoreErr
+}
@@ -1,23 +1,53 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"path"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"filippo.io/age"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/admin"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/azurekeyvault"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/backup"
 	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller"
 	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/externalsecrets"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/gcpsecretmanager"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/notify"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/sealedsecrets"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/secretsmanager"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/vault"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/version"
 )
 
 const (
@@ -25,20 +55,233 @@ const (
 )
 
 type options struct {
-	configLocation string
-	numWorkers     int
-	logLevel       string
+	configLocation  string
+	configGit       config.GitRepository
+	configGitPoll   time.Duration
+	configURLPoll   time.Duration
+	numWorkers      int
+	maxQueueDepth   int
+	quarantine      int
+	retry           controller.RetryPolicy
+	listenAddress   string
+	adminTokenFile  string
+	grpcAddress     string
+	pprofAddress    string
+	auditLogPath    string
+	auditLogActor   string
+	auditLogKeyFile string
+	logLevel        string
+
+	slackTokenFile string
+	smtpAddress    string
+	smtpFrom       string
+
+	vaultAddress   string
+	vaultTokenFile string
+
+	awsSecretsManager  bool
+	awsCredentialsFile string
+	awsProfile         string
+
+	gcpSecretManager   bool
+	gcpCredentialsFile string
+
+	azureKeyVault          bool
+	azureKeyVaultDNSSuffix string
+	azureClientID          string
+
+	sealedSecretsCert string
+	externalSecrets   bool
+
+	sourceClusters clusterKubeconfigs
+	targetClusters clusterKubeconfigs
+
+	writeKubeconfig string
+	writeTokenFile  string
+
+	credentialExpiryWarning time.Duration
+	publishVersions         bool
+	hashKeyFile             string
+	watchSecretMirrors      bool
+	admissionAddress        string
+	admissionCertFile       string
+	admissionKeyFile        string
+	mirrorConfigMaps        bool
+	namespaceSelectors      bool
+	configuredNamespaces    bool
+	secretLabelSelector     string
+	protectedTargets        globPatterns
+	inventoryNamespace      string
+	pruneDryRun             bool
+	once                    bool
+	pruneOrphans            bool
+	warmStart               time.Duration
+	auditPeriod             time.Duration
+	mode                    string
+	frozen                  bool
+
+	master               string
+	certificateAuthority string
+	tokenFile            string
+
+	userAgentSuffix string
+
+	listPageSize int64
+
+	fileSinkDirectory string
+
+	backupDirectory      string
+	backupS3Bucket       string
+	backupS3Prefix       string
+	backupS3Region       string
+	backupS3Endpoint     string
+	backupRecipientsFile string
+	backupPeriod         time.Duration
+	backupRetention      int
+
+	faults controller.FaultInjector
+}
+
+// Modes the controller runs in
+const (
+	// modeMirror writes targets
+	modeMirror = "mirror"
+	// modeAudit never writes targets but reports those that are
+	// missing or drifted from their source
+	modeAudit = "audit"
+)
+
+// clusterKubeconfigs maps cluster names to kubeconfig paths,
+// collected from repeated name=path flag values.
+type clusterKubeconfigs map[string]string
+
+func (c clusterKubeconfigs) String() string {
+	var values []string
+	for name, path := range c {
+		values = append(values, fmt.Sprintf("%s=%s", name, path))
+	}
+	return strings.Join(values, ",")
+}
+
+func (c clusterKubeconfigs) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected name=path, not %q", value)
+	}
+	if strings.ContainsAny(parts[0], ":/") {
+		return fmt.Errorf("cluster name %q must not contain ':' or '/'", parts[0])
+	}
+	if _, duplicate := c[parts[0]]; duplicate {
+		return fmt.Errorf("cluster %q is configured more than once", parts[0])
+	}
+	c[parts[0]] = parts[1]
+	return nil
+}
+
+// globPatterns collects repeated glob pattern flag values.
+type globPatterns []string
+
+func (g *globPatterns) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globPatterns) Set(value string) error {
+	if _, err := path.Match(value, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", value, err)
+	}
+	*g = append(*g, value)
+	return nil
 }
 
 func bindOptions(flag *flag.FlagSet) *options {
-	opt := &options{}
-	flag.StringVar(&opt.configLocation, "config", "", "Path to configuration file.")
+	opt := &options{sourceClusters: clusterKubeconfigs{}, targetClusters: clusterKubeconfigs{}}
+	flag.StringVar(&opt.configLocation, "config", "", "Path to configuration file, relative to the root of the repository with --config-git-url, or an http(s):// URL serving it.")
+	flag.StringVar(&opt.configGit.URL, "config-git-url", "", "URL of a Git repository to load the configuration from instead of the local filesystem. The repository is polled for new commits and the commit of the configuration is recorded on the targets. Credentials are taken from the Git configuration, e.g. a credential helper or SSH key.")
+	flag.StringVar(&opt.configGit.Ref, "config-git-ref", "", "Branch, tag or commit of --config-git-url to load the configuration from. The default branch of the repository is used without it.")
+	flag.DurationVar(&opt.configGitPoll, "config-git-poll-interval", time.Minute, "How often to poll --config-git-url for new commits.")
+	flag.DurationVar(&opt.configURLPoll, "config-url-poll-interval", 30*time.Second, "How often to poll --config for changes when it is a URL. Requests carry the ETag of the last response, so servers supporting it only send the configuration when it changed.")
 	flag.IntVar(&opt.numWorkers, "num-workers", 10, "Number of worker threads.")
+	flag.IntVar(&opt.maxQueueDepth, "max-queue-depth", 10000, "Maximum number of keys waiting in the work queue before new keys are shed. Zero disables the limit.")
+	flag.IntVar(&opt.quarantine, "quarantine-after", 10, "Number of consecutive failures after which a rule is quarantined until the configuration is reloaded or the rule is resumed. Zero disables quarantine.")
+	flag.DurationVar(&opt.retry.BaseDelay, "retry-base-delay", controller.DefaultRetryPolicy.BaseDelay, "Delay before a failing source is first retried. The delay doubles with every consecutive failure.")
+	flag.DurationVar(&opt.retry.MaxDelay, "retry-max-delay", controller.DefaultRetryPolicy.MaxDelay, "Longest delay before a failing source is retried.")
+	flag.IntVar(&opt.retry.MaxRetries, "max-retries", controller.DefaultRetryPolicy.MaxRetries, "Number of times a failing source is retried before it is dropped until it changes, the configuration is reloaded or it is audited.")
+	flag.StringVar(&opt.listenAddress, "listen-address", ":8080", "Address on which to serve metrics and the admin endpoints.")
+	flag.StringVar(&opt.adminTokenFile, "admin-token-file", "", "Path to a bearer token required by the admin endpoints that trigger syncs or resume rules. These endpoints are disabled without it.")
+	flag.StringVar(&opt.grpcAddress, "grpc-address", "", "Address on which to serve the gRPC admin API. Requires --admin-token-file.")
+	flag.StringVar(&opt.pprofAddress, "pprof-address", "", "Address on which to serve the net/http/pprof profiling endpoints under /debug/pprof/. Profiling is disabled without it.")
+	flag.StringVar(&opt.auditLogPath, "audit-log", "", "Path to a file, or - for standard output, to which every create, update and delete of a target is appended as a hash-chained JSON entry. Values are never logged, only their keyed hash. The audit log is disabled without it. Requires --audit-log-key-file and --hash-key-file.")
+	flag.StringVar(&opt.auditLogActor, "audit-log-actor", "", "Identity of the controller recorded in the audit log, e.g. its service account. Defaults to the name of the controller and its host.")
+	flag.StringVar(&opt.auditLogKeyFile, "audit-log-key-file", "", "Path to a secret key that the chain of the audit log is keyed with, which readers of the log must not hold. Required by --audit-log.")
+	flag.StringVar(&opt.slackTokenFile, "slack-token-file", "", "Path to a Slack token used to post failure notifications.")
+	flag.StringVar(&opt.smtpAddress, "smtp-address", "", "Address (host:port) of the SMTP relay used to mail failure notifications.")
+	flag.StringVar(&opt.smtpFrom, "smtp-from", "", "Sender address for mailed failure notifications.")
+	flag.StringVar(&opt.vaultAddress, "vault-address", "", "URL of the Vault server that rules may mirror from with from.vault.path and to with to.vault.path. Requires --vault-token-file.")
+	flag.StringVar(&opt.vaultTokenFile, "vault-token-file", "", "Path to a Vault token allowed to read the mirrored secrets and to write the data and metadata of published secrets. The token is renewed while the controller runs.")
+	flag.BoolVar(&opt.awsSecretsManager, "aws-secrets-manager", false, "Allow rules to mirror from AWS Secrets Manager with from.awsSecretsManager.arn. Authenticates with --aws-credentials-file, or else with the IAM role for the service account of the controller set in AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE.")
+	flag.StringVar(&opt.awsCredentialsFile, "aws-credentials-file", "", "Path to a shared AWS credentials file holding static credentials allowed to read the mirrored secrets.")
+	flag.StringVar(&opt.awsProfile, "aws-profile", "default", "Profile of the shared AWS credentials file to use.")
+	flag.BoolVar(&opt.gcpSecretManager, "gcp-secret-manager", false, "Allow rules to mirror from GCP Secret Manager with from.gcpSecretManager.name. Authenticates with --gcp-credentials-file, or else as the service account of the workload through the metadata server, e.g. with Workload Identity.")
+	flag.StringVar(&opt.gcpCredentialsFile, "gcp-credentials-file", "", "Path to a JSON key of a GCP service account allowed to access the mirrored secrets.")
+	flag.BoolVar(&opt.azureKeyVault, "azure-key-vault", false, "Allow rules to mirror from Azure Key Vault with from.azureKeyVault. Authenticates with the managed identity of the node through the instance metadata service.")
+	flag.StringVar(&opt.azureKeyVaultDNSSuffix, "azure-key-vault-dns-suffix", azurekeyvault.DefaultDNSSuffix, "Domain of the key vaults, which differs in sovereign clouds.")
+	flag.StringVar(&opt.azureClientID, "azure-client-id", "", "Client ID of the user-assigned managed identity to authenticate with. The system-assigned identity is used without it.")
+	flag.StringVar(&opt.sealedSecretsCert, "sealed-secrets-cert", "", "Path to the certificate of the sealing key of the cluster, as fetched with kubeseal --fetch-cert, which rules with output: SealedSecret seal their data with. The identity used for writes needs to write SealedSecrets.")
+	flag.BoolVar(&opt.externalSecrets, "external-secrets", false, "Allow rules with output: ExternalSecret to write ExternalSecrets that the External Secrets Operator fetches the source with. The identity used for writes needs to write ExternalSecrets.")
+	flag.Var(opt.sourceClusters, "source-cluster", "A remote cluster that rules may mirror from, as name=/path/to/kubeconfig. The credentials only need to read secrets. May be repeated.")
+	flag.Var(opt.targetClusters, "target-cluster", "A remote cluster that rules may mirror to, as name=/path/to/kubeconfig. The credentials need to read and write secrets. May be repeated.")
+	flag.StringVar(&opt.writeKubeconfig, "write-kubeconfig", "", "Path to a kubeconfig whose identity is used only to write targets and events. The default identity then only needs to read secrets.")
+	flag.StringVar(&opt.writeTokenFile, "write-token-file", "", "Path to a bearer token used only to write targets and events, against the default cluster. The default identity then only needs to read secrets.")
+	flag.DurationVar(&opt.credentialExpiryWarning, "credential-expiry-warning", 7*24*time.Hour, "How long before mirrored tokens and client certificates expire to start warning about them.")
+	flag.BoolVar(&opt.watchSecretMirrors, "watch-secret-mirrors", false, "Merge the rules declared by SecretMirror objects in every namespace into the configuration. Requires the SecretMirror CustomResourceDefinition to be installed.")
+	flag.StringVar(&opt.admissionAddress, "admission-address", "", "Address on which to serve the validating admission webhook for SecretMirror objects under /validate-secretmirrors over TLS. Requires --watch-secret-mirrors, --admission-cert-file and --admission-key-file.")
+	flag.StringVar(&opt.admissionCertFile, "admission-cert-file", "", "Path to the serving certificate of the admission webhook.")
+	flag.StringVar(&opt.admissionKeyFile, "admission-key-file", "", "Path to the private key of the serving certificate of the admission webhook.")
+	flag.BoolVar(&opt.mirrorConfigMaps, "mirror-config-maps", false, "Mirror the ConfigMaps configured in the configMaps section of the configuration. Requires permissions to list and watch ConfigMaps in every namespace.")
+	flag.BoolVar(&opt.namespaceSelectors, "namespace-selectors", false, "Mirror into every namespace matching the to.namespaceSelector of a rule. Requires permissions to list and watch namespaces.")
+	flag.BoolVar(&opt.configuredNamespaces, "watch-configured-namespaces-only", false, "Watch secrets only in the namespaces that the configuration references instead of in every namespace, which only requires permissions to list and watch secrets in those namespaces. Namespaces are watched and unwatched as the configuration is reloaded.")
+	flag.StringVar(&opt.secretLabelSelector, "secret-label-selector", "", "Label selector, e.g. ci.openshift.io/mirror=true, restricting the secrets that are watched to those carrying the labels. Every source must carry them, while targets are read from the API server.")
+	flag.BoolVar(&opt.publishVersions, "publish-secret-versions", false, "Maintain a mirrored-secret-versions ConfigMap in every target namespace, mapping targets to the keyed hash of their data and when it last changed. Requires --hash-key-file.")
+	flag.StringVar(&opt.hashKeyFile, "hash-key-file", "", "Path to a secret key that hashes of data are keyed with where the data itself cannot be read: on SealedSecrets, in the versions ConfigMap, in the pod templates of workloads, in the audit log and in the names of changes awaiting approval. Required by --sealed-secrets-cert, --publish-secret-versions, --audit-log and rules setting injectChecksum or requiring approval.")
+	flag.Var(&opt.protectedTargets, "protected-target-pattern", fmt.Sprintf("A glob pattern matching the names of targets that must never be written to, in addition to %s. May be repeated.", strings.Join(controller.DefaultProtectedTargetPatterns, ", ")))
+	flag.StringVar(&opt.inventoryNamespace, "inventory-namespace", "", fmt.Sprintf("Namespace in which to maintain the %s ConfigMap, recording every target the controller manages and the rule that produced it. The inventory is disabled without it.", controller.InventoryConfigMap))
+	flag.BoolVar(&opt.pruneOrphans, "prune-orphaned-targets", false, "Delete targets that the controller wrote when the rules writing to them are removed from the configuration.")
+	flag.BoolVar(&opt.pruneDryRun, "prune-dry-run", false, "Print the managed targets that pruning would delete under the configuration as JSON and exit, without deleting anything. Requires --inventory-namespace.")
+	flag.BoolVar(&opt.once, "once", false, "Reconcile every configured rule once and exit, failing if any rule fails, instead of watching sources.")
+	flag.DurationVar(&opt.warmStart, "warm-start-period", 30*time.Second, "Period over which the initial reconciliation of every source is spread after a restart. Zero reconciles every source right away.")
+	flag.StringVar(&opt.mode, "mode", modeMirror, fmt.Sprintf("Mode to run in: %s writes targets, %s never writes targets but reports those that are missing or drifted from their source.", modeMirror, modeAudit))
+	bindClusterOptions(flag, opt)
+	flag.Int64Var(&opt.listPageSize, "list-page-size", 0, "Number of secrets to request per page when listing secrets. By default, all secrets are listed at once from the watch cache of the API server. Paged lists bound the memory the API server needs to serve a list, but bypass the watch cache: every page is a quorum read from etcd.")
+	flag.StringVar(&opt.fileSinkDirectory, "file-sink-directory", "", "Directory holding the directories that rules with a file sink write their data to. File sinks fail without it.")
+	flag.StringVar(&opt.backupDirectory, "backup-directory", "", "Directory, e.g. on a persistent volume, receiving encrypted archives of every target for disaster recovery. Backups are disabled without it or --backup-s3-bucket.")
+	flag.StringVar(&opt.backupS3Bucket, "backup-s3-bucket", "", "S3 bucket receiving encrypted archives of every target for disaster recovery, instead of --backup-directory. Authenticates like --aws-secrets-manager.")
+	flag.StringVar(&opt.backupS3Prefix, "backup-s3-prefix", "", "Prefix of the names of archives in --backup-s3-bucket, e.g. backups/.")
+	flag.StringVar(&opt.backupS3Region, "backup-s3-region", "us-east-1", "Region of --backup-s3-bucket.")
+	flag.StringVar(&opt.backupS3Endpoint, "backup-s3-endpoint", "", "URL of an S3-compatible service holding --backup-s3-bucket, e.g. MinIO. AWS is used without it.")
+	flag.StringVar(&opt.backupRecipientsFile, "backup-recipients-file", "", "Path to a file listing the age public keys that backups are encrypted to, one per line. Required by backups.")
+	flag.DurationVar(&opt.backupPeriod, "backup-period", 24*time.Hour, "How often targets are backed up.")
+	flag.IntVar(&opt.backupRetention, "backup-retention", 7, "Number of backups to keep. Zero keeps every backup.")
+	flag.Float64Var(&opt.faults.ErrorRate, "fault-error-rate", 0, "Rate between 0 and 1 of requests to the API server that fail with an injected error. For resilience testing only.")
+	flag.Float64Var(&opt.faults.LatencyRate, "fault-latency-rate", 0, "Rate between 0 and 1 of requests to the API server that are delayed by --fault-latency. For resilience testing only.")
+	flag.DurationVar(&opt.faults.Latency, "fault-latency", time.Second, "Delay injected into requests selected by --fault-latency-rate.")
+	flag.Float64Var(&opt.faults.WatchDropRate, "fault-watch-drop-rate", 0, "Rate between 0 and 1 of watches that are dropped within --fault-watch-drop-after. For resilience testing only.")
+	flag.DurationVar(&opt.faults.WatchDropAfter, "fault-watch-drop-after", time.Minute, "Longest time that watches selected by --fault-watch-drop-rate stay open.")
+	flag.BoolVar(&opt.frozen, "frozen", false, "Start with writes frozen: changes are computed and exposed on /freeze but not written until writes are unfrozen.")
+	flag.DurationVar(&opt.auditPeriod, "audit-period", time.Hour, "How often the target of every rule is compared to its source to repair drift the watch missed. Zero disables auditing.")
 	flag.StringVar(&opt.logLevel, "log-level", logrus.DebugLevel.String(), "Logging level.")
 
 	return opt
 }
 
+// bindClusterOptions binds the flags that configure
+// how to connect to the cluster we're deploying to.
+func bindClusterOptions(flag *flag.FlagSet, opt *options) {
+	flag.StringVar(&opt.master, "master", "", "Address of the API server, overriding in-cluster configuration and kubeconfig files.")
+	flag.StringVar(&opt.certificateAuthority, "certificate-authority", "", "Path to a certificate authority bundle for the API server. Requires --master.")
+	flag.StringVar(&opt.tokenFile, "token-file", "", "Path to a bearer token to authenticate to the API server with. Requires --master.")
+	flag.StringVar(&opt.userAgentSuffix, "user-agent-suffix", "", "Suffix appended to the User-Agent sent to API servers, to tell apart instances of the controller in audit logs.")
+}
+
 func (o *options) Validate() error {
 	level, err := logrus.ParseLevel(o.logLevel)
 	if err != nil {
@@ -50,54 +293,891 @@ func (o *options) Validate() error {
 		return fmt.Errorf("a non-zero, positive --num-workers is necessary, not %d", o.numWorkers)
 	}
 
+	if o.maxQueueDepth < 0 {
+		return fmt.Errorf("--max-queue-depth must not be negative, not %d", o.maxQueueDepth)
+	}
+
+	if o.quarantine < 0 {
+		return fmt.Errorf("--quarantine-after must not be negative, not %d", o.quarantine)
+	}
+
+	if o.retry.BaseDelay <= 0 {
+		return fmt.Errorf("--retry-base-delay must be positive, not %s", o.retry.BaseDelay)
+	}
+
+	if o.retry.MaxDelay < o.retry.BaseDelay {
+		return fmt.Errorf("--retry-max-delay must not be shorter than --retry-base-delay, not %s", o.retry.MaxDelay)
+	}
+
+	if o.retry.MaxRetries < 1 {
+		return fmt.Errorf("a non-zero, positive --max-retries is necessary, not %d", o.retry.MaxRetries)
+	}
+
+	if o.credentialExpiryWarning < 0 {
+		return fmt.Errorf("--credential-expiry-warning must not be negative, not %s", o.credentialExpiryWarning)
+	}
+
+	if o.warmStart < 0 {
+		return fmt.Errorf("--warm-start-period must not be negative, not %s", o.warmStart)
+	}
+
+	if o.auditPeriod < 0 {
+		return fmt.Errorf("--audit-period must not be negative, not %s", o.auditPeriod)
+	}
+
+	if o.mode != modeMirror && o.mode != modeAudit {
+		return fmt.Errorf("--mode must be one of %s or %s, not %q", modeMirror, modeAudit, o.mode)
+	}
+
+	if o.grpcAddress != "" && o.adminTokenFile == "" {
+		return errors.New("--admin-token-file is required to serve the gRPC admin API")
+	}
+
+	if (o.smtpAddress == "") != (o.smtpFrom == "") {
+		return errors.New("--smtp-address and --smtp-from must be provided together")
+	}
+
+	if (o.vaultAddress == "") != (o.vaultTokenFile == "") {
+		return errors.New("--vault-address and --vault-token-file must be provided together")
+	}
+
+	if o.awsCredentialsFile != "" && !o.awsSecretsManager && o.backupS3Bucket == "" {
+		return errors.New("--aws-credentials-file requires --aws-secrets-manager or --backup-s3-bucket")
+	}
+
+	if o.gcpCredentialsFile != "" && !o.gcpSecretManager {
+		return errors.New("--gcp-credentials-file requires --gcp-secret-manager")
+	}
+
+	if o.writeKubeconfig != "" && o.writeTokenFile != "" {
+		return errors.New("--write-kubeconfig and --write-token-file are mutually exclusive")
+	}
+
 	if o.configLocation == "" {
-		return errors.New("a file path must be provided for --config")
+		return errors.New("a file path or URL must be provided for --config")
+	}
+
+	if o.configGit.URL == "" && o.configGit.Ref != "" {
+		return errors.New("--config-git-ref requires --config-git-url")
+	}
+
+	if config.IsURL(o.configLocation) {
+		if o.configGit.URL != "" {
+			return errors.New("--config must not be a URL with --config-git-url")
+		}
+		if o.configURLPoll <= 0 {
+			return fmt.Errorf("--config-url-poll-interval must be positive, not %s", o.configURLPoll)
+		}
+	}
+
+	if o.configGit.URL != "" {
+		if path.IsAbs(o.configLocation) || strings.HasPrefix(path.Clean(o.configLocation), "..") {
+			return fmt.Errorf("--config must be a path within the repository with --config-git-url, not %s", o.configLocation)
+		}
+		if o.configGitPoll <= 0 {
+			return fmt.Errorf("--config-git-poll-interval must be positive, not %s", o.configGitPoll)
+		}
+	}
+
+	if o.listPageSize < 0 {
+		return fmt.Errorf("--list-page-size must not be negative, not %d", o.listPageSize)
+	}
+
+	if (o.sealedSecretsCert != "" || o.publishVersions || o.auditLogPath != "") && o.hashKeyFile == "" {
+		return errors.New("--hash-key-file is required by --sealed-secrets-cert, --publish-secret-versions and --audit-log")
+	}
+
+	if (o.auditLogPath == "") != (o.auditLogKeyFile == "") {
+		return errors.New("--audit-log-key-file is required by, and requires, --audit-log")
+	}
+
+	if o.backupDirectory != "" && o.backupS3Bucket != "" {
+		return errors.New("--backup-directory and --backup-s3-bucket are mutually exclusive")
+	}
+
+	if (o.backupDirectory == "" && o.backupS3Bucket == "") != (o.backupRecipientsFile == "") {
+		return errors.New("--backup-recipients-file is required by, and requires, --backup-directory or --backup-s3-bucket")
+	}
+
+	if o.backupS3Endpoint != "" && !config.IsURL(o.backupS3Endpoint) {
+		return fmt.Errorf("--backup-s3-endpoint must be an HTTP(S) URL, not %q", o.backupS3Endpoint)
+	}
+
+	if o.backupPeriod <= 0 {
+		return fmt.Errorf("--backup-period must be positive, not %s", o.backupPeriod)
+	}
+
+	if o.backupRetention < 0 {
+		return fmt.Errorf("--backup-retention must not be negative, not %d", o.backupRetention)
+	}
+
+	for name, rate := range map[string]float64{"--fault-error-rate": o.faults.ErrorRate, "--fault-latency-rate": o.faults.LatencyRate, "--fault-watch-drop-rate": o.faults.WatchDropRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, not %v", name, rate)
+		}
+	}
+
+	if err := o.validateClusterOptions(); err != nil {
+		return err
+	}
+
+	if o.pruneDryRun && o.inventoryNamespace == "" {
+		return errors.New("--inventory-namespace is required for --prune-dry-run")
+	}
+
+	if o.once && o.pruneDryRun {
+		return errors.New("--once and --prune-dry-run are mutually exclusive")
+	}
+
+	if _, err := labels.Parse(o.secretLabelSelector); err != nil {
+		return fmt.Errorf("failed to parse --secret-label-selector: %v", err)
+	}
+
+	if o.configuredNamespaces && o.namespaceSelectors {
+		return errors.New("--watch-configured-namespaces-only and --namespace-selectors are mutually exclusive")
+	}
+
+	if o.configuredNamespaces && o.watchSecretMirrors {
+		return errors.New("--watch-configured-namespaces-only and --watch-secret-mirrors are mutually exclusive")
+	}
+
+	if o.admissionAddress != "" && !o.watchSecretMirrors {
+		return errors.New("--admission-address requires --watch-secret-mirrors")
+	}
+
+	if o.admissionAddress != "" && (o.admissionCertFile == "" || o.admissionKeyFile == "") {
+		return errors.New("--admission-address requires --admission-cert-file and --admission-key-file")
 	}
 
 	return nil
 }
 
 func (o *options) Run() error {
+	if o.pruneDryRun {
+		return o.reportPrunable()
+	}
+
 	configAgent := &config.Agent{}
-	if err := configAgent.Start(o.configLocation); err != nil {
+	if config.IsURL(o.configLocation) {
+		if err := configAgent.StartURL(o.configLocation, o.configURLPoll); err != nil {
+			logrus.WithError(err).Fatal("Error starting config agent.")
+		}
+	} else if o.configGit.URL != "" {
+		if err := o.cloneConfig(); err != nil {
+			logrus.WithError(err).Fatal("Error starting config agent.")
+		}
+		if err := configAgent.StartGit(&o.configGit, o.configLocation, o.configGitPoll); err != nil {
+			logrus.WithError(err).Fatal("Error starting config agent.")
+		}
+	} else if err := configAgent.Start(o.configLocation); err != nil {
 		logrus.WithError(err).Fatal("Error starting config agent.")
 	}
 
-	clusterConfig, err := loadClusterConfig()
+	clusterConfig, err := o.loadClusterConfig()
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to load cluster config")
 	}
+	clusterConfig.UserAgent = version.UserAgent(o.userAgentSuffix)
+	throttle := controller.NewThrottle()
+	clusterConfig.WrapTransport = throttle.WrapTransport
+	if o.faults.Enabled() {
+		logrus.Warn("injecting faults into requests to the API server")
+		clusterConfig.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			return throttle.WrapTransport(o.faults.WrapTransport(rt))
+		}
+	}
 
 	client, err := kubernetes.NewForConfig(clusterConfig)
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to initialize kubernetes client")
 	}
 
+	writeClient, err := o.loadWriteClient(clusterConfig)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to initialize kubernetes client for writes")
+	}
+
 	informerFactory := informers.NewSharedInformerFactory(client, resync)
+	if !o.configuredNamespaces {
+		// requesting the informer makes the factory start it
+		controller.UsePagedFilteredSecretInformer(informerFactory, metav1.NamespaceAll, o.secretLabelSelector, o.listPageSize)
+	}
 
-	secretMirror := controller.NewSecretMirror(informerFactory.Core().V1().Secrets(), client, configAgent.Config)
-	stop := make(chan struct{})
+	factories := []informers.SharedInformerFactory{informerFactory}
+	remoteClusters := map[string]controller.RemoteCluster{}
+	for cluster, kubeconfig := range o.sourceClusters {
+		remoteConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			logrus.WithField("cluster", cluster).WithError(err).Fatal("failed to load remote cluster config")
+		}
+		remoteConfig.UserAgent = clusterConfig.UserAgent
+		remoteClient, err := kubernetes.NewForConfig(remoteConfig)
+		if err != nil {
+			logrus.WithField("cluster", cluster).WithError(err).Fatal("failed to initialize remote kubernetes client")
+		}
+		remoteFactory := informers.NewSharedInformerFactory(remoteClient, resync)
+		controller.UsePagedFilteredSecretInformer(remoteFactory, metav1.NamespaceAll, o.secretLabelSelector, o.listPageSize)
+		factories = append(factories, remoteFactory)
+		remoteClusters[cluster] = controller.RemoteCluster{
+			Client:  remoteClient,
+			Secrets: remoteFactory.Core().V1().Secrets(),
+		}
+	}
+
+	targetClusters := map[string]kubernetes.Interface{}
+	for cluster, kubeconfig := range o.targetClusters {
+		targetConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			logrus.WithField("cluster", cluster).WithError(err).Fatal("failed to load target cluster config")
+		}
+		targetConfig.UserAgent = clusterConfig.UserAgent
+		if targetClusters[cluster], err = kubernetes.NewForConfig(targetConfig); err != nil {
+			logrus.WithField("cluster", cluster).WithError(err).Fatal("failed to initialize target kubernetes client")
+		}
+	}
+
+	slackToken, err := readToken(o.slackTokenFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to read Slack token")
+	}
+
+	var vaultClient *vault.Client
+	providers := map[string]controller.Provider{}
+	if o.vaultAddress != "" {
+		vaultToken, err := readToken(o.vaultTokenFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to read Vault token")
+		}
+		vaultClient = vault.NewClient(o.vaultAddress, vaultToken)
+		providers[config.ProviderVault] = vaultClient
+	}
+	if o.awsSecretsManager {
+		credentials, err := o.awsCredentials()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to load AWS credentials for --aws-secrets-manager")
+		}
+		providers[config.ProviderAWSSecretsManager] = secretsmanager.NewClient(credentials)
+	}
+	if o.gcpSecretManager {
+		var tokens gcpsecretmanager.TokenSource = gcpsecretmanager.NewMetadataServer()
+		if o.gcpCredentialsFile != "" {
+			if tokens, err = gcpsecretmanager.LoadServiceAccountKey(o.gcpCredentialsFile); err != nil {
+				logrus.WithError(err).Fatal("failed to load the GCP service account key")
+			}
+		}
+		providers[config.ProviderGCPSecretManager] = gcpsecretmanager.NewClient(tokens)
+	}
+	if o.azureKeyVault {
+		providers[config.ProviderAzureKeyVault] = azurekeyvault.NewClient(o.azureKeyVaultDNSSuffix, o.azureClientID)
+	}
+
+	hashKey, err := readToken(o.hashKeyFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to read hash key")
+	}
+	if o.hashKeyFile != "" && hashKey == "" {
+		logrus.Fatalf("--hash-key-file %s holds no key", o.hashKeyFile)
+	}
+	if hashKey == "" {
+		current := configAgent.Config()
+		for _, mirrorConfig := range current.Secrets {
+			if current.Resolve(mirrorConfig).RequireApproval {
+				logrus.Fatal("--hash-key-file is required by rules requiring approval")
+			}
+		}
+	}
+
+	var sealedSecrets controller.SealedSecrets
+	if o.sealedSecretsCert != "" {
+		sealingKey, err := sealedsecrets.LoadCertificate(o.sealedSecretsCert)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to load the sealing certificate")
+		}
+		sealingConfig, err := o.loadWriteConfig(clusterConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to initialize kubernetes client for writes")
+		}
+		if sealingConfig == nil {
+			sealingConfig = clusterConfig
+		}
+		if sealedSecrets, err = sealedsecrets.NewClient(sealingConfig, sealingKey); err != nil {
+			logrus.WithError(err).Fatal("failed to initialize SealedSecret client")
+		}
+	}
+
+	var externalSecrets controller.ExternalSecrets
+	if o.externalSecrets {
+		externalConfig, err := o.loadWriteConfig(clusterConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to initialize kubernetes client for writes")
+		}
+		if externalConfig == nil {
+			externalConfig = clusterConfig
+		}
+		if externalSecrets, err = externalsecrets.NewClient(externalConfig); err != nil {
+			logrus.WithError(err).Fatal("failed to initialize ExternalSecret client")
+		}
+	}
+
+	var backupStore backup.Store
+	var backupRecipients []age.Recipient
+	if o.backupRecipientsFile != "" {
+		if backupRecipients, err = backup.LoadRecipients(o.backupRecipientsFile); err != nil {
+			logrus.WithError(err).Fatal("failed to load backup recipients")
+		}
+		if o.backupDirectory != "" {
+			backupStore = &backup.DirectoryStore{Directory: o.backupDirectory}
+		} else {
+			credentials, err := o.awsCredentials()
+			if err != nil {
+				logrus.WithError(err).Fatal("failed to load AWS credentials for --backup-s3-bucket")
+			}
+			backupStore = backup.NewS3Store(backup.S3Options{
+				Bucket:      o.backupS3Bucket,
+				Prefix:      o.backupS3Prefix,
+				Region:      o.backupS3Region,
+				Endpoint:    o.backupS3Endpoint,
+				Credentials: awsCredentialsProvider(credentials),
+			})
+		}
+	}
+
+	adminToken, err := readToken(o.adminTokenFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to read admin token")
+	}
+	// an empty token would let any caller presenting it in
+	if o.adminTokenFile != "" && adminToken == "" {
+		logrus.Fatalf("--admin-token-file %s holds no token", o.adminTokenFile)
+	}
+
+	var auditLog *auditlog.Log
+	if o.auditLogPath != "" {
+		actor := o.auditLogActor
+		if actor == "" {
+			hostname, _ := os.Hostname()
+			actor = fmt.Sprintf("ci-secret-mirroring-controller/%s", hostname)
+		}
+		auditLogKey, err := readToken(o.auditLogKeyFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to read audit log key")
+		}
+		if auditLogKey == "" {
+			logrus.Fatalf("--audit-log-key-file %s holds no key", o.auditLogKeyFile)
+		}
+		if auditLog, err = auditlog.Open(o.auditLogPath, actor, []byte(auditLogKey)); err != nil {
+			logrus.WithError(err).Fatal("failed to open the audit log")
+		}
+	}
+
+	getConfig := configAgent.Config
+	var secretMirrorRules *controller.SecretMirrorRules
+	if o.watchSecretMirrors {
+		secretMirrorClient, err := controller.NewSecretMirrorClient(clusterConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to initialize SecretMirror client")
+		}
+		secretMirrorRules = controller.NewSecretMirrorRules(secretMirrorClient, configAgent.Config)
+		getConfig = secretMirrorRules.Config
+	}
+
+	var namespaces coreinformers.NamespaceInformer
+	if o.namespaceSelectors {
+		namespaces = informerFactory.Core().V1().Namespaces()
+	} else {
+		for _, mirrorConfig := range configAgent.Config().Secrets {
+			if mirrorConfig.FansOut() {
+				logrus.Warn("the configuration holds rules selecting their target namespaces, which write nowhere without --namespace-selectors")
+				break
+			}
+		}
+	}
+
+	secretMirrorOptions := controller.Options{
+		MaxQueueDepth:           o.maxQueueDepth,
+		QuarantineThreshold:     o.quarantine,
+		Notifier:                notify.NewNotifier(slackToken, o.smtpAddress, o.smtpFrom),
+		RemoteClusters:          remoteClusters,
+		TargetClusters:          targetClusters,
+		Namespaces:              namespaces,
+		WriteClient:             writeClient,
+		CredentialExpiryWarning: o.credentialExpiryWarning,
+		PublishVersions:         o.publishVersions,
+		HashKey:                 []byte(hashKey),
+		ProtectedTargetPatterns: o.protectedTargets,
+		InventoryNamespace:      o.inventoryNamespace,
+		WarmStart:               o.warmStart,
+		AuditPeriod:             o.auditPeriod,
+		ReportOnly:              o.mode == modeAudit,
+		PruneOrphans:            o.pruneOrphans,
+		FileSinkDirectory:       o.fileSinkDirectory,
+		BackupStore:             backupStore,
+		BackupRecipients:        backupRecipients,
+		BackupPeriod:            o.backupPeriod,
+		BackupRetention:         o.backupRetention,
+		Frozen:                  o.frozen,
+		FilteredSecrets:         o.secretLabelSelector != "",
+		Retry:                   o.retry,
+		AuditLog:                auditLog,
+		Throttle:                throttle,
+		Providers:               providers,
+		SealedSecrets:           sealedSecrets,
+		ExternalSecrets:         externalSecrets,
+	}
+	if secretMirrorRules != nil {
+		// targets of SecretMirror objects that are not listed yet
+		// would be taken for orphans and pruned
+		secretMirrorOptions.ConfigSynced = secretMirrorRules.HasSynced
+	}
+	var secretMirror *controller.SecretMirror
+	var watchedNamespaces *namespaceInformers
+	if o.configuredNamespaces {
+		watchedNamespaces = &namespaceInformers{
+			newFactory: func(namespace string) informers.SharedInformerFactory {
+				namespaceFactory := informers.NewSharedInformerFactoryWithOptions(client, resync, informers.WithNamespace(namespace))
+				controller.UsePagedFilteredSecretInformer(namespaceFactory, namespace, o.secretLabelSelector, o.listPageSize)
+				return namespaceFactory
+			},
+			watched: map[string]namespaceInformer{},
+		}
+		secretMirror = controller.NewNamespacedSecretMirror(watchedNamespaces.add(configAgent.Config().SecretNamespaces()), client, getConfig, secretMirrorOptions)
+		watchedNamespaces.start()
+		defer watchedNamespaces.stop()
+	} else {
+		secretMirror = controller.NewSecretMirror(informerFactory.Core().V1().Secrets(), client, getConfig, secretMirrorOptions)
+	}
+
+	var configMapMirror *controller.ConfigMapMirror
+	if o.mirrorConfigMaps {
+		configMapWriteClient := writeClient
+		if configMapWriteClient == nil {
+			configMapWriteClient = client
+		}
+		configMapMirror = controller.NewConfigMapMirror(informerFactory.Core().V1().ConfigMaps(), configMapWriteClient, getConfig, o.retry, auditLog)
+	} else if len(configAgent.Config().ConfigMaps) > 0 {
+		logrus.Warn("the configuration holds ConfigMap mirroring rules, which are ignored without --mirror-config-maps")
+	}
+
+	if o.once {
+		return runOnce(factories, secretMirrorRules, secretMirror, configMapMirror)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/healthz", controller.HealthzHandler())
+	mux.Handle("/readyz", secretMirror.ReadyzHandler())
+	mux.Handle("/api/v1/mirrors", secretMirror.MirrorsHandler())
+	mux.Handle("/quarantine", authenticateWrites(adminToken, secretMirror.QuarantineHandler()))
+	mux.Handle("/sync", authenticateWrites(adminToken, secretMirror.SyncHandler()))
+	mux.Handle("/approve", authenticateWrites(adminToken, secretMirror.ApproveHandler()))
+	mux.Handle("/freeze", authenticateWrites(adminToken, secretMirror.FreezeHandler()))
+	server := &http.Server{Addr: o.listenAddress, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Fatal("failed to serve metrics and admin endpoints")
+		}
+	}()
+
+	var admissionServer *http.Server
+	if o.admissionAddress != "" {
+		admissionMux := http.NewServeMux()
+		admissionMux.Handle("/validate-secretmirrors", secretMirrorRules.AdmissionHandler())
+		admissionServer = &http.Server{Addr: o.admissionAddress, Handler: admissionMux}
+		go func() {
+			if err := admissionServer.ListenAndServeTLS(o.admissionCertFile, o.admissionKeyFile); err != nil && err != http.ErrServerClosed {
+				logrus.WithError(err).Fatal("failed to serve the admission webhook")
+			}
+		}()
+	}
+
+	if o.pprofAddress != "" {
+		go func() {
+			if err := http.ListenAndServe(o.pprofAddress, pprofHandler()); err != nil {
+				logrus.WithError(err).Fatal("failed to serve the profiling endpoints")
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := ctx.Done()
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		close(stop)
+		logrus.Info("shutting down once the sources being reconciled are done")
+		cancel()
 		<-c
 		os.Exit(1) // second signal. Exit directly.
 	}()
+	if o.grpcAddress != "" {
+		listener, err := net.Listen("tcp", o.grpcAddress)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to listen for the gRPC admin API")
+		}
+		grpcServer, err := admin.NewServer(secretMirror, adminToken)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to create the gRPC admin API")
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				logrus.WithError(err).Fatal("failed to serve the gRPC admin API")
+			}
+		}()
+		defer grpcServer.GracefulStop()
+	}
+
+	for _, factory := range factories {
+		go factory.Start(stop)
+	}
+	if vaultClient != nil {
+		go vaultClient.Run(stop)
+	}
+	// rules may be added or removed without the source changing
+	configAgent.OnChange(func() {
+		if watchedNamespaces != nil {
+			watchedNamespaces.sync(secretMirror)
+		}
+		secretMirror.Sync("")
+		if configMapMirror != nil {
+			configMapMirror.Sync()
+		}
+	})
+	if secretMirrorRules != nil {
+		secretMirrorRules.OnChange(func() { secretMirror.Sync("") })
+		go secretMirrorRules.Run(stop)
+	}
+	var running sync.WaitGroup
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		secretMirror.Run(ctx, o.numWorkers)
+	}()
+	if configMapMirror != nil {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			configMapMirror.Run(ctx, o.numWorkers)
+		}()
+	}
+
+	// the controllers return once they are done after a signal
+	running.Wait()
+	if err := server.Shutdown(context.Background()); err != nil {
+		logrus.WithError(err).Warn("failed to shut down the metrics and admin endpoints")
+	}
+	if admissionServer != nil {
+		if err := admissionServer.Shutdown(context.Background()); err != nil {
+			logrus.WithError(err).Warn("failed to shut down the admission webhook")
+		}
+	}
+	logrus.Info("shut down")
+	return nil
+}
+
+// namespaceInformers runs an informer for the secrets in every namespace
+// that the configuration references with --watch-configured-namespaces-only,
+// starting and stopping them as the configuration is reloaded.
+type namespaceInformers struct {
+	newFactory func(namespace string) informers.SharedInformerFactory
+
+	lock    sync.Mutex
+	watched map[string]namespaceInformer
+}
+
+// namespaceInformer is the informer of a watched namespace.
+type namespaceInformer struct {
+	factory informers.SharedInformerFactory
+	secrets coreinformers.SecretInformer
+	stop    chan struct{}
+}
+
+// add builds the informers of the namespaces that are not watched yet,
+// without starting them.
+func (n *namespaceInformers) add(namespaces []string) map[string]coreinformers.SecretInformer {
+	added := map[string]coreinformers.SecretInformer{}
+	for _, namespace := range namespaces {
+		if _, watched := n.watched[namespace]; watched {
+			continue
+		}
+		factory := n.newFactory(namespace)
+		informer := namespaceInformer{factory: factory, secrets: factory.Core().V1().Secrets(), stop: make(chan struct{})}
+		n.watched[namespace] = informer
+		added[namespace] = informer.secrets
+	}
+	return added
+}
+
+// start starts the informers that are not running yet.
+func (n *namespaceInformers) start() {
+	for _, informer := range n.watched {
+		// factories only start each of their informers once
+		informer.factory.Start(informer.stop)
+	}
+}
+
+// stop stops every informer.
+func (n *namespaceInformers) stop() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for namespace, informer := range n.watched {
+		close(informer.stop)
+		delete(n.watched, namespace)
+	}
+}
+
+// sync watches the namespaces that the controller reads or writes
+// secrets in and stops watching the others.
+func (n *namespaceInformers) sync(secretMirror *controller.SecretMirror) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	namespaces := secretMirror.SecretNamespaces()
+	referenced := sets.NewString(namespaces...)
+	for namespace, informer := range n.watched {
+		if !referenced.Has(namespace) {
+			secretMirror.UnwatchNamespace(namespace)
+			close(informer.stop)
+			delete(n.watched, namespace)
+			logrus.WithField("namespace", namespace).Info("stopped watching secrets in namespace the configuration no longer references")
+		}
+	}
+	for namespace, informer := range n.add(namespaces) {
+		secretMirror.WatchNamespace(namespace, informer)
+		logrus.WithField("namespace", namespace).Info("started watching secrets in namespace the configuration references")
+	}
+	n.start()
+}
+
+// pprofHandler serves the profiling endpoints of net/http/pprof. They are
+// registered explicitly rather than through http.DefaultServeMux so that
+// they are never exposed on the metrics and admin listener.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// runOnce reconciles every configured rule once, so that the controller
+// can run as a batch job, e.g. in CI or to bootstrap a new cluster.
+func runOnce(factories []informers.SharedInformerFactory, secretMirrorRules *controller.SecretMirrorRules, secretMirror *controller.SecretMirror, configMapMirror *controller.ConfigMapMirror) error {
+	stop := make(chan struct{})
 	defer close(stop)
-	go informerFactory.Start(stop)
-	go secretMirror.Run(o.numWorkers, stop)
+	for _, factory := range factories {
+		factory.Start(stop)
+	}
+	if secretMirrorRules != nil {
+		go secretMirrorRules.Run(stop)
+		if !cache.WaitForCacheSync(stop, secretMirrorRules.HasSynced) {
+			return errors.New("failed to wait for SecretMirrors to sync")
+		}
+	}
+
+	var errs []error
+	if err := secretMirror.RunOnce(stop); err != nil {
+		errs = append(errs, err)
+	}
+	if configMapMirror != nil {
+		if err := configMapMirror.RunOnce(stop); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		return fmt.Errorf("failed to reconcile every rule: %v", err)
+	}
+	logrus.Info("reconciled every rule")
+	return nil
+}
+
+// reportPrunable prints the targets that pruning would delete under the
+// configuration, including the rules of SecretMirror objects if they are
+// watched, so that they can be reviewed before anything is deleted.
+func (o *options) reportPrunable() error {
+	configuration, err := o.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	clusterConfig, err := o.loadClusterConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load cluster config: %v", err)
+	}
+	clusterConfig.UserAgent = version.UserAgent(o.userAgentSuffix)
+	client, err := kubernetes.NewForConfig(clusterConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize kubernetes client: %v", err)
+	}
+	if o.watchSecretMirrors {
+		secretMirrorClient, err := controller.NewSecretMirrorClient(clusterConfig)
+		if err != nil {
+			return fmt.Errorf("failed to initialize SecretMirror client: %v", err)
+		}
+		if configuration, err = controller.MergeListedSecretMirrors(secretMirrorClient, configuration); err != nil {
+			return err
+		}
+	}
+	prunable, err := controller.PrunableTargets(client, o.inventoryNamespace, configuration, o.protectedTargets)
+	if err != nil {
+		return err
+	}
+	if prunable == nil {
+		prunable = []controller.PrunableTarget{}
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(prunable)
+}
+
+// awsCredentials loads the credentials that requests to AWS are signed
+// with: static credentials from --aws-credentials-file, or else those of
+// the IAM role for the service account of the controller.
+func (o *options) awsCredentials() (secretsmanager.CredentialSource, error) {
+	if o.awsCredentialsFile != "" {
+		return secretsmanager.LoadCredentialsFile(o.awsCredentialsFile, o.awsProfile)
+	}
+	roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN == "" || tokenFile == "" {
+		return nil, errors.New("--aws-credentials-file or an IAM role for the service account in AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE is required")
+	}
+	return secretsmanager.NewWebIdentityCredentials(roleARN, tokenFile, "ci-secret-mirroring-controller", os.Getenv("AWS_REGION")), nil
+}
+
+// awsCredentialsProvider signs the requests of the AWS SDK with the
+// credentials from the source, which refreshes them itself.
+func awsCredentialsProvider(source secretsmanager.CredentialSource) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		credentials, err := source.Credentials()
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		return aws.Credentials{AccessKeyID: credentials.AccessKeyID, SecretAccessKey: credentials.SecretAccessKey, SessionToken: credentials.SessionToken}, nil
+	})
+}
+
+// loadWriteClient loads the client used to write targets and events, if a
+// separate identity is configured for writes. Otherwise, it returns nil so
+// that the default client is used.
+func (o *options) loadWriteClient(clusterConfig *rest.Config) (kubernetes.Interface, error) {
+	writeConfig, err := o.loadWriteConfig(clusterConfig)
+	if err != nil || writeConfig == nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(writeConfig)
+}
+
+// cloneConfig picks the directory that the repository holding the
+// configuration is cloned to.
+func (o *options) cloneConfig() error {
+	directory, err := ioutil.TempDir("", "config-git")
+	if err != nil {
+		return fmt.Errorf("failed to create directory for the configuration: %v", err)
+	}
+	o.configGit.Directory = directory
+	return nil
+}
+
+// loadConfig loads the configuration once, from the URL, the repository
+// with --config-git-url or else from the local filesystem.
+func (o *options) loadConfig() (*config.Configuration, error) {
+	if config.IsURL(o.configLocation) {
+		return config.LoadURL(o.configLocation)
+	}
+	if o.configGit.URL == "" {
+		return config.Load(o.configLocation)
+	}
+	if err := o.cloneConfig(); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(o.configGit.Directory)
+	return config.LoadGit(&o.configGit, o.configLocation)
+}
+
+// loadWriteConfig loads the configuration of the identity used for writes,
+// if one is configured.
+func (o *options) loadWriteConfig(clusterConfig *rest.Config) (*rest.Config, error) {
+	var writeConfig *rest.Config
+	switch {
+	case o.writeKubeconfig != "":
+		config, err := clientcmd.BuildConfigFromFlags("", o.writeKubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not load kubeconfig for writes: %v", err)
+		}
+		writeConfig = config
+	case o.writeTokenFile != "":
+		token, err := readToken(o.writeTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read token for writes: %v", err)
+		}
+		writeConfig = rest.AnonymousClientConfig(clusterConfig)
+		writeConfig.BearerToken = token
+	default:
+		return nil, nil
+	}
+	writeConfig.WrapTransport = clusterConfig.WrapTransport
+	writeConfig.UserAgent = clusterConfig.UserAgent
+	return writeConfig, nil
+}
+
+// readToken reads a token from the file at path, if one is given.
+func readToken(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// authenticateWrites requires requests that may change the state of the
+// controller to present the admin token. Such requests are refused outright
+// if no token is configured.
+func authenticateWrites(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if token == "" {
+				http.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+				return
+			}
+			presented := r.Header.Get("Authorization")
+			if !strings.HasPrefix(presented, "Bearer ") || subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(presented, "Bearer ")), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
 
-	// Wait forever
-	select {}
+// validateClusterOptions validates the flags bound by bindClusterOptions.
+func (o *options) validateClusterOptions() error {
+	if o.master == "" && (o.certificateAuthority != "" || o.tokenFile != "") {
+		return errors.New("--master is required for --certificate-authority and --token-file")
+	}
+	return nil
 }
 
 // loadClusterConfig loads connection configuration
-// for the cluster we're deploying to. We prefer to
-// use in-cluster configuration if possible, but will
-// fall back to using default rules otherwise.
-func loadClusterConfig() (*rest.Config, error) {
+// for the cluster we're deploying to. An API server
+// given with --master is used as-is. Otherwise, we
+// prefer to use in-cluster configuration if possible,
+// but will fall back to using default rules otherwise.
+func (o *options) loadClusterConfig() (*rest.Config, error) {
+	if o.master != "" {
+		token, err := readToken(o.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read token: %v", err)
+		}
+		return &rest.Config{
+			Host:            o.master,
+			BearerToken:     token,
+			TLSClientConfig: rest.TLSClientConfig{CAFile: o.certificateAuthority},
+		}, nil
+	}
+
 	clusterConfig, err := rest.InClusterConfig()
 	if err == nil {
 		return clusterConfig, nil
@@ -115,8 +1195,27 @@ func loadClusterConfig() (*rest.Config, error) {
 	return clusterConfig, nil
 }
 
+// subcommands run instead of the controller when
+// named by the first argument.
+var subcommands = map[string]func(args []string) error{
+	"mirror-once":      mirrorOnce,
+	"import":           importArchive,
+	"schema":           printSchema,
+	"validate":         validateConfig,
+	"verify-audit-log": verifyAuditLog,
+}
+
 func main() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
+	if len(os.Args) > 1 {
+		if subcommand, exists := subcommands[os.Args[1]]; exists {
+			if err := subcommand(os.Args[2:]); err != nil {
+				logrus.WithError(err).Fatalf("Failed to run %s", os.Args[1])
+			}
+			return
+		}
+	}
+
 	flagSet := flag.NewFlagSet("", flag.ExitOnError)
 	opt := bindOptions(flagSet)
 	flagSet.Parse(os.Args[1:])
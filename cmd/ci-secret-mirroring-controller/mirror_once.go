@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/version"
+)
+
+// secretLocation is a secret given as namespace/name.
+type secretLocation config.SecretLocation
+
+func (l *secretLocation) String() string {
+	if l.Namespace == "" && l.Name == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", l.Namespace, l.Name)
+}
+
+func (l *secretLocation) Set(value string) error {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected namespace/name, not %q", value)
+	}
+	l.Namespace, l.Name = parts[0], parts[1]
+	return nil
+}
+
+type mirrorOnceOptions struct {
+	options
+
+	from          secretLocation
+	to            secretLocation
+	adoptExisting bool
+}
+
+func bindMirrorOnceOptions(flag *flag.FlagSet) *mirrorOnceOptions {
+	opt := &mirrorOnceOptions{}
+	flag.Var(&opt.from, "from", "Source secret to mirror, as namespace/name.")
+	flag.Var(&opt.to, "to", "Target secret to mirror the source into, as namespace/name.")
+	flag.BoolVar(&opt.adoptExisting, "adopt-existing", false, "Overwrite the target if it exists but was not created by the controller.")
+	flag.Var(&opt.protectedTargets, "protected-target-pattern", fmt.Sprintf("A glob pattern matching the names of targets that must never be written to, in addition to %s. May be repeated.", strings.Join(controller.DefaultProtectedTargetPatterns, ", ")))
+	bindClusterOptions(flag, &opt.options)
+	flag.StringVar(&opt.logLevel, "log-level", logrus.InfoLevel.String(), "Logging level.")
+	return opt
+}
+
+func (o *mirrorOnceOptions) Validate() error {
+	level, err := logrus.ParseLevel(o.logLevel)
+	if err != nil {
+		return fmt.Errorf("failed to parse --log-level: %v", err)
+	}
+	logrus.SetLevel(level)
+
+	if o.from.Name == "" || o.to.Name == "" {
+		return errors.New("--from and --to are required")
+	}
+
+	return o.validateClusterOptions()
+}
+
+// mirrorOnce mirrors a single secret without a configuration file and
+// exits, e.g. for bootstrap scripts or to propagate a secret by hand.
+func mirrorOnce(args []string) error {
+	flagSet := flag.NewFlagSet("mirror-once", flag.ExitOnError)
+	opt := bindMirrorOnceOptions(flagSet)
+	flagSet.Parse(args)
+	if err := opt.Validate(); err != nil {
+		return fmt.Errorf("invalid options specified: %v", err)
+	}
+
+	clusterConfig, err := opt.loadClusterConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load cluster config: %v", err)
+	}
+	clusterConfig.UserAgent = version.UserAgent(opt.userAgentSuffix)
+	client, err := kubernetes.NewForConfig(clusterConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize kubernetes client: %v", err)
+	}
+
+	mirrorConfig := config.MirrorConfig{From: config.SecretLocation(opt.from), To: config.SecretLocation(opt.to), AdoptExisting: opt.adoptExisting}
+	return controller.MirrorOnce(client, mirrorConfig, controller.Options{ProtectedTargetPatterns: opt.protectedTargets})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// printSchema prints the JSON Schema of configuration files, so that
+// editors and CI can validate them without running the controller.
+func printSchema(args []string) error {
+	flagSet := flag.NewFlagSet("schema", flag.ExitOnError)
+	flagSet.Parse(args)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(config.Schema())
+}
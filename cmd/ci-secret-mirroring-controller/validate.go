@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// validateConfig loads the configuration the way the controller does and
+// prints every problem with it, so that changes can be checked in CI
+// before they reach the controller.
+func validateConfig(args []string) error {
+	flagSet := flag.NewFlagSet("validate", flag.ExitOnError)
+	configLocation := flagSet.String("config", "", "Path to the configuration file to validate.")
+	flagSet.Parse(args)
+	if *configLocation == "" {
+		return errors.New("--config is required")
+	}
+	if _, err := config.Load(*configLocation); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return fmt.Errorf("%s is invalid", *configLocation)
+	}
+	fmt.Printf("%s is valid\n", *configLocation)
+	return nil
+}
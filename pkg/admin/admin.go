@@ -0,0 +1,153 @@
+// Package admin serves the gRPC admin API described in admin.proto, from
+// which admin.pb.go and admin_grpc.pb.go are generated with make generate.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller"
+)
+
+// Controller is the part of the secret mirroring controller
+// that the admin service manages.
+type Controller interface {
+	Rules() []controller.RuleStatus
+	Sync(rule string) bool
+	Pause(rule string) bool
+	Resume(rule string) bool
+	DriftReport() controller.DriftReport
+	Approve(rule, change string) bool
+	Freeze() bool
+	Unfreeze() bool
+}
+
+// NewServer returns a gRPC server exposing the Admin service for the
+// controller, which requires every call to present the token.
+func NewServer(c Controller, token string) (*grpc.Server, error) {
+	if token == "" {
+		return nil, errors.New("a token is required to serve the admin API")
+	}
+	s := grpc.NewServer(grpc.UnaryInterceptor(authenticate(token)))
+	RegisterAdminServer(s, &server{controller: c})
+	return s, nil
+}
+
+// authenticate refuses calls that do not present the token, and every
+// call if there is no token to present.
+func authenticate(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var presented string
+		if values := md.Get("authorization"); len(values) > 0 && strings.HasPrefix(values[0], "Bearer ") {
+			presented = strings.TrimPrefix(values[0], "Bearer ")
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "a valid bearer token is required")
+		}
+		return handler(ctx, req)
+	}
+}
+
+type server struct {
+	UnimplementedAdminServer
+	controller Controller
+}
+
+func (s *server) ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error) {
+	response := &ListRulesResponse{}
+	for _, rule := range s.controller.Rules() {
+		response.Rules = append(response.Rules, toRuleStatus(rule))
+	}
+	return response, nil
+}
+
+func (s *server) GetStatus(_ context.Context, request *GetStatusRequest) (*RuleStatus, error) {
+	for _, rule := range s.controller.Rules() {
+		if rule.Rule == request.Rule {
+			return toRuleStatus(rule), nil
+		}
+	}
+	return nil, notFound(request.Rule)
+}
+
+func (s *server) TriggerSync(_ context.Context, request *TriggerSyncRequest) (*TriggerSyncResponse, error) {
+	if !s.controller.Sync(request.Rule) {
+		return nil, notFound(request.Rule)
+	}
+	return &TriggerSyncResponse{}, nil
+}
+
+func (s *server) PauseRule(_ context.Context, request *PauseRuleRequest) (*PauseRuleResponse, error) {
+	if !s.controller.Pause(request.Rule) {
+		return nil, notFound(request.Rule)
+	}
+	return &PauseRuleResponse{}, nil
+}
+
+func (s *server) ResumeRule(_ context.Context, request *ResumeRuleRequest) (*ResumeRuleResponse, error) {
+	if !s.controller.Resume(request.Rule) {
+		return nil, notFound(request.Rule)
+	}
+	return &ResumeRuleResponse{}, nil
+}
+
+func (s *server) GetDriftReport(context.Context, *GetDriftReportRequest) (*DriftReport, error) {
+	report := s.controller.DriftReport()
+	response := &DriftReport{Audited: int32(report.Audited)}
+	if report.Completed != nil {
+		response.Completed = report.Completed.Unix()
+	}
+	for rule := range report.Drifted {
+		response.DriftedRules = append(response.DriftedRules, rule)
+	}
+	sort.Strings(response.DriftedRules)
+	return response, nil
+}
+
+func (s *server) ApproveChange(_ context.Context, request *ApproveChangeRequest) (*ApproveChangeResponse, error) {
+	if !s.controller.Approve(request.Rule, request.Change) {
+		return nil, status.Errorf(codes.NotFound, "change %q is not pending for rule %q", request.Change, request.Rule)
+	}
+	return &ApproveChangeResponse{}, nil
+}
+
+func (s *server) SetFrozen(_ context.Context, request *SetFrozenRequest) (*SetFrozenResponse, error) {
+	if request.Frozen {
+		s.controller.Freeze()
+	} else {
+		s.controller.Unfreeze()
+	}
+	return &SetFrozenResponse{}, nil
+}
+
+func notFound(rule string) error {
+	return status.Errorf(codes.NotFound, "rule %q is not configured", rule)
+}
+
+func toRuleStatus(rule controller.RuleStatus) *RuleStatus {
+	status := &RuleStatus{
+		Rule:                rule.Rule,
+		From:                rule.From,
+		To:                  rule.To,
+		Paused:              rule.Paused,
+		ConsecutiveFailures: int32(rule.ConsecutiveFailures),
+		LastError:           rule.LastError,
+		Drift:               rule.Drift,
+		PendingChange:       rule.PendingChange,
+		HeldChange:          rule.HeldChange,
+	}
+	if rule.QuarantinedSince != nil {
+		status.Quarantined = true
+		status.QuarantinedSince = rule.QuarantinedSince.Unix()
+	}
+	return status
+}
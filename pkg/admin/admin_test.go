@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller"
+)
+
+type fakeController struct {
+	rules  []controller.RuleStatus
+	synced []string
+	paused map[string]bool
+	drift  controller.DriftReport
+	// approved maps rules to approved changes
+	approved map[string]string
+	frozen   bool
+}
+
+func (f *fakeController) Rules() []controller.RuleStatus {
+	return f.rules
+}
+
+func (f *fakeController) known(rule string) bool {
+	for _, status := range f.rules {
+		if status.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeController) Sync(rule string) bool {
+	if rule != "" && !f.known(rule) {
+		return false
+	}
+	f.synced = append(f.synced, rule)
+	return true
+}
+
+func (f *fakeController) Pause(rule string) bool {
+	if !f.known(rule) {
+		return false
+	}
+	f.paused[rule] = true
+	return true
+}
+
+func (f *fakeController) Resume(rule string) bool {
+	if !f.known(rule) {
+		return false
+	}
+	delete(f.paused, rule)
+	return true
+}
+
+func (f *fakeController) DriftReport() controller.DriftReport {
+	return f.drift
+}
+
+func (f *fakeController) Approve(rule, change string) bool {
+	if !f.known(rule) || change != "pending" {
+		return false
+	}
+	f.approved[rule] = change
+	return true
+}
+
+func (f *fakeController) Freeze() bool {
+	frozen := f.frozen
+	f.frozen = true
+	return !frozen
+}
+
+func (f *fakeController) Unfreeze() bool {
+	frozen := f.frozen
+	f.frozen = false
+	return frozen
+}
+
+func TestAdminServer(t *testing.T) {
+	since := time.Unix(1500000000, 0)
+	fake := &fakeController{
+		rules: []controller.RuleStatus{
+			{Rule: "(a/b -> c/d)", From: "a/b", To: "c/d", Paused: true},
+			{Rule: "(e/f -> g/h)", From: "e/f", To: "g/h", ConsecutiveFailures: 10, QuarantinedSince: &since, LastError: "failed to mirror into g/h: boom", Drift: "target is missing"},
+		},
+		paused:   map[string]bool{},
+		approved: map[string]string{},
+		drift:    controller.DriftReport{Completed: &since, Audited: 2, Drifted: map[string]string{"(e/f -> g/h)": "target is missing"}},
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server, err := NewServer(fake, "secret")
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	client := NewAdminClient(conn)
+	authenticated := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+
+	if _, err := client.ListRules(context.Background(), &ListRulesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected an unauthenticated call to be rejected, got %v", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	if _, err := client.ListRules(wrong, &ListRulesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a call with the wrong token to be rejected, got %v", err)
+	}
+
+	list, err := client.ListRules(authenticated, &ListRulesRequest{})
+	if err != nil {
+		t.Fatalf("failed to list rules: %v", err)
+	}
+	expected := &ListRulesResponse{Rules: []*RuleStatus{
+		{Rule: "(a/b -> c/d)", From: "a/b", To: "c/d", Paused: true},
+		{Rule: "(e/f -> g/h)", From: "e/f", To: "g/h", ConsecutiveFailures: 10, Quarantined: true, QuarantinedSince: since.Unix(), LastError: "failed to mirror into g/h: boom", Drift: "target is missing"},
+	}}
+	if !proto.Equal(list, expected) {
+		t.Errorf("expected rules %v, got %v", expected.Rules, list.Rules)
+	}
+
+	rule, err := client.GetStatus(authenticated, &GetStatusRequest{Rule: "(e/f -> g/h)"})
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if !proto.Equal(rule, expected.Rules[1]) {
+		t.Errorf("expected status %v, got %v", expected.Rules[1], rule)
+	}
+	if _, err := client.GetStatus(authenticated, &GetStatusRequest{Rule: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected status of an unknown rule to be not found, got %v", err)
+	}
+
+	if _, err := client.TriggerSync(authenticated, &TriggerSyncRequest{}); err != nil {
+		t.Errorf("failed to trigger sync: %v", err)
+	}
+	if _, err := client.TriggerSync(authenticated, &TriggerSyncRequest{Rule: "(a/b -> c/d)"}); err != nil {
+		t.Errorf("failed to trigger sync: %v", err)
+	}
+	if !reflect.DeepEqual(fake.synced, []string{"", "(a/b -> c/d)"}) {
+		t.Errorf("unexpected syncs: %v", fake.synced)
+	}
+
+	if _, err := client.PauseRule(authenticated, &PauseRuleRequest{Rule: "(e/f -> g/h)"}); err != nil {
+		t.Errorf("failed to pause rule: %v", err)
+	}
+	if !fake.paused["(e/f -> g/h)"] {
+		t.Error("expected the rule to be paused")
+	}
+	if _, err := client.ResumeRule(authenticated, &ResumeRuleRequest{Rule: "(e/f -> g/h)"}); err != nil {
+		t.Errorf("failed to resume rule: %v", err)
+	}
+	if fake.paused["(e/f -> g/h)"] {
+		t.Error("expected the rule to be resumed")
+	}
+	if _, err := client.PauseRule(authenticated, &PauseRuleRequest{Rule: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected pausing an unknown rule to be not found, got %v", err)
+	}
+
+	if _, err := client.ApproveChange(authenticated, &ApproveChangeRequest{Rule: "(a/b -> c/d)", Change: "pending"}); err != nil {
+		t.Errorf("failed to approve change: %v", err)
+	}
+	if fake.approved["(a/b -> c/d)"] != "pending" {
+		t.Error("expected the change to be approved")
+	}
+	if _, err := client.ApproveChange(authenticated, &ApproveChangeRequest{Rule: "(a/b -> c/d)", Change: "other"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected approving a change that is not pending to be not found, got %v", err)
+	}
+
+	if _, err := client.SetFrozen(authenticated, &SetFrozenRequest{Frozen: true}); err != nil {
+		t.Errorf("failed to freeze writes: %v", err)
+	}
+	if !fake.frozen {
+		t.Error("expected writes to be frozen")
+	}
+	if _, err := client.SetFrozen(authenticated, &SetFrozenRequest{}); err != nil {
+		t.Errorf("failed to unfreeze writes: %v", err)
+	}
+	if fake.frozen {
+		t.Error("expected writes to be unfrozen")
+	}
+
+	report, err := client.GetDriftReport(authenticated, &GetDriftReportRequest{})
+	if err != nil {
+		t.Fatalf("failed to get the drift report: %v", err)
+	}
+	if expected := (&DriftReport{Completed: since.Unix(), Audited: 2, DriftedRules: []string{"(e/f -> g/h)"}}); !proto.Equal(report, expected) {
+		t.Errorf("expected drift report %v, got %v", expected, report)
+	}
+}
+
+func TestAdminServerRequiresToken(t *testing.T) {
+	if _, err := NewServer(&fakeController{}, ""); err == nil {
+		t.Error("expected the server not to be created without a token")
+	}
+
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	for _, header := range []string{"", "Bearer ", "Bearer"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", header))
+		if _, err := authenticate("")(ctx, nil, &grpc.UnaryServerInfo{}, handler); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected %q to be rejected without a token, got %v", header, err)
+		}
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "secret"))
+	if _, err := authenticate("secret")(ctx, nil, &grpc.UnaryServerInfo{}, handler); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a token without the Bearer scheme to be rejected, got %v", err)
+	}
+}
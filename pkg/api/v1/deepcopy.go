@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// The repository does not generate code, so deep copies are written by
+// hand. Rules are copied through their serialization, which copies every
+// field they hold without having to be kept in sync with them.
+
+// DeepCopyInto copies the spec into out.
+func (in *SecretMirrorSpec) DeepCopyInto(out *SecretMirrorSpec) {
+	*out = SecretMirrorSpec{}
+	if in.Secrets == nil {
+		return
+	}
+	raw, err := json.Marshal(in.Secrets)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(raw, &out.Secrets); err != nil {
+		panic(err)
+	}
+	if out.Secrets == nil {
+		out.Secrets = []config.MirrorConfig{}
+	}
+}
+
+// DeepCopyInto copies the status into out.
+func (in *SecretMirrorStatus) DeepCopyInto(out *SecretMirrorStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		out.Conditions = make([]Condition, len(in.Conditions))
+		copy(out.Conditions, in.Conditions)
+	}
+}
+
+// DeepCopyInto copies the object into out.
+func (in *SecretMirror) DeepCopyInto(out *SecretMirror) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy copies the object.
+func (in *SecretMirror) DeepCopy() *SecretMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the object.
+func (in *SecretMirror) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+// DeepCopyInto copies the list into out.
+func (in *SecretMirrorList) DeepCopyInto(out *SecretMirrorList) {
+	*out = *in
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]SecretMirror, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopy copies the list.
+func (in *SecretMirrorList) DeepCopy() *SecretMirrorList {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretMirrorList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the list.
+func (in *SecretMirrorList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
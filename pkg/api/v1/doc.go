@@ -0,0 +1,3 @@
+// Package v1 holds the SecretMirror API, which declares mirroring rules
+// as namespaced objects in addition to the configuration file.
+package v1
@@ -0,0 +1,29 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group of SecretMirrors.
+const GroupName = "ci.openshift.io"
+
+// Resource is the plural name SecretMirrors are served under.
+const Resource = "secretmirrors"
+
+// SchemeGroupVersion is the version of the API in this package.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1"}
+
+var (
+	// SchemeBuilder registers the types of the API with a scheme.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme registers the types of the API with the scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &SecretMirror{}, &SecretMirrorList{})
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
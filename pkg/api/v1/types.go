@@ -0,0 +1,69 @@
+package v1
+
+import (
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// SecretMirror declares rules that are mirrored as if they were part of
+// the configuration file. Owners of a namespace can only share their own
+// secrets, so every rule must mirror from the namespace of the object.
+type SecretMirror struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SecretMirrorSpec   `json:"spec"`
+	Status SecretMirrorStatus `json:"status,omitempty"`
+}
+
+// SecretMirrorSpec holds the rules of a SecretMirror.
+type SecretMirrorSpec struct {
+	// Secrets are rules in the format of the configuration file. The
+	// namespace of sources defaults to the namespace of the object.
+	// Groups, suffixSourceNamespace and remote sources are not supported.
+	Secrets []config.MirrorConfig `json:"secrets"`
+}
+
+// SecretMirrorStatus reports if the rules of a SecretMirror are mirrored.
+type SecretMirrorStatus struct {
+	// ObservedGeneration is the generation of the object
+	// that the conditions were determined for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the state of the object
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// ConditionAccepted is true while the rules of the object are part of the
+// configuration of the controller, and false when they were rejected.
+const ConditionAccepted = "Accepted"
+
+// Reasons for the Accepted condition
+const (
+	// ReasonAccepted means the rules are mirrored
+	ReasonAccepted = "Accepted"
+	// ReasonInvalid means the rules are invalid on their own
+	ReasonInvalid = "Invalid"
+	// ReasonConflict means the rules conflict with the rest of
+	// the configuration, e.g. by writing to the same target
+	ReasonConflict = "Conflict"
+)
+
+// Condition describes one aspect of the state of an object.
+type Condition struct {
+	Type               string                  `json:"type"`
+	Status             coreapi.ConditionStatus `json:"status"`
+	Reason             string                  `json:"reason,omitempty"`
+	Message            string                  `json:"message,omitempty"`
+	LastTransitionTime metav1.Time             `json:"lastTransitionTime,omitempty"`
+}
+
+// SecretMirrorList is a list of SecretMirrors.
+type SecretMirrorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []SecretMirror `json:"items"`
+}
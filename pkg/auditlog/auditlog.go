@@ -0,0 +1,183 @@
+// Package auditlog writes an append-only trail of the writes the controller
+// makes to targets, for security reviews of how secrets propagate.
+//
+// The log holds one JSON entry per line. Entries never hold secret data,
+// only a keyed hash of the data that was written. Every entry carries its
+// position in the log, the HMAC of the entry before it and its own HMAC,
+// keyed with a secret that readers of the log do not hold, so that
+// editing, removing or reordering entries, the last one included, breaks
+// a chain that cannot be forged anew, which Verify detects.
+// A log that lost its last entries still chains, so Verify returns the
+// number of entries, to be compared with the number the controller
+// reported having written.
+package auditlog
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Operations recorded in the log
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// Entry records one write to a target.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Operation is one of create, update or delete
+	Operation string `json:"operation"`
+	// Rule is the rule that the write was made for, empty for
+	// deletions of targets that no rule writes to anymore, which
+	// name the sources the target was written from
+	Rule string `json:"rule,omitempty"`
+	// Source is the location of the sources the target was written from
+	Source string `json:"source,omitempty"`
+	// Target is the location of the target that was written
+	Target string `json:"target"`
+	// Hash is the keyed hash of the data written to the target,
+	// or of the data it held when it was deleted
+	Hash string `json:"hash,omitempty"`
+	// Actor identifies who made the write
+	Actor string `json:"actor"`
+	// Sequence is the position of the entry in the log, from 1
+	Sequence int `json:"sequence"`
+	// Previous is the MAC of the previous entry of the log,
+	// empty for the first entry
+	Previous string `json:"previous"`
+	// MAC is the HMAC of the entry, computed with the MAC unset
+	MAC string `json:"mac"`
+}
+
+// mac authenticates the entry with everything but its MAC.
+func (e Entry) mac(key []byte) (string, error) {
+	e.MAC = ""
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(raw)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Log appends entries to a writer. A nil *Log records nothing.
+type Log struct {
+	lock     sync.Mutex
+	writer   io.Writer
+	actor    string
+	key      []byte
+	entries  int
+	previous string
+}
+
+// New returns a Log writing a new chain of entries made by the actor,
+// chained with the key.
+func New(writer io.Writer, actor string, key []byte) *Log {
+	return &Log{writer: writer, actor: actor, key: key}
+}
+
+// Open returns a Log appending to the file at path, continuing the chain
+// of the entries it already holds, or writing to standard output if the
+// path is "-". Files whose chain is broken are refused rather than
+// continued, so that tampering does not go unnoticed.
+func Open(path, actor string, key []byte) (*Log, error) {
+	if path == "-" {
+		return New(os.Stdout, actor, key), nil
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	log := New(file, actor, key)
+	if log.entries, log.previous, err = verify(file, key); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit log is not intact: %v", err)
+	}
+	return log, nil
+}
+
+// Entries returns the number of entries in the log.
+func (l *Log) Entries() int {
+	if l == nil {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.entries
+}
+
+// Record appends the entry made by the actor of the log now.
+func (l *Log) Record(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	entry.Time = time.Now().UTC()
+	entry.Actor = l.actor
+	entry.Sequence = l.entries + 1
+	entry.Previous = l.previous
+	mac, err := entry.mac(l.key)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate audit log entry: %v", err)
+	}
+	entry.MAC = mac
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit log entry: %v", err)
+	}
+	if _, err := l.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log entry: %v", err)
+	}
+	l.entries++
+	l.previous = mac
+	return nil
+}
+
+// Verify checks that every entry read from the log is authentic and
+// chains to the entry before it with the key, returning the number of
+// entries.
+func Verify(reader io.Reader, key []byte) (int, error) {
+	entries, _, err := verify(reader, key)
+	return entries, err
+}
+
+// verify checks the chain of the log, returning the number of entries
+// and the MAC of the last entry.
+func verify(reader io.Reader, key []byte) (int, string, error) {
+	scanner := bufio.NewScanner(reader)
+	previous, entries := "", 0
+	for scanner.Scan() {
+		entries++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, "", fmt.Errorf("line %d: %v", entries, err)
+		}
+		mac, err := entry.mac(key)
+		if err != nil {
+			return entries, "", fmt.Errorf("line %d: %v", entries, err)
+		}
+		if !hmac.Equal([]byte(entry.MAC), []byte(mac)) {
+			return entries, "", fmt.Errorf("line %d: is not authentic, the log was tampered with", entries)
+		}
+		if entry.Sequence != entries || !hmac.Equal([]byte(entry.Previous), []byte(previous)) {
+			return entries, "", fmt.Errorf("line %d: does not follow the line before it, the log was tampered with", entries)
+		}
+		previous = entry.MAC
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, "", err
+	}
+	return entries, previous, nil
+}
@@ -0,0 +1,146 @@
+package auditlog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var key = []byte("audit-log-key")
+
+func record(t *testing.T, log *Log, operations ...string) {
+	for _, operation := range operations {
+		if err := log.Record(Entry{Operation: operation, Rule: "ci/src -> team/dst", Source: "ci/src", Target: "team/dst", Hash: "abc"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	var buffer bytes.Buffer
+	log := New(&buffer, "controller", key)
+	record(t, log, OperationCreate, OperationUpdate, OperationDelete)
+	lines := strings.SplitAfter(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected three entries, got %q", buffer.String())
+	}
+	if log.Entries() != 3 {
+		t.Errorf("expected the log to count three entries, got %d", log.Entries())
+	}
+	// a chain written with another key is what anyone rewriting the log could forge
+	var forged bytes.Buffer
+	record(t, New(&forged, "controller", []byte("guessed")), OperationCreate, OperationUpdate)
+
+	var testCases = []struct {
+		name            string
+		log             string
+		expectedEntries int
+		expectedError   string
+	}{
+		{
+			name:            "untouched log",
+			log:             buffer.String(),
+			expectedEntries: 3,
+		},
+		{
+			name:            "truncated log",
+			log:             lines[0] + lines[1],
+			expectedEntries: 2,
+		},
+		{
+			name:          "forged chain",
+			log:           forged.String(),
+			expectedError: "line 1:",
+		},
+		{
+			name:          "edited entry",
+			log:           lines[0] + strings.Replace(lines[1], `"hash":"abc"`, `"hash":"def"`, 1) + lines[2],
+			expectedError: "line 2:",
+		},
+		{
+			name:          "edited last entry",
+			log:           lines[0] + lines[1] + strings.Replace(lines[2], `"hash":"abc"`, `"hash":"def"`, 1),
+			expectedError: "line 3:",
+		},
+		{
+			name:          "replaced last entry",
+			log:           lines[0] + lines[1] + strings.Replace(lines[1], `"sequence":2`, `"sequence":3`, 1),
+			expectedError: "line 3:",
+		},
+		{
+			name:          "removed entry",
+			log:           lines[0] + lines[2],
+			expectedError: "line 2:",
+		},
+		{
+			name:          "removed first entry",
+			log:           lines[1] + lines[2],
+			expectedError: "line 1:",
+		},
+		{
+			name:          "reordered entries",
+			log:           lines[1] + lines[0] + lines[2],
+			expectedError: "line 1:",
+		},
+	}
+	for _, testCase := range testCases {
+		entries, err := Verify(strings.NewReader(testCase.log), key)
+		if testCase.expectedError == "" && err != nil {
+			t.Errorf("%s: expected no error, got %v", testCase.name, err)
+		}
+		if testCase.expectedError == "" && entries != testCase.expectedEntries {
+			t.Errorf("%s: expected %d entries, got %d", testCase.name, testCase.expectedEntries, entries)
+		}
+		if testCase.expectedError != "" && (err == nil || !strings.HasPrefix(err.Error(), testCase.expectedError)) {
+			t.Errorf("%s: expected an error for %s, got %v", testCase.name, testCase.expectedError, err)
+		}
+	}
+	if !strings.Contains(buffer.String(), `"actor":"controller"`) {
+		t.Errorf("expected entries to name the actor, got %s", buffer.String())
+	}
+}
+
+func TestOpenContinuesChain(t *testing.T) {
+	directory, err := ioutil.TempDir("", "auditlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "audit.log")
+
+	for restart := 0; restart < 2; restart++ {
+		log, err := Open(path, "controller", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record(t, log, OperationCreate, OperationUpdate)
+		log.writer.(*os.File).Close()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	entries, err := Verify(file, key)
+	if err != nil {
+		t.Errorf("expected the chain to continue across restarts: %v", err)
+	}
+	if entries != 4 {
+		t.Errorf("expected four entries, got %d", entries)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, bytes.Replace(raw, []byte(`"hash":"abc"`), []byte(`"hash":"def"`), 1), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, "controller", key); err == nil {
+		t.Error("expected a log that was tampered with not to be continued")
+	}
+}
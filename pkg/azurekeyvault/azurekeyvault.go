@@ -0,0 +1,187 @@
+package azurekeyvault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+const (
+	// DefaultDNSSuffix is the domain of key vaults in the public cloud
+	DefaultDNSSuffix = "vault.azure.net"
+	// ValueKey is the key under which secrets that do not hold a JSON
+	// object are mirrored.
+	ValueKey = "value"
+
+	// apiVersion is the version of the Key Vault API that is used
+	apiVersion = "7.4"
+	// defaultIdentityURL is where the instance metadata service hands
+	// out tokens of the managed identities assigned to the node
+	defaultIdentityURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+	// tokenExpiryMargin is how long before access tokens expire to
+	// fetch new ones.
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+// Client reads secrets from Azure Key Vault, authenticating with a
+// managed identity.
+type Client struct {
+	// DNSSuffix is the domain of the key vaults, which differs in
+	// sovereign clouds, e.g. vault.usgovcloudapi.net
+	DNSSuffix string
+	// ClientID selects a user-assigned managed identity. The
+	// system-assigned identity is used without it.
+	ClientID string
+
+	client      *http.Client
+	identityURL string
+	// vaultURL returns the URL of a key vault
+	vaultURL func(vault string) string
+
+	lock       sync.Mutex
+	token      string
+	expiration time.Time
+}
+
+// NewClient returns a Client for the key vaults under the DNS suffix
+// that authenticates with the managed identity with the client ID, or
+// the system-assigned identity if no client ID is given.
+func NewClient(dnsSuffix, clientID string) *Client {
+	c := &Client{
+		DNSSuffix:   dnsSuffix,
+		ClientID:    clientID,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		identityURL: defaultIdentityURL,
+	}
+	c.vaultURL = func(vault string) string {
+		return fmt.Sprintf("https://%s.%s", vault, c.DNSSuffix)
+	}
+	return c
+}
+
+// Read returns the value of the version of the secret at the location
+// and the identifier of that version, which is the current version
+// unless the location pins one, or no data if the secret or version does
+// not exist. Secrets holding a JSON object are mirrored key by key,
+// encoding values that are not strings as JSON, while any other secret
+// is mirrored under ValueKey.
+func (c *Client) Read(location config.SecretLocation) (map[string][]byte, string, error) {
+	token, err := c.accessToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not obtain an access token: %v", err)
+	}
+	secret := location.AzureKeyVault
+	path := "/secrets/" + secret.Name
+	if secret.Version != "" {
+		path += "/" + secret.Version
+	}
+	request, err := http.NewRequest(http.MethodGet, c.vaultURL(secret.Vault)+path+"?api-version="+apiVersion, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&failure); err == nil && failure.Error.Code != "" {
+			return nil, "", fmt.Errorf("unexpected status %s: %s: %s", response.Status, failure.Error.Code, failure.Error.Message)
+		}
+		return nil, "", fmt.Errorf("unexpected status %s", response.Status)
+	}
+	var result struct {
+		Value string `json:"value"`
+		ID    string `json:"id"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("could not decode response: %v", err)
+	}
+	// the identifier of the secret ends with its version even if the
+	// current version was requested
+	version := result.ID[strings.LastIndex(result.ID, "/")+1:]
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Value), &values); err != nil || values == nil {
+		return map[string][]byte{ValueKey: []byte(result.Value)}, version, nil
+	}
+	data := map[string][]byte{}
+	for key, value := range values {
+		if s, ok := value.(string); ok {
+			data[key] = []byte(s)
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not encode %s: %v", key, err)
+		}
+		data[key] = raw
+	}
+	return data, version, nil
+}
+
+// accessToken returns an access token of the managed identity for Key
+// Vault, fetching a new one from the instance metadata service once it
+// is about to expire.
+func (c *Client) accessToken() (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if time.Now().Add(tokenExpiryMargin).Before(c.expiration) {
+		return c.token, nil
+	}
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {"https://" + c.DNSSuffix},
+	}
+	if c.ClientID != "" {
+		query.Set("client_id", c.ClientID)
+	}
+	request, err := http.NewRequest(http.MethodGet, c.identityURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata", "true")
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("could not reach the instance metadata service: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", response.Status)
+	}
+	// the instance metadata service encodes numbers as strings
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("could not decode the access token: %v", err)
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("no access token was issued")
+	}
+	expiresIn, err := strconv.Atoi(result.ExpiresIn)
+	if err != nil {
+		return "", fmt.Errorf("could not parse the validity of the access token: %v", err)
+	}
+	c.token, c.expiration = result.AccessToken, time.Now().Add(time.Duration(expiresIn)*time.Second)
+	return c.token, nil
+}
@@ -0,0 +1,104 @@
+package azurekeyvault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRead(t *testing.T) {
+	identities := 0
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identities++
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != "https://vault.azure.net" || r.URL.Query().Get("client_id") != "identity" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token","expires_in":"3599","token_type":"Bearer"}`))
+	}))
+	defer identity.Close()
+
+	var vault string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("api-version") != apiVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/secrets/registry":
+			w.Write([]byte(`{"value":"{\"user\":\"ci\",\"port\":5000}","id":"https://ci-secrets.vault.azure.net/secrets/registry/4387e9f3d6e14c459867679a90fd0f79"}`))
+		case "/secrets/registry/0f2c4e2a8d6f4f0c9f3c0e8a6b1d2c3e":
+			w.Write([]byte(`{"value":"old","id":"https://ci-secrets.vault.azure.net/secrets/registry/0f2c4e2a8d6f4f0c9f3c0e8a6b1d2c3e"}`))
+		case "/secrets/disabled":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":"Forbidden","message":"Operation get is not allowed on a disabled secret."}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"SecretNotFound","message":"not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	var testCases = []struct {
+		name            string
+		location        config.AzureKeyVaultLocation
+		expectedData    map[string][]byte
+		expectedVersion string
+		expectedErr     bool
+	}{
+		{
+			name:            "current version is mirrored by key",
+			location:        config.AzureKeyVaultLocation{Vault: "ci-secrets", Name: "registry"},
+			expectedData:    map[string][]byte{"user": []byte("ci"), "port": []byte("5000")},
+			expectedVersion: "4387e9f3d6e14c459867679a90fd0f79",
+		},
+		{
+			name:            "pinned version is mirrored as a value",
+			location:        config.AzureKeyVaultLocation{Vault: "ci-secrets", Name: "registry", Version: "0f2c4e2a8d6f4f0c9f3c0e8a6b1d2c3e"},
+			expectedData:    map[string][]byte{ValueKey: []byte("old")},
+			expectedVersion: "0f2c4e2a8d6f4f0c9f3c0e8a6b1d2c3e",
+		},
+		{
+			name:     "unknown secret is missing",
+			location: config.AzureKeyVaultLocation{Vault: "ci-secrets", Name: "unknown"},
+		},
+		{
+			name:        "disabled secret fails",
+			location:    config.AzureKeyVaultLocation{Vault: "ci-secrets", Name: "disabled"},
+			expectedErr: true,
+		},
+	}
+
+	client := NewClient(DefaultDNSSuffix, "identity")
+	client.identityURL = identity.URL
+	client.vaultURL = func(v string) string {
+		vault = v
+		return server.URL
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			data, version, err := client.Read(config.SecretLocation{AzureKeyVault: testCase.location})
+			if err == nil && testCase.expectedErr {
+				t.Errorf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !reflect.DeepEqual(data, testCase.expectedData) {
+				t.Errorf("%s: expected data %v, got %v", testCase.name, testCase.expectedData, data)
+			}
+			if version != testCase.expectedVersion {
+				t.Errorf("%s: expected version %q, got %q", testCase.name, testCase.expectedVersion, version)
+			}
+			if vault != "ci-secrets" {
+				t.Errorf("%s: expected the key vault of the secret to be used, got %q", testCase.name, vault)
+			}
+		})
+	}
+	if identities != 1 {
+		t.Errorf("expected the access token to be reused until it expires, got %d requests for tokens", identities)
+	}
+}
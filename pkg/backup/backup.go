@@ -0,0 +1,192 @@
+// Package backup reads and writes encrypted archives of mirrored targets,
+// from which targets can be restored after the loss of a cluster.
+//
+// An archive is a gzipped JSON document encrypted with age to one or more
+// recipients, so that it can also be decrypted with the age CLI, e.g.
+// `age --decrypt --identity key.txt archive | gunzip`. Archives are kept
+// in a Store, a directory or an S3 bucket.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+)
+
+// archivePrefix and archiveSuffix surround the
+// timestamp in the names of archive files.
+const (
+	archivePrefix = "secret-mirror-backup-"
+	archiveSuffix = ".age"
+)
+
+// timestampFormat sorts archive file names chronologically.
+const timestampFormat = "20060102T150405Z"
+
+// Archive holds the targets managed by the controller.
+type Archive struct {
+	// Created is when the archive was taken
+	Created time.Time `json:"created"`
+	// Targets are the secrets written by the controller
+	Targets []Target `json:"targets"`
+}
+
+// Target is a secret written by the controller.
+type Target struct {
+	// Rule is the rule that writes the target
+	Rule        string            `json:"rule"`
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	Type        string            `json:"type,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Data        map[string][]byte `json:"data"`
+}
+
+// LoadRecipients reads the age recipients that archives are encrypted to
+// from the file at path, one public key per line. Empty lines and lines
+// starting with # are ignored.
+func LoadRecipients(path string) ([]age.Recipient, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	recipients, err := age.ParseRecipients(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipients: %v", err)
+	}
+	return recipients, nil
+}
+
+// LoadIdentities reads the age identities that archives are decrypted with
+// from the file at path, in the format written by age-keygen.
+func LoadIdentities(path string) ([]age.Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identities: %v", err)
+	}
+	return identities, nil
+}
+
+// Write serializes, compresses and encrypts the archive to the recipients.
+func Write(w io.Writer, recipients []age.Recipient, archive Archive) error {
+	if len(recipients) == 0 {
+		return errors.New("archives must be encrypted to at least one recipient")
+	}
+	encryptor, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt archive: %v", err)
+	}
+	compressor := gzip.NewWriter(encryptor)
+	if err := json.NewEncoder(compressor).Encode(archive); err != nil {
+		return fmt.Errorf("failed to serialize archive: %v", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %v", err)
+	}
+	if err := encryptor.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %v", err)
+	}
+	return nil
+}
+
+// Read decrypts, decompresses and deserializes an archive with one of the
+// identities.
+func Read(r io.Reader, identities []age.Identity) (Archive, error) {
+	var archive Archive
+	decryptor, err := age.Decrypt(r, identities...)
+	if err != nil {
+		if _, noMatch := err.(*age.NoIdentityMatchError); noMatch {
+			return archive, errors.New("failed to decrypt archive, it was encrypted to other recipients")
+		}
+		return archive, fmt.Errorf("failed to decrypt archive: %v", err)
+	}
+	// the payload is authenticated as it is read, so
+	// corruption surfaces while decompressing it
+	decompressor, err := gzip.NewReader(decryptor)
+	if err != nil {
+		return archive, fmt.Errorf("failed to decompress archive: %v", err)
+	}
+	if err := json.NewDecoder(decompressor).Decode(&archive); err != nil {
+		return archive, fmt.Errorf("failed to deserialize archive: %v", err)
+	}
+	// the JSON document may end before the last chunk of the
+	// payload, which is only authenticated once it is read
+	if _, err := io.Copy(ioutil.Discard, decompressor); err != nil {
+		return archive, fmt.Errorf("failed to decompress archive: %v", err)
+	}
+	return archive, nil
+}
+
+// Store holds archives by name.
+type Store interface {
+	// Put stores the archive under the name. Archives are
+	// never visible to List before they are complete.
+	Put(name string, archive []byte) error
+	// List returns the names of the archives in the store.
+	List() ([]string, error)
+	// Delete removes the archive with the name.
+	Delete(name string) error
+}
+
+// Save encrypts the archive to the recipients and stores it under a name
+// recording when the archive was created, then removes all but the newest
+// retain archives from the store. Zero retains every archive.
+func Save(store Store, recipients []age.Recipient, archive Archive, retain int) (string, error) {
+	var encrypted bytes.Buffer
+	if err := Write(&encrypted, recipients, archive); err != nil {
+		return "", err
+	}
+	name := archivePrefix + archive.Created.UTC().Format(timestampFormat) + archiveSuffix
+	if err := store.Put(name, encrypted.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to store archive: %v", err)
+	}
+
+	if retain <= 0 {
+		return name, nil
+	}
+	archives, err := List(store)
+	if err != nil {
+		return name, err
+	}
+	for len(archives) > retain {
+		if err := store.Delete(archives[0]); err != nil {
+			return name, fmt.Errorf("failed to remove expired archive: %v", err)
+		}
+		archives = archives[1:]
+	}
+	return name, nil
+}
+
+// List returns the names of the archives in the store, oldest first.
+// Other objects in the store are ignored.
+func List(store Store) ([]string, error) {
+	names, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %v", err)
+	}
+	var archives []string
+	for _, name := range names {
+		if strings.HasPrefix(name, archivePrefix) && strings.HasSuffix(name, archiveSuffix) {
+			archives = append(archives, name)
+		}
+	}
+	sort.Strings(archives)
+	return archives, nil
+}
@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func testIdentity(t *testing.T) *age.X25519Identity {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	return identity
+}
+
+func TestRoundTrip(t *testing.T) {
+	archive := Archive{
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Targets: []Target{{
+			Rule:      "(ci/src -> team/dst)",
+			Namespace: "team",
+			Name:      "dst",
+			Type:      "Opaque",
+			Labels:    map[string]string{"team": "a"},
+			Data:      map[string][]byte{"token": []byte("hunter2")},
+		}},
+	}
+	identity, other := testIdentity(t), testIdentity(t)
+	var buffer bytes.Buffer
+	if err := Write(&buffer, []age.Recipient{identity.Recipient()}, archive); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	if bytes.Contains(buffer.Bytes(), []byte("hunter2")) || bytes.Contains(buffer.Bytes(), []byte("dst")) {
+		t.Error("expected the archive not to hold its content in the clear")
+	}
+	if err := Write(&bytes.Buffer{}, nil, archive); err == nil {
+		t.Error("expected writing an archive without recipients to fail")
+	}
+
+	if _, err := Read(bytes.NewReader(buffer.Bytes()), []age.Identity{other}); err == nil {
+		t.Error("expected reading the archive with another identity to fail")
+	}
+	tampered := append([]byte{}, buffer.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := Read(bytes.NewReader(tampered), []age.Identity{identity}); err == nil {
+		t.Error("expected reading a tampered archive to fail")
+	}
+	read, err := Read(bytes.NewReader(buffer.Bytes()), []age.Identity{other, identity})
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	if !reflect.DeepEqual(read, archive) {
+		t.Errorf("expected to read the archive that was written:\nexpected %v\ngot      %v", archive, read)
+	}
+}
+
+func TestSaveRetains(t *testing.T) {
+	directory, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	if err := ioutil.WriteFile(filepath.Join(directory, "README"), []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &DirectoryStore{Directory: directory}
+	recipients := []age.Recipient{testIdentity(t).Recipient()}
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var saved []string
+	for i := 0; i < 4; i++ {
+		name, err := Save(store, recipients, Archive{Created: created.Add(time.Duration(i) * time.Hour)}, 2)
+		if err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		saved = append(saved, name)
+	}
+	archives, err := List(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := saved[2:]; !reflect.DeepEqual(archives, expected) {
+		t.Errorf("expected the newest archives to be retained:\nexpected %v\ngot      %v", expected, archives)
+	}
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected no temporary files to be left behind and other files to be left alone, got %d files", len(files))
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	directory, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	identity := testIdentity(t)
+	recipientsPath, identityPath := filepath.Join(directory, "recipients"), filepath.Join(directory, "key")
+	if err := ioutil.WriteFile(recipientsPath, []byte(fmt.Sprintf("# disaster recovery\n%s\n\n", identity.Recipient())), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(identityPath, []byte(fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient(), identity)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := LoadRecipients(recipientsPath)
+	if err != nil || len(recipients) != 1 {
+		t.Fatalf("expected to load the recipient, got %d: %v", len(recipients), err)
+	}
+	identities, err := LoadIdentities(identityPath)
+	if err != nil || len(identities) != 1 {
+		t.Fatalf("expected to load the identity, got %d: %v", len(identities), err)
+	}
+	var buffer bytes.Buffer
+	if err := Write(&buffer, recipients, Archive{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(&buffer, identities); err != nil {
+		t.Errorf("expected the loaded identity to decrypt archives for the loaded recipient, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(recipientsPath, []byte("hunter2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRecipients(recipientsPath); err == nil {
+		t.Error("expected an invalid recipient to be rejected")
+	}
+}
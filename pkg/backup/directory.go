@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DirectoryStore keeps archives as files in a directory, e.g. on a
+// persistent volume.
+type DirectoryStore struct {
+	// Directory holds the archives
+	Directory string
+}
+
+// Put writes the archive to a temporary file in the directory and renames
+// it into place once it is complete, so that partial archives are never
+// mistaken for backups.
+func (s *DirectoryStore) Put(name string, archive []byte) error {
+	temporary, err := ioutil.TempFile(s.Directory, ".backup-")
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+	// the temporary file is only left behind if writing it failed
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(archive); err != nil {
+		temporary.Close()
+		return fmt.Errorf("failed to write archive: %v", err)
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return fmt.Errorf("failed to write archive: %v", err)
+	}
+	if err := temporary.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %v", err)
+	}
+	if err := os.Rename(temporary.Name(), filepath.Join(s.Directory, name)); err != nil {
+		return fmt.Errorf("failed to move archive into place: %v", err)
+	}
+	return nil
+}
+
+// List returns the names of the regular files in the directory.
+func (s *DirectoryStore) List() ([]string, error) {
+	files, err := ioutil.ReadDir(s.Directory)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if file.Mode().IsRegular() {
+			names = append(names, file.Name())
+		}
+	}
+	return names, nil
+}
+
+// Delete removes the archive file, if it still exists.
+func (s *DirectoryStore) Delete(name string) error {
+	if err := os.Remove(filepath.Join(s.Directory, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Timeout bounds every request to S3.
+const s3Timeout = 5 * time.Minute
+
+// S3Options configure where an S3Store keeps archives.
+type S3Options struct {
+	// Bucket holds the archives
+	Bucket string
+	// Prefix is prepended to the names of archives in the bucket,
+	// e.g. a directory such as "backups/"
+	Prefix string
+	// Region of the bucket
+	Region string
+	// Endpoint is the URL of an S3-compatible service, e.g. MinIO
+	// or Ceph, whose buckets are then addressed by path. AWS is
+	// used if empty.
+	Endpoint string
+	// Credentials sign the requests
+	Credentials aws.CredentialsProvider
+}
+
+// S3Store keeps archives as objects in an S3 bucket.
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns a store keeping archives in the bucket.
+func NewS3Store(options S3Options) *S3Store {
+	clientOptions := s3.Options{
+		Region:      options.Region,
+		Credentials: options.Credentials,
+		// S3-compatible services do not all support the checksums
+		// that the SDK adds by default, and archives are
+		// authenticated by their encryption anyway
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	}
+	if options.Endpoint != "" {
+		clientOptions.BaseEndpoint = aws.String(options.Endpoint)
+		clientOptions.UsePathStyle = true
+	}
+	return &S3Store{client: s3.New(clientOptions), bucket: options.Bucket, prefix: options.Prefix}
+}
+
+// Put uploads the archive in a single request, so that it only
+// becomes visible once it is complete.
+func (s *S3Store) Put(name string, archive []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(s.prefix + name),
+		Body:          bytes.NewReader(archive),
+		ContentLength: aws.Int64(int64(len(archive))),
+	})
+	return err
+}
+
+// List returns the names of the objects under the prefix in the bucket.
+func (s *S3Store) List() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	var names []string
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(s.prefix)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(object.Key), s.prefix)
+			// objects in "directories" below the prefix are not archives
+			if !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// Delete removes the archive object.
+func (s *S3Store) Delete(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.prefix + name)})
+	return err
+}
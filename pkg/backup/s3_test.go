@@ -0,0 +1,126 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// s3Server serves the objects of a bucket, addressed by path
+type s3Server struct {
+	lock    sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+// listBucketResult is the response to ListObjectsV2
+type listBucketResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated bool `xml:"IsTruncated"`
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path != s.bucket && !strings.HasPrefix(path, s.bucket+"/") {
+		http.NotFound(w, r)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(path, s.bucket), "/")
+	switch {
+	case r.Method == http.MethodPut && key != "":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = body
+	case r.Method == http.MethodDelete && key != "":
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && key == "" && r.URL.Query().Get("list-type") == "2":
+		var keys []string
+		for key := range s.objects {
+			if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var result listBucketResult
+		for _, key := range keys {
+			result.Contents = append(result.Contents, struct {
+				Key string `xml:"Key"`
+			}{Key: key})
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+	default:
+		http.Error(w, "unsupported request", http.StatusNotImplemented)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	server := &s3Server{bucket: "backups", objects: map[string][]byte{
+		"other/secret-mirror-backup-20200101T000000Z.age":      []byte("outside of the prefix"),
+		"ci/nested/secret-mirror-backup-20200101T000000Z.age":  []byte("below the prefix"),
+		"ci/secret-mirror-backup-20200101T000000Z.age.partial": []byte("not an archive"),
+	}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	store := NewS3Store(S3Options{
+		Bucket:   "backups",
+		Prefix:   "ci/",
+		Region:   "us-east-1",
+		Endpoint: httpServer.URL,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}, nil
+		}),
+	})
+	identity := testIdentity(t)
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		archive := Archive{Created: created.Add(time.Duration(i) * time.Hour), Targets: []Target{{Namespace: "team", Name: "dst"}}}
+		if _, err := Save(store, []age.Recipient{identity.Recipient()}, archive, 2); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+	}
+
+	archives, err := List(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"secret-mirror-backup-20200102T040405Z.age", "secret-mirror-backup-20200102T050405Z.age"}; !reflect.DeepEqual(archives, expected) {
+		t.Errorf("expected the newest archives to be retained:\nexpected %v\ngot      %v", expected, archives)
+	}
+	if len(server.objects) != 5 {
+		t.Errorf("expected objects that are not archives under the prefix to be left alone, got %d objects", len(server.objects))
+	}
+	archive, err := Read(bytes.NewReader(server.objects["ci/"+archives[1]]), []age.Identity{identity})
+	if err != nil {
+		t.Fatalf("failed to read the uploaded archive: %v", err)
+	}
+	if !archive.Created.Equal(created.Add(2*time.Hour)) || len(archive.Targets) != 1 {
+		t.Errorf("expected the archive to be uploaded as written, got %+v", archive)
+	}
+}
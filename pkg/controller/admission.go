@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	mirrorapi "github.com/openshift/ci-secret-mirroring-controller/pkg/api/v1"
+)
+
+// admissionReview is the part of an admission.k8s.io/v1beta1 AdmissionReview
+// that the webhook reads and writes. The types are declared here as the
+// Kubernetes API vendored by the repository does not hold them.
+type admissionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *admissionRequest  `json:"request,omitempty"`
+	Response        *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID       types.UID       `json:"uid"`
+	Namespace string          `json:"namespace,omitempty"`
+	Operation string          `json:"operation"`
+	Object    json.RawMessage `json:"object,omitempty"`
+}
+
+type admissionResponse struct {
+	UID     types.UID      `json:"uid"`
+	Allowed bool           `json:"allowed"`
+	Result  *metav1.Status `json:"status,omitempty"`
+}
+
+// errSecretMirrorsNotSynced is returned by Admit until the SecretMirror
+// objects have been listed, as objects cannot be checked against an
+// incomplete set of others.
+var errSecretMirrorsNotSynced = errors.New("SecretMirror objects have not been listed yet, try again later")
+
+// Admit determines whether the rules of the object would be accepted
+// alongside the configuration and every other SecretMirror, returning
+// why they would not be. Unlike merging, which accepts the object that
+// sorts first when objects conflict, the object is checked against all
+// others, as those have been admitted before.
+func (r *SecretMirrorRules) Admit(mirror *mirrorapi.SecretMirror) error {
+	if !r.HasSynced() {
+		return errSecretMirrorsNotSynced
+	}
+	var others []*mirrorapi.SecretMirror
+	for _, obj := range r.informer.GetStore().List() {
+		other := obj.(*mirrorapi.SecretMirror)
+		if other.Namespace == mirror.Namespace && other.Name == mirror.Name {
+			continue
+		}
+		others = append(others, other)
+	}
+	merged, _ := mergeSecretMirrors(r.base(), others)
+	_, outcomes := mergeSecretMirrors(merged, []*mirrorapi.SecretMirror{mirror})
+	if outcome := outcomes[mirror.Namespace+"/"+mirror.Name]; outcome.reason != mirrorapi.ReasonAccepted {
+		return fmt.Errorf("%s: %s", outcome.reason, outcome.message)
+	}
+	return nil
+}
+
+// AdmissionHandler serves a validating admission webhook that rejects
+// SecretMirror objects whose rules would not be accepted when they are
+// created or updated, instead of only reporting them in their status.
+func (r *SecretMirrorRules) AdmissionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var review admissionReview
+		if err := json.NewDecoder(req.Body).Decode(&review); err != nil || review.Request == nil {
+			http.Error(w, "expected an AdmissionReview", http.StatusBadRequest)
+			return
+		}
+		response := &admissionResponse{UID: review.Request.UID, Allowed: true}
+		if review.Request.Operation == "CREATE" || review.Request.Operation == "UPDATE" {
+			var mirror mirrorapi.SecretMirror
+			err := json.Unmarshal(review.Request.Object, &mirror)
+			if err == nil {
+				if mirror.Namespace == "" {
+					mirror.Namespace = review.Request.Namespace
+				}
+				err = r.Admit(&mirror)
+			}
+			if err != nil {
+				response.Allowed = false
+				response.Result = &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonInvalid, Code: http.StatusUnprocessableEntity, Message: err.Error()}
+				if err == errSecretMirrorsNotSynced {
+					response.Result.Reason, response.Result.Code = metav1.StatusReasonServiceUnavailable, http.StatusServiceUnavailable
+				}
+				r.logger.WithField("secretMirror", mirror.Namespace+"/"+mirror.Name).WithError(err).Info("rejected SecretMirror")
+			}
+		}
+		review.Request, review.Response = nil, response
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			r.logger.WithError(err).Error("failed to write admission review")
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mirrorapi "github.com/openshift/ci-secret-mirroring-controller/pkg/api/v1"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestAdmit(t *testing.T) {
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{
+		Secrets: []config.MirrorConfig{
+			{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}},
+		},
+		Policy: &config.Policy{DeniedNamespaces: []string{"openshift-*"}},
+	})
+	r := NewSecretMirrorRules(nil, ca.Config)
+	existing := secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}})
+	if err := r.Admit(secretMirrorObject("team-b", "share", existing.Spec.Secrets...)); err != errSecretMirrorsNotSynced {
+		t.Errorf("expected objects not to be admitted before the others are listed, got %v", err)
+	}
+	r.hasSynced = func() bool { return true }
+	existing = secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}})
+	if err := r.informer.GetStore().Add(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name          string
+		mirror        *mirrorapi.SecretMirror
+		expectedError string
+	}{
+		{
+			name:   "new target",
+			mirror: secretMirrorObject("team", "other", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "other-token"}}),
+		},
+		{
+			name:   "update of an admitted object",
+			mirror: secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}}),
+		},
+		{
+			name:          "missing target namespace",
+			mirror:        secretMirrorObject("team", "other", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Name: "token"}}),
+			expectedError: mirrorapi.ReasonInvalid,
+		},
+		{
+			name:          "target of the configuration",
+			mirror:        secretMirrorObject("team", "other", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}}),
+			expectedError: mirrorapi.ReasonConflict,
+		},
+		{
+			name:          "target of another object",
+			mirror:        secretMirrorObject("team-b", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}}),
+			expectedError: mirrorapi.ReasonConflict,
+		},
+		{
+			name:          "namespace denied by the policy",
+			mirror:        secretMirrorObject("team", "other", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "openshift-config", Name: "token"}}),
+			expectedError: mirrorapi.ReasonConflict,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := r.Admit(testCase.mirror)
+			if testCase.expectedError == "" && err != nil {
+				t.Errorf("expected the object to be admitted, got %v", err)
+			}
+			if testCase.expectedError != "" && (err == nil || !strings.HasPrefix(err.Error(), testCase.expectedError)) {
+				t.Errorf("expected the object to be rejected as %s, got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestAdmissionHandler(t *testing.T) {
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}},
+	}})
+	r := NewSecretMirrorRules(nil, ca.Config)
+	handler := r.AdmissionHandler()
+
+	review := func(operation string, mirror *mirrorapi.SecretMirror) *admissionResponse {
+		object, err := json.Marshal(mirror)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(admissionReview{Request: &admissionRequest{UID: "uid", Namespace: "team", Operation: operation, Object: object}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected the review to be answered, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var answered admissionReview
+		if err := json.NewDecoder(recorder.Body).Decode(&answered); err != nil {
+			t.Fatal(err)
+		}
+		if answered.Response == nil || answered.Response.UID != "uid" {
+			t.Fatalf("expected a response to the request, got %+v", answered)
+		}
+		return answered.Response
+	}
+
+	conflicting := secretMirrorObject("", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}})
+	if response := review("CREATE", conflicting); response.Allowed || response.Result == nil || response.Result.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected objects to be rejected until the others are listed, got %+v", response)
+	}
+	r.hasSynced = func() bool { return true }
+	if response := review("CREATE", conflicting); response.Allowed || response.Result == nil || !strings.Contains(response.Result.Message, "test-ns/dst") {
+		t.Errorf("expected the conflicting object to be rejected, got %+v", response)
+	}
+	if response := review("DELETE", conflicting); !response.Allowed {
+		t.Errorf("expected deletions to be allowed, got %+v", response)
+	}
+	accepted := secretMirrorObject("", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}})
+	if response := review("UPDATE", accepted); !response.Allowed {
+		t.Errorf("expected the object to be admitted, got %+v", response)
+	}
+}
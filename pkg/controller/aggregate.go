@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// metricLabeler maps rules and targets to the value that metrics about
+// them are labelled with, so that configurations with many rules may
+// aggregate their metrics by namespace or team.
+type metricLabeler struct {
+	lock sync.RWMutex
+
+	rules      map[string]string
+	targets    map[string]string
+	generation *config.Configuration
+}
+
+// metricLabels labels every aggregated metric.
+var metricLabels = &metricLabeler{}
+
+// sync determines the labels for the configuration if it has been reloaded
+// since the last time we looked at it, moving members of aggregated gauges
+// to their new series.
+func (l *metricLabeler) sync(generation *config.Configuration) {
+	l.lock.Lock()
+	if l.generation == generation {
+		l.lock.Unlock()
+		return
+	}
+	l.generation = generation
+	l.rules, l.targets = map[string]string{}, map[string]string{}
+	for _, mirrorConfig := range generation.Secrets {
+		rule, target := mirrorConfig.String(), mirrorConfig.To.String()
+		l.rules[rule] = generation.MetricsLabel(mirrorConfig, rule)
+		// targets written by many rules are detailed if any of them asks
+		if _, labelled := l.targets[target]; !labelled || mirrorConfig.DetailedMetrics {
+			l.targets[target] = generation.MetricsLabel(mirrorConfig, target)
+		}
+	}
+	l.lock.Unlock()
+
+	for _, gauge := range aggregatedGauges {
+		gauge.relabel()
+	}
+}
+
+// label determines the labels for a rule instantiated from a rule that
+// selects its sources by pattern, which are not known when the
+// configuration is loaded.
+func (l *metricLabeler) label(generation *config.Configuration, mirrorConfig config.MirrorConfig) {
+	rule, target := mirrorConfig.String(), mirrorConfig.To.String()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.generation != generation {
+		return
+	}
+	if _, labelled := l.rules[rule]; !labelled {
+		l.rules[rule] = generation.MetricsLabel(mirrorConfig, rule)
+	}
+	if _, labelled := l.targets[target]; !labelled {
+		l.targets[target] = generation.MetricsLabel(mirrorConfig, target)
+	}
+}
+
+// rule returns the label for the rule, which is the rule
+// itself unless it is configured to be aggregated.
+func (l *metricLabeler) rule(rule string) string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if label, configured := l.rules[rule]; configured {
+		return label
+	}
+	return rule
+}
+
+// target returns the label for the target, which is the
+// target itself unless it is configured to be aggregated.
+func (l *metricLabeler) target(target string) string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if label, configured := l.targets[target]; configured {
+		return label
+	}
+	return target
+}
+
+// aggregatedGauge exports one series for all members, i.e. rules or
+// targets, that share a label. Series count their members, unless they
+// export the earliest value of their members instead.
+type aggregatedGauge struct {
+	lock sync.Mutex
+
+	vec *prometheus.GaugeVec
+	// label determines the label of a member from its subject
+	label    func(subject string) string
+	earliest bool
+	members  map[string]aggregatedMember
+	// series holds the members of every series
+	series map[string]map[string]bool
+}
+
+type aggregatedMember struct {
+	subject string
+	extra   []string
+	labels  []string
+	value   float64
+}
+
+// aggregatedGauges are relabelled when the configuration is reloaded.
+var aggregatedGauges []*aggregatedGauge
+
+// newAggregatedGauge returns a gauge aggregating the subject label with
+// the label function. Members are further identified by the extra labels,
+// which are never aggregated.
+func newAggregatedGauge(opts prometheus.GaugeOpts, label func(string) string, earliest bool, subject string, extra ...string) *aggregatedGauge {
+	gauge := &aggregatedGauge{
+		vec:      prometheus.NewGaugeVec(opts, append([]string{subject}, extra...)),
+		label:    label,
+		earliest: earliest,
+		members:  map[string]aggregatedMember{},
+		series:   map[string]map[string]bool{},
+	}
+	aggregatedGauges = append(aggregatedGauges, gauge)
+	return gauge
+}
+
+func memberKey(subject string, extra []string) string {
+	return strings.Join(append([]string{subject}, extra...), "\x00")
+}
+
+// set records the value of the member, which is ignored unless
+// the gauge exports the earliest value of its members.
+func (g *aggregatedGauge) set(subject string, value float64, extra ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.add(aggregatedMember{subject: subject, extra: extra, value: value})
+}
+
+// delete forgets the member.
+func (g *aggregatedGauge) delete(subject string, extra ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.remove(memberKey(subject, extra))
+}
+
+// reset forgets every member.
+func (g *aggregatedGauge) reset() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.vec.Reset()
+	g.members = map[string]aggregatedMember{}
+	g.series = map[string]map[string]bool{}
+}
+
+// relabel moves every member to the series it is labelled with now.
+func (g *aggregatedGauge) relabel() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	var members []aggregatedMember
+	for _, member := range g.members {
+		members = append(members, member)
+	}
+	for _, member := range members {
+		g.add(member)
+	}
+}
+
+func (g *aggregatedGauge) add(member aggregatedMember) {
+	key := memberKey(member.subject, member.extra)
+	g.remove(key)
+	member.labels = append([]string{g.label(member.subject)}, member.extra...)
+	g.members[key] = member
+	series := strings.Join(member.labels, "\x00")
+	if g.series[series] == nil {
+		g.series[series] = map[string]bool{}
+	}
+	g.series[series][key] = true
+	g.refresh(member.labels)
+}
+
+func (g *aggregatedGauge) remove(key string) {
+	member, exists := g.members[key]
+	if !exists {
+		return
+	}
+	delete(g.members, key)
+	series := strings.Join(member.labels, "\x00")
+	delete(g.series[series], key)
+	if len(g.series[series]) == 0 {
+		delete(g.series, series)
+	}
+	g.refresh(member.labels)
+}
+
+// refresh exports the value of the series from its members.
+func (g *aggregatedGauge) refresh(labels []string) {
+	members := g.series[strings.Join(labels, "\x00")]
+	if len(members) == 0 {
+		g.vec.DeleteLabelValues(labels...)
+		return
+	}
+	if !g.earliest {
+		g.vec.WithLabelValues(labels...).Set(float64(len(members)))
+		return
+	}
+	g.vec.WithLabelValues(labels...).Set(g.earliestValue(labels))
+}
+
+// earliestValue returns the smallest value of the members of the series.
+func (g *aggregatedGauge) earliestValue(labels []string) float64 {
+	var earliest float64
+	first := true
+	for key := range g.series[strings.Join(labels, "\x00")] {
+		if value := g.members[key].value; first || value < earliest {
+			earliest, first = value, false
+		}
+	}
+	return earliest
+}
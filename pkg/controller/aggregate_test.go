@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// seriesSizes returns the number of members of every series of the gauge.
+func seriesSizes(g *aggregatedGauge) map[string]int {
+	sizes := map[string]int{}
+	for series, members := range g.series {
+		sizes[strings.Replace(series, "\x00", ",", -1)] = len(members)
+	}
+	return sizes
+}
+
+func TestAggregatedGauge(t *testing.T) {
+	labels := &metricLabeler{}
+	gauge := newAggregatedGauge(prometheus.GaugeOpts{Name: "test_rule", Help: "test"}, labels.rule, false, "rule")
+	defer func() { aggregatedGauges = aggregatedGauges[:len(aggregatedGauges)-1] }()
+
+	first := config.MirrorConfig{From: config.SecretLocation{Namespace: "src", Name: "a"}, To: config.SecretLocation{Namespace: "team-a", Name: "a"}}
+	second := config.MirrorConfig{From: config.SecretLocation{Namespace: "src", Name: "b"}, To: config.SecretLocation{Namespace: "team-a", Name: "b"}}
+	detailed := config.MirrorConfig{From: config.SecretLocation{Namespace: "src", Name: "c"}, To: config.SecretLocation{Namespace: "team-a", Name: "c"}, DetailedMetrics: true}
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{first, second, detailed}}
+	labels.sync(configuration)
+
+	for _, mirrorConfig := range configuration.Secrets {
+		gauge.set(mirrorConfig.String(), 1)
+	}
+	if actual, expected := seriesSizes(gauge), map[string]int{first.String(): 1, second.String(): 1, detailed.String(): 1}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected a series for every rule by default, got %v", actual)
+	}
+
+	aggregated := &config.Configuration{Secrets: configuration.Secrets, Metrics: config.Metrics{Aggregation: config.MetricsAggregationNamespace}}
+	labels.sync(aggregated)
+	if actual, expected := seriesSizes(gauge), map[string]int{"team-a": 2, detailed.String(): 1}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected rules to be counted by namespace after a reload, got %v", actual)
+	}
+
+	gauge.delete(first.String())
+	if actual, expected := seriesSizes(gauge), map[string]int{"team-a": 1, detailed.String(): 1}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected the deleted rule to no longer be counted, got %v", actual)
+	}
+}
+
+func TestAggregatedGaugeEarliest(t *testing.T) {
+	gauge := newAggregatedGauge(prometheus.GaugeOpts{Name: "test_expiry", Help: "test"}, func(string) string { return "team-a" }, true, "target", "key")
+	defer func() { aggregatedGauges = aggregatedGauges[:len(aggregatedGauges)-1] }()
+
+	gauge.set("team-a/a", 20, "token")
+	gauge.set("team-a/b", 10, "token")
+	gauge.set("team-a/b", 30, "tls.crt")
+	if actual, expected := seriesSizes(gauge), map[string]int{"team-a,token": 2, "team-a,tls.crt": 1}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected members to be aggregated by target but not by key, got %v", actual)
+	}
+	if actual := gauge.earliestValue([]string{"team-a", "token"}); actual != 10 {
+		t.Errorf("expected the earliest value of the members to be exported, got %v", actual)
+	}
+}
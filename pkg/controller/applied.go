@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// lastAppliedHashAnnotation records the hash of the data the
+// controller last wrote to a target secret.
+const lastAppliedHashAnnotation = "ci.openshift.io/mirror-last-applied-hash"
+
+// dataHash returns a stable hash of secret data.
+func dataHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	length := make([]byte, 8)
+	for _, key := range keys {
+		// keys cannot contain NUL and values are length-prefixed,
+		// so this encoding is unambiguous
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		binary.BigEndian.PutUint64(length, uint64(len(data[key])))
+		hash.Write(length)
+		hash.Write(data[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// keyedHash hides the hash of data that is published where the data
+// itself cannot be read, i.e. on SealedSecrets, in the versions
+// ConfigMap, in pod templates and in the names of changes awaiting
+// approval: without the key, it cannot be used to confirm guesses
+// of low-entropy values the way a plain hash of the data can.
+func keyedHash(key []byte, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fingerprint identifies the outcome of applying a rule to source data:
+// if neither the rule nor the data change, neither does the fingerprint.
+func fingerprint(mirrorConfig config.MirrorConfig, sourceHash string) string {
+	rule, err := json.Marshal(mirrorConfig)
+	if err != nil {
+		// fall back to the human-readable form, which identifies the rule
+		rule = []byte(mirrorConfig.String())
+	}
+	hash := sha256.New()
+	hash.Write(rule)
+	hash.Write([]byte{0})
+	hash.Write([]byte(sourceHash))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// appliedState is what we know we last applied to a target.
+type appliedState struct {
+	fingerprint     string
+	resourceVersion string
+}
+
+// appliedStore remembers what was last applied to each target so that
+// reconciles which would not change anything can be skipped entirely.
+// Entries are forgotten as soon as the target is observed to change.
+type appliedStore struct {
+	lock    sync.Mutex
+	targets map[string]appliedState
+}
+
+func (s *appliedStore) matches(target, fingerprint string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, recorded := s.targets[target]
+	return recorded && state.fingerprint == fingerprint
+}
+
+func (s *appliedStore) record(target, fingerprint, resourceVersion string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.targets == nil {
+		s.targets = map[string]appliedState{}
+	}
+	s.targets[target] = appliedState{fingerprint: fingerprint, resourceVersion: resourceVersion}
+}
+
+// wrote determines if the resource version of the target is the one
+// we last wrote, rather than a change made by someone else.
+func (s *appliedStore) wrote(target, resourceVersion string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, recorded := s.targets[target]
+	return recorded && state.resourceVersion == resourceVersion
+}
+
+// observe forgets what was applied to the target if it has
+// been changed since we last wrote to it.
+func (s *appliedStore) observe(target, resourceVersion string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if state, recorded := s.targets[target]; recorded && state.resourceVersion != resourceVersion {
+		delete(s.targets, target)
+	}
+}
+
+func (s *appliedStore) forget(target string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.targets, target)
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestDataHash(t *testing.T) {
+	base := dataHash(map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	if again := dataHash(map[string][]byte{"b": []byte("2"), "a": []byte("1")}); again != base {
+		t.Errorf("expected the hash to be independent of map order, got %s and %s", base, again)
+	}
+	for _, data := range []map[string][]byte{
+		{"a": []byte("1")},
+		{"a": []byte("1"), "b": []byte("3")},
+		{"a": []byte("1b"), "": []byte("2")},
+	} {
+		if hash := dataHash(data); hash == base {
+			t.Errorf("expected %v to hash differently", data)
+		}
+	}
+}
+
+func TestAppliedStore(t *testing.T) {
+	store := appliedStore{}
+	if store.matches("ns/name", "fp") {
+		t.Error("expected an empty store not to match")
+	}
+	store.record("ns/name", "fp", "1")
+	if !store.matches("ns/name", "fp") || store.matches("ns/name", "other") {
+		t.Error("expected only the recorded fingerprint to match")
+	}
+	store.observe("ns/name", "1")
+	if !store.matches("ns/name", "fp") {
+		t.Error("expected observing our own write to keep the record")
+	}
+	store.observe("ns/name", "2")
+	if store.matches("ns/name", "fp") {
+		t.Error("expected observing a foreign write to forget the record")
+	}
+	store.record("ns/name", "fp", "3")
+	store.forget("ns/name")
+	if store.matches("ns/name", "fp") {
+		t.Error("expected forgetting to drop the record")
+	}
+}
+
+func TestReconcileSkipsAppliedTargets(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	factory := informers.NewSharedInformerFactory(client, 5*time.Minute)
+	informer := factory.Core().V1().Secrets()
+	informer.Informer().AddEventHandler(&cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { cancel() },
+	})
+	factory.Start(ctx.Done())
+	src := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Create(src); err != nil {
+		t.Fatal(err)
+	}
+	<-ctx.Done()
+
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	dst, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be created: %v", err)
+	}
+	if hash := dst.Annotations[lastAppliedHashAnnotation]; hash != dataHash(src.Data) {
+		t.Errorf("expected the target to record the applied hash, got %q", hash)
+	}
+
+	client.ClearActions()
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected no writes for an unchanged source, got %v", actions)
+	}
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// approvedChangesAnnotation on a source lists, separated by commas, the
+// pending changes to targets requiring approval that may be applied.
+const approvedChangesAnnotation = "ci.openshift.io/approved-changes"
+
+// changeIDLength is the length of the prefix of the keyed hash of
+// the fingerprint of a change that identifies it for approval.
+const changeIDLength = 16
+
+// approvals tracks changes to targets that require approval.
+type approvals struct {
+	lock sync.Mutex
+	// pending maps rules to the change awaiting approval
+	pending map[string]string
+	// approved maps rules to the change approved through the API
+	approved map[string]string
+}
+
+// await records the change as pending for the rule, returning
+// false if it was already pending.
+func (a *approvals) await(rule, change string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.pending == nil {
+		a.pending = map[string]string{}
+	}
+	if a.pending[rule] == change {
+		return false
+	}
+	a.pending[rule] = change
+	return true
+}
+
+// approve approves the change for the rule, returning
+// false if the change is not pending.
+func (a *approvals) approve(rule, change string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if change == "" || a.pending[rule] != change {
+		return false
+	}
+	if a.approved == nil {
+		a.approved = map[string]string{}
+	}
+	a.approved[rule] = change
+	return true
+}
+
+func (a *approvals) isApproved(rule, change string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.approved[rule] == change
+}
+
+// settle forgets the pending and approved changes of the
+// rule once its target matches what the rule would write.
+func (a *approvals) settle(rule string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.pending, rule)
+	delete(a.approved, rule)
+	pendingApprovals.delete(rule)
+}
+
+func (a *approvals) pendingChange(rule string) string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.pending[rule]
+}
+
+// approvedBySource determines if the owners of the
+// source annotated it to approve the change.
+func approvedBySource(source *coreapi.Secret, change string) bool {
+	for _, approved := range strings.Split(source.Annotations[approvedChangesAnnotation], ",") {
+		if strings.TrimSpace(approved) == change {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitingApproval determines if writing the change identified by the
+// fingerprint to the target of the rule has to wait for an approval,
+// recording the pending change in metrics and in events on the source.
+// Events are readable by those who may not read the source, so the
+// change is identified by a keyed hash of the fingerprint.
+func (c *SecretMirror) awaitingApproval(source *coreapi.Secret, mirrorConfig config.MirrorConfig, fingerprint string, logger *logrus.Entry) (bool, error) {
+	if !mirrorConfig.RequireApproval {
+		return false, nil
+	}
+	if len(c.hashKey) == 0 {
+		return true, fmt.Errorf("the rule requires approval but no hash key is configured")
+	}
+	rule, change := mirrorConfig.String(), keyedHash(c.hashKey, fingerprint)[:changeIDLength]
+	if c.approvals.isApproved(rule, change) || approvedBySource(source, change) {
+		logger.WithField("change", change).Info("writing approved change to target secret")
+		return false, nil
+	}
+	pendingApprovals.set(rule, 1)
+	if c.approvals.await(rule, change) {
+		logger.WithField("change", change).Warn("not writing target secret until the change is approved")
+		c.recorder.Eventf(source, coreapi.EventTypeNormal, reasonApprovalRequired, "Change %s to %s requires approval: annotate this secret with %s=%s or approve it through the admin API", change, mirrorConfig.To.String(), approvedChangesAnnotation, change)
+	}
+	return true, nil
+}
+
+// Approve approves the pending change for the named rule and triggers a
+// sync. It returns false if the change is not pending for the rule.
+func (c *SecretMirror) Approve(rule, change string) bool {
+	if !c.approvals.approve(rule, change) {
+		return false
+	}
+	c.logger.WithFields(logrus.Fields{"rule": rule, "change": change}).Info("approved change")
+	return c.enqueueRule(rule)
+}
+
+// ApproveHandler approves the change named by the `change`
+// parameter for the rule named by the `rule` parameter on POST.
+func (c *SecretMirror) ApproveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rule, change := r.FormValue("rule"), r.FormValue("change")
+		if !c.Approve(rule, change) {
+			http.Error(w, fmt.Sprintf("change %s is not pending for rule %s", change, rule), http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileAwaitsApproval(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	informer := syncedInformer(t, client, source)
+	mirrorConfig := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "prod", Name: "dst"},
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{mirrorConfig}, ApprovalNamespaces: []string{"prod"}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{HashKey: []byte("key")})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("prod").Get("dst", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the target not to be written before the change is approved")
+	}
+	rule := mirrorConfig.String()
+	change := c.approvals.pendingChange(rule)
+	if len(change) != changeIDLength {
+		t.Fatalf("expected the change to be pending, got %q", change)
+	}
+	if fingerprint := fingerprint(ca.Config().Resolve(mirrorConfig), dataHash(source.Data)); change == fingerprint[:changeIDLength] {
+		t.Errorf("expected the change not to be named by the unkeyed fingerprint %s", fingerprint)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "ApprovalRequired") || !strings.Contains(event, change) {
+			t.Errorf("expected an event asking to approve change %s, got %q", change, event)
+		}
+	default:
+		t.Error("expected the pending change to be recorded as an event")
+	}
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Error("expected a change that is already pending not to be recorded again")
+	}
+
+	if c.Approve(rule, "unknown") {
+		t.Error("expected approving a change that is not pending to fail")
+	}
+	if !c.Approve(rule, change) {
+		t.Fatal("expected the pending change to be approved")
+	}
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("prod").Get("dst", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the approved change to be written: %v", err)
+	}
+	if pending := c.approvals.pendingChange(rule); pending != "" {
+		t.Errorf("expected no change to be pending once it was written, got %q", pending)
+	}
+}
+
+func TestReconcileRequiresAHashKeyForApprovals(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	informer := syncedInformer(t, client, source)
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From:            config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:              config.SecretLocation{Namespace: "prod", Name: "dst"},
+		RequireApproval: true,
+	}}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	c.recorder = record.NewFakeRecorder(10)
+
+	if err := c.reconcile("test-ns/src"); err == nil {
+		t.Error("expected a rule requiring approval to fail without a hash key")
+	}
+	if _, err := client.CoreV1().Secrets("prod").Get("dst", metav1.GetOptions{}); err == nil {
+		t.Error("expected the target not to be written without a hash key")
+	}
+}
+
+func TestApprovedBySource(t *testing.T) {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{approvedChangesAnnotation: "0123456789abcdef, fedcba9876543210"}}}
+	if !approvedBySource(source, "fedcba9876543210") {
+		t.Error("expected a change listed in the annotation to be approved")
+	}
+	if approvedBySource(source, "0000000000000000") {
+		t.Error("expected a change missing from the annotation not to be approved")
+	}
+}
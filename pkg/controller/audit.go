@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// Ways in which a target can drift from its source
+const (
+	driftMissing  = "target is missing"
+	driftType     = "target has a different type than the rule converts to"
+	driftData     = "target data differs from the source"
+	driftMetadata = "target is missing labels or annotations set by the rule"
+)
+
+// DriftReport summarizes the last audit sweep.
+type DriftReport struct {
+	// Completed is when the sweep finished, nil before the first sweep
+	Completed *time.Time
+	// Audited is the number of rules whose target was compared to the source
+	Audited int
+	// Drifted maps rules whose target had drifted to how it drifted
+	Drifted map[string]string
+}
+
+// driftReports holds the report of the last audit sweep.
+type driftReports struct {
+	lock   sync.RWMutex
+	report DriftReport
+}
+
+func (d *driftReports) get() DriftReport {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	report := d.report
+	report.Drifted = map[string]string{}
+	for rule, drift := range d.report.Drifted {
+		report.Drifted[rule] = drift
+	}
+	return report
+}
+
+// record updates the drift of one rule between sweeps,
+// returning the number of rules that drifted.
+func (d *driftReports) record(rule, drift string) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.report.Drifted == nil {
+		d.report.Drifted = map[string]string{}
+	}
+	if drift == "" {
+		delete(d.report.Drifted, rule)
+	} else {
+		d.report.Drifted[rule] = drift
+	}
+	return len(d.report.Drifted)
+}
+
+func (d *driftReports) set(report DriftReport) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.report = report
+}
+
+// DriftReport returns the report of the last audit sweep.
+func (c *SecretMirror) DriftReport() DriftReport {
+	return c.drift.get()
+}
+
+// runAudits sweeps every rule once per audit period. Every source is
+// reconciled on start-up anyway, so the first sweep waits for a period.
+func (c *SecretMirror) runAudits(stopCh <-chan struct{}) {
+	if c.auditPeriod == 0 {
+		return
+	}
+	select {
+	case <-time.After(c.auditPeriod):
+		wait.Until(c.audit, c.auditPeriod, stopCh)
+	case <-stopCh:
+	}
+}
+
+// audit compares the target of every rule to its source, reading both
+// from the API server rather than trusting the watch, and reconciles
+// the sources of targets that drifted, publishing what it found in
+// metrics and the drift report. In report-only mode, reconciling the
+// sources reports the drift as events.
+func (c *SecretMirror) audit() {
+	configuration := c.config()
+	report := DriftReport{Drifted: map[string]string{}}
+	driftedRules.reset()
+	for _, mirrorConfig := range c.concreteRules(configuration) {
+		rule := mirrorConfig.String()
+		if c.pauses.isPaused(rule) || c.quarantine.isQuarantined(rule) {
+			continue
+		}
+		logger := c.logger.WithField("rule", rule)
+		drift, audited, err := c.checkDrift(configuration, mirrorConfig)
+		if err != nil {
+			logger.WithError(err).Warn("failed to audit rule")
+			continue
+		}
+		if !audited {
+			continue
+		}
+		report.Audited++
+		if drift == "" {
+			continue
+		}
+		logger.WithField("drift", drift).Warn("target drifted from the source, reconciling it")
+		report.Drifted[rule] = drift
+		driftedRules.set(rule, 1)
+		c.applied.forget(mirrorConfig.To.String())
+		c.enqueueKey(mirrorConfig.From.String())
+	}
+	completed := time.Now()
+	report.Completed = &completed
+	c.drift.set(report)
+	driftedTargets.Set(float64(len(report.Drifted)))
+	lastAuditTimestamp.Set(float64(completed.Unix()))
+	c.logger.WithFields(logrus.Fields{"audited": report.Audited, "drifted": len(report.Drifted)}).Info("finished audit sweep")
+}
+
+// checkDrift determines how the target of the rule differs from what the
+// rule would write to it, reading the source and target from the API server.
+// Targets that the External Secrets Operator writes are not audited.
+func (c *SecretMirror) checkDrift(configuration *config.Configuration, mirrorConfig config.MirrorConfig) (drift string, audited bool, err error) {
+	if mirrorConfig.Output == config.OutputExternalSecret {
+		return "", false, nil
+	}
+	source, err := c.liveSource(mirrorConfig)
+	if errors.IsNotFound(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if source, err = c.mergedSource(source, mirrorConfig); err != nil {
+		return "", false, err
+	}
+	drift, _, audited, err = c.compareTarget(source, configuration.Resolve(mirrorConfig), c.liveTarget)
+	return drift, audited, err
+}
+
+// compareTarget determines how the target of the rule differs from what the
+// rule would write to it and which keys would be written or removed. Rules
+// are not audited if the controller would not write their target, e.g.
+// because the source has no data, the target is held back or the rule
+// only writes files.
+func (c *SecretMirror) compareTarget(source *coreapi.Secret, mirrorConfig config.MirrorConfig, getTarget func(config.SecretLocation) (*coreapi.Secret, error)) (drift string, keys []string, audited bool, err error) {
+	if !source.DeletionTimestamp.IsZero() || optedOut(source) || len(source.Data) == 0 || (mirrorConfig.Files != nil && mirrorConfig.Files.SkipTarget) {
+		return "", nil, false, nil
+	}
+	sourceData, convertedType, err := c.mirroredData(source.Data, mirrorConfig)
+	if err != nil || len(sourceData) == 0 {
+		// failures to build the data are surfaced by reconciling
+		return "", nil, false, nil
+	}
+	targetType, enforceType := desiredType(source, convertedType, mirrorConfig)
+	mirrorConfig = withSourceMetadata(source, mirrorConfig)
+
+	target, err := getTarget(mirrorConfig.To)
+	if errors.IsNotFound(err) {
+		return driftMissing, changedKeys(nil, sourceData), true, nil
+	}
+	if err != nil {
+		return "", nil, false, err
+	}
+	if target.Annotations[doNotOverwriteAnnotation] == "true" {
+		return "", nil, false, nil
+	}
+	desired := desiredData(sourceData, target, mirrorConfig)
+	switch {
+	case enforceType && target.Type != targetType:
+		return driftType, changedKeys(target.Data, desired), true, nil
+	case !reflect.DeepEqual(target.Data, desired):
+		return driftData, changedKeys(target.Data, desired), true, nil
+	case !containsAll(target.Labels, mirrorConfig.Labels) || !containsAll(target.Annotations, mirrorConfig.Annotations):
+		return driftMetadata, nil, true, nil
+	}
+	return "", nil, true, nil
+}
+
+// changedKeys returns the keys whose values differ between
+// the current and the desired data, in order.
+func changedKeys(current, desired map[string][]byte) []string {
+	var keys []string
+	for key, value := range desired {
+		if existing, present := current[key]; !present || !bytes.Equal(existing, value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range current {
+		if _, present := desired[key]; !present {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reportDrift compares the target of the rule to the source instead of
+// writing it, in report-only mode, and records drift in metrics, the
+// drift report and events on the source.
+func (c *SecretMirror) reportDrift(source *coreapi.Secret, rule string, mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	drift, _, _, err := c.compareTarget(source, mirrorConfig, c.getTarget)
+	if err != nil {
+		return fmt.Errorf("failed to compare the target to the source: %v", err)
+	}
+	driftedTargets.Set(float64(c.drift.record(rule, drift)))
+	if drift == "" {
+		driftedRules.delete(rule)
+		return nil
+	}
+	driftedRules.set(rule, 1)
+	logger.WithFields(logrus.Fields{"rule": rule, "drift": drift}).Warn("target drifted from the source, not reconciling it in report-only mode")
+	c.recorder.Eventf(source, coreapi.EventTypeWarning, reasonDrift, "Target %s drifted from this secret: %s", mirrorConfig.To.String(), drift)
+	return nil
+}
+
+// liveSource reads the source of the rule from the API server.
+func (c *SecretMirror) liveSource(mirrorConfig config.MirrorConfig) (*coreapi.Secret, error) {
+	from := mirrorConfig.From
+	client := c.client
+	if from.Cluster != "" {
+		remote, configured := c.remoteClients[from.Cluster]
+		if !configured {
+			return nil, fmt.Errorf("cluster %s is not configured", from.Cluster)
+		}
+		client = remote
+	}
+	return client.CoreV1().Secrets(from.Namespace).Get(from.Name, metav1.GetOptions{})
+}
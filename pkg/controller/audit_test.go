@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestAuditReportsDrift(t *testing.T) {
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, source)
+	rule := func(target string) config.MirrorConfig {
+		return config.MirrorConfig{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: target},
+		}
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{rule("synced"), rule("edited"), rule("deleted"), rule("held")}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	secrets := client.CoreV1().Secrets("test-ns")
+	edited, err := secrets.Get("edited", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited.Data["key"] = []byte("edited")
+	if _, err := secrets.Update(edited); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.Delete("deleted", &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	held, err := secrets.Get("held", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	held.Data["key"] = []byte("debugging")
+	held.Annotations[doNotOverwriteAnnotation] = "true"
+	if _, err := secrets.Update(held); err != nil {
+		t.Fatal(err)
+	}
+
+	c.audit()
+	report := c.DriftReport()
+	if report.Completed == nil || report.Audited != 3 {
+		t.Errorf("expected a completed sweep auditing every target but the held one, got %+v", report)
+	}
+	editedRule, deletedRule := rule("edited"), rule("deleted")
+	expected := map[string]string{editedRule.String(): driftData, deletedRule.String(): driftMissing}
+	if !reflect.DeepEqual(report.Drifted, expected) {
+		t.Errorf("expected drift %v, got %v", expected, report.Drifted)
+	}
+	if depth := c.queue.Len(); depth != 1 {
+		t.Errorf("expected the source of the drifted targets to be queued, got %d queued keys", depth)
+	}
+	for _, status := range c.Rules() {
+		if status.Drift != expected[status.Rule] {
+			t.Errorf("expected the status of %s to report drift %q, got %q", status.Rule, expected[status.Rule], status.Drift)
+		}
+	}
+}
+
+func TestReportOnlyReconcileDoesNotWrite(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	mirrorConfig := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{mirrorConfig}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{ReportOnly: true})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+	client.ClearActions()
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" && action.GetVerb() != "list" && action.GetVerb() != "watch" {
+			t.Errorf("expected nothing to be written in report-only mode, got %v", action)
+		}
+	}
+	if drift := c.DriftReport().Drifted[mirrorConfig.String()]; drift != driftMissing {
+		t.Errorf("expected the missing target to be reported, got %q", drift)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "MirroringDrift") {
+			t.Errorf("expected a drift event, got %q", event)
+		}
+	default:
+		t.Error("expected the drift to be recorded as an event")
+	}
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/backup"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// runBackups exports every target to an encrypted archive in the
+// backup store once per backup period, starting right away.
+func (c *SecretMirror) runBackups(stopCh <-chan struct{}) {
+	if c.backupStore == nil {
+		return
+	}
+	wait.Until(func() {
+		if err := c.backup(time.Now()); err != nil {
+			c.logger.WithError(err).Error("failed to back up targets")
+		}
+	}, c.backupPeriod, stopCh)
+}
+
+// backup exports the targets of every rule that exist as an archive.
+func (c *SecretMirror) backup(now time.Time) error {
+	configuration := c.config()
+	archive := backup.Archive{Created: now.UTC().Truncate(time.Second), Targets: []backup.Target{}}
+	seen := map[string]bool{}
+	for _, mirrorConfig := range c.concreteRules(configuration) {
+		to := mirrorConfig.To
+		// archives are restored into the cluster the controller runs in,
+		// as Secrets, so targets written as SealedSecrets or by the
+		// External Secrets Operator are left out
+		if seen[to.String()] || to.Cluster != "" || to.Provider() != "" || (mirrorConfig.Output != "" && mirrorConfig.Output != config.OutputSecret) || (mirrorConfig.Files != nil && mirrorConfig.Files.SkipTarget) {
+			continue
+		}
+		seen[to.String()] = true
+		target, err := c.getTarget(to)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		archive.Targets = append(archive.Targets, backup.Target{
+			Rule:        mirrorConfig.String(),
+			Namespace:   target.Namespace,
+			Name:        target.Name,
+			Type:        string(target.Type),
+			Labels:      target.Labels,
+			Annotations: target.Annotations,
+			Data:        target.Data,
+		})
+	}
+	sort.Slice(archive.Targets, func(i, j int) bool {
+		if archive.Targets[i].Namespace != archive.Targets[j].Namespace {
+			return archive.Targets[i].Namespace < archive.Targets[j].Namespace
+		}
+		return archive.Targets[i].Name < archive.Targets[j].Name
+	})
+
+	name, err := backup.Save(c.backupStore, c.backupRecipients, archive, c.backupRetention)
+	if err != nil {
+		return err
+	}
+	c.logger.WithField("archive", name).Infof("backed up %d targets", len(archive.Targets))
+	lastBackupTimestamp.Set(float64(archive.Created.Unix()))
+	return nil
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/backup"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestBackup(t *testing.T) {
+	directory, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "dst", Labels: map[string]string{"team": "a"}},
+		Data:       map[string][]byte{"token": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "team", Name: "dst"}},
+		{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "team", Name: "missing"}},
+	}})
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &backup.DirectoryStore{Directory: directory}
+	c := NewSecretMirror(informer, client, ca.Config, Options{BackupStore: store, BackupRecipients: []age.Recipient{identity.Recipient()}, BackupRetention: 1})
+
+	if err := c.backup(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatalf("failed to back up: %v", err)
+	}
+	archives, err := backup.List(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 {
+		t.Fatalf("expected one archive, got %v", archives)
+	}
+	file, err := os.Open(filepath.Join(directory, archives[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	archive, err := backup.Read(file, []age.Identity{identity})
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	if len(archive.Targets) != 1 {
+		t.Fatalf("expected the existing target to be backed up, got %v", archive.Targets)
+	}
+	target := archive.Targets[0]
+	if target.Namespace != "team" || target.Name != "dst" || string(target.Data["token"]) != "value" || target.Labels["team"] != "a" {
+		t.Errorf("expected the target to be backed up with its data and metadata, got %v", target)
+	}
+	if target.Rule != "(ci/src -> team/dst)" {
+		t.Errorf("expected the target to record the rule that writes it, got %q", target.Rule)
+	}
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// manyRules configures the given number of rules, mirroring one source
+// into every namespace.
+func manyRules(rules int) *config.Configuration {
+	configuration := &config.Configuration{}
+	for i := 0; i < rules; i++ {
+		configuration.Secrets = append(configuration.Secrets, config.MirrorConfig{
+			From: config.SecretLocation{Namespace: fmt.Sprintf("source-%d", i%100), Name: "src"},
+			To:   config.SecretLocation{Namespace: fmt.Sprintf("target-%d", i), Name: "dst"},
+		})
+	}
+	return configuration
+}
+
+func BenchmarkRuleIndex(b *testing.B) {
+	for _, rules := range []int{1000, 10000, 50000} {
+		b.Run(fmt.Sprintf("%d rules", rules), func(b *testing.B) {
+			configuration := manyRules(rules)
+			source := config.SecretLocation{Namespace: "source-0", Name: "src"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index := &ruleIndex{}
+				index.from(configuration, source)
+			}
+		})
+	}
+}
+
+func BenchmarkReconcileUnchanged(b *testing.B) {
+	configuration := manyRules(20000)
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(b, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "source-0", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(configuration)
+	c := NewSecretMirror(informer, client, ca.Config, Options{InventoryNamespace: "ci"})
+	if err := c.reconcile("source-0/src"); err != nil {
+		b.Fatalf("failed to reconcile: %v", err)
+	}
+	c.flushInventory()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.reconcile("source-0/src"); err != nil {
+			b.Fatalf("failed to reconcile: %v", err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// collisions tracks what every rule wrote to each target during one
+// generation of the configuration, so that rules fighting over a target
+// are halted instead of overwriting each other on every sync. Halted
+// rules are released when the configuration is reloaded.
+type collisions struct {
+	lock sync.Mutex
+
+	// writes maps targets to the rules writing them
+	writes map[string]map[string]ruleWrite
+	// halted maps halted rules to the rule they collided with
+	halted     map[string]string
+	generation *config.Configuration
+}
+
+// ruleWrite is what a rule last wrote to a target.
+type ruleWrite struct {
+	merge bool
+	// hashes of the values written, by key
+	hashes map[string][sha256.Size]byte
+}
+
+// sync forgets all writes and releases all halted rules if the
+// configuration has been reloaded since we last looked at it.
+func (c *collisions) sync(generation *config.Configuration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generation == generation {
+		return
+	}
+	c.generation = generation
+	c.writes = map[string]map[string]ruleWrite{}
+	c.halted = map[string]string{}
+	collidingRules.reset()
+}
+
+// record registers the data a rule is about to write to its target and
+// returns the rule it collides with, if any, and whether the collision
+// was detected just now. Rules collide when one of them replaces the
+// target or when they write different values to the same key. Both
+// rules stay halted once they collided.
+func (c *collisions) record(mirrorConfig config.MirrorConfig, data map[string][]byte) (other string, halted, detected bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.writes == nil {
+		c.writes = map[string]map[string]ruleWrite{}
+		c.halted = map[string]string{}
+	}
+	rule, target := mirrorConfig.String(), mirrorConfig.To.String()
+	if other, halted := c.halted[rule]; halted {
+		return other, true, false
+	}
+
+	write := ruleWrite{merge: mirrorConfig.Merge, hashes: map[string][sha256.Size]byte{}}
+	for key, value := range data {
+		write.hashes[key] = sha256.Sum256(value)
+	}
+	for other, written := range c.writes[target] {
+		if other == rule || !write.collidesWith(written) {
+			continue
+		}
+		c.halted[rule], c.halted[other] = other, rule
+		collidingRules.set(rule, 1)
+		collidingRules.set(other, 1)
+		return other, true, true
+	}
+	if c.writes[target] == nil {
+		c.writes[target] = map[string]ruleWrite{}
+	}
+	c.writes[target][rule] = write
+	return "", false, false
+}
+
+func (w ruleWrite) collidesWith(other ruleWrite) bool {
+	if !w.merge || !other.merge {
+		return true
+	}
+	for key, hash := range w.hashes {
+		if otherHash, written := other.hashes[key]; written && otherHash != hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestCollisionsRecord(t *testing.T) {
+	first := config.MirrorConfig{
+		From:  config.SecretLocation{Namespace: "team-a", Name: "token"},
+		To:    config.SecretLocation{Namespace: "shared", Name: "tokens"},
+		Merge: true,
+	}
+	second := config.MirrorConfig{
+		From:  config.SecretLocation{Namespace: "team-b", Name: "token"},
+		To:    config.SecretLocation{Namespace: "shared", Name: "tokens"},
+		Merge: true,
+	}
+	replacing := second
+	replacing.Merge = false
+
+	var testCases = []struct {
+		name          string
+		second        config.MirrorConfig
+		firstData     map[string][]byte
+		secondData    map[string][]byte
+		expectedHalts bool
+	}{
+		{
+			name:       "merging different keys does not collide",
+			second:     second,
+			firstData:  map[string][]byte{"a": []byte("1")},
+			secondData: map[string][]byte{"b": []byte("2")},
+		},
+		{
+			name:       "merging the same value into the same key does not collide",
+			second:     second,
+			firstData:  map[string][]byte{"a": []byte("1")},
+			secondData: map[string][]byte{"a": []byte("1")},
+		},
+		{
+			name:          "merging different values into the same key collides",
+			second:        second,
+			firstData:     map[string][]byte{"a": []byte("1")},
+			secondData:    map[string][]byte{"a": []byte("2")},
+			expectedHalts: true,
+		},
+		{
+			name:          "replacing a target that another rule writes collides",
+			second:        replacing,
+			firstData:     map[string][]byte{"a": []byte("1")},
+			secondData:    map[string][]byte{"b": []byte("2")},
+			expectedHalts: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := &collisions{}
+			c.sync(&config.Configuration{})
+			if _, halted, _ := c.record(first, testCase.firstData); halted {
+				t.Fatalf("%s: expected the first write not to collide", testCase.name)
+			}
+			other, halted, detected := c.record(testCase.second, testCase.secondData)
+			if halted != testCase.expectedHalts || detected != testCase.expectedHalts {
+				t.Fatalf("%s: expected the second write to collide to be %t, got halted %t and detected %t", testCase.name, testCase.expectedHalts, halted, detected)
+			}
+			if !testCase.expectedHalts {
+				return
+			}
+			if other != first.String() {
+				t.Errorf("%s: expected the second rule to collide with %s, got %s", testCase.name, first.String(), other)
+			}
+			if other, halted, detected := c.record(first, testCase.firstData); !halted || detected || other != testCase.second.String() {
+				t.Errorf("%s: expected the first rule to stay halted by %s, got %q (halted %t, detected %t)", testCase.name, testCase.second.String(), other, halted, detected)
+			}
+			c.sync(&config.Configuration{})
+			if _, halted, _ := c.record(first, testCase.firstData); halted {
+				t.Errorf("%s: expected the first rule to be released on reload", testCase.name)
+			}
+		})
+	}
+}
+
+func TestReconcileHaltsCollidingRules(t *testing.T) {
+	client := testclient.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "token"},
+		Data:       map[string][]byte{"token": []byte("b")},
+	})
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "token"},
+		Data:       map[string][]byte{"token": []byte("a")},
+	})
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := informer.Lister().Secrets("team-b").Get("token")
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("informer did not observe the second source: %v", err)
+	}
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:  config.SecretLocation{Namespace: "team-a", Name: "token"},
+			To:    config.SecretLocation{Namespace: "shared", Name: "tokens"},
+			Merge: true,
+		},
+		{
+			From:  config.SecretLocation{Namespace: "team-b", Name: "token"},
+			To:    config.SecretLocation{Namespace: "shared", Name: "tokens"},
+			Merge: true,
+		},
+	}}
+	ca := &config.Agent{}
+	ca.Set(configuration)
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+
+	if err := c.reconcile("team-a/token"); err != nil {
+		t.Fatalf("expected the first rule to be mirrored, got: %v", err)
+	}
+	if err := c.reconcile("team-b/token"); err == nil {
+		t.Fatal("expected the second rule to collide with the first")
+	}
+	if err := c.reconcile("team-a/token"); err == nil {
+		t.Fatal("expected the first rule to be halted after the collision")
+	}
+	collisionEvents := 0
+	for len(recorder.Events) > 0 {
+		if strings.Contains(<-recorder.Events, "MirroringCollision") {
+			collisionEvents++
+		}
+	}
+	if collisionEvents != 1 {
+		t.Errorf("expected one event about the collision, got %d", collisionEvents)
+	}
+
+	reloaded := *configuration
+	ca.Set(&reloaded)
+	if err := c.reconcile("team-a/token"); err != nil {
+		t.Errorf("expected the first rule to be released when the configuration is reloaded, got: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// mergedSource returns the source with the data of every source the rule
+// merges from combined into it, or the source itself if the rule only
+// mirrors from it. Every merged source must exist.
+func (c *SecretMirror) mergedSource(source *coreapi.Secret, mirrorConfig config.MirrorConfig) (*coreapi.Secret, error) {
+	if len(mirrorConfig.MergeFrom) == 0 {
+		return source, nil
+	}
+	sources := []sourceData{{location: mirrorConfig.From, data: source.Data}}
+	for _, from := range mirrorConfig.MergeFrom {
+		lister := c.lister
+		if from.Cluster != "" {
+			remote, configured := c.remoteListers[from.Cluster]
+			if !configured {
+				return nil, fmt.Errorf("cluster %s of merged source %s is not configured", from.Cluster, from.String())
+			}
+			lister = remote
+		}
+		secret, err := lister.Secrets(from.Namespace).Get(from.Name)
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("merged source %s does not exist", from.String())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get merged source %s: %v", from.String(), err)
+		}
+		sources = append(sources, sourceData{location: from, data: secret.Data})
+	}
+	data, err := combinedData(sources, mirrorConfig.KeyConflicts)
+	if err != nil {
+		return nil, err
+	}
+	merged := source.DeepCopy()
+	merged.Data = data
+	return merged, nil
+}
+
+// sourceData is the data of one of the sources merged into a target.
+type sourceData struct {
+	location config.SecretLocation
+	data     map[string][]byte
+}
+
+// combinedData combines the data of the sources in order. Sources holding
+// the same value for a key never conflict; otherwise the policy decides.
+func combinedData(sources []sourceData, policy string) (map[string][]byte, error) {
+	combined := map[string][]byte{}
+	origins := map[string]config.SecretLocation{}
+	for _, source := range sources {
+		for key, value := range source.data {
+			existing, conflict := combined[key]
+			if conflict && !bytes.Equal(existing, value) {
+				switch policy {
+				case config.KeyConflictsFirstWins:
+					continue
+				case config.KeyConflictsLastWins:
+				default:
+					origin := origins[key]
+					return nil, fmt.Errorf("merged sources %s and %s hold different values for key %q", origin.String(), source.location.String(), key)
+				}
+			}
+			combined[key] = value
+			origins[key] = source.location
+		}
+	}
+	return combined, nil
+}
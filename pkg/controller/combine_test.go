@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestCombinedData(t *testing.T) {
+	sources := []sourceData{
+		{location: config.SecretLocation{Namespace: "ci", Name: "a"}, data: map[string][]byte{"token": []byte("a"), "shared": []byte("same"), "user": []byte("ci")}},
+		{location: config.SecretLocation{Namespace: "ci", Name: "b"}, data: map[string][]byte{"token": []byte("b"), "shared": []byte("same"), "ca.crt": []byte("ca")}},
+	}
+	var testCases = []struct {
+		name        string
+		policy      string
+		expected    map[string][]byte
+		expectedErr bool
+	}{
+		{
+			name:        "conflicting values fail by default",
+			expectedErr: true,
+		},
+		{
+			name:        "conflicting values fail",
+			policy:      config.KeyConflictsFail,
+			expectedErr: true,
+		},
+		{
+			name:     "the first source wins",
+			policy:   config.KeyConflictsFirstWins,
+			expected: map[string][]byte{"token": []byte("a"), "shared": []byte("same"), "user": []byte("ci"), "ca.crt": []byte("ca")},
+		},
+		{
+			name:     "the last source wins",
+			policy:   config.KeyConflictsLastWins,
+			expected: map[string][]byte{"token": []byte("b"), "shared": []byte("same"), "user": []byte("ci"), "ca.crt": []byte("ca")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := combinedData(sources, testCase.policy)
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !testCase.expectedErr && !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("%s: expected %q, got %q", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+
+	if _, err := combinedData(sources[1:], ""); err != nil {
+		t.Errorf("expected a single source never to conflict, got %v", err)
+	}
+}
+
+func TestReconcileMergedSources(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "registry"},
+		Data:       map[string][]byte{"registry": []byte("quay.io")},
+	})
+	if err := informer.Informer().GetIndexer().Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "credentials"},
+		Data:       map[string][]byte{"username": []byte("ci"), "password": []byte("hunter2")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From:      config.SecretLocation{Namespace: "ci", Name: "registry"},
+		MergeFrom: []config.SecretLocation{{Namespace: "ci", Name: "credentials"}, {Namespace: "ci", Name: "missing"}},
+		To:        config.SecretLocation{Namespace: "team", Name: "pull-secret"},
+	}}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+
+	if err := c.reconcile("ci/registry"); err == nil {
+		t.Error("expected reconciling to fail while a merged source is missing")
+	}
+	if err := informer.Informer().GetIndexer().Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "missing"},
+		Data:       map[string][]byte{"email": []byte("ci@example.com")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes to merged sources are reconciled from the first source
+	if err := c.reconcile("ci/credentials"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("team").Get("pull-secret", metav1.GetOptions{}); err == nil {
+		t.Error("expected a merged source not to be mirrored on its own")
+	}
+	if actual := c.queue.Len(); actual != 1 {
+		t.Errorf("expected the first source to be enqueued, got %d keys", actual)
+	}
+
+	if err := c.reconcile("ci/registry"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("team").Get("pull-secret", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be created: %v", err)
+	}
+	expected := map[string][]byte{"registry": []byte("quay.io"), "username": []byte("ci"), "password": []byte("hunter2"), "email": []byte("ci@example.com")}
+	if !reflect.DeepEqual(target.Data, expected) {
+		t.Errorf("expected the target to hold %q, got %q", expected, target.Data)
+	}
+}
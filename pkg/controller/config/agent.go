@@ -28,8 +28,9 @@ import (
 // Agent watches a path and automatically loads the config stored
 // therein.
 type Agent struct {
-	mut sync.RWMutex // do not export Lock, etc methods
-	c   *Configuration
+	mut      sync.RWMutex // do not export Lock, etc methods
+	c        *Configuration
+	onChange func()
 }
 
 // Start will begin polling the config file at the path. If the first load
@@ -70,16 +71,115 @@ func (ca *Agent) Start(configLocation string) error {
 					WithError(err).Error("Error loading config.")
 			} else {
 				skips = 0
-				if !reflect.DeepEqual(c, ca.c) {
+				// only replace the config when it changed, so that
+				// consumers can detect reloads by identity
+				if !reflect.DeepEqual(c, ca.Config()) {
 					logrus.Info("Changes of configuration detected.")
+					ca.Set(c)
+					ca.changed()
 				}
+			}
+		}
+	}()
+	return nil
+}
+
+// StartGit will begin polling the repository for new commits of its ref,
+// loading the config at the path within it from every new commit. If the
+// first load fails, StartGit will return the error and abort. Future
+// failures will log the failure message but continue polling.
+func (ca *Agent) StartGit(repository *GitRepository, path string, interval time.Duration) error {
+	c, err := LoadGit(repository, path)
+	if err != nil {
+		return err
+	}
+	logrus.WithField("revision", c.Revision).Info("Loaded config from Git.")
+	ca.Set(c)
+	go func() {
+		revision := c.Revision
+		for range time.Tick(interval) {
+			sha, err := repository.Fetch()
+			if err != nil {
+				logrus.WithError(err).Error("Error fetching config.")
+				continue
+			}
+			if sha == revision {
+				continue
+			}
+			revision = sha
+			logger := logrus.WithField("revision", sha)
+			c, err := loadRevision(repository, path, sha)
+			if err != nil {
+				logger.WithError(err).Error("Error loading config.")
+				continue
+			}
+			// commits that leave the config alone do not reload it, so
+			// targets record the commit that last changed the config
+			if unchanged(c, ca.Config()) {
+				logger.Debug("New commit does not change the config.")
+				continue
+			}
+			logger.Info("Changes of configuration detected.")
+			ca.Set(c)
+			ca.changed()
+		}
+	}()
+	return nil
+}
+
+// StartURL will begin polling the URL for changes of the config served
+// there. If the first load fails, StartURL will return the error and
+// abort. Future failures will log the failure message but continue
+// polling.
+func (ca *Agent) StartURL(url string, interval time.Duration) error {
+	source := NewHTTPSource(url)
+	data, err := source.Fetch()
+	if err != nil {
+		return err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	ca.Set(c)
+	go func() {
+		for range time.Tick(interval) {
+			data, err := source.Fetch()
+			if err != nil {
+				logrus.WithField("configLocation", url).WithError(err).Error("Error fetching config.")
+				continue
+			}
+			if data == nil {
+				// the ETag still matches
+				continue
+			}
+			c, err := Parse(data)
+			if err != nil {
+				logrus.WithField("configLocation", url).WithError(err).Error("Error loading config.")
+				continue
+			}
+			// servers without ETags send the config every time
+			if !reflect.DeepEqual(c, ca.Config()) {
+				logrus.Info("Changes of configuration detected.")
 				ca.Set(c)
+				ca.changed()
 			}
 		}
 	}()
 	return nil
 }
 
+// unchanged determines if the configurations only differ by the
+// revision they were loaded from.
+func unchanged(loaded, current *Configuration) bool {
+	if loaded == nil || current == nil {
+		return loaded == current
+	}
+	aligned := *loaded
+	aligned.Revision = current.Revision
+	return reflect.DeepEqual(&aligned, current)
+}
+
 // Getter returns the current Config in a thread-safe manner.
 type Getter func() *Configuration
 
@@ -90,6 +190,23 @@ func (ca *Agent) Config() *Configuration {
 	return ca.c
 }
 
+// OnChange registers a function that is called whenever a changed
+// configuration was loaded, but not for the initial one.
+func (ca *Agent) OnChange(onChange func()) {
+	ca.mut.Lock()
+	defer ca.mut.Unlock()
+	ca.onChange = onChange
+}
+
+func (ca *Agent) changed() {
+	ca.mut.RLock()
+	onChange := ca.onChange
+	ca.mut.RUnlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
 // Set sets the config. Useful for testing.
 func (ca *Agent) Set(c *Configuration) {
 	ca.mut.Lock()
@@ -75,6 +75,13 @@ func TestConfig(t *testing.T) {
 	}
 
 	unitUnderTest = someTestClass{config: configAgent.Config}
+	changes := make(chan struct{}, 1)
+	configAgent.OnChange(func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
 
 	expected := unitUnderTest.config()
 	result := &Configuration{
@@ -119,4 +126,9 @@ func TestConfig(t *testing.T) {
 	if err != nil {
 		t.Errorf("expected no error (wait.Poll) but got one: %v", err)
 	}
+	select {
+	case <-changes:
+	case <-time.After(10 * time.Second):
+		t.Error("expected the reload to be signalled")
+	}
 }
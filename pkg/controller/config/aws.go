@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AWSSecretsManagerScheme prefixes the ARNs of secrets in AWS Secrets
+// Manager when locations are formatted, as the colons of ARNs would
+// otherwise be taken for the separator of a remote cluster.
+const AWSSecretsManagerScheme = "awssecretsmanager://"
+
+// ProviderAWSSecretsManager names AWS Secrets Manager as the provider
+// of a location
+const ProviderAWSSecretsManager = "awsSecretsManager"
+
+// AWSSecretsManagerLocation identifies a secret in AWS Secrets Manager
+// instead of a secret in a cluster.
+type AWSSecretsManagerLocation struct {
+	// ARN is the Amazon Resource Name of the secret, e.g.
+	// arn:aws:secretsmanager:us-east-1:123456789012:secret:ci/registry-AbCdEf
+	ARN string `json:"arn,omitempty"`
+}
+
+// Partition returns the partition of the ARN, e.g. aws or aws-cn.
+func (l *AWSSecretsManagerLocation) Partition() string {
+	return l.field(1)
+}
+
+// Region returns the region holding the secret, e.g. us-east-1.
+func (l *AWSSecretsManagerLocation) Region() string {
+	return l.field(3)
+}
+
+// field returns a field of the ARN, which are separated by colons
+// except for the name of the secret, which may contain colons.
+func (l *AWSSecretsManagerLocation) field(i int) string {
+	fields := strings.SplitN(l.ARN, ":", 7)
+	if len(fields) != 7 {
+		return ""
+	}
+	return fields[i]
+}
+
+func (l *AWSSecretsManagerLocation) validate(parent string) []string {
+	fields := strings.SplitN(l.ARN, ":", 7)
+	if len(fields) != 7 || fields[0] != "arn" || fields[1] == "" || fields[2] != "secretsmanager" || fields[3] == "" || fields[4] == "" || fields[5] != "secret" || fields[6] == "" {
+		return []string{fmt.Sprintf("%s.arn: must be the ARN of a secret, e.g. arn:aws:secretsmanager:us-east-1:123456789012:secret:ci/registry-AbCdEf", parent)}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateAWSSecretsManager(t *testing.T) {
+	arn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:ci/registry-AbCdEf"
+	fromAWS := SecretLocation{AWSSecretsManager: AWSSecretsManagerLocation{ARN: arn}}
+	to := SecretLocation{Namespace: "team", Name: "registry"}
+	var testCases = []struct {
+		name        string
+		mirror      MirrorConfig
+		expectedErr string
+	}{
+		{
+			name:   "source in AWS Secrets Manager",
+			mirror: MirrorConfig{From: fromAWS, To: to},
+		},
+		{
+			name:        "ARN of another service",
+			mirror:      MirrorConfig{From: SecretLocation{AWSSecretsManager: AWSSecretsManagerLocation{ARN: "arn:aws:ssm:us-east-1:123456789012:parameter/ci"}}, To: to},
+			expectedErr: "from.awsSecretsManager.arn",
+		},
+		{
+			name:        "ARN without a region",
+			mirror:      MirrorConfig{From: SecretLocation{AWSSecretsManager: AWSSecretsManagerLocation{ARN: "arn:aws:secretsmanager::123456789012:secret:ci"}}, To: to},
+			expectedErr: "from.awsSecretsManager.arn",
+		},
+		{
+			name:        "source in AWS Secrets Manager and in Vault",
+			mirror:      MirrorConfig{From: SecretLocation{AWSSecretsManager: fromAWS.AWSSecretsManager, Vault: VaultLocation{Path: "secret/ci/registry"}}, To: to},
+			expectedErr: "only one of",
+		},
+		{
+			name:        "target in AWS Secrets Manager",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "registry"}, To: fromAWS},
+			expectedErr: "to.awsSecretsManager",
+		},
+		{
+			name:        "merged source in AWS Secrets Manager",
+			mirror:      MirrorConfig{From: fromAWS, MergeFrom: []SecretLocation{{Namespace: "ci", Name: "registry"}}, To: to},
+			expectedErr: "mergeFrom: cannot be combined with sources in AWS Secrets Manager",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}}
+			err := configuration.Validate()
+			if testCase.expectedErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Errorf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestAWSSecretsManagerLocationSerialization(t *testing.T) {
+	location := SecretLocation{AWSSecretsManager: AWSSecretsManagerLocation{ARN: "arn:aws-cn:secretsmanager:cn-north-1:123456789012:secret:ci:registry"}}
+	if actual, expected := location.String(), "awssecretsmanager://arn:aws-cn:secretsmanager:cn-north-1:123456789012:secret:ci:registry"; actual != expected {
+		t.Errorf("expected the location to be formatted as %s, got %s", expected, actual)
+	}
+	if parsed, ok := ParseProviderLocation(location.String()); !ok || parsed != location {
+		t.Errorf("expected the formatted location to be parsed, got %+v", parsed)
+	}
+	if partition, region := location.AWSSecretsManager.Partition(), location.AWSSecretsManager.Region(); partition != "aws-cn" || region != "cn-north-1" {
+		t.Errorf("expected the partition aws-cn and the region cn-north-1, got %s and %s", partition, region)
+	}
+
+	raw, err := json.Marshal(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "vault") {
+		t.Errorf("expected secrets in AWS Secrets Manager to be serialized without Vault, got %s", raw)
+	}
+	var parsed SecretLocation
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed != location {
+		t.Errorf("expected %s to survive serialization, got %+v", raw, parsed)
+	}
+}
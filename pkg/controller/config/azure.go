@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AzureKeyVaultScheme prefixes the secrets in Azure Key Vault when
+// locations are formatted, which tells them apart from secrets in
+// clusters.
+const AzureKeyVaultScheme = "azurekeyvault://"
+
+// ProviderAzureKeyVault names Azure Key Vault as the provider of a
+// location
+const ProviderAzureKeyVault = "azureKeyVault"
+
+var (
+	// azureVaultName matches the names of key vaults
+	azureVaultName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]{1,22}[a-zA-Z0-9]$`)
+	// azureSecretName matches the names of secrets in key vaults
+	azureSecretName = regexp.MustCompile(`^[a-zA-Z0-9-]{1,127}$`)
+	// azureSecretVersion matches the identifiers of versions of secrets
+	azureSecretVersion = regexp.MustCompile(`^[a-f0-9]{32}$`)
+)
+
+// AzureKeyVaultLocation identifies a secret in Azure Key Vault instead
+// of a secret in a cluster.
+type AzureKeyVaultLocation struct {
+	// Vault is the name of the key vault, e.g. ci-secrets for the
+	// vault at https://ci-secrets.vault.azure.net
+	Vault string `json:"vault,omitempty"`
+	// Name is the name of the secret in the key vault
+	Name string `json:"name,omitempty"`
+	// Version pins the version of the secret to mirror instead of
+	// mirroring the current version whenever it changes.
+	Version string `json:"version,omitempty"`
+}
+
+func (l *AzureKeyVaultLocation) validate(parent string) []string {
+	var messages []string
+	if !azureVaultName.MatchString(l.Vault) || strings.Contains(l.Vault, "--") {
+		messages = append(messages, fmt.Sprintf("%s.vault: must be the name of a key vault, not %q", parent, l.Vault))
+	}
+	if !azureSecretName.MatchString(l.Name) {
+		messages = append(messages, fmt.Sprintf("%s.name: must be the name of a secret, which only holds letters, digits and dashes, not %q", parent, l.Name))
+	}
+	if l.Version != "" && !azureSecretVersion.MatchString(l.Version) {
+		messages = append(messages, fmt.Sprintf("%s.version: must be the identifier of a version, not %q", parent, l.Version))
+	}
+	return messages
+}
+
+// String formats the secret as the name of its key vault, its name and
+// its version if it is pinned, separated by slashes.
+func (l *AzureKeyVaultLocation) String() string {
+	formatted := l.Vault + "/" + l.Name
+	if l.Version != "" {
+		formatted += "/" + l.Version
+	}
+	return formatted
+}
+
+// parseAzureKeyVaultLocation parses a secret formatted by String.
+func parseAzureKeyVaultLocation(formatted string) AzureKeyVaultLocation {
+	var location AzureKeyVaultLocation
+	parts := strings.SplitN(formatted, "/", 3)
+	location.Vault = parts[0]
+	if len(parts) > 1 {
+		location.Name = parts[1]
+	}
+	if len(parts) > 2 {
+		location.Version = parts[2]
+	}
+	return location
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateAzureKeyVault(t *testing.T) {
+	to := SecretLocation{Namespace: "team", Name: "registry"}
+	from := func(vault, name, version string) SecretLocation {
+		return SecretLocation{AzureKeyVault: AzureKeyVaultLocation{Vault: vault, Name: name, Version: version}}
+	}
+	var testCases = []struct {
+		name        string
+		mirror      MirrorConfig
+		expectedErr string
+	}{
+		{
+			name:   "current version",
+			mirror: MirrorConfig{From: from("ci-secrets", "registry", ""), To: to},
+		},
+		{
+			name:   "pinned version",
+			mirror: MirrorConfig{From: from("ci-secrets", "registry", "4387e9f3d6e14c459867679a90fd0f79"), To: to},
+		},
+		{
+			name:        "vault name that is too short",
+			mirror:      MirrorConfig{From: from("ci", "registry", ""), To: to},
+			expectedErr: "from.azureKeyVault.vault",
+		},
+		{
+			name:        "vault name with consecutive dashes",
+			mirror:      MirrorConfig{From: from("ci--secrets", "registry", ""), To: to},
+			expectedErr: "from.azureKeyVault.vault",
+		},
+		{
+			name:        "missing secret name",
+			mirror:      MirrorConfig{From: from("ci-secrets", "", ""), To: to},
+			expectedErr: "from.azureKeyVault.name",
+		},
+		{
+			name:        "secret name with slashes",
+			mirror:      MirrorConfig{From: from("ci-secrets", "ci/registry", ""), To: to},
+			expectedErr: "from.azureKeyVault.name",
+		},
+		{
+			name:        "malformed version",
+			mirror:      MirrorConfig{From: from("ci-secrets", "registry", "latest"), To: to},
+			expectedErr: "from.azureKeyVault.version",
+		},
+		{
+			name:        "target in Azure Key Vault",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "registry"}, To: from("ci-secrets", "registry", "")},
+			expectedErr: "to.azureKeyVault: only sources may be in Azure Key Vault",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}}
+			err := configuration.Validate()
+			if testCase.expectedErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Errorf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestAzureKeyVaultLocationSerialization(t *testing.T) {
+	var testCases = []struct {
+		location       AzureKeyVaultLocation
+		expectedString string
+	}{
+		{
+			location:       AzureKeyVaultLocation{Vault: "ci-secrets", Name: "registry"},
+			expectedString: "azurekeyvault://ci-secrets/registry",
+		},
+		{
+			location:       AzureKeyVaultLocation{Vault: "ci-secrets", Name: "registry", Version: "4387e9f3d6e14c459867679a90fd0f79"},
+			expectedString: "azurekeyvault://ci-secrets/registry/4387e9f3d6e14c459867679a90fd0f79",
+		},
+	}
+	for _, testCase := range testCases {
+		location := SecretLocation{AzureKeyVault: testCase.location}
+		if actual := location.String(); actual != testCase.expectedString {
+			t.Errorf("expected the location to be formatted as %s, got %s", testCase.expectedString, actual)
+		}
+		if parsed, ok := ParseProviderLocation(location.String()); !ok || parsed != location {
+			t.Errorf("expected %s to be parsed, got %+v", location.String(), parsed)
+		}
+
+		raw, err := json.Marshal(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parsed SecretLocation
+		if err := json.Unmarshal(raw, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed != location {
+			t.Errorf("expected %s to survive serialization, got %+v", raw, parsed)
+		}
+	}
+}
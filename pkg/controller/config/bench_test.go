@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+// largeConfiguration returns a configuration with the given number of rules,
+// shaped like a fleet-wide configuration: every source is mirrored into many
+// namespaces and some targets are mirrored further.
+func largeConfiguration(rules int) *Configuration {
+	c := &Configuration{}
+	for i := 0; i < rules; i++ {
+		from := SecretLocation{Namespace: fmt.Sprintf("source-%d", i/20), Name: "secret"}
+		if i%100 == 0 && i > 0 {
+			// chain some targets of earlier rules into further targets
+			from = c.Secrets[i-50].To
+		}
+		c.Secrets = append(c.Secrets, MirrorConfig{
+			From: from,
+			To:   SecretLocation{Namespace: fmt.Sprintf("target-%d", i), Name: "secret"},
+		})
+	}
+	return c
+}
+
+func BenchmarkValidate(b *testing.B) {
+	for _, rules := range []int{1000, 10000, 50000} {
+		c := largeConfiguration(rules)
+		b.Run(fmt.Sprintf("%d rules", rules), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := c.Validate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
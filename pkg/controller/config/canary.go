@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Canary designates the rules that changes to the configuration are
+// rolled out to first. The rest of the configuration is only changed
+// once the canaries mirrored successfully for the verification period.
+type Canary struct {
+	// Namespaces are target namespaces whose rules are canaries
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Period is how long canaries must mirror without
+	// failures before the change is rolled out everywhere
+	Period metav1.Duration `json:"period"`
+}
+
+func (c *Canary) validate(parent string) []string {
+	var messages []string
+	if c.Period.Duration <= 0 {
+		messages = append(messages, fmt.Sprintf("%s.period: must be positive", parent))
+	}
+	for i, namespace := range c.Namespaces {
+		if namespace == "" {
+			messages = append(messages, fmt.Sprintf("%s.namespaces[%d]: must not be empty", parent, i))
+		}
+	}
+	return messages
+}
+
+// NamespaceLabels looks up the labels of a namespace, if it exists.
+type NamespaceLabels func(namespace string) (map[string]string, bool)
+
+// IsCanary determines if changes to the configuration are rolled out to
+// the rule first. Rules selecting their target namespaces are canaries if
+// they select one of the canary namespaces, whose labels are looked up.
+func (c *Configuration) IsCanary(mirror MirrorConfig, namespaceLabels NamespaceLabels) bool {
+	if c.Canary == nil {
+		return false
+	}
+	if mirror.Canary {
+		return true
+	}
+	for _, namespace := range c.Canary.Namespaces {
+		if !mirror.FansOut() {
+			if mirror.To.Namespace == namespace {
+				return true
+			}
+			continue
+		}
+		if namespaceLabels == nil {
+			continue
+		}
+		if set, exists := namespaceLabels(namespace); exists && matchesLabels(mirror.To.NamespaceSelector, set) {
+			return true
+		}
+	}
+	return false
+}
+
+// Staged returns the configuration that rolls the changes from the
+// previous configuration out to the canaries only: canaries are
+// configured as in this configuration and every other rule as in
+// the previous one. Rules are resolved with the defaults of the
+// configuration they are taken from, so that changes to the
+// defaults only reach the canaries, too.
+func (c *Configuration) Staged(previous *Configuration, namespaceLabels NamespaceLabels) *Configuration {
+	staged := &Configuration{
+		Defaults:           Defaults{Notifications: c.Defaults.Notifications},
+		Metrics:            c.Metrics,
+		ApprovalNamespaces: c.ApprovalNamespaces,
+		Revision:           c.Revision,
+	}
+	for _, mirror := range previous.Secrets {
+		if !c.IsCanary(mirror, namespaceLabels) {
+			staged.Secrets = append(staged.Secrets, previous.Resolve(mirror))
+		}
+	}
+	for _, mirror := range c.Secrets {
+		if c.IsCanary(mirror, namespaceLabels) {
+			staged.Secrets = append(staged.Secrets, c.Resolve(mirror))
+		}
+	}
+	return staged
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStaged(t *testing.T) {
+	mirror := func(to string) MirrorConfig {
+		return MirrorConfig{
+			From: SecretLocation{Namespace: "ci", Name: "src"},
+			To:   SecretLocation{Namespace: to, Name: "dst"},
+		}
+	}
+	previous := &Configuration{
+		Secrets:  []MirrorConfig{mirror("canary"), mirror("prod"), mirror("flagged")},
+		Defaults: Defaults{Labels: map[string]string{"version": "old"}},
+	}
+	flagged := mirror("flagged")
+	flagged.Canary = true
+	current := &Configuration{
+		Secrets:  []MirrorConfig{mirror("canary"), mirror("prod"), flagged, mirror("new")},
+		Defaults: Defaults{Labels: map[string]string{"version": "new"}},
+		Canary:   &Canary{Namespaces: []string{"canary"}, Period: metav1.Duration{Duration: time.Minute}},
+	}
+
+	labelled := func(mirror MirrorConfig, version string) MirrorConfig {
+		mirror.Labels = map[string]string{"version": version}
+		return mirror
+	}
+	expected := []MirrorConfig{
+		labelled(mirror("prod"), "old"),
+		labelled(mirror("flagged"), "old"),
+		labelled(mirror("canary"), "new"),
+		labelled(flagged, "new"),
+	}
+	staged := current.Staged(previous, nil)
+	if !reflect.DeepEqual(staged.Secrets, expected) {
+		t.Errorf("expected the canaries to be configured as in the new configuration and the rest as before:\nexpected %v\ngot      %v", expected, staged.Secrets)
+	}
+	if staged.Canary != nil {
+		t.Error("expected the staged configuration not to be staged again")
+	}
+}
+
+func TestValidateCanary(t *testing.T) {
+	configuration := Configuration{
+		Secrets: []MirrorConfig{{From: SecretLocation{Namespace: "ci", Name: "src"}, To: SecretLocation{Namespace: "canary", Name: "dst"}}},
+		Canary:  &Canary{Namespaces: []string{"canary", ""}},
+	}
+	if err := configuration.Validate(); err == nil {
+		t.Error("expected a canary without a period or with an empty namespace to be invalid")
+	}
+}
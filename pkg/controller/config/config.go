@@ -1,18 +1,199 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
 )
 
 // Configuration defines the action for the secret mirror
 type Configuration struct {
 	// Secrets holds mirroring configurations.
 	Secrets []MirrorConfig `json:"secrets"`
+
+	// ConfigMaps holds mirroring configurations for ConfigMaps.
+	ConfigMaps []ConfigMapMirrorConfig `json:"configMaps,omitempty"`
+
+	// Defaults holds settings for every mirroring configuration
+	// that does not override them.
+	Defaults Defaults `json:"defaults,omitempty"`
+
+	// Groups name sets of sources, targets and keys
+	// that mirroring configurations may reference.
+	Groups map[string]Group `json:"groups,omitempty"`
+
+	// Metrics configures how metrics about rules and targets are labelled.
+	Metrics Metrics `json:"metrics,omitempty"`
+
+	// Canary rolls changes to the configuration out
+	// to a subset of the rules first, if set.
+	Canary *Canary `json:"canary,omitempty"`
+
+	// ApprovalNamespaces are target namespaces that changes are only
+	// written to once they have been approved, as if every rule writing
+	// to them set requireApproval.
+	ApprovalNamespaces []string `json:"approvalNamespaces,omitempty"`
+
+	// Policy restricts the namespaces that targets may be written to.
+	Policy *Policy `json:"policy,omitempty"`
+
+	// Revision is the SHA of the commit the configuration was loaded
+	// from, if it was loaded from a Git repository.
+	Revision string `json:"-"`
+}
+
+// Defaults holds settings shared by mirroring configurations
+type Defaults struct {
+	// Notifications routes failure notifications for rules
+	// which do not configure their own
+	Notifications *Notifications `json:"notifications,omitempty"`
+
+	// Labels are set on every target, unless a rule
+	// sets a different value for the same label
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are set on every target, unless a rule
+	// sets a different value for the same annotation
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// AdoptExisting lets every rule take over existing
+	// targets that the controller did not create
+	AdoptExisting bool `json:"adoptExisting,omitempty"`
+}
+
+// Notifications defines where failure notifications for a rule are sent
+type Notifications struct {
+	// SlackChannel is the Slack channel to post to
+	SlackChannel string `json:"slackChannel,omitempty"`
+
+	// WebhookURL receives a JSON POST for every notification
+	WebhookURL string `json:"webhookURL,omitempty"`
+
+	// Emails lists the addresses to mail
+	Emails []string `json:"emails,omitempty"`
+}
+
+func (n *Notifications) validate(parent string) []string {
+	var messages []string
+	if len(n.SlackChannel) == 0 && len(n.WebhookURL) == 0 && len(n.Emails) == 0 {
+		messages = append(messages, fmt.Sprintf("%s: at least one destination must be set", parent))
+	}
+	if len(n.WebhookURL) != 0 {
+		if u, err := url.Parse(n.WebhookURL); err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+			messages = append(messages, fmt.Sprintf("%s.webhookURL: must be an absolute HTTP(S) URL", parent))
+		}
+	}
+	for i, email := range n.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			messages = append(messages, fmt.Sprintf("%s.emails[%d]: %v", parent, i, err))
+		}
+	}
+	return messages
+}
+
+// NotificationsFor returns where failure notifications for the
+// mirroring configuration should be sent, or nil if nowhere.
+func (c *Configuration) NotificationsFor(mirror MirrorConfig) *Notifications {
+	if mirror.Notifications != nil {
+		return mirror.Notifications
+	}
+	return c.Defaults.Notifications
+}
+
+// Hash identifies the content of the configuration.
+func (c *Configuration) Hash() string {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		// only malformed transform configurations fail to
+		// serialize and loading the configuration rejects them
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Resolve returns the mirroring configuration with the defaults
+// applied to the settings it does not override.
+func (c *Configuration) Resolve(mirror MirrorConfig) MirrorConfig {
+	mirror.Labels = mergeEntries(c.Defaults.Labels, mirror.Labels)
+	mirror.Annotations = mergeEntries(c.Defaults.Annotations, mirror.Annotations)
+	mirror.AdoptExisting = mirror.AdoptExisting || c.Defaults.AdoptExisting
+	for _, namespace := range c.ApprovalNamespaces {
+		if mirror.To.Namespace == namespace {
+			mirror.RequireApproval = true
+		}
+	}
+	return mirror
+}
+
+// mergeEntries returns the defaults overridden by the entries in
+// overrides, or nil if there are none.
+func mergeEntries(defaults, overrides map[string]string) map[string]string {
+	if len(defaults) == 0 && len(overrides) == 0 {
+		return nil
+	}
+	merged := map[string]string{}
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// reservedAnnotationPrefix prefixes the annotations
+// the controller records its own state in
+const reservedAnnotationPrefix = "ci.openshift.io/mirror-"
+
+// reservedLabel marks the targets the controller manages
+const reservedLabel = "app.kubernetes.io/managed-by"
+
+// validateAnnotations ensures annotations are well-formed and
+// do not clash with those the controller manages
+func validateAnnotations(annotations map[string]string, parent string) []string {
+	var messages []string
+	for key := range annotations {
+		for _, msg := range validation.IsQualifiedName(key) {
+			messages = append(messages, fmt.Sprintf("%s[%s]: invalid key: %s", parent, key, msg))
+		}
+		if strings.HasPrefix(key, reservedAnnotationPrefix) {
+			messages = append(messages, fmt.Sprintf("%s[%s]: annotations prefixed with %s are reserved for the controller", parent, key, reservedAnnotationPrefix))
+		}
+	}
+	return messages
+}
+
+// validateLabels ensures labels are well-formed and
+// do not clash with the label the controller sets
+func validateLabels(labels map[string]string, parent string) []string {
+	var messages []string
+	for key, value := range labels {
+		if key == reservedLabel {
+			messages = append(messages, fmt.Sprintf("%s[%s]: is reserved for the controller", parent, key))
+		}
+		for _, msg := range validation.IsQualifiedName(key) {
+			messages = append(messages, fmt.Sprintf("%s[%s]: invalid key: %s", parent, key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			messages = append(messages, fmt.Sprintf("%s[%s]: invalid value: %s", parent, key, msg))
+		}
+	}
+	return messages
 }
 
 // MirrorConfig defines a mirror mapping
@@ -22,6 +203,335 @@ type MirrorConfig struct {
 
 	// To is the destination of mirrored secret data
 	To SecretLocation `json:"to"`
+
+	// MergeFrom lists further sources whose data is combined with the
+	// data of From into the target, in order
+	MergeFrom []SecretLocation `json:"mergeFrom,omitempty"`
+
+	// KeyConflicts determines which value wins when merged sources hold
+	// different values for a key, defaulting to Fail
+	KeyConflicts string `json:"keyConflicts,omitempty"`
+
+	// AdoptExisting takes over a target that exists but that the
+	// controller did not create. Such targets are left alone and the
+	// rule fails by default.
+	AdoptExisting bool `json:"adoptExisting,omitempty"`
+
+	// PropagateDeletion deletes the target when the source is deleted,
+	// if the controller wrote the target
+	PropagateDeletion bool `json:"propagateDeletion,omitempty"`
+
+	// AnnotateSource records on the source when its data was last
+	// written to a target and the hash of that data, which matches
+	// the last-applied hash annotation of the target
+	AnnotateSource bool `json:"annotateSource,omitempty"`
+
+	// FromGroup mirrors from every source of the named group instead
+	// of From, as if the configuration was repeated for each of them
+	FromGroup string `json:"fromGroup,omitempty"`
+
+	// ToGroup mirrors to every target of the named group instead
+	// of To, as if the configuration was repeated for each of them
+	ToGroup string `json:"toGroup,omitempty"`
+
+	// IgnoreTargetKeysGroup adds the keys of the named
+	// group to the ignored target keys
+	IgnoreTargetKeysGroup string `json:"ignoreTargetKeysGroup,omitempty"`
+
+	// Notifications overrides the default routing of
+	// failure notifications for this mirror
+	Notifications *Notifications `json:"notifications,omitempty"`
+
+	// Merge preserves keys that other parties added to the target while
+	// removing keys that were removed from the source since they were
+	// last mirrored. By default, the target data is replaced wholesale.
+	Merge bool `json:"merge,omitempty"`
+
+	// IgnoreTargetKeys lists keys in the target that are owned by
+	// other parties and must never be modified or deleted
+	IgnoreTargetKeys []string `json:"ignoreTargetKeys,omitempty"`
+
+	// PollInterval configures the controller to periodically GET the
+	// source instead of relying on a watch, for sources in namespaces
+	// where the controller may not list or watch secrets
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+
+	// Conversion builds the target as a secret of a different
+	// type than the source
+	Conversion *Conversion `json:"conversion,omitempty"`
+
+	// TargetType sets the type of the target instead of the type of
+	// the source, e.g. kubernetes.io/tls for a source that is Opaque
+	TargetType string `json:"targetType,omitempty"`
+
+	// Validations lists checks the mirrored data must pass before
+	// it is written to the target
+	Validations []KeyValidation `json:"validations,omitempty"`
+
+	// NormalizePEM normalizes the PEM content of the tls.crt and
+	// ca.crt keys, ordering certificate chains leaf-first
+	NormalizePEM bool `json:"normalizePEM,omitempty"`
+
+	// InjectChecksum records a checksum of the target in the pod template
+	// of Deployments and StatefulSets consuming it, so they roll out new
+	// pods whenever the target changes
+	InjectChecksum bool `json:"injectChecksum,omitempty"`
+
+	// UpdateStrategy determines how existing targets are changed,
+	// defaulting to Update
+	UpdateStrategy string `json:"updateStrategy,omitempty"`
+
+	// Transforms are applied in order to the source data, after
+	// any conversion and before keys are ignored
+	Transforms []TransformConfig `json:"transforms,omitempty"`
+
+	// TargetKeyPrefix is prepended to every key written to the target,
+	// after the data has been transformed and normalized; ignored keys
+	// and validations refer to the prefixed keys
+	TargetKeyPrefix string `json:"targetKeyPrefix,omitempty"`
+
+	// StripSourceKeyPrefix is removed from every source key that has it
+	// before the data is converted or transformed
+	StripSourceKeyPrefix string `json:"stripSourceKeyPrefix,omitempty"`
+
+	// Keys selects the subset of the source keys that is mirrored,
+	// before any prefix is stripped from them
+	Keys *KeyFilter `json:"keys,omitempty"`
+
+	// Labels are set on the target, in addition to the default labels
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are set on the target, in addition
+	// to the default annotations
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// CopyMetadata copies labels and annotations of the source onto
+	// the target; labels and annotations set by the rule win
+	CopyMetadata *CopyMetadata `json:"copyMetadata,omitempty"`
+
+	// SuffixSourceNamespace appends the namespace of the source to the
+	// name of the target when the configuration is loaded, so that
+	// sources with the same name in different namespaces can be
+	// mirrored into one shared namespace
+	SuffixSourceNamespace bool `json:"suffixSourceNamespace,omitempty"`
+
+	// ImmutableTarget marks targets immutable when they are created,
+	// so that changes to the source recreate them; it requires the
+	// Recreate update strategy
+	ImmutableTarget bool `json:"immutableTarget,omitempty"`
+
+	// Team owns the mirror; metrics about it are labelled with the
+	// team when they are aggregated at the team level
+	Team string `json:"team,omitempty"`
+
+	// DetailedMetrics labels metrics about the mirror with its rule and
+	// target, regardless of the configured level of aggregation
+	DetailedMetrics bool `json:"detailedMetrics,omitempty"`
+
+	// Canary rolls changes to the configuration out to the
+	// mirror first when the configuration sets up canaries
+	Canary bool `json:"canary,omitempty"`
+
+	// RequireApproval only writes changes to the target once they have
+	// been approved, through an annotation on the source or the admin API
+	RequireApproval bool `json:"requireApproval,omitempty"`
+
+	// Files writes the mirrored data to files on the local filesystem
+	// of the controller, in addition to or instead of the target
+	Files *FileSink `json:"files,omitempty"`
+
+	// Output determines what is written to the target namespace,
+	// a Secret unless it is set to OutputSealedSecret or
+	// OutputExternalSecret
+	Output string `json:"output,omitempty"`
+
+	// ExternalSecret configures the ExternalSecret written with
+	// output: ExternalSecret
+	ExternalSecret *ExternalSecretConfig `json:"externalSecret,omitempty"`
+}
+
+// KeyFilter selects source keys by name. Without Include, every key
+// is selected; keys in Exclude are never selected.
+type KeyFilter struct {
+	// Include lists the only source keys that are mirrored
+	Include []string `json:"include,omitempty"`
+
+	// Exclude lists source keys that are not mirrored
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Selects determines if the key is selected by the filter.
+func (f *KeyFilter) Selects(key string) bool {
+	if f == nil {
+		return true
+	}
+	for _, excluded := range f.Exclude {
+		if key == excluded {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, included := range f.Include {
+		if key == included {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *KeyFilter) validate(parent string) []string {
+	var messages []string
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		messages = append(messages, fmt.Sprintf("%s: must include or exclude keys", parent))
+	}
+	for i, key := range f.Include {
+		if len(key) == 0 || !validKeyPattern.MatchString(key) {
+			messages = append(messages, fmt.Sprintf("%s.include[%d]: must be a non-empty key of alphanumerics, '-', '_' and '.'", parent, i))
+		}
+	}
+	for i, key := range f.Exclude {
+		if len(key) == 0 || !validKeyPattern.MatchString(key) {
+			messages = append(messages, fmt.Sprintf("%s.exclude[%d]: must be a non-empty key of alphanumerics, '-', '_' and '.'", parent, i))
+		}
+	}
+	return messages
+}
+
+// TransformConfig selects a registered transform and configures it
+type TransformConfig struct {
+	// Name is the name the transform is registered under
+	Name string `json:"name"`
+
+	// Config is passed to the transform as-is
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+const (
+	// UpdateStrategyUpdate updates targets in place
+	UpdateStrategyUpdate = "Update"
+	// UpdateStrategyRecreate deletes and re-creates targets that
+	// cannot be updated in place, e.g. when their type changes
+	UpdateStrategyRecreate = "Recreate"
+)
+
+const (
+	// OutputSecret writes the target as a Secret
+	OutputSecret = "Secret"
+	// OutputSealedSecret writes the target as a Bitnami SealedSecret,
+	// which the sealed-secrets controller of the cluster decrypts into
+	// the Secret
+	OutputSealedSecret = "SealedSecret"
+	// OutputExternalSecret writes an ExternalSecret instead of the
+	// target, which the External Secrets Operator fetches the data of
+	// the source into
+	OutputExternalSecret = "ExternalSecret"
+)
+
+// Validators known to the controller
+const (
+	// ValidatorNonEmpty requires the value to be present and non-empty
+	ValidatorNonEmpty = "nonEmpty"
+	// ValidatorUTF8 requires the value to be valid UTF-8
+	ValidatorUTF8 = "utf8"
+	// ValidatorJSON requires the value to be a JSON document
+	ValidatorJSON = "json"
+	// ValidatorPEM requires the value to hold only PEM blocks
+	ValidatorPEM = "pem"
+)
+
+// Validators lists the names of all validators known to the controller
+var Validators = []string{ValidatorNonEmpty, ValidatorUTF8, ValidatorJSON, ValidatorPEM}
+
+// KeyValidation attaches validators to a key of the mirrored data
+type KeyValidation struct {
+	// Key is the key in the target data to validate
+	Key string `json:"key"`
+
+	// Validators names the checks the value must pass
+	Validators []string `json:"validators"`
+}
+
+func (v *KeyValidation) validate(parent string) []string {
+	var messages []string
+	if len(v.Key) == 0 {
+		messages = append(messages, fmt.Sprintf("%s.key: must not be empty", parent))
+	}
+	if len(v.Validators) == 0 {
+		messages = append(messages, fmt.Sprintf("%s.validators: must not be empty", parent))
+	}
+	for i, name := range v.Validators {
+		known := false
+		for _, validator := range Validators {
+			if name == validator {
+				known = true
+			}
+		}
+		if !known {
+			messages = append(messages, fmt.Sprintf("%s.validators[%d]: unknown validator %q, must be one of %s", parent, i, name, strings.Join(Validators, ", ")))
+		}
+	}
+	return messages
+}
+
+const (
+	// ConversionDockerConfigJSON builds a kubernetes.io/dockerconfigjson
+	// secret from registry, username and password keys in the source
+	ConversionDockerConfigJSON = "kubernetes.io/dockerconfigjson"
+	// ConversionOpaque builds registry, username and password keys
+	// from a kubernetes.io/dockerconfigjson source
+	ConversionOpaque = "Opaque"
+)
+
+// Conversion defines how source data is converted to a different
+// secret type. Key names default to registry, username and password.
+type Conversion struct {
+	// Type is the type of the target secret
+	Type string `json:"type"`
+
+	// RegistryKey names the key holding the registry host
+	RegistryKey string `json:"registryKey,omitempty"`
+
+	// UsernameKey names the key holding the registry username
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// PasswordKey names the key holding the registry password
+	PasswordKey string `json:"passwordKey,omitempty"`
+
+	// Registry selects the credentials to extract when converting
+	// from a source holding credentials for more than one registry
+	Registry string `json:"registry,omitempty"`
+}
+
+// Keys returns the names of the registry, username and password keys.
+func (c *Conversion) Keys() (registry, username, password string) {
+	registry, username, password = "registry", "username", "password"
+	if len(c.RegistryKey) != 0 {
+		registry = c.RegistryKey
+	}
+	if len(c.UsernameKey) != 0 {
+		username = c.UsernameKey
+	}
+	if len(c.PasswordKey) != 0 {
+		password = c.PasswordKey
+	}
+	return registry, username, password
+}
+
+func (c *Conversion) validate(parent string) []string {
+	var messages []string
+	if c.Type != ConversionDockerConfigJSON && c.Type != ConversionOpaque {
+		messages = append(messages, fmt.Sprintf("%s.type: must be one of %s or %s", parent, ConversionDockerConfigJSON, ConversionOpaque))
+	}
+	registry, username, password := c.Keys()
+	if registry == username || registry == password || username == password {
+		messages = append(messages, fmt.Sprintf("%s: registry, username and password keys must be distinct", parent))
+	}
+	if len(c.Registry) != 0 && c.Type != ConversionOpaque {
+		messages = append(messages, fmt.Sprintf("%s.registry: only applies to conversions to %s", parent, ConversionOpaque))
+	}
+	return messages
 }
 
 func (c *MirrorConfig) validate(parent string) []string {
@@ -29,8 +539,123 @@ func (c *MirrorConfig) validate(parent string) []string {
 	for _, msg := range c.From.validate(fmt.Sprintf("%s.from", parent)) {
 		messages = append(messages, msg)
 	}
-	for _, msg := range c.To.validate(fmt.Sprintf("%s.to", parent)) {
-		messages = append(messages, msg)
+	if len(c.From.NamePattern) == 0 {
+		messages = append(messages, c.To.validate(fmt.Sprintf("%s.to", parent))...)
+	} else {
+		messages = append(messages, c.validatePattern(parent)...)
+	}
+	if len(c.To.NamePattern) != 0 {
+		messages = append(messages, fmt.Sprintf("%s.to.namePattern: only sources may be selected by pattern", parent))
+	}
+	messages = append(messages, c.validateSelector(parent)...)
+	messages = append(messages, c.validateMergeFrom(parent)...)
+	messages = append(messages, c.validateProviders(parent)...)
+	if len(c.To.Cluster) != 0 && c.InjectChecksum {
+		messages = append(messages, fmt.Sprintf("%s.injectChecksum: is not supported for targets in remote clusters", parent))
+	}
+	if c.AnnotateSource && c.From.Cluster != "" {
+		messages = append(messages, fmt.Sprintf("%s.annotateSource: is not supported for sources in remote clusters", parent))
+	}
+	if c.Notifications != nil {
+		messages = append(messages, c.Notifications.validate(fmt.Sprintf("%s.notifications", parent))...)
+	}
+	if c.PollInterval != nil && c.PollInterval.Duration <= 0 {
+		messages = append(messages, fmt.Sprintf("%s.pollInterval: must be positive", parent))
+	}
+	for i, key := range c.IgnoreTargetKeys {
+		if len(key) == 0 {
+			messages = append(messages, fmt.Sprintf("%s.ignoreTargetKeys[%d]: must not be empty", parent, i))
+		}
+	}
+	if c.Conversion != nil {
+		messages = append(messages, c.Conversion.validate(fmt.Sprintf("%s.conversion", parent))...)
+	}
+	if c.TargetType != "" && c.Conversion != nil {
+		messages = append(messages, fmt.Sprintf("%s.targetType: cannot be combined with conversion, which determines the type of the target", parent))
+	}
+	if c.SuffixSourceNamespace {
+		for _, msg := range validation.IsDNS1123Subdomain(c.To.Name) {
+			messages = append(messages, fmt.Sprintf("%s.to.name: suffixed with the source namespace as %q: %s", parent, c.To.Name, msg))
+		}
+	}
+	if c.UpdateStrategy != "" && c.UpdateStrategy != UpdateStrategyUpdate && c.UpdateStrategy != UpdateStrategyRecreate {
+		messages = append(messages, fmt.Sprintf("%s.updateStrategy: must be one of %s or %s", parent, UpdateStrategyUpdate, UpdateStrategyRecreate))
+	}
+	if c.ImmutableTarget && c.UpdateStrategy != UpdateStrategyRecreate {
+		messages = append(messages, fmt.Sprintf("%s.immutableTarget: requires updateStrategy: %s, as immutable targets cannot be changed in place", parent, UpdateStrategyRecreate))
+	}
+	if !validKeyPattern.MatchString(c.TargetKeyPrefix) {
+		messages = append(messages, fmt.Sprintf("%s.targetKeyPrefix: may only contain alphanumerics, '-', '_' and '.'", parent))
+	}
+	if !validKeyPattern.MatchString(c.StripSourceKeyPrefix) {
+		messages = append(messages, fmt.Sprintf("%s.stripSourceKeyPrefix: may only contain alphanumerics, '-', '_' and '.'", parent))
+	}
+	if c.Keys != nil {
+		messages = append(messages, c.Keys.validate(fmt.Sprintf("%s.keys", parent))...)
+	}
+	if len(c.TargetKeyPrefix) != 0 && c.Conversion != nil && c.Conversion.Type == ConversionDockerConfigJSON {
+		messages = append(messages, fmt.Sprintf("%s.targetKeyPrefix: cannot be used when converting to %s, which requires the .dockerconfigjson key", parent, ConversionDockerConfigJSON))
+	}
+	messages = append(messages, validateLabels(c.Labels, fmt.Sprintf("%s.labels", parent))...)
+	messages = append(messages, validateAnnotations(c.Annotations, fmt.Sprintf("%s.annotations", parent))...)
+	if c.CopyMetadata != nil {
+		messages = append(messages, c.CopyMetadata.validate(fmt.Sprintf("%s.copyMetadata", parent))...)
+	}
+	for i, t := range c.Transforms {
+		if _, err := transform.New(t.Name, t.Config); err != nil {
+			messages = append(messages, fmt.Sprintf("%s.transforms[%d]: %v", parent, i, err))
+		}
+	}
+	for i, validation := range c.Validations {
+		messages = append(messages, validation.validate(fmt.Sprintf("%s.validations[%d]", parent, i))...)
+	}
+	if c.Files != nil {
+		messages = append(messages, c.Files.validate(fmt.Sprintf("%s.files", parent))...)
+	}
+	messages = append(messages, c.validateOutput(parent)...)
+	return messages
+}
+
+// validateOutput ensures that rules writing SealedSecrets or
+// ExternalSecrets only use the settings that apply to them.
+func (c *MirrorConfig) validateOutput(parent string) []string {
+	if c.ExternalSecret != nil && c.Output != OutputExternalSecret {
+		return []string{fmt.Sprintf("%s.externalSecret: only applies to output: %s", parent, OutputExternalSecret)}
+	}
+	switch c.Output {
+	case "", OutputSecret:
+		return nil
+	case OutputSealedSecret:
+		return c.validateSealedSecret(parent)
+	case OutputExternalSecret:
+		return c.validateExternalSecret(parent)
+	default:
+		return []string{fmt.Sprintf("%s.output: must be one of %s, %s or %s", parent, OutputSecret, OutputSealedSecret, OutputExternalSecret)}
+	}
+}
+
+// validateSealedSecret validates a rule writing a SealedSecret, which is
+// sealed for the cluster the controller runs in and replaced as a whole
+// on every write, so the settings that act on the data or the lifecycle
+// of the Secret do not apply.
+func (c *MirrorConfig) validateSealedSecret(parent string) []string {
+	var messages []string
+	if c.To.Cluster != "" || c.To.Provider() != "" {
+		messages = append(messages, fmt.Sprintf("%s.output: %s is only supported for targets in the cluster the controller runs in", parent, OutputSealedSecret))
+	}
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{name: "merge", set: c.Merge},
+		{name: "ignoreTargetKeys", set: len(c.IgnoreTargetKeys) != 0 || c.IgnoreTargetKeysGroup != ""},
+		{name: "propagateDeletion", set: c.PropagateDeletion},
+		{name: "updateStrategy", set: c.UpdateStrategy != ""},
+		{name: "immutableTarget", set: c.ImmutableTarget},
+	} {
+		if field.set {
+			messages = append(messages, fmt.Sprintf("%s.%s: is not supported with output: %s", parent, field.name, OutputSealedSecret))
+		}
 	}
 	return messages
 }
@@ -39,54 +664,174 @@ func (c *MirrorConfig) String() string {
 	return fmt.Sprintf("(%s -> %s)", c.From.String(), c.To.String())
 }
 
+// validKeyPattern matches strings that may be part of secret keys
+var validKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]*$`)
+
 // SecretLocation unambiguously identifies a secret on the cluster
 type SecretLocation struct {
+	// Cluster identifies the remote cluster holding this secret,
+	// if it is not held by the cluster the controller runs in
+	Cluster string `json:"cluster,omitempty"`
+
 	// Namespace identifies the namespace for this secret
 	Namespace string `json:"namespace"`
 
 	// Name identifies the secret within the namespace
 	Name string `json:"name"`
+
+	// NamePattern selects every secret in the namespace whose whole
+	// name matches the regular expression instead of a single secret.
+	// Only sources may be selected by pattern.
+	NamePattern string `json:"namePattern,omitempty"`
+
+	// NamespaceSelector selects every namespace whose labels match the
+	// label selector instead of a single namespace, e.g. `team=ci`.
+	// Only targets may be selected by namespace selector.
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+
+	// Vault identifies a secret in Vault instead of in a cluster.
+	// Targets in Vault are written with the data of the source alone.
+	Vault VaultLocation `json:"vault,omitempty"`
+	// AWSSecretsManager identifies a secret in AWS Secrets Manager
+	// instead of in a cluster. Only sources may be in AWS Secrets
+	// Manager.
+	AWSSecretsManager AWSSecretsManagerLocation `json:"awsSecretsManager,omitempty"`
+	// GCPSecretManager identifies a secret in GCP Secret Manager
+	// instead of in a cluster. Only sources may be in GCP Secret
+	// Manager.
+	GCPSecretManager GCPSecretManagerLocation `json:"gcpSecretManager,omitempty"`
+	// AzureKeyVault identifies a secret in Azure Key Vault instead of
+	// in a cluster. Only sources may be in Azure Key Vault.
+	AzureKeyVault AzureKeyVaultLocation `json:"azureKeyVault,omitempty"`
 }
 
 func (l *SecretLocation) validate(parent string) []string {
+	if l.Provider() != "" {
+		return l.validateProvider(parent)
+	}
+	messages := l.validateNamespace(parent)
+	switch {
+	case len(l.Name) == 0 && len(l.NamePattern) == 0:
+		messages = append(messages, fmt.Sprintf("%s.name: must not be empty", parent))
+	case len(l.Name) != 0 && len(l.NamePattern) != 0:
+		messages = append(messages, fmt.Sprintf("%s: name and namePattern are mutually exclusive", parent))
+	case len(l.NamePattern) != 0:
+		if _, err := regexp.Compile(l.NamePattern); err != nil {
+			messages = append(messages, fmt.Sprintf("%s.namePattern: %v", parent, err))
+		}
+	default:
+		for _, msg := range validation.IsDNS1123Subdomain(l.Name) {
+			messages = append(messages, fmt.Sprintf("%s.name: invalid name %q: %s", parent, l.Name, msg))
+		}
+	}
+	return messages
+}
+
+// validateNamespace validates the location without its name.
+func (l *SecretLocation) validateNamespace(parent string) []string {
 	var messages []string
-	if len(l.Namespace) == 0 {
+	switch {
+	case len(l.Namespace) == 0 && len(l.NamespaceSelector) == 0:
 		messages = append(messages, fmt.Sprintf("%s.namespace: must not be empty", parent))
+	case len(l.Namespace) != 0 && len(l.NamespaceSelector) != 0:
+		messages = append(messages, fmt.Sprintf("%s: namespace and namespaceSelector are mutually exclusive", parent))
+	case len(l.NamespaceSelector) != 0:
+		if _, err := labels.Parse(l.NamespaceSelector); err != nil {
+			messages = append(messages, fmt.Sprintf("%s.namespaceSelector: %v", parent, err))
+		}
+	default:
+		for _, msg := range validation.IsDNS1123Label(l.Namespace) {
+			messages = append(messages, fmt.Sprintf("%s.namespace: invalid name %q: %s", parent, l.Namespace, msg))
+		}
 	}
-	if len(l.Name) == 0 {
-		messages = append(messages, fmt.Sprintf("%s.name: must not be empty", parent))
+	if strings.ContainsAny(l.Cluster, ":/") {
+		messages = append(messages, fmt.Sprintf("%s.cluster: must not contain ':' or '/'", parent))
 	}
 	return messages
 }
 
+// String formats the location as namespace/name, prefixed
+// with the cluster and a colon for remote secrets. Patterns
+// and selectors are enclosed in parentheses, which names
+// cannot contain. Secrets in stores outside of Kubernetes
+// are formatted as their identifier in the store prefixed
+// with the scheme of the store.
 func (l *SecretLocation) String() string {
-	return fmt.Sprintf("%s/%s", l.Namespace, l.Name)
+	if l.Provider() != "" {
+		return l.providerString()
+	}
+	namespace, name := l.Namespace, l.Name
+	if l.NamespaceSelector != "" {
+		namespace = fmt.Sprintf("(%s)", l.NamespaceSelector)
+	}
+	if l.NamePattern != "" {
+		name = fmt.Sprintf("(%s)", l.NamePattern)
+	}
+	if l.Cluster != "" {
+		return fmt.Sprintf("%s:%s/%s", l.Cluster, namespace, name)
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
 }
 
 func (l *SecretLocation) Equals(other SecretLocation) bool {
-	return l.Namespace == other.Namespace && l.Name == other.Name
+	return l.Cluster == other.Cluster && l.Namespace == other.Namespace && l.Name == other.Name && l.Vault == other.Vault && l.AWSSecretsManager == other.AWSSecretsManager && l.GCPSecretManager == other.GCPSecretManager && l.AzureKeyVault == other.AzureKeyVault
 }
 
 // Validate ensures that the configuration is valid
 func (c *Configuration) Validate() error {
-	if len(c.Secrets) == 0 {
-		return errors.New("secret mirroring mappings are required")
+	if len(c.Secrets) == 0 && len(c.ConfigMaps) == 0 {
+		return errors.New("secret or ConfigMap mirroring mappings are required")
 	}
 
 	var messages []string
 	nodes, edges := map[SecretLocation]bool{}, map[SecretLocation][]SecretLocation{}
+	targets := map[SecretLocation][]int{}
+	directories := map[string]int{}
 	for i, mapping := range c.Secrets {
-		nodes[mapping.From] = false
+		if mapping.Files != nil {
+			// files for keys that are not mirrored are removed, so
+			// sinks must not share a directory
+			if other, shared := directories[mapping.Files.Directory]; shared {
+				messages = append(messages, fmt.Sprintf("secrets[%d].files.directory: %s is also the directory of secrets[%d]", i, mapping.Files.Directory, other))
+			}
+			directories[mapping.Files.Directory] = i
+		}
+		for _, other := range targets[mapping.To] {
+			// sources may only share a target if they are merged into it
+			if c.Secrets[other].From.Equals(mapping.From) || (c.Secrets[other].Merge && mapping.Merge) {
+				continue
+			}
+			messages = append(messages, fmt.Sprintf("secrets[%d].to: %s is also the target of secrets[%d], which mirrors from %s instead of %s; set suffixSourceNamespace, choose another name or merge both sources", i, mapping.To.String(), other, c.Secrets[other].From.String(), mapping.From.String()))
+			break
+		}
+		targets[mapping.To] = append(targets[mapping.To], i)
 		nodes[mapping.To] = false
-		if destinations, exists := edges[mapping.From]; !exists {
-			edges[mapping.From] = []SecretLocation{mapping.To}
-		} else {
-			edges[mapping.From] = append(destinations, mapping.To)
+		for _, source := range mapping.Sources() {
+			nodes[source] = false
+			if source.Equals(mapping.To) {
+				messages = append(messages, fmt.Sprintf("secrets[%d]: mirrors %s onto itself", i, source.String()))
+				continue
+			}
+			edges[source] = append(edges[source], mapping.To)
 		}
 		messages = append(messages, mapping.validate(fmt.Sprintf("secrets[%d]", i))...)
 	}
+	messages = append(messages, c.validateOverlaps()...)
+	messages = append(messages, c.validatePolicy()...)
+	messages = append(messages, c.validateExternalSecretApprovals()...)
+	messages = append(messages, c.validateConfigMaps()...)
+	if c.Defaults.Notifications != nil {
+		messages = append(messages, c.Defaults.Notifications.validate("defaults.notifications")...)
+	}
+	messages = append(messages, validateLabels(c.Defaults.Labels, "defaults.labels")...)
+	messages = append(messages, validateAnnotations(c.Defaults.Annotations, "defaults.annotations")...)
+	messages = append(messages, c.Metrics.validate("metrics")...)
+	if c.Canary != nil {
+		messages = append(messages, c.Canary.validate("canary")...)
+	}
 
 	// cycles will cause the controller to go haywire, so we forbid them
+	c.wildcardEdges(nodes, edges)
 	for _, cycle := range findCycles(nodes, edges) {
 		var cycleFormatted []string
 		for _, node := range cycle {
@@ -145,16 +890,30 @@ func findCycle(path []SecretLocation, nodes map[SecretLocation]bool, edges map[S
 }
 
 // Load loads and parses the config at path.
-func Load(configLocation string) (c *Configuration, err error) {
+func Load(configLocation string) (*Configuration, error) {
+	data, err := ioutil.ReadFile(configLocation)
+	if err != nil {
+		return nil, fmt.Errorf("error opening configuration file: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses the config, expanding and validating it.
+func Parse(data []byte) (c *Configuration, err error) {
 	// we never want config loading to take down the controller
 	defer func() {
 		if r := recover(); r != nil {
 			c, err = nil, fmt.Errorf("panic loading config: %v", r)
 		}
 	}()
-	err = yamlToConfig(configLocation, &c)
-	if err != nil {
-		return nil, err
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	if c != nil {
+		if err := c.expandGroups(); err != nil {
+			return nil, err
+		}
+		c.disambiguate()
 	}
 	if err := c.Validate(); err != nil {
 		return nil, err
@@ -162,15 +921,13 @@ func Load(configLocation string) (c *Configuration, err error) {
 	return c, nil
 }
 
-func yamlToConfig(path string, c interface{}) error {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("error opening configuration file: %v", err)
-	}
-
-	if err := yaml.Unmarshal([]byte(data), &c); err != nil {
-		return fmt.Errorf("invalid configuration: %v", err)
+// disambiguate suffixes the names of targets with the namespace of
+// their source for rules that ask for it. It must only be applied
+// once, when the configuration is loaded.
+func (c *Configuration) disambiguate() {
+	for i := range c.Secrets {
+		if mirror := &c.Secrets[i]; mirror.SuffixSourceNamespace {
+			mirror.To.Name = fmt.Sprintf("%s-%s", mirror.To.Name, mirror.From.Namespace)
+		}
 	}
-
-	return nil
 }
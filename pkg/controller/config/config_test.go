@@ -1,6 +1,13 @@
 package config
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestValidate(t *testing.T) {
 	var testCases = []struct {
@@ -77,6 +84,436 @@ func TestValidate(t *testing.T) {
 			}},
 			expectedErr: true,
 		},
+		{
+			name: "config mirroring a secret onto itself is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "from-ns", Name: "from-name"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a namespace that is not a DNS-1123 label is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "From_NS", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a name that is not a DNS-1123 subdomain is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to name"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with only ConfigMaps is valid",
+			config: Configuration{ConfigMaps: []ConfigMapMirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+				},
+			}},
+			expectedErr: false,
+		},
+		{
+			name: "config with ConfigMaps sharing a target is invalid",
+			config: Configuration{ConfigMaps: []ConfigMapMirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+				},
+				{
+					From: SecretLocation{Namespace: "other-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a ConfigMap in a remote cluster is invalid",
+			config: Configuration{ConfigMaps: []ConfigMapMirrorConfig{
+				{
+					From: SecretLocation{Cluster: "master", Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a remote source is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Cluster: "master", Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+				},
+			}},
+			expectedErr: false,
+		},
+		{
+			name: "config with a remote target is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Cluster: "master", Namespace: "to-ns", Name: "to-name"},
+				},
+			}},
+			expectedErr: false,
+		},
+		{
+			name: "config injecting checksums for a remote target is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:           SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:             SecretLocation{Cluster: "master", Namespace: "to-ns", Name: "to-name"},
+					InjectChecksum: true,
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a malformed cluster is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Cluster: "master:1", Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with valid notifications is valid",
+			config: Configuration{
+				Secrets: []MirrorConfig{
+					{
+						From:          SecretLocation{Namespace: "from-ns", Name: "from-name"},
+						To:            SecretLocation{Namespace: "to-ns", Name: "to-name"},
+						Notifications: &Notifications{SlackChannel: "#team", Emails: []string{"team@example.com"}},
+					},
+				},
+				Defaults: Defaults{Notifications: &Notifications{WebhookURL: "https://example.com/hook"}},
+			},
+			expectedErr: false,
+		},
+		{
+			name: "config with empty notifications is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:          SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:            SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Notifications: &Notifications{},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a conversion is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:       SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:         SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Conversion: &Conversion{Type: ConversionOpaque, Registry: "quay.io"},
+				},
+			}},
+			expectedErr: false,
+		},
+		{
+			name: "config with an unknown conversion type is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:       SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:         SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Conversion: &Conversion{Type: "kubernetes.io/tls"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with clashing conversion keys is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:       SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:         SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Conversion: &Conversion{Type: ConversionDockerConfigJSON, UsernameKey: "password"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with validations is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:        SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:          SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Validations: []KeyValidation{{Key: "tls.crt", Validators: []string{ValidatorNonEmpty, ValidatorPEM}}},
+				},
+			}},
+			expectedErr: false,
+		},
+		{
+			name: "config with an unknown validator is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:        SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:          SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Validations: []KeyValidation{{Key: "tls.crt", Validators: []string{"yaml"}}},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with an unknown update strategy is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:           SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:             SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					UpdateStrategy: "Replace",
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with an unknown metrics aggregation is invalid",
+			config: Configuration{
+				Secrets: []MirrorConfig{
+					{
+						From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+						To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					},
+				},
+				Metrics: Metrics{Aggregation: "cluster"},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "config with transforms is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:       SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:         SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Transforms: []TransformConfig{{Name: "rename", Config: []byte(`{"keys":{"prod_token":"token"}}`)}},
+				},
+			}},
+			expectedErr: false,
+		},
+		{
+			name: "config with a misconfigured transform is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:       SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:         SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Transforms: []TransformConfig{{Name: "rename"}},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a malformed target key prefix is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:            SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:              SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					TargetKeyPrefix: "prod/",
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a key filter is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Keys: &KeyFilter{Include: []string{"token"}, Exclude: []string{"ca.crt"}},
+				},
+			}},
+		},
+		{
+			name: "config with an empty key filter is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Keys: &KeyFilter{},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a malformed included key is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Keys: &KeyFilter{Include: []string{"prod/token"}},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a malformed label is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:   SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:     SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Labels: map[string]string{"team": "not a valid value"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with a malformed default label is invalid",
+			config: Configuration{
+				Secrets: []MirrorConfig{
+					{
+						From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+						To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					},
+				},
+				Defaults: Defaults{Labels: map[string]string{"-team": "ci"}},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "config with a reserved annotation is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:        SecretLocation{Namespace: "from-ns", Name: "from-name"},
+					To:          SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					Annotations: map[string]string{"ci.openshift.io/mirror-last-applied-hash": "forged"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with an immutable target that is updated in place is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:            SecretLocation{Namespace: "a", Name: "a"},
+					To:              SecretLocation{Namespace: "b", Name: "b"},
+					ImmutableTarget: true,
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with an immutable target that is recreated is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:            SecretLocation{Namespace: "a", Name: "a"},
+					To:              SecretLocation{Namespace: "b", Name: "b"},
+					ImmutableTarget: true,
+					UpdateStrategy:  UpdateStrategyRecreate,
+				},
+			}},
+		},
+		{
+			name: "config writing a SealedSecret is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:   SecretLocation{Namespace: "a", Name: "a"},
+					To:     SecretLocation{Namespace: "b", Name: "b"},
+					Output: OutputSealedSecret,
+				},
+			}},
+		},
+		{
+			name: "config with an unknown output is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:   SecretLocation{Namespace: "a", Name: "a"},
+					To:     SecretLocation{Namespace: "b", Name: "b"},
+					Output: "ConfigMap",
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config merging into a SealedSecret is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:   SecretLocation{Namespace: "a", Name: "a"},
+					To:     SecretLocation{Namespace: "b", Name: "b"},
+					Output: OutputSealedSecret,
+					Merge:  true,
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config writing a SealedSecret to a remote cluster is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:   SecretLocation{Namespace: "a", Name: "a"},
+					To:     SecretLocation{Cluster: "build01", Namespace: "b", Name: "b"},
+					Output: OutputSealedSecret,
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with different sources mirrored to one target is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From: SecretLocation{Namespace: "team-a", Name: "token"},
+					To:   SecretLocation{Namespace: "shared", Name: "token"},
+				},
+				{
+					From: SecretLocation{Namespace: "team-b", Name: "token"},
+					To:   SecretLocation{Namespace: "shared", Name: "token"},
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with different sources merged into one target is valid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:            SecretLocation{Namespace: "team-a", Name: "token"},
+					To:              SecretLocation{Namespace: "shared", Name: "tokens"},
+					Merge:           true,
+					TargetKeyPrefix: "team-a.",
+				},
+				{
+					From:            SecretLocation{Namespace: "team-b", Name: "token"},
+					To:              SecretLocation{Namespace: "shared", Name: "tokens"},
+					Merge:           true,
+					TargetKeyPrefix: "team-b.",
+				},
+			}},
+		},
+		{
+			name: "config with a suffixed target name that is too long is invalid",
+			config: Configuration{Secrets: []MirrorConfig{
+				{
+					From:                  SecretLocation{Namespace: "team-a", Name: "token"},
+					To:                    SecretLocation{Namespace: "shared", Name: strings.Repeat("a", 250) + "-team-a"},
+					SuffixSourceNamespace: true,
+				},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "config with invalid default notifications is invalid",
+			config: Configuration{
+				Secrets: []MirrorConfig{
+					{
+						From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+						To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+					},
+				},
+				Defaults: Defaults{Notifications: &Notifications{WebhookURL: "example.com/hook", Emails: []string{"not an address"}}},
+			},
+			expectedErr: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -91,3 +528,159 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestNotificationsFor(t *testing.T) {
+	defaults := &Notifications{SlackChannel: "#central"}
+	override := &Notifications{SlackChannel: "#team"}
+	var testCases = []struct {
+		name     string
+		config   Configuration
+		mirror   MirrorConfig
+		expected *Notifications
+	}{
+		{
+			name:   "nothing configured routes nowhere",
+			config: Configuration{},
+			mirror: MirrorConfig{},
+		},
+		{
+			name:     "defaults are used when the mirror has no routing",
+			config:   Configuration{Defaults: Defaults{Notifications: defaults}},
+			mirror:   MirrorConfig{},
+			expected: defaults,
+		},
+		{
+			name:     "mirror routing overrides defaults",
+			config:   Configuration{Defaults: Defaults{Notifications: defaults}},
+			mirror:   MirrorConfig{Notifications: override},
+			expected: override,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := testCase.config.NotificationsFor(testCase.mirror); actual != testCase.expected {
+				t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var testCases = []struct {
+		name                string
+		defaults            Defaults
+		mirror              MirrorConfig
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name: "nothing configured sets no metadata",
+		},
+		{
+			name:                "defaults are used",
+			defaults:            Defaults{Labels: map[string]string{"team": "ci"}, Annotations: map[string]string{"example.com/ttl": "24h"}},
+			expectedLabels:      map[string]string{"team": "ci"},
+			expectedAnnotations: map[string]string{"example.com/ttl": "24h"},
+		},
+		{
+			name:     "mirror metadata overrides defaults",
+			defaults: Defaults{Labels: map[string]string{"team": "ci", "classification": "internal"}, Annotations: map[string]string{"example.com/ttl": "24h"}},
+			mirror: MirrorConfig{
+				Labels:      map[string]string{"team": "release"},
+				Annotations: map[string]string{"argocd.argoproj.io/compare-options": "IgnoreExtraneous"},
+			},
+			expectedLabels:      map[string]string{"team": "release", "classification": "internal"},
+			expectedAnnotations: map[string]string{"example.com/ttl": "24h", "argocd.argoproj.io/compare-options": "IgnoreExtraneous"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Defaults: testCase.defaults}
+			resolved := configuration.Resolve(testCase.mirror)
+			if !reflect.DeepEqual(resolved.Labels, testCase.expectedLabels) {
+				t.Errorf("%s: expected labels %v, got %v", testCase.name, testCase.expectedLabels, resolved.Labels)
+			}
+			if !reflect.DeepEqual(resolved.Annotations, testCase.expectedAnnotations) {
+				t.Errorf("%s: expected annotations %v, got %v", testCase.name, testCase.expectedAnnotations, resolved.Annotations)
+			}
+		})
+	}
+}
+
+func TestLoadDisambiguatesTargets(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		config          string
+		expectedTargets []string
+		expectedErr     bool
+	}{
+		{
+			name: "suffixed targets do not collide",
+			config: `secrets:
+- from: {namespace: team-a, name: token}
+  to: {namespace: shared, name: token}
+  suffixSourceNamespace: true
+- from: {namespace: team-b, name: token}
+  to: {namespace: shared, name: token}
+  suffixSourceNamespace: true
+`,
+			expectedTargets: []string{"shared/token-team-a", "shared/token-team-b"},
+		},
+		{
+			name: "collisions that remain after suffixing are detected",
+			config: `secrets:
+- from: {namespace: team-a, name: token}
+  to: {namespace: shared, name: token}
+  suffixSourceNamespace: true
+- from: {namespace: team-b, name: token}
+  to: {namespace: shared, name: token-team-a}
+`,
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "config.yaml")
+			if err := ioutil.WriteFile(path, []byte(testCase.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			c, err := Load(path)
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if testCase.expectedErr {
+				return
+			}
+			var targets []string
+			for _, mirror := range c.Secrets {
+				targets = append(targets, mirror.To.String())
+			}
+			if !reflect.DeepEqual(targets, testCase.expectedTargets) {
+				t.Errorf("%s: expected targets %v, got %v", testCase.name, testCase.expectedTargets, targets)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	configuration := func(name string) *Configuration {
+		return &Configuration{Secrets: []MirrorConfig{
+			{From: SecretLocation{Namespace: "a", Name: "a"}, To: SecretLocation{Namespace: "b", Name: name}},
+		}}
+	}
+	if configuration("b").Hash() != configuration("b").Hash() {
+		t.Error("expected equal configurations to have the same hash")
+	}
+	if configuration("b").Hash() == configuration("c").Hash() {
+		t.Error("expected different configurations to have different hashes")
+	}
+}
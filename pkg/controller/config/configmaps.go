@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConfigMapMirrorConfig mirrors a ConfigMap the way MirrorConfig mirrors
+// a secret. Only the settings that apply to ConfigMaps are supported.
+type ConfigMapMirrorConfig struct {
+	From SecretLocation `json:"from"`
+	To   SecretLocation `json:"to"`
+
+	// Merge preserves keys that other parties added to the target
+	Merge bool `json:"merge,omitempty"`
+
+	// IgnoreTargetKeys are keys in the target that the
+	// controller never modifies or deletes
+	IgnoreTargetKeys []string `json:"ignoreTargetKeys,omitempty"`
+
+	// Labels are set on the target
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are set on the target
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (c *ConfigMapMirrorConfig) String() string {
+	return fmt.Sprintf("(configmap %s -> %s)", c.From.String(), c.To.String())
+}
+
+func (c *ConfigMapMirrorConfig) validate(parent string) []string {
+	var messages []string
+	messages = append(messages, c.From.validate(fmt.Sprintf("%s.from", parent))...)
+	messages = append(messages, c.To.validate(fmt.Sprintf("%s.to", parent))...)
+	if len(c.From.NamePattern) != 0 || len(c.To.NamePattern) != 0 {
+		messages = append(messages, fmt.Sprintf("%s: selecting ConfigMaps by namePattern is not supported", parent))
+	}
+	if len(c.From.NamespaceSelector) != 0 || len(c.To.NamespaceSelector) != 0 {
+		messages = append(messages, fmt.Sprintf("%s: selecting namespaces by namespaceSelector is not supported for ConfigMaps", parent))
+	}
+	if len(c.From.Cluster) != 0 || len(c.To.Cluster) != 0 {
+		messages = append(messages, fmt.Sprintf("%s: mirroring ConfigMaps between clusters is not supported", parent))
+	}
+	for i, key := range c.IgnoreTargetKeys {
+		if len(key) == 0 {
+			messages = append(messages, fmt.Sprintf("%s.ignoreTargetKeys[%d]: must not be empty", parent, i))
+		}
+	}
+	messages = append(messages, validateLabels(c.Labels, fmt.Sprintf("%s.labels", parent))...)
+	messages = append(messages, validateAnnotations(c.Annotations, fmt.Sprintf("%s.annotations", parent))...)
+	return messages
+}
+
+// validateConfigMaps validates the ConfigMap mirroring configurations
+// on their own, as they never interact with secrets.
+func (c *Configuration) validateConfigMaps() []string {
+	var messages []string
+	nodes, edges := map[SecretLocation]bool{}, map[SecretLocation][]SecretLocation{}
+	targets := map[SecretLocation]int{}
+	for i, mapping := range c.ConfigMaps {
+		if other, taken := targets[mapping.To]; taken && !c.ConfigMaps[other].From.Equals(mapping.From) {
+			messages = append(messages, fmt.Sprintf("configMaps[%d].to: %s is also the target of configMaps[%d], which mirrors from %s instead of %s", i, mapping.To.String(), other, c.ConfigMaps[other].From.String(), mapping.From.String()))
+		}
+		targets[mapping.To] = i
+		nodes[mapping.From] = false
+		nodes[mapping.To] = false
+		edges[mapping.From] = append(edges[mapping.From], mapping.To)
+		messages = append(messages, mapping.validate(fmt.Sprintf("configMaps[%d]", i))...)
+	}
+	for _, cycle := range findCycles(nodes, edges) {
+		var cycleFormatted []string
+		for _, node := range cycle {
+			cycleFormatted = append(cycleFormatted, node.String())
+		}
+		messages = append(messages, fmt.Sprintf("ConfigMap mirroring mapping contains the cycle [%s], which is forbidden", strings.Join(cycleFormatted, " -> ")))
+	}
+	return messages
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// excludedMetadataPrefixes prefix the labels and annotations that are never
+// copied from a source: kubectl bookkeeping, which describes the source
+// object only, and those of the controller, which would make a target
+// look held, approved or sourced from elsewhere.
+var excludedMetadataPrefixes = []string{"kubectl.kubernetes.io/", "ci.openshift.io/", reservedLabel}
+
+// CopyMetadata selects labels and annotations of the source that are
+// copied onto the target, by glob patterns matching their keys, in
+// which `*` matches any characters including the `/` of a prefix.
+type CopyMetadata struct {
+	// Labels selects the labels of the source to copy, e.g. `*` for all
+	Labels []string `json:"labels,omitempty"`
+
+	// Annotations selects the annotations of the source to copy
+	Annotations []string `json:"annotations,omitempty"`
+
+	// Exclude selects labels and annotations that are not copied,
+	// in addition to those of kubectl and the controller
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// CopiesLabel determines if the label of the source is copied.
+func (c *CopyMetadata) CopiesLabel(key string) bool {
+	return c != nil && c.copies(c.Labels, key)
+}
+
+// CopiesAnnotation determines if the annotation of the source is copied.
+func (c *CopyMetadata) CopiesAnnotation(key string) bool {
+	return c != nil && c.copies(c.Annotations, key)
+}
+
+func (c *CopyMetadata) copies(patterns []string, key string) bool {
+	for _, prefix := range excludedMetadataPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	if matchesAny(c.Exclude, key) {
+		return false
+	}
+	return matchesAny(patterns, key)
+}
+
+// matchesAny determines if any glob pattern matches the key.
+func matchesAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		expression := strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+		if matchesName(expression, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CopyMetadata) validate(parent string) []string {
+	var messages []string
+	if len(c.Labels) == 0 && len(c.Annotations) == 0 {
+		messages = append(messages, fmt.Sprintf("%s: must select labels or annotations", parent))
+	}
+	for _, field := range []struct {
+		name     string
+		patterns []string
+	}{{"labels", c.Labels}, {"annotations", c.Annotations}, {"exclude", c.Exclude}} {
+		for i, pattern := range field.patterns {
+			if len(pattern) == 0 {
+				messages = append(messages, fmt.Sprintf("%s.%s[%d]: must not be empty", parent, field.name, i))
+			}
+		}
+	}
+	return messages
+}
@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestCopyMetadata(t *testing.T) {
+	copyMetadata := &CopyMetadata{
+		Labels:      []string{"team", "app.kubernetes.io/*"},
+		Annotations: []string{"*"},
+		Exclude:     []string{"*.internal/*"},
+	}
+	var testCases = []struct {
+		key               string
+		label, annotation bool
+	}{
+		{key: "team", label: true, annotation: true},
+		{key: "app.kubernetes.io/name", label: true, annotation: true},
+		{key: "owner", annotation: true},
+		{key: "example.internal/secret"},
+		{key: "kubectl.kubernetes.io/last-applied-configuration"},
+		{key: "ci.openshift.io/do-not-overwrite"},
+		{key: "app.kubernetes.io/managed-by"},
+	}
+	for _, testCase := range testCases {
+		if actual := copyMetadata.CopiesLabel(testCase.key); actual != testCase.label {
+			t.Errorf("%s: expected the label to be copied %t, got %t", testCase.key, testCase.label, actual)
+		}
+		if actual := copyMetadata.CopiesAnnotation(testCase.key); actual != testCase.annotation {
+			t.Errorf("%s: expected the annotation to be copied %t, got %t", testCase.key, testCase.annotation, actual)
+		}
+	}
+	var unset *CopyMetadata
+	if unset.CopiesLabel("team") || unset.CopiesAnnotation("team") {
+		t.Error("expected nothing to be copied without copyMetadata")
+	}
+}
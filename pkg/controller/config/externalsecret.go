@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kinds of stores of the External Secrets Operator
+const (
+	// StoreKindSecretStore is a store in the target namespace
+	StoreKindSecretStore = "SecretStore"
+	// StoreKindClusterSecretStore is a store serving every namespace
+	StoreKindClusterSecretStore = "ClusterSecretStore"
+)
+
+// ExternalSecretConfig determines the store the External Secrets
+// Operator fetches the source from for rules with output: ExternalSecret.
+// The store must read from where the source is, e.g. from the namespace
+// of the source through the kubernetes provider, or from the engine
+// mounted in Vault.
+type ExternalSecretConfig struct {
+	// Store is the name of the store
+	Store string `json:"store"`
+
+	// StoreKind is either StoreKindSecretStore or
+	// StoreKindClusterSecretStore, the default
+	StoreKind string `json:"storeKind,omitempty"`
+
+	// RefreshInterval is how often the operator fetches the source,
+	// leaving it to the operator if unset
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// Kind returns the kind of the store.
+func (c *ExternalSecretConfig) Kind() string {
+	if c.StoreKind == "" {
+		return StoreKindClusterSecretStore
+	}
+	return c.StoreKind
+}
+
+// RemoteKey returns the key and version, if pinned, identifying the
+// secret at the location in a store of the External Secrets Operator
+// that reads from where the secret is.
+func (l *SecretLocation) RemoteKey() (string, string) {
+	switch l.Provider() {
+	case ProviderVault:
+		_, path := l.Vault.MountAndPath()
+		return path, ""
+	case ProviderAWSSecretsManager:
+		return l.AWSSecretsManager.ARN, ""
+	case ProviderGCPSecretManager:
+		// stores are bound to a project
+		name := l.GCPSecretManager.Name
+		return name[strings.LastIndex(name, "/")+1:], l.GCPSecretManager.Version
+	case ProviderAzureKeyVault:
+		// stores are bound to a key vault
+		return l.AzureKeyVault.Name, l.AzureKeyVault.Version
+	default:
+		return l.Name, ""
+	}
+}
+
+// validateExternalSecret validates a rule writing an ExternalSecret. The
+// operator writes the target from the source as it is, so the settings
+// that act on the data or rely on the controller writing the target do
+// not apply.
+func (c *MirrorConfig) validateExternalSecret(parent string) []string {
+	var messages []string
+	if c.ExternalSecret == nil {
+		messages = append(messages, fmt.Sprintf("%s.externalSecret: must be set with output: %s", parent, OutputExternalSecret))
+	} else {
+		if c.ExternalSecret.Store == "" {
+			messages = append(messages, fmt.Sprintf("%s.externalSecret.store: must not be empty", parent))
+		}
+		if kind := c.ExternalSecret.Kind(); kind != StoreKindSecretStore && kind != StoreKindClusterSecretStore {
+			messages = append(messages, fmt.Sprintf("%s.externalSecret.storeKind: must be one of %s or %s", parent, StoreKindSecretStore, StoreKindClusterSecretStore))
+		}
+		if c.ExternalSecret.RefreshInterval != nil && c.ExternalSecret.RefreshInterval.Duration <= 0 {
+			messages = append(messages, fmt.Sprintf("%s.externalSecret.refreshInterval: must be positive", parent))
+		}
+	}
+	if c.To.Cluster != "" || c.To.Provider() != "" {
+		messages = append(messages, fmt.Sprintf("%s.output: %s is only supported for targets in the cluster the controller runs in", parent, OutputExternalSecret))
+	}
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{name: "mergeFrom", set: len(c.MergeFrom) != 0},
+		{name: "merge", set: c.Merge},
+		{name: "ignoreTargetKeys", set: len(c.IgnoreTargetKeys) != 0 || c.IgnoreTargetKeysGroup != ""},
+		{name: "propagateDeletion", set: c.PropagateDeletion},
+		{name: "annotateSource", set: c.AnnotateSource},
+		{name: "pollInterval", set: c.PollInterval != nil},
+		{name: "conversion", set: c.Conversion != nil},
+		{name: "validations", set: len(c.Validations) != 0},
+		{name: "normalizePEM", set: c.NormalizePEM},
+		{name: "injectChecksum", set: c.InjectChecksum},
+		{name: "updateStrategy", set: c.UpdateStrategy != ""},
+		{name: "transforms", set: len(c.Transforms) != 0},
+		{name: "targetKeyPrefix", set: c.TargetKeyPrefix != ""},
+		{name: "stripSourceKeyPrefix", set: c.StripSourceKeyPrefix != ""},
+		{name: "keys", set: c.Keys != nil},
+		{name: "copyMetadata", set: c.CopyMetadata != nil},
+		{name: "immutableTarget", set: c.ImmutableTarget},
+		{name: "requireApproval", set: c.RequireApproval},
+		{name: "files", set: c.Files != nil},
+	} {
+		if field.set {
+			messages = append(messages, fmt.Sprintf("%s.%s: is not supported with output: %s", parent, field.name, OutputExternalSecret))
+		}
+	}
+	return messages
+}
+
+// validateExternalSecretApprovals ensures that no rule writes an
+// ExternalSecret into a namespace where changes require approval, as the
+// operator writes the target without waiting for one.
+func (c *Configuration) validateExternalSecretApprovals() []string {
+	var messages []string
+	for i, mapping := range c.Secrets {
+		if mapping.Output != OutputExternalSecret {
+			continue
+		}
+		for _, namespace := range c.ApprovalNamespaces {
+			if mapping.To.Namespace == namespace {
+				messages = append(messages, fmt.Sprintf("secrets[%d].output: %s is not supported in %s, where changes require approval", i, OutputExternalSecret, namespace))
+			}
+		}
+	}
+	return messages
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateExternalSecret(t *testing.T) {
+	from := SecretLocation{Namespace: "ci", Name: "registry"}
+	to := SecretLocation{Namespace: "team", Name: "registry"}
+	var testCases = []struct {
+		name               string
+		mirror             MirrorConfig
+		approvalNamespaces []string
+		expectedErr        string
+	}{
+		{
+			name:   "ExternalSecret from a cluster store",
+			mirror: MirrorConfig{From: from, To: to, Output: OutputExternalSecret, ExternalSecret: &ExternalSecretConfig{Store: "ci"}},
+		},
+		{
+			name: "ExternalSecret from a namespaced store refreshed hourly",
+			mirror: MirrorConfig{From: from, To: to, Output: OutputExternalSecret, ExternalSecret: &ExternalSecretConfig{
+				Store: "ci", StoreKind: StoreKindSecretStore, RefreshInterval: &metav1.Duration{Duration: time.Hour},
+			}},
+		},
+		{
+			name: "ExternalSecret from Vault",
+			mirror: MirrorConfig{
+				From: SecretLocation{Vault: VaultLocation{Path: "secret/ci/registry"}}, To: to,
+				Output: OutputExternalSecret, ExternalSecret: &ExternalSecretConfig{Store: "vault"},
+			},
+		},
+		{
+			name:        "ExternalSecret without a store",
+			mirror:      MirrorConfig{From: from, To: to, Output: OutputExternalSecret},
+			expectedErr: "externalSecret: must be set",
+		},
+		{
+			name:        "ExternalSecret from an unknown kind of store",
+			mirror:      MirrorConfig{From: from, To: to, Output: OutputExternalSecret, ExternalSecret: &ExternalSecretConfig{Store: "ci", StoreKind: "Vault"}},
+			expectedErr: "externalSecret.storeKind",
+		},
+		{
+			name:        "ExternalSecret in a remote cluster",
+			mirror:      MirrorConfig{From: from, To: SecretLocation{Cluster: "build01", Namespace: "team", Name: "registry"}, Output: OutputExternalSecret, ExternalSecret: &ExternalSecretConfig{Store: "ci"}},
+			expectedErr: "output: ExternalSecret is only supported",
+		},
+		{
+			name:        "ExternalSecret filtering keys",
+			mirror:      MirrorConfig{From: from, To: to, Output: OutputExternalSecret, ExternalSecret: &ExternalSecretConfig{Store: "ci"}, Keys: &KeyFilter{Include: []string{"token"}}},
+			expectedErr: "keys: is not supported with output: ExternalSecret",
+		},
+		{
+			name:               "ExternalSecret in a namespace requiring approval",
+			mirror:             MirrorConfig{From: from, To: to, Output: OutputExternalSecret, ExternalSecret: &ExternalSecretConfig{Store: "ci"}},
+			approvalNamespaces: []string{"team"},
+			expectedErr:        "output: ExternalSecret is not supported in team, where changes require approval",
+		},
+		{
+			name:        "store without output: ExternalSecret",
+			mirror:      MirrorConfig{From: from, To: to, ExternalSecret: &ExternalSecretConfig{Store: "ci"}},
+			expectedErr: "externalSecret: only applies to output: ExternalSecret",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}, ApprovalNamespaces: testCase.approvalNamespaces}
+			err := configuration.Validate()
+			if testCase.expectedErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Errorf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRemoteKey(t *testing.T) {
+	var testCases = []struct {
+		location        SecretLocation
+		expectedKey     string
+		expectedVersion string
+	}{
+		{
+			location:    SecretLocation{Namespace: "ci", Name: "registry"},
+			expectedKey: "registry",
+		},
+		{
+			location:    SecretLocation{Vault: VaultLocation{Path: "secret/ci/registry"}},
+			expectedKey: "ci/registry",
+		},
+		{
+			location:    SecretLocation{AWSSecretsManager: AWSSecretsManagerLocation{ARN: "arn:aws:secretsmanager:us-east-1:123456789012:secret:ci/registry-AbCdEf"}},
+			expectedKey: "arn:aws:secretsmanager:us-east-1:123456789012:secret:ci/registry-AbCdEf",
+		},
+		{
+			location:        SecretLocation{GCPSecretManager: GCPSecretManagerLocation{Name: "projects/ci/secrets/registry", Version: "3"}},
+			expectedKey:     "registry",
+			expectedVersion: "3",
+		},
+		{
+			location:    SecretLocation{AzureKeyVault: AzureKeyVaultLocation{Vault: "ci-secrets", Name: "registry"}},
+			expectedKey: "registry",
+		},
+	}
+	for _, testCase := range testCases {
+		key, version := testCase.location.RemoteKey()
+		if key != testCase.expectedKey || version != testCase.expectedVersion {
+			t.Errorf("%s: expected key %q and version %q, got %q and %q", testCase.location.String(), testCase.expectedKey, testCase.expectedVersion, key, version)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultFileMode is the mode of files written by
+// file sinks that do not configure a mode
+const DefaultFileMode os.FileMode = 0600
+
+// FileSink writes every key of the mirrored data to a file of the
+// same name in a directory on the local filesystem of the controller,
+// e.g. for agents running next to it that cannot read secrets.
+type FileSink struct {
+	// Directory is relative to the file sink directory of the controller
+	// and holds nothing but the files of this sink; files for keys that
+	// are not mirrored anymore are removed from it
+	Directory string `json:"directory"`
+
+	// Mode of the files, in octal, defaulting to 0600
+	Mode string `json:"mode,omitempty"`
+
+	// SkipTarget only writes the files, not the target secret
+	SkipTarget bool `json:"skipTarget,omitempty"`
+}
+
+// FileMode returns the mode of the files written by the sink.
+func (s *FileSink) FileMode() os.FileMode {
+	if s.Mode == "" {
+		return DefaultFileMode
+	}
+	// the mode is validated when the configuration is loaded
+	mode, _ := strconv.ParseUint(s.Mode, 8, 32)
+	return os.FileMode(mode)
+}
+
+func (s *FileSink) validate(parent string) []string {
+	var messages []string
+	if s.Directory == "" {
+		messages = append(messages, fmt.Sprintf("%s.directory: must not be empty", parent))
+	} else if filepath.IsAbs(s.Directory) || s.Directory != filepath.Clean(s.Directory) || s.Directory == "." || strings.HasPrefix(s.Directory, "..") {
+		messages = append(messages, fmt.Sprintf("%s.directory: must be a clean relative path within the file sink directory", parent))
+	}
+	if s.Mode != "" {
+		if mode, err := strconv.ParseUint(s.Mode, 8, 32); err != nil || mode > 0777 {
+			messages = append(messages, fmt.Sprintf("%s.mode: must be an octal file mode no greater than 0777", parent))
+		}
+	}
+	return messages
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		sink         FileSink
+		expectedMode os.FileMode
+		expectedErr  bool
+	}{
+		{
+			name:         "default mode",
+			sink:         FileSink{Directory: "agent"},
+			expectedMode: 0600,
+		},
+		{
+			name:         "configured mode",
+			sink:         FileSink{Directory: "agents/build", Mode: "0444"},
+			expectedMode: 0444,
+		},
+		{
+			name:        "no directory",
+			sink:        FileSink{},
+			expectedErr: true,
+		},
+		{
+			name:        "absolute directory",
+			sink:        FileSink{Directory: "/etc"},
+			expectedErr: true,
+		},
+		{
+			name:        "directory escaping the file sink directory",
+			sink:        FileSink{Directory: "../etc"},
+			expectedErr: true,
+		},
+		{
+			name:        "mode that is not octal",
+			sink:        FileSink{Directory: "agent", Mode: "rw-------"},
+			expectedErr: true,
+		},
+		{
+			name:        "mode with special bits",
+			sink:        FileSink{Directory: "agent", Mode: "4755"},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			messages := testCase.sink.validate("files")
+			if len(messages) == 0 && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if len(messages) != 0 && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got: %v", testCase.name, messages)
+			}
+			if !testCase.expectedErr && testCase.sink.FileMode() != testCase.expectedMode {
+				t.Errorf("%s: expected mode %v, got %v", testCase.name, testCase.expectedMode, testCase.sink.FileMode())
+			}
+		})
+	}
+}
+
+func TestValidateSharedFileSinkDirectory(t *testing.T) {
+	configuration := Configuration{Secrets: []MirrorConfig{
+		{From: SecretLocation{Namespace: "ci", Name: "first"}, To: SecretLocation{Namespace: "ci", Name: "first-dst"}, Files: &FileSink{Directory: "agent"}},
+		{From: SecretLocation{Namespace: "ci", Name: "second"}, To: SecretLocation{Namespace: "ci", Name: "second-dst"}, Files: &FileSink{Directory: "agent"}},
+	}}
+	if err := configuration.Validate(); err == nil {
+		t.Error("expected file sinks sharing a directory to be invalid")
+	}
+}
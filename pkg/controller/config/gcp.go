@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GCPSecretManagerScheme prefixes the names of secrets in GCP Secret
+// Manager when locations are formatted, which tells them apart from
+// secrets in clusters.
+const GCPSecretManagerScheme = "gcpsecretmanager://"
+
+// ProviderGCPSecretManager names GCP Secret Manager as the provider of
+// a location
+const ProviderGCPSecretManager = "gcpSecretManager"
+
+// GCPSecretManagerLatest is the alias of the latest version of a secret
+const GCPSecretManagerLatest = "latest"
+
+// gcpSecretName matches the resource names of secrets
+var gcpSecretName = regexp.MustCompile(`^projects/[^/]+/secrets/[a-zA-Z0-9_-]+$`)
+
+// GCPSecretManagerLocation identifies a secret in GCP Secret Manager
+// instead of a secret in a cluster.
+type GCPSecretManagerLocation struct {
+	// Name is the resource name of the secret, e.g.
+	// projects/ci/secrets/registry
+	Name string `json:"name,omitempty"`
+	// Version pins the version of the secret to mirror, e.g. 3, instead
+	// of mirroring the latest version whenever it changes.
+	Version string `json:"version,omitempty"`
+}
+
+// VersionName returns the resource name of the version to mirror.
+func (l *GCPSecretManagerLocation) VersionName() string {
+	version := l.Version
+	if version == "" {
+		version = GCPSecretManagerLatest
+	}
+	return fmt.Sprintf("%s/versions/%s", l.Name, version)
+}
+
+func (l *GCPSecretManagerLocation) validate(parent string) []string {
+	var messages []string
+	if !gcpSecretName.MatchString(l.Name) {
+		messages = append(messages, fmt.Sprintf("%s.name: must be the resource name of a secret, e.g. projects/ci/secrets/registry", parent))
+	}
+	if l.Version != "" && l.Version != GCPSecretManagerLatest {
+		if version, err := strconv.Atoi(l.Version); err != nil || version <= 0 || strings.HasPrefix(l.Version, "0") {
+			messages = append(messages, fmt.Sprintf("%s.version: must be %s or the number of a version, not %q", parent, GCPSecretManagerLatest, l.Version))
+		}
+	}
+	return messages
+}
+
+// parseGCPSecretManagerLocation parses the resource name of the version
+// of a secret as formatted by SecretLocation.String, which leaves out
+// the version if it is not set.
+func parseGCPSecretManagerLocation(formatted string) GCPSecretManagerLocation {
+	if i := strings.LastIndex(formatted, "/versions/"); i != -1 {
+		return GCPSecretManagerLocation{Name: formatted[:i], Version: formatted[i+len("/versions/"):]}
+	}
+	return GCPSecretManagerLocation{Name: formatted}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateGCPSecretManager(t *testing.T) {
+	to := SecretLocation{Namespace: "team", Name: "registry"}
+	from := func(name, version string) SecretLocation {
+		return SecretLocation{GCPSecretManager: GCPSecretManagerLocation{Name: name, Version: version}}
+	}
+	var testCases = []struct {
+		name        string
+		mirror      MirrorConfig
+		expectedErr string
+	}{
+		{
+			name:   "latest version",
+			mirror: MirrorConfig{From: from("projects/ci/secrets/registry", ""), To: to},
+		},
+		{
+			name:   "explicitly latest version",
+			mirror: MirrorConfig{From: from("projects/ci/secrets/registry", "latest"), To: to},
+		},
+		{
+			name:   "pinned version",
+			mirror: MirrorConfig{From: from("projects/ci/secrets/registry", "3"), To: to},
+		},
+		{
+			name:        "name of a version",
+			mirror:      MirrorConfig{From: from("projects/ci/secrets/registry/versions/3", ""), To: to},
+			expectedErr: "from.gcpSecretManager.name",
+		},
+		{
+			name:        "version that is no number",
+			mirror:      MirrorConfig{From: from("projects/ci/secrets/registry", "newest"), To: to},
+			expectedErr: "from.gcpSecretManager.version",
+		},
+		{
+			name:        "version zero",
+			mirror:      MirrorConfig{From: from("projects/ci/secrets/registry", "0"), To: to},
+			expectedErr: "from.gcpSecretManager.version",
+		},
+		{
+			name:        "version without a name",
+			mirror:      MirrorConfig{From: from("", "3"), To: to},
+			expectedErr: "from.gcpSecretManager.name",
+		},
+		{
+			name:        "annotated source in GCP Secret Manager",
+			mirror:      MirrorConfig{From: from("projects/ci/secrets/registry", ""), To: to, AnnotateSource: true},
+			expectedErr: "annotateSource: is not supported for sources in GCP Secret Manager",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}}
+			err := configuration.Validate()
+			if testCase.expectedErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Errorf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestGCPSecretManagerLocationSerialization(t *testing.T) {
+	var testCases = []struct {
+		location            GCPSecretManagerLocation
+		expectedString      string
+		expectedVersionName string
+	}{
+		{
+			location:            GCPSecretManagerLocation{Name: "projects/ci/secrets/registry"},
+			expectedString:      "gcpsecretmanager://projects/ci/secrets/registry",
+			expectedVersionName: "projects/ci/secrets/registry/versions/latest",
+		},
+		{
+			location:            GCPSecretManagerLocation{Name: "projects/ci/secrets/registry", Version: "3"},
+			expectedString:      "gcpsecretmanager://projects/ci/secrets/registry/versions/3",
+			expectedVersionName: "projects/ci/secrets/registry/versions/3",
+		},
+	}
+	for _, testCase := range testCases {
+		location := SecretLocation{GCPSecretManager: testCase.location}
+		if actual := location.String(); actual != testCase.expectedString {
+			t.Errorf("expected the location to be formatted as %s, got %s", testCase.expectedString, actual)
+		}
+		if actual := testCase.location.VersionName(); actual != testCase.expectedVersionName {
+			t.Errorf("expected the version to be named %s, got %s", testCase.expectedVersionName, actual)
+		}
+		if parsed, ok := ParseProviderLocation(location.String()); !ok || parsed != location {
+			t.Errorf("expected %s to be parsed, got %+v", location.String(), parsed)
+		}
+
+		raw, err := json.Marshal(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(raw), "vault") || strings.Contains(string(raw), "aws") {
+			t.Errorf("expected secrets in GCP Secret Manager to be serialized without other providers, got %s", raw)
+		}
+		var parsed SecretLocation
+		if err := json.Unmarshal(raw, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed != location {
+			t.Errorf("expected %s to survive serialization, got %+v", raw, parsed)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitTimeout bounds every Git command by default, so that a remote
+// that stops responding does not stall reloading the configuration
+const gitTimeout = 2 * time.Minute
+
+// GitRepository is a shallow clone of the Git repository that the
+// configuration is loaded from. Credentials are taken from the Git
+// configuration of the process, e.g. a credential helper or SSH key.
+type GitRepository struct {
+	// URL is the remote the repository is fetched from
+	URL string
+	// Ref is the branch, tag or commit to check out, the
+	// default branch of the remote if empty
+	Ref string
+	// Directory is where the repository is cloned to
+	Directory string
+	// Timeout bounds every Git command, defaulting to two minutes
+	Timeout time.Duration
+}
+
+// Fetch checks out the latest commit of the ref in the clone, cloning
+// the repository first if needed, and returns the SHA of the commit.
+func (r *GitRepository) Fetch() (string, error) {
+	if _, err := os.Stat(filepath.Join(r.Directory, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(r.Directory, 0755); err != nil {
+			return "", fmt.Errorf("failed to create the clone: %v", err)
+		}
+		if _, err := r.git("init", "--quiet"); err != nil {
+			return "", err
+		}
+		if _, err := r.git("remote", "add", "origin", r.URL); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("failed to check the clone: %v", err)
+	}
+	ref := r.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := r.git("fetch", "--quiet", "--depth", "1", "origin", ref); err != nil {
+		return "", err
+	}
+	if _, err := r.git("checkout", "--quiet", "--force", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	sha, err := r.git("rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// git runs a Git command in the clone, returning its output without the
+// trailing newline. Errors hold the output of the command rather than
+// its arguments, as the URL may hold credentials.
+func (r *GitRepository) git(command string, args ...string) (string, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = gitTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.Directory, command}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// helpers that git runs, e.g. git-remote-https, may hold on to the
+	// output after git is killed, so we do not wait for them for long
+	cmd.WaitDelay = time.Second
+	// never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("git %s did not finish within %s", command, timeout)
+		}
+		return "", fmt.Errorf("git %s failed: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// LoadGit fetches the repository and loads the configuration from
+// the path within it, recording the commit it was loaded from.
+func LoadGit(repository *GitRepository, path string) (*Configuration, error) {
+	sha, err := repository.Fetch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the configuration: %v", err)
+	}
+	return loadRevision(repository, path, sha)
+}
+
+// loadRevision loads the configuration from the path within the
+// checked out repository, recording the commit it was loaded from.
+func loadRevision(repository *GitRepository, path, sha string) (*Configuration, error) {
+	c, err := Load(filepath.Join(repository.Directory, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the configuration at revision %s: %v", sha, err)
+	}
+	if c == nil {
+		c = &Configuration{}
+	}
+	c.Revision = sha
+	return c, nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// commit writes the files to the origin repository and commits them,
+// returning the SHA of the commit.
+func commit(t *testing.T, origin string, files map[string]string) string {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(origin, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	for _, args := range [][]string{{"add", "--all"}, {"commit", "--quiet", "--message", "update"}} {
+		cmd := exec.Command("git", append([]string{"-C", origin}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("failed to commit: %v: %s", err, output)
+		}
+	}
+	sha, err := exec.Command("git", "-C", origin, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("failed to resolve the commit: %v", err)
+	}
+	return strings.TrimSpace(string(sha))
+}
+
+func TestStartGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "config-git")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	origin := filepath.Join(dir, "origin")
+	if output, err := exec.Command("git", "init", "--quiet", origin).CombinedOutput(); err != nil {
+		t.Fatalf("failed to create the origin: %v: %s", err, output)
+	}
+	first := commit(t, origin, map[string]string{"mapping.yaml": config1Str})
+
+	repository := &GitRepository{URL: origin, Directory: filepath.Join(dir, "clone")}
+	if _, err := LoadGit(&GitRepository{URL: origin, Ref: "missing", Directory: filepath.Join(dir, "other")}, "mapping.yaml"); err == nil {
+		t.Error("expected a missing ref to fail to load")
+	}
+	configAgent := &Agent{}
+	if err := configAgent.StartGit(repository, "mapping.yaml", 100*time.Millisecond); err != nil {
+		t.Fatalf("expected the config to be loaded, got %v", err)
+	}
+	changes := make(chan struct{}, 1)
+	configAgent.OnChange(func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	if loaded := configAgent.Config(); loaded.Revision != first || len(loaded.Secrets) != 1 {
+		t.Fatalf("expected the config of %s to be loaded, got %d rules at %s", first, len(loaded.Secrets), loaded.Revision)
+	}
+
+	// commits that do not change the config do not reload it
+	commit(t, origin, map[string]string{"README.md": "mappings"})
+	select {
+	case <-changes:
+		t.Error("expected a commit leaving the config alone not to reload it")
+	case <-time.After(time.Second):
+	}
+
+	third := commit(t, origin, map[string]string{"mapping.yaml": config2Str})
+	if err := wait.Poll(100*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return configAgent.Config().Revision == third, nil
+	}); err != nil {
+		t.Fatalf("expected the config of %s to be loaded, got %s", third, configAgent.Config().Revision)
+	}
+	if rules := len(configAgent.Config().Secrets); rules != 2 {
+		t.Errorf("expected the changed config to be loaded, got %d rules", rules)
+	}
+	select {
+	case <-changes:
+	case <-time.After(10 * time.Second):
+		t.Error("expected the reload to be signalled")
+	}
+}
+
+func TestGitTimeout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "config-git")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// a remote that accepts connections but never responds
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	repository := &GitRepository{URL: "git://" + listener.Addr().String() + "/config", Directory: dir, Timeout: 500 * time.Millisecond}
+	start := time.Now()
+	_, err = repository.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "did not finish within 500ms") {
+		t.Errorf("expected fetching from an unresponsive remote to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the fetch to be stopped at its deadline, took %s", elapsed)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Group names a set of sources, targets or keys that is
+// defined once and referenced by many mirroring configurations
+type Group struct {
+	// Sources are mirrored from by configurations
+	// referencing the group with fromGroup
+	Sources []SecretLocation `json:"sources,omitempty"`
+
+	// Targets are mirrored to by configurations
+	// referencing the group with toGroup
+	Targets []SecretLocation `json:"targets,omitempty"`
+
+	// Keys are ignored in the targets of configurations
+	// referencing the group with ignoreTargetKeysGroup
+	Keys []string `json:"keys,omitempty"`
+}
+
+// expandGroups replaces every mirroring configuration that references
+// groups with one configuration for every combination of the sources and
+// targets it refers to. It must only be applied once, when the
+// configuration is loaded, and before anything else looks at the sources
+// and targets of the configurations.
+func (c *Configuration) expandGroups() error {
+	var messages []string
+	var expanded []MirrorConfig
+	for i, mirror := range c.Secrets {
+		parent := fmt.Sprintf("secrets[%d]", i)
+		sources, sourceMessages := c.groupLocations(mirror.From, mirror.FromGroup, parent, "from", "sources", func(g Group) []SecretLocation { return g.Sources })
+		targets, targetMessages := c.groupLocations(mirror.To, mirror.ToGroup, parent, "to", "targets", func(g Group) []SecretLocation { return g.Targets })
+		messages = append(append(messages, sourceMessages...), targetMessages...)
+
+		ignored := append([]string{}, mirror.IgnoreTargetKeys...)
+		if mirror.IgnoreTargetKeysGroup != "" {
+			if group, exists := c.Groups[mirror.IgnoreTargetKeysGroup]; !exists {
+				messages = append(messages, fmt.Sprintf("%s.ignoreTargetKeysGroup: unknown group %q", parent, mirror.IgnoreTargetKeysGroup))
+			} else if len(group.Keys) == 0 {
+				messages = append(messages, fmt.Sprintf("%s.ignoreTargetKeysGroup: group %q has no keys", parent, mirror.IgnoreTargetKeysGroup))
+			} else {
+				ignored = append(ignored, group.Keys...)
+			}
+		}
+
+		for _, source := range sources {
+			for _, target := range targets {
+				rule := mirror
+				rule.From, rule.To = source, target
+				rule.FromGroup, rule.ToGroup, rule.IgnoreTargetKeysGroup = "", "", ""
+				if len(ignored) > 0 {
+					rule.IgnoreTargetKeys = append([]string{}, ignored...)
+				}
+				expanded = append(expanded, rule)
+			}
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("invalid groups: %s", strings.Join(messages, ", "))
+	}
+	c.Secrets = expanded
+	return nil
+}
+
+// groupLocations resolves the locations a mirroring configuration refers
+// to with field, either directly or through the named group.
+func (c *Configuration) groupLocations(location SecretLocation, name, parent, field, kind string, locations func(Group) []SecretLocation) ([]SecretLocation, []string) {
+	if name == "" {
+		return []SecretLocation{location}, nil
+	}
+	group, exists := c.Groups[name]
+	switch {
+	case location != (SecretLocation{}):
+		return nil, []string{fmt.Sprintf("%s.%sGroup: must not be set together with %s.%s", parent, field, parent, field)}
+	case !exists:
+		return nil, []string{fmt.Sprintf("%s.%sGroup: unknown group %q", parent, field, name)}
+	case len(locations(group)) == 0:
+		return nil, []string{fmt.Sprintf("%s.%sGroup: group %q has no %s", parent, field, name, kind)}
+	}
+	return locations(group), nil
+}
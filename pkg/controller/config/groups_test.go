@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandGroups(t *testing.T) {
+	groups := map[string]Group{
+		"teams": {Sources: []SecretLocation{
+			{Namespace: "team-a", Name: "token"},
+			{Namespace: "team-b", Name: "token"},
+		}},
+		"clusters": {Targets: []SecretLocation{
+			{Namespace: "build01", Name: "token"},
+			{Namespace: "build02", Name: "token"},
+		}},
+		"owned-by-others": {Keys: []string{"ca.crt", "namespace"}},
+	}
+
+	var testCases = []struct {
+		name        string
+		config      Configuration
+		expected    []MirrorConfig
+		expectedErr bool
+	}{
+		{
+			name: "configurations without groups are left alone",
+			config: Configuration{Groups: groups, Secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "a", Name: "a"}, To: SecretLocation{Namespace: "b", Name: "b"}, IgnoreTargetKeys: []string{"key"}},
+			}},
+			expected: []MirrorConfig{
+				{From: SecretLocation{Namespace: "a", Name: "a"}, To: SecretLocation{Namespace: "b", Name: "b"}, IgnoreTargetKeys: []string{"key"}},
+			},
+		},
+		{
+			name: "sources of a group are mirrored to the target",
+			config: Configuration{Groups: groups, Secrets: []MirrorConfig{
+				{FromGroup: "teams", To: SecretLocation{Namespace: "shared", Name: "token"}, SuffixSourceNamespace: true},
+			}},
+			expected: []MirrorConfig{
+				{From: SecretLocation{Namespace: "team-a", Name: "token"}, To: SecretLocation{Namespace: "shared", Name: "token"}, SuffixSourceNamespace: true},
+				{From: SecretLocation{Namespace: "team-b", Name: "token"}, To: SecretLocation{Namespace: "shared", Name: "token"}, SuffixSourceNamespace: true},
+			},
+		},
+		{
+			name: "the source is mirrored to the targets of a group, ignoring the keys of a group",
+			config: Configuration{Groups: groups, Secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "ci", Name: "token"}, ToGroup: "clusters", IgnoreTargetKeys: []string{"token"}, IgnoreTargetKeysGroup: "owned-by-others"},
+			}},
+			expected: []MirrorConfig{
+				{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{Namespace: "build01", Name: "token"}, IgnoreTargetKeys: []string{"token", "ca.crt", "namespace"}},
+				{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{Namespace: "build02", Name: "token"}, IgnoreTargetKeys: []string{"token", "ca.crt", "namespace"}},
+			},
+		},
+		{
+			name: "unknown groups are rejected",
+			config: Configuration{Groups: groups, Secrets: []MirrorConfig{
+				{FromGroup: "missing", To: SecretLocation{Namespace: "b", Name: "b"}},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "groups without the referenced locations are rejected",
+			config: Configuration{Groups: groups, Secrets: []MirrorConfig{
+				{FromGroup: "clusters", To: SecretLocation{Namespace: "b", Name: "b"}},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "groups referenced together with a location are rejected",
+			config: Configuration{Groups: groups, Secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "a", Name: "a"}, ToGroup: "clusters", To: SecretLocation{Namespace: "b", Name: "b"}},
+			}},
+			expectedErr: true,
+		},
+		{
+			name: "groups without keys are rejected for ignored keys",
+			config: Configuration{Groups: groups, Secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "a", Name: "a"}, To: SecretLocation{Namespace: "b", Name: "b"}, IgnoreTargetKeysGroup: "teams"},
+			}},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.config.expandGroups()
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if testCase.expectedErr {
+				return
+			}
+			if !reflect.DeepEqual(testCase.config.Secrets, testCase.expected) {
+				t.Errorf("%s: expected configurations %+v, got %+v", testCase.name, testCase.expected, testCase.config.Secrets)
+			}
+		})
+	}
+}
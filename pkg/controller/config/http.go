@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpTimeout bounds every request for the configuration
+const httpTimeout = 30 * time.Second
+
+// IsURL determines if the configuration is served over HTTP(S)
+// rather than read from a path.
+func IsURL(configLocation string) bool {
+	return strings.HasPrefix(configLocation, "https://") || strings.HasPrefix(configLocation, "http://")
+}
+
+// HTTPSource is a configuration served over HTTP(S). The ETag of the
+// last response is sent along with every request, so that servers
+// supporting it only send the configuration when it changed.
+type HTTPSource struct {
+	// URL serves the configuration
+	URL string
+
+	client *http.Client
+	etag   string
+}
+
+// NewHTTPSource returns a source fetching the configuration from the URL.
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{URL: url, client: &http.Client{Timeout: httpTimeout}}
+}
+
+// Fetch returns the configuration served at the URL, or nil if the
+// server reports that it did not change since the last fetch.
+func (s *HTTPSource) Fetch() ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration URL: %v", err)
+	}
+	if s.etag != "" {
+		request.Header.Set("If-None-Match", s.etag)
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch configuration: %v", err)
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to fetch configuration: server responded with %s", response.Status)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %v", err)
+	}
+	// only remember the ETag of a complete response, so that a
+	// truncated one is fetched again
+	s.etag = response.Header.Get("ETag")
+	return data, nil
+}
+
+// LoadURL fetches and parses the config served at the URL.
+func LoadURL(url string) (*Configuration, error) {
+	data, err := NewHTTPSource(url).Fetch()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// configServer serves a configuration with an ETag of its version
+type configServer struct {
+	lock        sync.Mutex
+	content     string
+	version     int
+	notModified int
+}
+
+func (s *configServer) set(content string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.content = content
+	s.version++
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/missing" {
+		http.NotFound(w, r)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	etag := fmt.Sprintf(`"%d"`, s.version)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, s.content)
+}
+
+func TestHTTPSource(t *testing.T) {
+	server := &configServer{}
+	server.set(config1Str)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	source := NewHTTPSource(httpServer.URL)
+	if data, err := source.Fetch(); err != nil || string(data) != config1Str {
+		t.Fatalf("expected the configuration to be fetched, got %q and %v", data, err)
+	}
+	if data, err := source.Fetch(); err != nil || data != nil {
+		t.Errorf("expected the unchanged configuration not to be fetched again, got %q and %v", data, err)
+	}
+	server.set(config2Str)
+	if data, err := source.Fetch(); err != nil || string(data) != config2Str {
+		t.Errorf("expected the changed configuration to be fetched, got %q and %v", data, err)
+	}
+	if _, err := NewHTTPSource(httpServer.URL + "/missing").Fetch(); err == nil {
+		t.Error("expected a missing configuration to fail")
+	}
+}
+
+func TestStartURL(t *testing.T) {
+	server := &configServer{}
+	server.set(config1Str)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	configAgent := &Agent{}
+	if err := configAgent.StartURL(httpServer.URL+"/mapping.yaml", 100*time.Millisecond); err != nil {
+		t.Fatalf("expected the config to be loaded, got %v", err)
+	}
+	changes := make(chan struct{}, 1)
+	configAgent.OnChange(func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	if rules := len(configAgent.Config().Secrets); rules != 1 {
+		t.Fatalf("expected the served config to be loaded, got %d rules", rules)
+	}
+
+	server.set(config2Str)
+	if err := wait.Poll(100*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return len(configAgent.Config().Secrets) == 2, nil
+	}); err != nil {
+		t.Fatalf("expected the changed config to be loaded, got %d rules", len(configAgent.Config().Secrets))
+	}
+	select {
+	case <-changes:
+	case <-time.After(10 * time.Second):
+		t.Error("expected the reload to be signalled")
+	}
+	if err := wait.Poll(100*time.Millisecond, 10*time.Second, func() (bool, error) {
+		server.lock.Lock()
+		defer server.lock.Unlock()
+		return server.notModified > 0, nil
+	}); err != nil {
+		t.Error("expected the unchanged config to be polled by its ETag")
+	}
+
+	if err := (&Agent{}).StartURL(httpServer.URL+"/missing", time.Second); err == nil {
+		t.Error("expected a missing configuration to fail to load")
+	}
+}
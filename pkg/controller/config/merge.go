@@ -0,0 +1,55 @@
+package config
+
+import "fmt"
+
+const (
+	// KeyConflictsFail fails the rule when merged sources
+	// hold different values for a key
+	KeyConflictsFail = "Fail"
+	// KeyConflictsFirstWins keeps the value of the first
+	// source holding the key, in the order of the sources
+	KeyConflictsFirstWins = "FirstWins"
+	// KeyConflictsLastWins keeps the value of the last
+	// source holding the key, in the order of the sources
+	KeyConflictsLastWins = "LastWins"
+)
+
+// Sources returns the sources the rule mirrors from, From first.
+func (c *MirrorConfig) Sources() []SecretLocation {
+	return append([]SecretLocation{c.From}, c.MergeFrom...)
+}
+
+// validateMergeFrom validates the sources merged into the target.
+func (c *MirrorConfig) validateMergeFrom(parent string) []string {
+	var messages []string
+	if len(c.MergeFrom) == 0 {
+		if c.KeyConflicts != "" {
+			messages = append(messages, fmt.Sprintf("%s.keyConflicts: only applies with mergeFrom", parent))
+		}
+		return messages
+	}
+	if c.IsPattern() {
+		messages = append(messages, fmt.Sprintf("%s.mergeFrom: cannot be combined with from.namePattern", parent))
+	}
+	if c.PollInterval != nil {
+		messages = append(messages, fmt.Sprintf("%s.mergeFrom: merged sources cannot be polled", parent))
+	}
+	seen := map[SecretLocation]bool{c.From: true}
+	for i, location := range c.MergeFrom {
+		field := fmt.Sprintf("%s.mergeFrom[%d]", parent, i)
+		messages = append(messages, location.validate(field)...)
+		if len(location.NamePattern) != 0 || len(location.NamespaceSelector) != 0 {
+			messages = append(messages, fmt.Sprintf("%s: merged sources must be named", field))
+		}
+		if seen[location] {
+			messages = append(messages, fmt.Sprintf("%s: %s is already a source of the rule", field, location.String()))
+		}
+		seen[location] = true
+	}
+	switch c.KeyConflicts {
+	case "", KeyConflictsFail, KeyConflictsFirstWins, KeyConflictsLastWins:
+	default:
+		messages = append(messages, fmt.Sprintf("%s.keyConflicts: must be one of %s, %s or %s", parent, KeyConflictsFail, KeyConflictsFirstWins, KeyConflictsLastWins))
+	}
+	return messages
+}
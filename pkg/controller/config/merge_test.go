@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateMergeFrom(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		mirror      MirrorConfig
+		expectedErr bool
+	}{
+		{
+			name:   "merged sources",
+			mirror: MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "a"}, MergeFrom: []SecretLocation{{Namespace: "ci", Name: "b"}}, To: SecretLocation{Namespace: "team", Name: "ab"}, KeyConflicts: KeyConflictsLastWins},
+		},
+		{
+			name:        "unknown key conflict policy",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "a"}, MergeFrom: []SecretLocation{{Namespace: "ci", Name: "b"}}, To: SecretLocation{Namespace: "team", Name: "ab"}, KeyConflicts: "Random"},
+			expectedErr: true,
+		},
+		{
+			name:        "key conflict policy without merged sources",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "a"}, To: SecretLocation{Namespace: "team", Name: "ab"}, KeyConflicts: KeyConflictsFirstWins},
+			expectedErr: true,
+		},
+		{
+			name:        "repeated source",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "a"}, MergeFrom: []SecretLocation{{Namespace: "ci", Name: "a"}}, To: SecretLocation{Namespace: "team", Name: "ab"}},
+			expectedErr: true,
+		},
+		{
+			name:        "merged source selected by pattern",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "a"}, MergeFrom: []SecretLocation{{Namespace: "ci", NamePattern: "b-.*"}}, To: SecretLocation{Namespace: "team", Name: "ab"}},
+			expectedErr: true,
+		},
+		{
+			name:        "polled merged sources",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "a"}, MergeFrom: []SecretLocation{{Namespace: "ci", Name: "b"}}, To: SecretLocation{Namespace: "team", Name: "ab"}, PollInterval: &metav1.Duration{Duration: time.Minute}},
+			expectedErr: true,
+		},
+		{
+			name:        "merged source that is the target",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "a"}, MergeFrom: []SecretLocation{{Namespace: "team", Name: "ab"}}, To: SecretLocation{Namespace: "team", Name: "ab"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}}
+			if err := configuration.Validate(); (err != nil) != testCase.expectedErr {
+				t.Errorf("%s: expected error %t, got %v", testCase.name, testCase.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Levels that metrics about rules and targets are aggregated at
+const (
+	// MetricsAggregationRule labels metrics with the rule or target
+	// they are about, which is the default
+	MetricsAggregationRule = "rule"
+	// MetricsAggregationNamespace labels metrics with the namespace
+	// of the target instead
+	MetricsAggregationNamespace = "namespace"
+	// MetricsAggregationTeam labels metrics with the team owning the
+	// rule instead, falling back to the namespace of the target
+	MetricsAggregationTeam = "team"
+)
+
+// Metrics configures how metrics about rules and targets are labelled, so
+// that configurations with many rules do not export a series for each.
+type Metrics struct {
+	// Aggregation is the level that metrics about rules and targets
+	// are aggregated at, defaulting to rule; series that are aggregated
+	// count the rules or targets they cover
+	Aggregation string `json:"aggregation,omitempty"`
+}
+
+func (m Metrics) validate(parent string) []string {
+	switch m.Aggregation {
+	case "", MetricsAggregationRule, MetricsAggregationNamespace, MetricsAggregationTeam:
+		return nil
+	default:
+		return []string{fmt.Sprintf("%s.aggregation: must be one of %s, %s or %s", parent, MetricsAggregationRule, MetricsAggregationNamespace, MetricsAggregationTeam)}
+	}
+}
+
+// MetricsLabel is the value that metrics about the rule or its target are
+// labelled with at the configured level of aggregation.
+func (c *Configuration) MetricsLabel(mirror MirrorConfig, detailed string) string {
+	if mirror.DetailedMetrics {
+		return detailed
+	}
+	switch c.Metrics.Aggregation {
+	case MetricsAggregationNamespace:
+		return mirror.To.Namespace
+	case MetricsAggregationTeam:
+		if mirror.Team != "" {
+			return mirror.Team
+		}
+		return mirror.To.Namespace
+	default:
+		return detailed
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import "testing"
+
+func TestMetricsLabel(t *testing.T) {
+	owned := MirrorConfig{
+		From: SecretLocation{Namespace: "from-ns", Name: "from-name"},
+		To:   SecretLocation{Namespace: "to-ns", Name: "to-name"},
+		Team: "dptp",
+	}
+	unowned := MirrorConfig{From: owned.From, To: owned.To}
+	detailed := MirrorConfig{From: owned.From, To: owned.To, Team: "dptp", DetailedMetrics: true}
+
+	var testCases = []struct {
+		name        string
+		aggregation string
+		mirror      MirrorConfig
+		expected    string
+	}{
+		{
+			name:     "rules are labelled in detail by default",
+			mirror:   owned,
+			expected: "detail",
+		},
+		{
+			name:        "rules are labelled with the target namespace",
+			aggregation: MetricsAggregationNamespace,
+			mirror:      owned,
+			expected:    "to-ns",
+		},
+		{
+			name:        "rules are labelled with their team",
+			aggregation: MetricsAggregationTeam,
+			mirror:      owned,
+			expected:    "dptp",
+		},
+		{
+			name:        "rules without a team are labelled with the target namespace",
+			aggregation: MetricsAggregationTeam,
+			mirror:      unowned,
+			expected:    "to-ns",
+		},
+		{
+			name:        "rules asking for detailed metrics are not aggregated",
+			aggregation: MetricsAggregationTeam,
+			mirror:      detailed,
+			expected:    "detail",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := &Configuration{Metrics: Metrics{Aggregation: testCase.aggregation}}
+			if actual := configuration.MetricsLabel(testCase.mirror, "detail"); actual != testCase.expected {
+				t.Errorf("expected label %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import "sort"
+
+// SecretNamespaces returns the namespaces of the cluster the controller
+// runs in that hold the sources and targets of the secret mirroring
+// rules, in order. Namespaces selected by label are not known up front
+// and so are not returned.
+func (c *Configuration) SecretNamespaces() []string {
+	namespaces := map[string]bool{}
+	add := func(location SecretLocation) {
+		if location.Cluster == "" && location.Namespace != "" {
+			namespaces[location.Namespace] = true
+		}
+	}
+	for _, mirror := range c.Secrets {
+		add(mirror.From)
+		add(mirror.To)
+		for _, from := range mirror.MergeFrom {
+			add(from)
+		}
+	}
+	var names []string
+	for namespace := range namespaces {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSecretNamespaces(t *testing.T) {
+	configuration := &Configuration{
+		Secrets: []MirrorConfig{
+			{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{Namespace: "team-b", Name: "token"}},
+			{From: SecretLocation{Namespace: "ci", NamePattern: "registry-.*"}, To: SecretLocation{Namespace: "team-a", Name: "registry"}},
+			{
+				From:      SecretLocation{Namespace: "ci", Name: "token"},
+				MergeFrom: []SecretLocation{{Namespace: "vault", Name: "token"}, {Cluster: "build01", Namespace: "remote", Name: "token"}},
+				To:        SecretLocation{Namespace: "team-a", Name: "merged"},
+			},
+			{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{NamespaceSelector: "team=ci", Name: "token"}},
+			{From: SecretLocation{Cluster: "build01", Namespace: "remote", Name: "token"}, To: SecretLocation{Namespace: "team-c", Name: "token"}},
+		},
+	}
+	expected := []string{"ci", "team-a", "team-b", "team-c", "vault"}
+	if actual := configuration.SecretNamespaces(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected namespaces %v, got %v", expected, actual)
+	}
+}
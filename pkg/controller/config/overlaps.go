@@ -0,0 +1,39 @@
+package config
+
+import "fmt"
+
+// validateOverlaps finds rules that would fight over a target in ways that
+// comparing the targets of named rules cannot: rules that are repeated,
+// and rules selecting their sources by pattern or their target namespaces
+// by selector that would write to the target of another rule.
+func (c *Configuration) validateOverlaps() []string {
+	var messages []string
+	rules := map[[2]SecretLocation]int{}
+	for i, mapping := range c.Secrets {
+		key := [2]SecretLocation{mapping.From, mapping.To}
+		if other, repeated := rules[key]; repeated {
+			messages = append(messages, fmt.Sprintf("secrets[%d]: mirrors %s to %s like secrets[%d]; combine both rules into one", i, mapping.From.String(), mapping.To.String(), other))
+			continue
+		}
+		rules[key] = i
+	}
+	for i, selecting := range c.Secrets {
+		if !selecting.IsPattern() && !selecting.FansOut() {
+			continue
+		}
+		for j, named := range c.Secrets {
+			if named.IsPattern() || named.FansOut() || !selecting.WritesTo(named.To) {
+				continue
+			}
+			from := selecting.From
+			if selecting.IsPattern() {
+				from.Name, from.NamePattern = named.To.Name, ""
+			}
+			if named.From.Equals(from) {
+				continue
+			}
+			messages = append(messages, fmt.Sprintf("secrets[%d].to: %s may also be written by secrets[%d], which would mirror from %s instead of %s", j, named.To.String(), i, from.String(), named.From.String()))
+		}
+	}
+	return messages
+}
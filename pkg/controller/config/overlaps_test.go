@@ -0,0 +1,62 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateOverlaps(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		secrets  []MirrorConfig
+		expected []string
+	}{
+		{
+			name: "distinct rules do not overlap",
+			secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "src-ns", Name: "src"}, To: SecretLocation{Namespace: "dst-ns", Name: "dst"}},
+				{From: SecretLocation{Namespace: "src-ns", Name: "src"}, To: SecretLocation{Namespace: "dst-ns", Name: "other"}},
+			},
+		},
+		{
+			name: "repeated rules overlap",
+			secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "src-ns", Name: "src"}, To: SecretLocation{Namespace: "dst-ns", Name: "dst"}},
+				{From: SecretLocation{Namespace: "src-ns", Name: "src"}, To: SecretLocation{Namespace: "dst-ns", Name: "dst"}, Merge: true},
+			},
+			expected: []string{"secrets[1]: mirrors src-ns/src to dst-ns/dst like secrets[0]; combine both rules into one"},
+		},
+		{
+			name: "pattern writing the target of a named rule from another source overlaps",
+			secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "src-ns", NamePattern: "team-.*"}, To: SecretLocation{Namespace: "dst-ns"}},
+				{From: SecretLocation{Namespace: "other-ns", Name: "src"}, To: SecretLocation{Namespace: "dst-ns", Name: "team-a"}},
+			},
+			expected: []string{"secrets[1].to: dst-ns/team-a may also be written by secrets[0], which would mirror from src-ns/team-a instead of other-ns/src"},
+		},
+		{
+			name: "pattern writing the target of a named rule from the same source does not overlap",
+			secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "src-ns", NamePattern: "team-.*"}, To: SecretLocation{Namespace: "dst-ns"}},
+				{From: SecretLocation{Namespace: "src-ns", Name: "team-a"}, To: SecretLocation{Namespace: "dst-ns", Name: "team-a"}},
+			},
+		},
+		{
+			name: "namespace selector writing the target of a named rule from another source overlaps",
+			secrets: []MirrorConfig{
+				{From: SecretLocation{Namespace: "src-ns", Name: "src"}, To: SecretLocation{NamespaceSelector: "team=ci", Name: "dst"}},
+				{From: SecretLocation{Namespace: "other-ns", Name: "src"}, To: SecretLocation{Namespace: "dst-ns", Name: "dst"}},
+			},
+			expected: []string{"secrets[1].to: dst-ns/dst may also be written by secrets[0], which would mirror from src-ns/src instead of other-ns/src"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := &Configuration{Secrets: testCase.secrets}
+			if actual := configuration.validateOverlaps(); !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("%s: expected messages %v, got %v", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// namePatterns caches compiled name patterns, as they are matched
+// against every secret that is added or updated.
+var namePatterns sync.Map
+
+// matchesName determines if the whole name matches the pattern. Patterns
+// are validated when the configuration is loaded; invalid ones match nothing.
+func matchesName(pattern, name string) bool {
+	compiled, cached := namePatterns.Load(pattern)
+	if !cached {
+		expression, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+		if err != nil {
+			return false
+		}
+		compiled, _ = namePatterns.LoadOrStore(pattern, expression)
+	}
+	return compiled.(*regexp.Regexp).MatchString(name)
+}
+
+// IsPattern determines if the rule selects its sources by a name pattern.
+func (c *MirrorConfig) IsPattern() bool {
+	return c.From.NamePattern != ""
+}
+
+// Instantiate returns the rule that mirrors from the source, if the rule
+// selects it by pattern. Targets are named after their source.
+func (c *MirrorConfig) Instantiate(source SecretLocation) (MirrorConfig, bool) {
+	from := c.From
+	if !c.IsPattern() || from.Cluster != source.Cluster || from.Namespace != source.Namespace || !matchesName(from.NamePattern, source.Name) {
+		return MirrorConfig{}, false
+	}
+	instance := *c
+	instance.From.Name, instance.From.NamePattern = source.Name, ""
+	instance.To.Name = source.Name
+	return instance, true
+}
+
+// Instantiates determines if the instance is the rule itself or was
+// instantiated from it, for rules selecting their sources by pattern
+// or their targets by namespace selector.
+func (c *MirrorConfig) Instantiates(instance MirrorConfig) bool {
+	switch {
+	case c.String() == instance.String():
+		return true
+	case c.IsPattern():
+		expected, matches := c.Instantiate(instance.From)
+		return matches && expected.To.Equals(instance.To)
+	case c.FansOut():
+		return c.From.Equals(instance.From) && instance.To.NamespaceSelector == "" && c.WritesTo(instance.To)
+	default:
+		return false
+	}
+}
+
+// WritesTo determines if the rule writes to the target. Rules selecting
+// their sources by pattern may write to every secret in their target
+// namespace whose name matches the pattern, and rules selecting their
+// target namespaces may write to the target in any namespace.
+func (c *MirrorConfig) WritesTo(to SecretLocation) bool {
+	if c.FansOut() {
+		return c.To.Cluster == to.Cluster && c.To.Name == to.Name
+	}
+	if !c.IsPattern() {
+		return c.To.Equals(to)
+	}
+	return c.To.Cluster == to.Cluster && c.To.Namespace == to.Namespace && matchesName(c.From.NamePattern, to.Name)
+}
+
+// validatePattern validates a rule selecting its sources by pattern.
+func (c *MirrorConfig) validatePattern(parent string) []string {
+	messages := c.To.validateNamespace(fmt.Sprintf("%s.to", parent))
+	if len(c.To.Name) != 0 || len(c.To.NamePattern) != 0 {
+		messages = append(messages, fmt.Sprintf("%s.to: must not set name or namePattern, as targets are named after the sources matching from.namePattern", parent))
+	}
+	if c.To.Cluster == c.From.Cluster && c.To.Namespace == c.From.Namespace {
+		messages = append(messages, fmt.Sprintf("%s.to.namespace: must differ from the namespace of the sources, as targets are named after the sources matching from.namePattern", parent))
+	}
+	if c.PollInterval != nil {
+		messages = append(messages, fmt.Sprintf("%s.pollInterval: sources matching from.namePattern cannot be polled", parent))
+	}
+	if c.SuffixSourceNamespace {
+		messages = append(messages, fmt.Sprintf("%s.suffixSourceNamespace: is not supported with from.namePattern", parent))
+	}
+	if c.Files != nil {
+		// every instance would remove the files of the others
+		messages = append(messages, fmt.Sprintf("%s.files: is not supported with from.namePattern, as the sources matching it would share the directory", parent))
+	}
+	return messages
+}
+
+// wildcardEdges adds to the graph of named secrets the edges of rules
+// selecting their sources by pattern or their targets by namespace
+// selector, so that cycles through them are found too. Which namespaces
+// are selected is only known at runtime, so every named secret a rule
+// could write to is assumed to be selected. Cycles made up of patterns
+// alone are not found, as they depend on the secrets that exist; the
+// controller detects those when it mirrors them.
+func (c *Configuration) wildcardEdges(nodes map[SecretLocation]bool, edges map[SecretLocation][]SecretLocation) {
+	// targets of patterns may in turn be matched by other patterns
+	for added := true; added; {
+		added = false
+		for _, mirrorConfig := range c.Secrets {
+			if !mirrorConfig.IsPattern() && !mirrorConfig.FansOut() {
+				continue
+			}
+			for node := range nodes {
+				if node.Name == "" || node.Namespace == "" || node.NamePattern != "" || node.NamespaceSelector != "" {
+					continue
+				}
+				var from, to SecretLocation
+				if instance, matches := mirrorConfig.Instantiate(node); matches {
+					from, to = instance.From, instance.To
+				} else if mirrorConfig.FansOut() && mirrorConfig.WritesTo(node) && !mirrorConfig.From.Equals(node) {
+					from, to = mirrorConfig.From, node
+				} else {
+					continue
+				}
+				known := false
+				for _, existing := range edges[from] {
+					known = known || existing.Equals(to)
+				}
+				if known {
+					continue
+				}
+				edges[from] = append(edges[from], to)
+				if _, exists := nodes[to]; !exists {
+					nodes[to] = false
+					added = true
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePattern(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		mirror      MirrorConfig
+		expectedErr bool
+	}{
+		{
+			name:   "pattern source",
+			mirror: MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: SecretLocation{Namespace: "team"}},
+		},
+		{
+			name:        "pattern that does not compile",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-("}, To: SecretLocation{Namespace: "team"}},
+			expectedErr: true,
+		},
+		{
+			name:        "name and pattern",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "ci-token", NamePattern: "ci-token-.*"}, To: SecretLocation{Namespace: "team"}},
+			expectedErr: true,
+		},
+		{
+			name:        "named target",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: SecretLocation{Namespace: "team", Name: "token"}},
+			expectedErr: true,
+		},
+		{
+			name:        "target in the namespace of the sources",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: SecretLocation{Namespace: "ci"}},
+			expectedErr: true,
+		},
+		{
+			name:        "polled pattern source",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: SecretLocation{Namespace: "team"}, PollInterval: &metav1.Duration{Duration: time.Minute}},
+			expectedErr: true,
+		},
+		{
+			name:        "pattern source writing files",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: SecretLocation{Namespace: "team"}, Files: &FileSink{Directory: "tokens"}},
+			expectedErr: true,
+		},
+		{
+			name:        "pattern target",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "ci-token"}, To: SecretLocation{Namespace: "team", NamePattern: "ci-token-.*"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}}
+			if err := configuration.Validate(); (err != nil) != testCase.expectedErr {
+				t.Errorf("%s: expected error %t, got %v", testCase.name, testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	mirror := MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-[a-z]+"}, To: SecretLocation{Namespace: "team"}}
+	instance, matches := mirror.Instantiate(SecretLocation{Namespace: "ci", Name: "ci-token-build"})
+	if !matches {
+		t.Fatal("expected the source to match the pattern")
+	}
+	if expected := (SecretLocation{Namespace: "ci", Name: "ci-token-build"}); instance.From != expected {
+		t.Errorf("expected the instance to mirror from %s, got %s", expected.String(), instance.From.String())
+	}
+	if expected := (SecretLocation{Namespace: "team", Name: "ci-token-build"}); instance.To != expected {
+		t.Errorf("expected the instance to mirror to %s, got %s", expected.String(), instance.To.String())
+	}
+	if mirror.To.Name != "" {
+		t.Error("expected the rule to be left alone")
+	}
+
+	for _, source := range []SecretLocation{
+		{Namespace: "ci", Name: "ci-token-1"},
+		{Namespace: "ci", Name: "my-ci-token-build"},
+		{Namespace: "other", Name: "ci-token-build"},
+		{Cluster: "remote", Namespace: "ci", Name: "ci-token-build"},
+	} {
+		if _, matches := mirror.Instantiate(source); matches {
+			t.Errorf("expected %s not to match the pattern", source.String())
+		}
+	}
+
+	if !mirror.WritesTo(SecretLocation{Namespace: "team", Name: "ci-token-build"}) {
+		t.Error("expected the rule to write to targets named like matching sources")
+	}
+	if mirror.WritesTo(SecretLocation{Namespace: "team", Name: "other"}) {
+		t.Error("expected the rule not to write to targets named unlike matching sources")
+	}
+	if actual, expected := mirror.String(), "(ci/(ci-token-[a-z]+) -> team/)"; actual != expected {
+		t.Errorf("expected the rule to be formatted as %s, got %s", expected, actual)
+	}
+}
+
+func TestValidateFindsCyclesThroughWildcards(t *testing.T) {
+	pattern := MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: SecretLocation{Namespace: "team"}}
+	fanOut := MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "src"}, To: SecretLocation{Name: "shared", NamespaceSelector: "team=a"}}
+	var testCases = []struct {
+		name    string
+		secrets []MirrorConfig
+		// through is a secret the cycle passes through, if any
+		through string
+	}{
+		{
+			name: "instance of a pattern mirrored back to its source",
+			secrets: []MirrorConfig{pattern, {
+				From: SecretLocation{Namespace: "team", Name: "ci-token-a"},
+				To:   SecretLocation{Namespace: "ci", Name: "ci-token-a"},
+			}},
+			through: "team/ci-token-a",
+		},
+		{
+			name: "instance of a pattern matched by a chained pattern",
+			secrets: []MirrorConfig{pattern, {
+				From: SecretLocation{Namespace: "ci", Name: "src"},
+				To:   SecretLocation{Namespace: "ci", Name: "ci-token-a"},
+			}, {
+				From: SecretLocation{Namespace: "team", NamePattern: "ci-token-.*"},
+				To:   SecretLocation{Namespace: "other"},
+			}, {
+				From: SecretLocation{Namespace: "other", Name: "ci-token-a"},
+				To:   SecretLocation{Namespace: "ci", Name: "src"},
+			}},
+			through: "other/ci-token-a",
+		},
+		{
+			name: "target of a fan-out mirrored back to its source",
+			secrets: []MirrorConfig{fanOut, {
+				From: SecretLocation{Namespace: "team-a", Name: "shared"},
+				To:   SecretLocation{Namespace: "ci", Name: "src"},
+			}},
+			through: "team-a/shared",
+		},
+		{
+			name: "chain through a pattern",
+			secrets: []MirrorConfig{pattern, {
+				From: SecretLocation{Namespace: "team", Name: "ci-token-a"},
+				To:   SecretLocation{Namespace: "other", Name: "ci-token-a"},
+			}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: testCase.secrets}
+			err := configuration.Validate()
+			if testCase.through == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if testCase.through != "" && (err == nil || !strings.Contains(err.Error(), "cycle") || !strings.Contains(err.Error(), testCase.through)) {
+				t.Errorf("expected a cycle through %s, got %v", testCase.through, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"path"
+)
+
+// Policy restricts where the controller may write, whatever the rules say.
+// Namespaces are matched by glob patterns, e.g. `openshift-*`.
+type Policy struct {
+	// AllowedNamespaces are the only namespaces targets may be
+	// written to, if set
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// DeniedNamespaces are namespaces targets are never written
+	// to, even if they are allowed
+	DeniedNamespaces []string `json:"deniedNamespaces,omitempty"`
+
+	// SecretMirrorClusters are the remote clusters that the rules of
+	// SecretMirror objects may write to; they may write to none if unset
+	SecretMirrorClusters []string `json:"secretMirrorClusters,omitempty"`
+}
+
+// AllowsNamespace determines if targets may be written to the namespace.
+// Without a policy, every namespace is allowed.
+func (p *Policy) AllowsNamespace(namespace string) bool {
+	if p == nil {
+		return true
+	}
+	if matchesNamespace(p.DeniedNamespaces, namespace) {
+		return false
+	}
+	return len(p.AllowedNamespaces) == 0 || matchesNamespace(p.AllowedNamespaces, namespace)
+}
+
+// AllowsSecretMirrorCluster determines if the rules of SecretMirror
+// objects may write to the remote cluster.
+func (p *Policy) AllowsSecretMirrorCluster(cluster string) bool {
+	if p == nil {
+		return false
+	}
+	for _, allowed := range p.SecretMirrorClusters {
+		if allowed == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNamespace determines if any of the patterns matches the
+// namespace. Patterns are validated when the configuration is loaded.
+func matchesNamespace(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if matches, _ := path.Match(pattern, namespace); matches {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) validate(parent string) []string {
+	var messages []string
+	for i, pattern := range p.AllowedNamespaces {
+		if _, err := path.Match(pattern, ""); err != nil || len(pattern) == 0 {
+			messages = append(messages, fmt.Sprintf("%s.allowedNamespaces[%d]: must be a valid glob pattern", parent, i))
+		}
+	}
+	for i, pattern := range p.DeniedNamespaces {
+		if _, err := path.Match(pattern, ""); err != nil || len(pattern) == 0 {
+			messages = append(messages, fmt.Sprintf("%s.deniedNamespaces[%d]: must be a valid glob pattern", parent, i))
+		}
+	}
+	for i, cluster := range p.SecretMirrorClusters {
+		if len(cluster) == 0 {
+			messages = append(messages, fmt.Sprintf("%s.secretMirrorClusters[%d]: must not be empty", parent, i))
+		}
+	}
+	return messages
+}
+
+// validatePolicy rejects rules whose targets the policy does not allow.
+// Rules selecting their target namespaces are checked when they are
+// instantiated instead.
+func (c *Configuration) validatePolicy() []string {
+	if c.Policy == nil {
+		return nil
+	}
+	messages := c.Policy.validate("policy")
+	for i, mapping := range c.Secrets {
+		if !mapping.FansOut() && !c.Policy.AllowsNamespace(mapping.To.Namespace) {
+			messages = append(messages, fmt.Sprintf("secrets[%d].to.namespace: %s is not allowed by the policy", i, mapping.To.Namespace))
+		}
+	}
+	for i, mapping := range c.ConfigMaps {
+		if !c.Policy.AllowsNamespace(mapping.To.Namespace) {
+			messages = append(messages, fmt.Sprintf("configMaps[%d].to.namespace: %s is not allowed by the policy", i, mapping.To.Namespace))
+		}
+	}
+	return messages
+}
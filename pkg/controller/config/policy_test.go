@@ -0,0 +1,92 @@
+package config
+
+import "testing"
+
+func TestPolicyAllowsNamespace(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		policy    *Policy
+		namespace string
+		expected  bool
+	}{
+		{
+			name:      "every namespace is allowed without a policy",
+			namespace: "kube-system",
+			expected:  true,
+		},
+		{
+			name:      "denied namespaces are not allowed",
+			policy:    &Policy{DeniedNamespaces: []string{"kube-system", "openshift-*"}},
+			namespace: "openshift-config",
+		},
+		{
+			name:      "other namespaces are allowed without an allowlist",
+			policy:    &Policy{DeniedNamespaces: []string{"kube-system", "openshift-*"}},
+			namespace: "ci",
+			expected:  true,
+		},
+		{
+			name:      "namespaces outside the allowlist are not allowed",
+			policy:    &Policy{AllowedNamespaces: []string{"team-*"}},
+			namespace: "ci",
+		},
+		{
+			name:      "denied namespaces are not allowed even if they are allowed",
+			policy:    &Policy{AllowedNamespaces: []string{"team-*"}, DeniedNamespaces: []string{"team-admin"}},
+			namespace: "team-admin",
+		},
+		{
+			name:      "allowed namespaces are allowed",
+			policy:    &Policy{AllowedNamespaces: []string{"team-*"}, DeniedNamespaces: []string{"team-admin"}},
+			namespace: "team-a",
+			expected:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if actual := testCase.policy.AllowsNamespace(testCase.namespace); actual != testCase.expected {
+			t.Errorf("%s: expected %s to be allowed: %t, got %t", testCase.name, testCase.namespace, testCase.expected, actual)
+		}
+	}
+}
+
+func TestPolicyAllowsSecretMirrorCluster(t *testing.T) {
+	if (*Policy)(nil).AllowsSecretMirrorCluster("build01") {
+		t.Error("expected no remote cluster to be allowed without a policy")
+	}
+	policy := &Policy{SecretMirrorClusters: []string{"build01"}}
+	if !policy.AllowsSecretMirrorCluster("build01") {
+		t.Error("expected the listed cluster to be allowed")
+	}
+	if policy.AllowsSecretMirrorCluster("build02") {
+		t.Error("expected other clusters not to be allowed")
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	configuration := &Configuration{
+		Secrets: []MirrorConfig{
+			{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{Namespace: "team-a", Name: "token"}},
+			{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{Namespace: "kube-system", Name: "token"}},
+			{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{NamespaceSelector: "team=ci", Name: "token"}},
+		},
+		ConfigMaps: []ConfigMapMirrorConfig{
+			{From: SecretLocation{Namespace: "ci", Name: "config"}, To: SecretLocation{Namespace: "openshift-config", Name: "config"}},
+		},
+		Policy: &Policy{DeniedNamespaces: []string{"kube-system", "openshift-*", "[invalid"}},
+	}
+	expected := []string{
+		"policy.deniedNamespaces[2]: must be a valid glob pattern",
+		"secrets[1].to.namespace: kube-system is not allowed by the policy",
+		"configMaps[0].to.namespace: openshift-config is not allowed by the policy",
+	}
+	actual := configuration.validatePolicy()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected messages %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("expected message %q, got %q", expected[i], actual[i])
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// providerNames are the names under which the stores outside of
+// Kubernetes are reported, by the field locations declare them with.
+var providerNames = map[string]string{
+	ProviderVault:             "Vault",
+	ProviderAWSSecretsManager: "AWS Secrets Manager",
+	ProviderGCPSecretManager:  "GCP Secret Manager",
+	ProviderAzureKeyVault:     "Azure Key Vault",
+}
+
+// Provider names the store outside of Kubernetes holding the secret,
+// which is the field of the location identifying it, or empty for
+// secrets in a cluster.
+func (l *SecretLocation) Provider() string {
+	switch {
+	case l.Vault != (VaultLocation{}):
+		return ProviderVault
+	case l.AWSSecretsManager != (AWSSecretsManagerLocation{}):
+		return ProviderAWSSecretsManager
+	case l.GCPSecretManager != (GCPSecretManagerLocation{}):
+		return ProviderGCPSecretManager
+	case l.AzureKeyVault != (AzureKeyVaultLocation{}):
+		return ProviderAzureKeyVault
+	}
+	return ""
+}
+
+// providerCount counts the stores outside of Kubernetes that the
+// location identifies a secret in, which is at most one when valid.
+func (l *SecretLocation) providerCount() int {
+	count := 0
+	for _, set := range []bool{
+		l.Vault != (VaultLocation{}),
+		l.AWSSecretsManager != (AWSSecretsManagerLocation{}),
+		l.GCPSecretManager != (GCPSecretManagerLocation{}),
+		l.AzureKeyVault != (AzureKeyVaultLocation{}),
+	} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
+
+// ParseProviderLocation parses a location formatted by String that
+// identifies a secret in a store outside of Kubernetes, returning false
+// for any other string.
+func ParseProviderLocation(formatted string) (SecretLocation, bool) {
+	var location SecretLocation
+	switch {
+	case strings.HasPrefix(formatted, VaultScheme):
+		location.Vault.Path = strings.TrimPrefix(formatted, VaultScheme)
+	case strings.HasPrefix(formatted, AWSSecretsManagerScheme):
+		location.AWSSecretsManager.ARN = strings.TrimPrefix(formatted, AWSSecretsManagerScheme)
+	case strings.HasPrefix(formatted, GCPSecretManagerScheme):
+		location.GCPSecretManager = parseGCPSecretManagerLocation(strings.TrimPrefix(formatted, GCPSecretManagerScheme))
+	case strings.HasPrefix(formatted, AzureKeyVaultScheme):
+		location.AzureKeyVault = parseAzureKeyVaultLocation(strings.TrimPrefix(formatted, AzureKeyVaultScheme))
+	default:
+		return location, false
+	}
+	return location, true
+}
+
+// providerString formats a location in a store outside of Kubernetes
+// as its identifier in the store prefixed with the scheme of the store.
+func (l *SecretLocation) providerString() string {
+	switch l.Provider() {
+	case ProviderVault:
+		return VaultScheme + l.Vault.Path
+	case ProviderAWSSecretsManager:
+		return AWSSecretsManagerScheme + l.AWSSecretsManager.ARN
+	case ProviderGCPSecretManager:
+		if l.GCPSecretManager.Version == "" {
+			return GCPSecretManagerScheme + l.GCPSecretManager.Name
+		}
+		return GCPSecretManagerScheme + l.GCPSecretManager.VersionName()
+	case ProviderAzureKeyVault:
+		return AzureKeyVaultScheme + l.AzureKeyVault.String()
+	}
+	return ""
+}
+
+// MarshalJSON leaves out the providers the location does not use. The
+// encoding/json package only leaves out empty pointers, but locations
+// hold the providers by value to remain comparable, as they are used
+// as keys throughout the controller.
+func (l SecretLocation) MarshalJSON() ([]byte, error) {
+	type location SecretLocation
+	serialized := struct {
+		location
+		Vault             *VaultLocation             `json:"vault,omitempty"`
+		AWSSecretsManager *AWSSecretsManagerLocation `json:"awsSecretsManager,omitempty"`
+		GCPSecretManager  *GCPSecretManagerLocation  `json:"gcpSecretManager,omitempty"`
+		AzureKeyVault     *AzureKeyVaultLocation     `json:"azureKeyVault,omitempty"`
+	}{location: location(l)}
+	if l.Vault != (VaultLocation{}) {
+		serialized.Vault = &l.Vault
+	}
+	if l.AWSSecretsManager != (AWSSecretsManagerLocation{}) {
+		serialized.AWSSecretsManager = &l.AWSSecretsManager
+	}
+	if l.GCPSecretManager != (GCPSecretManagerLocation{}) {
+		serialized.GCPSecretManager = &l.GCPSecretManager
+	}
+	if l.AzureKeyVault != (AzureKeyVaultLocation{}) {
+		serialized.AzureKeyVault = &l.AzureKeyVault
+	}
+	return json.Marshal(serialized)
+}
+
+// validateProvider validates a location in a store outside of
+// Kubernetes, which must not identify a secret in a cluster or in
+// another store as well.
+func (l *SecretLocation) validateProvider(parent string) []string {
+	var messages []string
+	field := fmt.Sprintf("%s.%s", parent, l.Provider())
+	if l.Cluster != "" || l.Namespace != "" || l.Name != "" || l.NamePattern != "" || l.NamespaceSelector != "" {
+		messages = append(messages, fmt.Sprintf("%s: cannot be combined with cluster, namespace, name, namePattern or namespaceSelector", field))
+	}
+	if l.providerCount() > 1 {
+		messages = append(messages, fmt.Sprintf("%s: only one of vault, awsSecretsManager, gcpSecretManager or azureKeyVault may be set", parent))
+	}
+	switch l.Provider() {
+	case ProviderVault:
+		messages = append(messages, l.Vault.validate(field)...)
+	case ProviderAWSSecretsManager:
+		messages = append(messages, l.AWSSecretsManager.validate(field)...)
+	case ProviderGCPSecretManager:
+		messages = append(messages, l.GCPSecretManager.validate(field)...)
+	case ProviderAzureKeyVault:
+		messages = append(messages, l.AzureKeyVault.validate(field)...)
+	}
+	return messages
+}
+
+// validateProviders validates the use of secrets in stores outside of
+// Kubernetes by the rule. Such secrets are always polled, so they cannot
+// be merged either. Only Vault holds targets.
+func (c *MirrorConfig) validateProviders(parent string) []string {
+	var messages []string
+	if provider := c.To.Provider(); provider != "" && provider != ProviderVault {
+		messages = append(messages, fmt.Sprintf("%s.to.%s: only sources may be in %s", parent, provider, providerNames[provider]))
+	}
+	if c.To.Provider() == ProviderVault {
+		messages = append(messages, c.validateVaultTarget(parent)...)
+	}
+	for i, from := range c.MergeFrom {
+		if provider := from.Provider(); provider != "" {
+			messages = append(messages, fmt.Sprintf("%s.mergeFrom[%d].%s: merged sources cannot be in %s", parent, i, provider, providerNames[provider]))
+		}
+	}
+	provider := c.From.Provider()
+	if provider == "" {
+		return messages
+	}
+	if len(c.MergeFrom) != 0 {
+		messages = append(messages, fmt.Sprintf("%s.mergeFrom: cannot be combined with sources in %s", parent, providerNames[provider]))
+	}
+	if c.AnnotateSource {
+		messages = append(messages, fmt.Sprintf("%s.annotateSource: is not supported for sources in %s", parent, providerNames[provider]))
+	}
+	if c.SuffixSourceNamespace {
+		messages = append(messages, fmt.Sprintf("%s.suffixSourceNamespace: is not supported for sources in %s", parent, providerNames[provider]))
+	}
+	return messages
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
+)
+
+// schemaVersion is the JSON Schema draft the schema follows
+const schemaVersion = "http://json-schema.org/draft-07/schema#"
+
+var (
+	durationType = reflect.TypeOf(metav1.Duration{})
+	timeType     = reflect.TypeOf(time.Time{})
+	rawType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// enums lists the values allowed for string fields, by the name
+// of the type holding the field and the JSON name of the field.
+func enums() map[string]map[string][]string {
+	return map[string]map[string][]string{
+		"MirrorConfig": {
+			"updateStrategy": {UpdateStrategyUpdate, UpdateStrategyRecreate},
+		},
+		"Conversion": {
+			"type": {ConversionDockerConfigJSON, ConversionOpaque},
+		},
+		"KeyValidation": {
+			"validators": Validators,
+		},
+		"Metrics": {
+			"aggregation": {MetricsAggregationRule, MetricsAggregationNamespace, MetricsAggregationTeam},
+		},
+		"TransformConfig": {
+			"name": transform.Names(),
+		},
+	}
+}
+
+// Schema returns a JSON Schema for configuration files. It is generated
+// from the configuration types, so it covers every field the controller
+// reads. Unknown fields are rejected, to catch typos.
+func Schema() map[string]interface{} {
+	generator := &schemaGenerator{definitions: map[string]interface{}{}, enums: enums()}
+	schema := generator.schemaFor(reflect.TypeOf(Configuration{}))
+	schema["$schema"] = schemaVersion
+	schema["title"] = "ci-secret-mirroring-controller configuration"
+	schema["definitions"] = generator.definitions
+	return schema
+}
+
+type schemaGenerator struct {
+	definitions map[string]interface{}
+	enums       map[string]map[string][]string
+}
+
+// schemaFor returns the schema of the type. Structs other than the
+// Configuration are defined once and referenced wherever they are used.
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t {
+	case durationType:
+		return map[string]interface{}{"type": "string", "description": "A duration, e.g. 1h30m."}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawType:
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t == reflect.TypeOf(Configuration{}) {
+			return g.structSchema(t)
+		}
+		if _, defined := g.definitions[t.Name()]; !defined {
+			// registered before recursing, for types that refer to themselves
+			g.definitions[t.Name()] = nil
+			g.definitions[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := g.schemaFor(field.Type)
+		if values, enumerated := g.enums[t.Name()][name]; enumerated {
+			if schema["type"] == "array" {
+				schema["items"] = map[string]interface{}{"type": "string", "enum": values}
+			} else {
+				schema["enum"] = values
+			}
+		}
+		properties[name] = schema
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
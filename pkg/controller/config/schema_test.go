@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("failed to serialize schema: %v", err)
+	}
+	definitions := schema["definitions"].(map[string]interface{})
+	property := func(definition, name string) map[string]interface{} {
+		properties := definitions[definition].(map[string]interface{})["properties"].(map[string]interface{})
+		value, exists := properties[name]
+		if !exists {
+			t.Fatalf("expected %s to have the property %s", definition, name)
+		}
+		return value.(map[string]interface{})
+	}
+
+	if secrets := schema["properties"].(map[string]interface{})["secrets"].(map[string]interface{}); !reflect.DeepEqual(secrets["items"], map[string]interface{}{"$ref": "#/definitions/MirrorConfig"}) {
+		t.Errorf("expected secrets to be a list of mirror configurations, got %v", secrets)
+	}
+	if to := property("MirrorConfig", "to"); to["$ref"] != "#/definitions/SecretLocation" {
+		t.Errorf("expected targets to refer to the secret location definition, got %v", to)
+	}
+	if pollInterval := property("MirrorConfig", "pollInterval"); pollInterval["type"] != "string" {
+		t.Errorf("expected durations to be strings, got %v", pollInterval)
+	}
+	if labels := property("MirrorConfig", "labels"); !reflect.DeepEqual(labels["additionalProperties"], map[string]interface{}{"type": "string"}) {
+		t.Errorf("expected labels to map strings to strings, got %v", labels)
+	}
+
+	// enums refer to fields by name, which must not drift from the types
+	for definition, fields := range enums() {
+		if _, defined := definitions[definition]; !defined {
+			t.Errorf("enums refer to the undefined type %s", definition)
+			continue
+		}
+		for name, values := range fields {
+			field := property(definition, name)
+			if field["enum"] == nil && (field["items"] == nil || field["items"].(map[string]interface{})["enum"] == nil) {
+				t.Errorf("expected %s.%s to be restricted to %v, got %v", definition, name, values, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// namespaceSelectors caches parsed namespace selectors, as they are
+// matched against every namespace that is added or updated.
+var namespaceSelectors sync.Map
+
+// matchesLabels determines if the labels match the selector. Selectors are
+// validated when the configuration is loaded; invalid ones match nothing.
+func matchesLabels(selector string, set map[string]string) bool {
+	parsed, cached := namespaceSelectors.Load(selector)
+	if !cached {
+		parsedSelector, err := labels.Parse(selector)
+		if err != nil {
+			return false
+		}
+		parsed, _ = namespaceSelectors.LoadOrStore(selector, parsedSelector)
+	}
+	return parsed.(labels.Selector).Matches(labels.Set(set))
+}
+
+// FansOut determines if the rule writes to every
+// namespace matching a namespace selector.
+func (c *MirrorConfig) FansOut() bool {
+	return c.To.NamespaceSelector != ""
+}
+
+// InstantiateTarget returns the rule that writes to the namespace, if the
+// rule selects it by its labels. The source is never its own target.
+func (c *MirrorConfig) InstantiateTarget(namespace string, namespaceLabels map[string]string) (MirrorConfig, bool) {
+	if !c.FansOut() || !matchesLabels(c.To.NamespaceSelector, namespaceLabels) {
+		return MirrorConfig{}, false
+	}
+	instance := *c
+	instance.To.Namespace, instance.To.NamespaceSelector = namespace, ""
+	if instance.To.Equals(instance.From) {
+		return MirrorConfig{}, false
+	}
+	return instance, true
+}
+
+// validateSelector validates a rule selecting its target namespaces.
+func (c *MirrorConfig) validateSelector(parent string) []string {
+	var messages []string
+	if len(c.From.NamespaceSelector) != 0 {
+		messages = append(messages, fmt.Sprintf("%s.from.namespaceSelector: only targets may be selected by namespace selector", parent))
+	}
+	if !c.FansOut() {
+		return messages
+	}
+	if len(c.From.NamePattern) != 0 {
+		messages = append(messages, fmt.Sprintf("%s.to.namespaceSelector: cannot be combined with from.namePattern", parent))
+	}
+	if len(c.To.Cluster) != 0 {
+		messages = append(messages, fmt.Sprintf("%s.to.namespaceSelector: is not supported for targets in remote clusters", parent))
+	}
+	if c.SuffixSourceNamespace {
+		messages = append(messages, fmt.Sprintf("%s.suffixSourceNamespace: is not supported with to.namespaceSelector", parent))
+	}
+	return messages
+}
@@ -0,0 +1,77 @@
+package config
+
+import "testing"
+
+func TestValidateSelector(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		mirror      MirrorConfig
+		expectedErr bool
+	}{
+		{
+			name:   "selected target namespaces",
+			mirror: MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{NamespaceSelector: "team-secrets=true", Name: "token"}},
+		},
+		{
+			name:        "selector that does not parse",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{NamespaceSelector: "team-secrets in (", Name: "token"}},
+			expectedErr: true,
+		},
+		{
+			name:        "namespace and selector",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{Namespace: "team", NamespaceSelector: "team-secrets=true", Name: "token"}},
+			expectedErr: true,
+		},
+		{
+			name:        "selected source namespaces",
+			mirror:      MirrorConfig{From: SecretLocation{NamespaceSelector: "team-secrets=true", Name: "token"}, To: SecretLocation{Namespace: "team", Name: "token"}},
+			expectedErr: true,
+		},
+		{
+			name:        "pattern source",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", NamePattern: "token-.*"}, To: SecretLocation{NamespaceSelector: "team-secrets=true"}},
+			expectedErr: true,
+		},
+		{
+			name:        "remote target cluster",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{Cluster: "build01", NamespaceSelector: "team-secrets=true", Name: "token"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}}
+			if err := configuration.Validate(); (err != nil) != testCase.expectedErr {
+				t.Errorf("%s: expected error %t, got %v", testCase.name, testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestInstantiateTarget(t *testing.T) {
+	mirror := MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "token"}, To: SecretLocation{NamespaceSelector: "team-secrets=true", Name: "token"}}
+	instance, matches := mirror.InstantiateTarget("team", map[string]string{"team-secrets": "true"})
+	if !matches {
+		t.Fatal("expected the namespace to match the selector")
+	}
+	if expected := (SecretLocation{Namespace: "team", Name: "token"}); instance.To != expected {
+		t.Errorf("expected the instance to mirror to %s, got %s", expected.String(), instance.To.String())
+	}
+	if _, matches := mirror.InstantiateTarget("other", map[string]string{"team-secrets": "false"}); matches {
+		t.Error("expected a namespace with other labels not to match the selector")
+	}
+	if _, matches := mirror.InstantiateTarget("ci", map[string]string{"team-secrets": "true"}); matches {
+		t.Error("expected the source never to be its own target")
+	}
+
+	if !mirror.WritesTo(SecretLocation{Namespace: "anywhere", Name: "token"}) {
+		t.Error("expected the rule to write to targets of its name in any namespace")
+	}
+	if mirror.WritesTo(SecretLocation{Namespace: "team", Name: "other"}) {
+		t.Error("expected the rule not to write to targets of other names")
+	}
+	if actual, expected := mirror.String(), "(ci/token -> (team-secrets=true)/token)"; actual != expected {
+		t.Errorf("expected the rule to be formatted as %s, got %s", expected, actual)
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VaultScheme prefixes the paths of secrets in Vault when locations are
+// formatted, which tells them apart from secrets in remote clusters, as
+// those are never formatted with an empty namespace.
+const VaultScheme = "vault://"
+
+// ProviderVault names Vault as the provider of a location
+const ProviderVault = "vault"
+
+// VaultLocation identifies a secret in a KV version 2 secrets engine of
+// Vault instead of a secret in a cluster.
+type VaultLocation struct {
+	// Path is the path of the secret, starting with the path the secrets
+	// engine is mounted at, e.g. secret/ci/registry for the secret
+	// ci/registry in the engine mounted at secret
+	Path string `json:"path,omitempty"`
+}
+
+// MountAndPath splits the path into the mount of the secrets engine,
+// which is its first segment, and the path of the secret within it.
+func (l *VaultLocation) MountAndPath() (string, string) {
+	parts := strings.SplitN(l.Path, "/", 2)
+	if len(parts) != 2 {
+		return l.Path, ""
+	}
+	return parts[0], parts[1]
+}
+
+func (l *VaultLocation) validate(parent string) []string {
+	mount, path := l.MountAndPath()
+	if mount == "" || path == "" || strings.HasSuffix(path, "/") || strings.Contains(path, "//") {
+		return []string{fmt.Sprintf("%s.path: must be the path of the secrets engine followed by the path of the secret, e.g. secret/ci/registry", parent)}
+	}
+	return nil
+}
+
+// validateVaultTarget validates a rule writing to Vault, which holds
+// nothing but the data of secrets, so the rule cannot set the metadata
+// or type of its target nor rely on the metadata the controller keeps on
+// targets in clusters.
+func (c *MirrorConfig) validateVaultTarget(parent string) []string {
+	var messages []string
+	if provider := c.From.Provider(); provider != "" {
+		messages = append(messages, fmt.Sprintf("%s.from.%s: targets in Vault must be mirrored from secrets in a cluster", parent, provider))
+	}
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{name: "merge", set: c.Merge},
+		{name: "ignoreTargetKeys", set: len(c.IgnoreTargetKeys) != 0 || c.IgnoreTargetKeysGroup != ""},
+		{name: "propagateDeletion", set: c.PropagateDeletion},
+		{name: "targetType", set: c.TargetType != ""},
+		{name: "updateStrategy", set: c.UpdateStrategy != ""},
+		{name: "immutableTarget", set: c.ImmutableTarget},
+		{name: "injectChecksum", set: c.InjectChecksum},
+		{name: "labels", set: len(c.Labels) != 0},
+		{name: "annotations", set: len(c.Annotations) != 0},
+		{name: "copyMetadata", set: c.CopyMetadata != nil},
+	} {
+		if field.set {
+			messages = append(messages, fmt.Sprintf("%s.%s: is not supported for targets in Vault", parent, field.name))
+		}
+	}
+	return messages
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateVault(t *testing.T) {
+	fromVault := SecretLocation{Vault: VaultLocation{Path: "secret/ci/registry"}}
+	to := SecretLocation{Namespace: "team", Name: "registry"}
+	var testCases = []struct {
+		name        string
+		mirror      MirrorConfig
+		expectedErr string
+	}{
+		{
+			name:   "source in Vault",
+			mirror: MirrorConfig{From: fromVault, To: to},
+		},
+		{
+			name:        "path without a secret",
+			mirror:      MirrorConfig{From: SecretLocation{Vault: VaultLocation{Path: "secret"}}, To: to},
+			expectedErr: "from.vault.path",
+		},
+		{
+			name:        "path of a directory",
+			mirror:      MirrorConfig{From: SecretLocation{Vault: VaultLocation{Path: "secret/ci/"}}, To: to},
+			expectedErr: "from.vault.path",
+		},
+		{
+			name:        "source in Vault and in a namespace",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "registry", Vault: fromVault.Vault}, To: to},
+			expectedErr: "from.vault: cannot be combined",
+		},
+		{
+			name:   "target in Vault",
+			mirror: MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "registry"}, To: fromVault},
+		},
+		{
+			name:        "target in Vault mirrored from a store outside of Kubernetes",
+			mirror:      MirrorConfig{From: SecretLocation{AWSSecretsManager: AWSSecretsManagerLocation{ARN: "arn:aws:secretsmanager:us-east-1:123456789012:secret:ci"}}, To: fromVault},
+			expectedErr: "from.awsSecretsManager: targets in Vault must be mirrored from secrets in a cluster",
+		},
+		{
+			name:        "merged target in Vault",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "registry"}, To: fromVault, Merge: true},
+			expectedErr: "merge: is not supported for targets in Vault",
+		},
+		{
+			name:        "labelled target in Vault",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "registry"}, To: fromVault, Labels: map[string]string{"team": "ci"}},
+			expectedErr: "labels: is not supported for targets in Vault",
+		},
+		{
+			name:        "merged source in Vault",
+			mirror:      MirrorConfig{From: SecretLocation{Namespace: "ci", Name: "registry"}, MergeFrom: []SecretLocation{fromVault}, To: to},
+			expectedErr: "mergeFrom[0].vault",
+		},
+		{
+			name:        "annotated source in Vault",
+			mirror:      MirrorConfig{From: fromVault, To: to, AnnotateSource: true},
+			expectedErr: "annotateSource",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := Configuration{Secrets: []MirrorConfig{testCase.mirror}}
+			err := configuration.Validate()
+			if testCase.expectedErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Errorf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestVaultLocationSerialization(t *testing.T) {
+	location := SecretLocation{Vault: VaultLocation{Path: "secret/ci/registry"}}
+	if actual, expected := location.String(), "vault://secret/ci/registry"; actual != expected {
+		t.Errorf("expected the location to be formatted as %s, got %s", expected, actual)
+	}
+	if mount, path := location.Vault.MountAndPath(); mount != "secret" || path != "ci/registry" {
+		t.Errorf("expected the mount secret and the path ci/registry, got %s and %s", mount, path)
+	}
+
+	for _, original := range []SecretLocation{location, {Namespace: "ci", Name: "registry"}} {
+		raw, err := json.Marshal(original)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if original.Provider() == "" && strings.Contains(string(raw), "vault") {
+			t.Errorf("expected secrets in a cluster to be serialized without Vault, got %s", raw)
+		}
+		var parsed SecretLocation
+		if err := json.Unmarshal(raw, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed != original {
+			t.Errorf("expected %s to survive serialization, got %+v", raw, parsed)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+const configMapMirrorName = "configmap-mirroring-manager"
+
+// ConfigMapMirror mirrors the ConfigMaps configured in the configMaps
+// section of the configuration, with the semantics that the SecretMirror
+// applies to secrets: targets are only written when their source has data,
+// merging preserves keys that other parties added, ignored keys are never
+// touched and targets annotated to not be overwritten are left alone.
+type ConfigMapMirror struct {
+	config      config.Getter
+	writeClient kubeclientset.Interface
+	lister      corelisters.ConfigMapLister
+	queue       workqueue.RateLimitingInterface
+	retry       RetryPolicy
+	synced      cache.InformerSynced
+	auditLog    *auditlog.Log
+	logger      *logrus.Entry
+}
+
+// NewConfigMapMirror returns a controller mirroring ConfigMaps observed by
+// the informer, writing targets with the client and retrying failures by
+// the policy, DefaultRetryPolicy if it is zero. Every write to a target is
+// recorded in the audit log, if set.
+func NewConfigMapMirror(informer coreinformers.ConfigMapInformer, writeClient kubeclientset.Interface, config config.Getter, retry RetryPolicy, auditLog *auditlog.Log) *ConfigMapMirror {
+	retry = retry.withDefaults()
+	c := &ConfigMapMirror{
+		config:      config,
+		writeClient: writeClient,
+		lister:      informer.Lister(),
+		queue:       retry.queue(configMapMirrorName),
+		retry:       retry,
+		synced:      informer.Informer().HasSynced,
+		auditLog:    auditLog,
+		logger:      logrus.WithField("controller", configMapMirrorName),
+	}
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(_, obj interface{}) { c.enqueue(obj) },
+	})
+	return c
+}
+
+// enqueue enqueues ConfigMaps that rules mirror from. ConfigMaps that become
+// sources when the configuration is reloaded are enqueued by Sync.
+func (c *ConfigMapMirror) enqueue(obj interface{}) {
+	configMap := obj.(*coreapi.ConfigMap)
+	from := config.SecretLocation{Namespace: configMap.Namespace, Name: configMap.Name}
+	if len(configMapRulesFrom(c.config(), from)) == 0 {
+		return
+	}
+	c.queue.Add(from.String())
+}
+
+// Sync enqueues the source of every configured rule.
+func (c *ConfigMapMirror) Sync() {
+	for _, key := range c.sourceKeys() {
+		c.queue.Add(key)
+	}
+}
+
+// sourceKeys returns the keys of the sources of every configured rule.
+func (c *ConfigMapMirror) sourceKeys() []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, rule := range c.config().ConfigMaps {
+		key := rule.From.String()
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// configMapRulesFrom returns the rules mirroring from the ConfigMap.
+func configMapRulesFrom(configuration *config.Configuration, from config.SecretLocation) []config.ConfigMapMirrorConfig {
+	if configuration == nil {
+		return nil
+	}
+	var rules []config.ConfigMapMirrorConfig
+	for _, rule := range configuration.ConfigMaps {
+		if rule.From.Equals(from) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Run runs c; will not return until ctx is cancelled and the ConfigMaps being
+// mirrored are done. workers determines how many ConfigMaps will be handled
+// in parallel.
+func (c *ConfigMapMirror) Run(ctx context.Context, workers int) {
+	stopCh := ctx.Done()
+	defer utilruntime.HandleCrash()
+
+	c.logger.Infof("starting %s controller", configMapMirrorName)
+	defer c.logger.Infof("shutting down %s controller", configMapMirrorName)
+
+	if !cache.WaitForCacheSync(stopCh, c.synced) {
+		utilruntime.HandleError(fmt.Errorf("unable to reconcile caches for %s controller", configMapMirrorName))
+	}
+	// like secrets, every rule is reconciled on start
+	c.Sync()
+	var running background
+	for i := 0; i < workers; i++ {
+		running.run(func() { wait.Until(func() { c.worker(ctx) }, time.Second, stopCh) })
+	}
+
+	<-stopCh
+	c.queue.ShutDown()
+	running.wait()
+}
+
+// RunOnce mirrors every configured ConfigMap once after the cache is
+// synced and returns the failures, instead of watching them like Run.
+func (c *ConfigMapMirror) RunOnce(stopCh <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stopCh, c.synced) {
+		return fmt.Errorf("unable to reconcile caches for %s controller", configMapMirrorName)
+	}
+	var errs []error
+	for _, key := range c.sourceKeys() {
+		if err := c.reconcile(key); err != nil {
+			c.logger.WithField("configmap", key).WithError(err).Error("error syncing ConfigMap")
+			errs = append(errs, fmt.Errorf("ConfigMap %s: %v", key, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c *ConfigMapMirror) worker(ctx context.Context) {
+	for ctx.Err() == nil && c.processNextWorkItem() {
+	}
+}
+
+func (c *ConfigMapMirror) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.reconcile(key.(string))
+	if err == nil {
+		c.queue.Forget(key)
+		return true
+	}
+	logger := c.logger.WithField("configmap", key)
+	logger.WithError(err).Error("error syncing ConfigMap")
+	if c.queue.NumRequeues(key) < c.retry.MaxRetries {
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	utilruntime.HandleError(err)
+	logger.Info("dropping ConfigMap out of the queue")
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile mirrors the ConfigMap to the target of every rule mirroring from it.
+func (c *ConfigMapMirror) reconcile(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	logger := c.logger.WithFields(logrus.Fields{"source-namespace": namespace, "source-configmap": name})
+	rules := configMapRulesFrom(c.config(), config.SecretLocation{Namespace: namespace, Name: name})
+	if len(rules) == 0 {
+		logger.Debug("not doing work for ConfigMap because no rule mirrors from it")
+		return nil
+	}
+
+	source, err := c.lister.ConfigMaps(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		logger.Info("not doing work for ConfigMap because it has been deleted")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !source.DeletionTimestamp.IsZero() {
+		logger.Info("not doing work for ConfigMap because it is being deleted")
+		return nil
+	}
+	if source.Annotations[sourceOptOutAnnotation] == sourceOptOutValue {
+		logger.Warnf("not doing work for ConfigMap because it is annotated with %s: %s", sourceOptOutAnnotation, sourceOptOutValue)
+		return nil
+	}
+
+	var errs []error
+	for _, rule := range rules {
+		if err := c.mirrorConfigMap(source, rule, logger); err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %v", rule.String(), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c *ConfigMapMirror) mirrorConfigMap(source *coreapi.ConfigMap, rule config.ConfigMapMirrorConfig, logger *logrus.Entry) error {
+	to := rule.To
+	logger = logger.WithFields(logrus.Fields{"target-namespace": to.Namespace, "target-configmap": to.Name})
+
+	// the data of secrets and ConfigMaps is merged the same way
+	mirrorConfig := config.MirrorConfig{Merge: rule.Merge, IgnoreTargetKeys: rule.IgnoreTargetKeys}
+	sourceData := applicableData(configMapEntries(source), mirrorConfig)
+	if len(sourceData) == 0 {
+		logger.Info("not updating target ConfigMap as source has no data")
+		return nil
+	}
+	hash, keys := dataHash(sourceData), formatKeys(sourceData)
+	configMaps := c.writeClient.CoreV1().ConfigMaps(to.Namespace)
+
+	target, err := c.lister.ConfigMaps(to.Namespace).Get(to.Name)
+	if errors.IsNotFound(err) {
+		logger.Info("creating target ConfigMap")
+		created := &coreapi.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   to.Namespace,
+				Name:        to.Name,
+				Labels:      withEntries(nil, rule.Labels),
+				Annotations: withEntries(rule.Annotations, map[string]string{lastAppliedHashAnnotation: hash, lastAppliedKeysAnnotation: keys}),
+			},
+		}
+		created.Data, created.BinaryData = splitConfigMapEntries(sourceData, source, nil)
+		stamp(created, []config.SecretLocation{rule.From}, source.ResourceVersion, c.config().Revision, time.Now())
+		if _, err := configMaps.Create(created); err != nil {
+			return err
+		}
+		c.recordWrite(rule, auditlog.OperationCreate, hash)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if target.Annotations[doNotOverwriteAnnotation] == "true" {
+		logger.Warnf("not updating target ConfigMap as it is annotated with %s", doNotOverwriteAnnotation)
+		return nil
+	}
+
+	current := configMapEntries(target)
+	desired := desiredData(sourceData, &coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: target.Annotations}, Data: current}, mirrorConfig)
+	if reflect.DeepEqual(current, desired) && target.Annotations[lastAppliedHashAnnotation] == hash && target.Annotations[lastAppliedKeysAnnotation] == keys && containsAll(target.Labels, rule.Labels) && containsAll(target.Annotations, rule.Annotations) && stamped(target, []config.SecretLocation{rule.From}) {
+		logger.Debug("not updating target ConfigMap as it already matches the source")
+		return nil
+	}
+
+	logger.Info("updating target ConfigMap")
+	updated := target.DeepCopy()
+	updated.Data, updated.BinaryData = splitConfigMapEntries(desired, source, target)
+	updated.Labels = withEntries(updated.Labels, rule.Labels)
+	updated.Annotations = withEntries(updated.Annotations, rule.Annotations)
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[lastAppliedHashAnnotation] = hash
+	updated.Annotations[lastAppliedKeysAnnotation] = keys
+	stamp(updated, []config.SecretLocation{rule.From}, source.ResourceVersion, c.config().Revision, time.Now())
+	if _, err := configMaps.Update(updated); err != nil {
+		return err
+	}
+	c.recordWrite(rule, auditlog.OperationUpdate, hash)
+	return nil
+}
+
+// recordWrite appends the write of the target of the rule to the audit
+// log. ConfigMaps are not secret, so the hash of their data is not keyed.
+func (c *ConfigMapMirror) recordWrite(rule config.ConfigMapMirrorConfig, operation, hash string) {
+	recordAudit(c.auditLog, auditlog.Entry{Operation: operation, Rule: rule.String(), Source: rule.From.String(), Target: rule.To.String(), Hash: hash}, c.logger)
+}
+
+// configMapEntries returns the data and binary data of the ConfigMap in
+// one map. Keys are unique across both, so none are lost.
+func configMapEntries(configMap *coreapi.ConfigMap) map[string][]byte {
+	entries := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for key, value := range configMap.Data {
+		entries[key] = []byte(value)
+	}
+	for key, value := range configMap.BinaryData {
+		entries[key] = value
+	}
+	return entries
+}
+
+// splitConfigMapEntries splits entries into data and binary data. Keys of
+// the source keep their kind, while keys only the target holds keep theirs.
+func splitConfigMapEntries(entries map[string][]byte, source, target *coreapi.ConfigMap) (map[string]string, map[string][]byte) {
+	var data map[string]string
+	var binaryData map[string][]byte
+	for key, value := range entries {
+		_, sourceBinary := source.BinaryData[key]
+		_, sourceText := source.Data[key]
+		targetBinary := false
+		if target != nil {
+			_, targetBinary = target.BinaryData[key]
+		}
+		if sourceBinary || (!sourceText && targetBinary) {
+			if binaryData == nil {
+				binaryData = map[string][]byte{}
+			}
+			binaryData[key] = value
+			continue
+		}
+		if data == nil {
+			data = map[string]string{}
+		}
+		data[key] = string(value)
+	}
+	return data, binaryData
+}
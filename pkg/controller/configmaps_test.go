@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileConfigMap(t *testing.T) {
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "src"},
+		Data:       map[string]string{"config.yaml": "new", "removed": "value"},
+		BinaryData: map[string][]byte{"logo.png": {0xff, 0x00}},
+	}
+	var testCases = []struct {
+		name               string
+		target             *v1.ConfigMap
+		merge              bool
+		expectedData       map[string]string
+		expectedBinaryData map[string][]byte
+		expectedOperation  string
+	}{
+		{
+			name:               "missing target is created",
+			expectedData:       map[string]string{"config.yaml": "new", "removed": "value"},
+			expectedBinaryData: map[string][]byte{"logo.png": {0xff, 0x00}},
+			expectedOperation:  auditlog.OperationCreate,
+		},
+		{
+			name: "existing target is replaced",
+			target: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst"},
+				Data:       map[string]string{"config.yaml": "old", "other": "value"},
+			},
+			expectedData:       map[string]string{"config.yaml": "new", "removed": "value"},
+			expectedBinaryData: map[string][]byte{"logo.png": {0xff, 0x00}},
+			expectedOperation:  auditlog.OperationUpdate,
+		},
+		{
+			name: "merging preserves keys added by others and ignored keys",
+			target: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst"},
+				Data:       map[string]string{"config.yaml": "old", "other": "value", "owned": "elsewhere"},
+				BinaryData: map[string][]byte{"blob": {0x01}},
+			},
+			merge:              true,
+			expectedData:       map[string]string{"config.yaml": "new", "removed": "value", "other": "value", "owned": "elsewhere"},
+			expectedBinaryData: map[string][]byte{"logo.png": {0xff, 0x00}, "blob": {0x01}},
+			expectedOperation:  auditlog.OperationUpdate,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().ConfigMaps()
+			for _, configMap := range []*v1.ConfigMap{source, testCase.target} {
+				if configMap == nil {
+					continue
+				}
+				if _, err := client.CoreV1().ConfigMaps(configMap.Namespace).Create(configMap); err != nil {
+					t.Fatal(err)
+				}
+				if err := informer.Informer().GetIndexer().Add(configMap); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{ConfigMaps: []config.ConfigMapMirrorConfig{
+				{
+					From:             config.SecretLocation{Namespace: "ci", Name: "src"},
+					To:               config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+					Merge:            testCase.merge,
+					IgnoreTargetKeys: []string{"owned"},
+					Labels:           map[string]string{"team": "ci"},
+				},
+			}})
+			var log bytes.Buffer
+			c := NewConfigMapMirror(informer, client, ca.Config, RetryPolicy{}, auditlog.New(&log, "controller", []byte("audit")))
+			if err := c.reconcile("ci/src"); err != nil {
+				t.Fatalf("failed to reconcile: %v", err)
+			}
+
+			target, err := client.CoreV1().ConfigMaps("test-ns").Get("dst", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(target.Data, testCase.expectedData) {
+				t.Errorf("expected data %v, got %v", testCase.expectedData, target.Data)
+			}
+			if !reflect.DeepEqual(target.BinaryData, testCase.expectedBinaryData) {
+				t.Errorf("expected binary data %v, got %v", testCase.expectedBinaryData, target.BinaryData)
+			}
+			if target.Labels["team"] != "ci" {
+				t.Errorf("expected the labels of the rule on the target, got %v", target.Labels)
+			}
+			var entry auditlog.Entry
+			if err := json.Unmarshal(log.Bytes(), &entry); err != nil {
+				t.Fatalf("expected the write to be recorded in the audit log: %v", err)
+			}
+			if entry.Operation != testCase.expectedOperation || entry.Rule != "(configmap ci/src -> test-ns/dst)" || entry.Source != "ci/src" || entry.Target != "test-ns/dst" || entry.Hash != target.Annotations[lastAppliedHashAnnotation] {
+				t.Errorf("expected the %s of the target to be recorded, got %+v", testCase.expectedOperation, entry)
+			}
+
+			// the target now matches the source, so it is left alone
+			if err := informer.Informer().GetIndexer().Add(target); err != nil {
+				t.Fatal(err)
+			}
+			client.ClearActions()
+			if err := c.reconcile("ci/src"); err != nil {
+				t.Fatalf("failed to reconcile: %v", err)
+			}
+			if actions := client.Actions(); len(actions) != 0 {
+				t.Errorf("expected no writes to an up-to-date target, got %v", actions)
+			}
+			if entries := strings.Count(log.String(), "\n"); entries != 1 {
+				t.Errorf("expected no write to be recorded for an up-to-date target, got %d entries", entries)
+			}
+		})
+	}
+}
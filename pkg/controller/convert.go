@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// dockerConfigJSON is the format of the data held under the
+// .dockerconfigjson key of kubernetes.io/dockerconfigjson secrets
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// desiredType determines the type of the target: the type the rule sets,
+// the type a conversion produces or else the type of the source. Types
+// set by the rule or a conversion are enforced on existing targets, while
+// the type of the source is only given to targets that are created or
+// recreated anyway. Service account tokens are mirrored as opaque secrets,
+// as the server only accepts them for the account they belong to.
+func desiredType(source *coreapi.Secret, converted coreapi.SecretType, mirrorConfig config.MirrorConfig) (secretType coreapi.SecretType, enforced bool) {
+	switch {
+	case mirrorConfig.TargetType != "":
+		return coreapi.SecretType(mirrorConfig.TargetType), true
+	case converted != "":
+		return converted, true
+	case source.Type == coreapi.SecretTypeServiceAccountToken:
+		return coreapi.SecretTypeOpaque, false
+	default:
+		return source.Type, false
+	}
+}
+
+// convertData determines the data and type of the target from the source
+// data. Without a conversion, the source data is used as-is and the type
+// is left for the server to default.
+func convertData(source map[string][]byte, conversion *config.Conversion) (map[string][]byte, coreapi.SecretType, error) {
+	if conversion == nil {
+		return source, "", nil
+	}
+	switch conversion.Type {
+	case config.ConversionDockerConfigJSON:
+		data, err := toDockerConfigJSON(source, conversion)
+		return data, coreapi.SecretTypeDockerConfigJson, err
+	case config.ConversionOpaque:
+		data, err := fromDockerConfigJSON(source, conversion)
+		return data, coreapi.SecretTypeOpaque, err
+	default:
+		return nil, "", fmt.Errorf("unknown conversion type %q", conversion.Type)
+	}
+}
+
+func toDockerConfigJSON(source map[string][]byte, conversion *config.Conversion) (map[string][]byte, error) {
+	registryKey, usernameKey, passwordKey := conversion.Keys()
+	var missing []string
+	for _, key := range []string{registryKey, usernameKey, passwordKey} {
+		if len(source[key]) == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("source is missing keys required for the conversion: %s", strings.Join(missing, ", "))
+	}
+	username, password := string(source[usernameKey]), string(source[passwordKey])
+	raw, err := json.Marshal(dockerConfigJSON{Auths: map[string]dockerConfigEntry{
+		string(source[registryKey]): {
+			Username: username,
+			Password: password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+		},
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize docker configuration: %v", err)
+	}
+	return map[string][]byte{coreapi.DockerConfigJsonKey: raw}, nil
+}
+
+func fromDockerConfigJSON(source map[string][]byte, conversion *config.Conversion) (map[string][]byte, error) {
+	raw, present := source[coreapi.DockerConfigJsonKey]
+	if !present {
+		return nil, fmt.Errorf("source is missing the %s key required for the conversion", coreapi.DockerConfigJsonKey)
+	}
+	var dockerConfig dockerConfigJSON
+	if err := json.Unmarshal(raw, &dockerConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse docker configuration: %v", err)
+	}
+
+	registry := conversion.Registry
+	if len(registry) == 0 {
+		if len(dockerConfig.Auths) != 1 {
+			var registries []string
+			for name := range dockerConfig.Auths {
+				registries = append(registries, name)
+			}
+			sort.Strings(registries)
+			return nil, fmt.Errorf("source holds credentials for %d registries (%s), a registry must be selected", len(registries), strings.Join(registries, ", "))
+		}
+		for name := range dockerConfig.Auths {
+			registry = name
+		}
+	}
+	entry, present := dockerConfig.Auths[registry]
+	if !present {
+		return nil, fmt.Errorf("source holds no credentials for registry %s", registry)
+	}
+
+	username, password := entry.Username, entry.Password
+	if (len(username) == 0 || len(password) == 0) && len(entry.Auth) != 0 {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode credentials for registry %s: %v", registry, err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed credentials for registry %s", registry)
+		}
+		username, password = parts[0], parts[1]
+	}
+
+	registryKey, usernameKey, passwordKey := conversion.Keys()
+	return map[string][]byte{
+		registryKey: []byte(registry),
+		usernameKey: []byte(username),
+		passwordKey: []byte(password),
+	}, nil
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestConvertData(t *testing.T) {
+	opaque := map[string][]byte{
+		"registry": []byte("quay.io"),
+		"username": []byte("robot"),
+		"password": []byte("hunter2"),
+	}
+	dockerConfig := map[string][]byte{
+		v1.DockerConfigJsonKey: []byte(`{"auths":{"quay.io":{"username":"robot","password":"hunter2","auth":"cm9ib3Q6aHVudGVyMg=="}}}`),
+	}
+	var testCases = []struct {
+		name         string
+		data         map[string][]byte
+		conversion   *config.Conversion
+		expected     map[string][]byte
+		expectedType v1.SecretType
+		expectedErr  bool
+	}{
+		{
+			name:     "without a conversion the data is unchanged",
+			data:     opaque,
+			expected: opaque,
+		},
+		{
+			name:         "opaque keys are converted to a docker configuration",
+			data:         opaque,
+			conversion:   &config.Conversion{Type: config.ConversionDockerConfigJSON},
+			expected:     dockerConfig,
+			expectedType: v1.SecretTypeDockerConfigJson,
+		},
+		{
+			name:         "custom key names are honored",
+			data:         map[string][]byte{"host": []byte("quay.io"), "user": []byte("robot"), "token": []byte("hunter2")},
+			conversion:   &config.Conversion{Type: config.ConversionDockerConfigJSON, RegistryKey: "host", UsernameKey: "user", PasswordKey: "token"},
+			expected:     dockerConfig,
+			expectedType: v1.SecretTypeDockerConfigJson,
+		},
+		{
+			name:        "missing opaque keys are an error",
+			data:        map[string][]byte{"registry": []byte("quay.io")},
+			conversion:  &config.Conversion{Type: config.ConversionDockerConfigJSON},
+			expectedErr: true,
+		},
+		{
+			name:         "a docker configuration is converted to opaque keys",
+			data:         dockerConfig,
+			conversion:   &config.Conversion{Type: config.ConversionOpaque},
+			expected:     opaque,
+			expectedType: v1.SecretTypeOpaque,
+		},
+		{
+			name:         "credentials are decoded from the auth field",
+			data:         map[string][]byte{v1.DockerConfigJsonKey: []byte(`{"auths":{"quay.io":{"auth":"cm9ib3Q6aHVudGVyMg=="}}}`)},
+			conversion:   &config.Conversion{Type: config.ConversionOpaque},
+			expected:     opaque,
+			expectedType: v1.SecretTypeOpaque,
+		},
+		{
+			name:        "several registries require a selection",
+			data:        map[string][]byte{v1.DockerConfigJsonKey: []byte(`{"auths":{"quay.io":{"auth":"cm9ib3Q6aHVudGVyMg=="},"docker.io":{"auth":"YTpi"}}}`)},
+			conversion:  &config.Conversion{Type: config.ConversionOpaque},
+			expectedErr: true,
+		},
+		{
+			name:         "the selected registry is extracted",
+			data:         map[string][]byte{v1.DockerConfigJsonKey: []byte(`{"auths":{"quay.io":{"auth":"cm9ib3Q6aHVudGVyMg=="},"docker.io":{"auth":"YTpi"}}}`)},
+			conversion:   &config.Conversion{Type: config.ConversionOpaque, Registry: "quay.io"},
+			expected:     opaque,
+			expectedType: v1.SecretTypeOpaque,
+		},
+		{
+			name:        "a missing docker configuration is an error",
+			data:        opaque,
+			conversion:  &config.Conversion{Type: config.ConversionOpaque},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			data, secretType, err := convertData(testCase.data, testCase.conversion)
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if testCase.expectedErr {
+				return
+			}
+			if !reflect.DeepEqual(data, testCase.expected) {
+				t.Errorf("%s: expected data %q, got %q", testCase.name, testCase.expected, data)
+			}
+			if secretType != testCase.expectedType {
+				t.Errorf("%s: expected type %q, got %q", testCase.name, testCase.expectedType, secretType)
+			}
+		})
+	}
+}
+
+func TestReconcileConvertsType(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data: map[string][]byte{
+			"registry": []byte("quay.io"),
+			"username": []byte("robot"),
+			"password": []byte("hunter2"),
+		},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:       config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:         config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+			Conversion: &config.Conversion{Type: config.ConversionDockerConfigJSON},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be created: %v", err)
+	}
+	if target.Type != v1.SecretTypeDockerConfigJson {
+		t.Errorf("expected the target to have type %s, got %s", v1.SecretTypeDockerConfigJson, target.Type)
+	}
+	if _, present := target.Data[v1.DockerConfigJsonKey]; !present {
+		t.Errorf("expected the target to hold %s, got keys %s", v1.DockerConfigJsonKey, formatKeys(target.Data))
+	}
+}
+
+func TestDesiredType(t *testing.T) {
+	var testCases = []struct {
+		name             string
+		sourceType       v1.SecretType
+		converted        v1.SecretType
+		mirrorConfig     config.MirrorConfig
+		expectedType     v1.SecretType
+		expectedEnforced bool
+	}{
+		{
+			name:         "the type of the source is preserved",
+			sourceType:   v1.SecretTypeTLS,
+			expectedType: v1.SecretTypeTLS,
+		},
+		{
+			name:         "service account tokens are mirrored as opaque secrets",
+			sourceType:   v1.SecretTypeServiceAccountToken,
+			expectedType: v1.SecretTypeOpaque,
+		},
+		{
+			name:             "the type of a conversion is enforced",
+			sourceType:       v1.SecretTypeOpaque,
+			converted:        v1.SecretTypeDockerConfigJson,
+			expectedType:     v1.SecretTypeDockerConfigJson,
+			expectedEnforced: true,
+		},
+		{
+			name:             "the type set by the rule is enforced",
+			sourceType:       v1.SecretTypeOpaque,
+			mirrorConfig:     config.MirrorConfig{TargetType: string(v1.SecretTypeTLS)},
+			expectedType:     v1.SecretTypeTLS,
+			expectedEnforced: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			source := &v1.Secret{Type: testCase.sourceType}
+			actual, enforced := desiredType(source, testCase.converted, testCase.mirrorConfig)
+			if actual != testCase.expectedType || enforced != testCase.expectedEnforced {
+				t.Errorf("%s: expected type %s enforced %t, got %s enforced %t", testCase.name, testCase.expectedType, testCase.expectedEnforced, actual, enforced)
+			}
+		})
+	}
+}
+
+func TestReconcilePreservesType(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Type:       v1.SecretTypeTLS,
+		Data:       map[string][]byte{v1.TLSCertKey: []byte("cert"), v1.TLSPrivateKeyKey: []byte("key")},
+	})
+	opaque := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "opaque", Labels: map[string]string{managedByLabel: managedByValue}},
+		Type:       v1.SecretTypeOpaque,
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Create(opaque); err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(opaque); err != nil {
+		t.Fatal(err)
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{From: config.SecretLocation{Namespace: "test-ns", Name: "src"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}},
+		{From: config.SecretLocation{Namespace: "test-ns", Name: "src"}, To: config.SecretLocation{Namespace: "test-ns", Name: "opaque"}},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	for name, expected := range map[string]v1.SecretType{"dst": v1.SecretTypeTLS, "opaque": v1.SecretTypeOpaque} {
+		target, err := client.CoreV1().Secrets("test-ns").Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected target %s to be written: %v", name, err)
+		}
+		if target.Type != expected {
+			t.Errorf("expected target %s to have type %s, got %s", name, expected, target.Type)
+		}
+		if string(target.Data[v1.TLSCertKey]) != "cert" {
+			t.Errorf("expected target %s to hold the source data, got keys %s", name, formatKeys(target.Data))
+		}
+	}
+}
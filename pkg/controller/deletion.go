@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// propagateDeletion deletes the targets of the rules that propagate
+// the deletion of their source.
+func (c *SecretMirror) propagateDeletion(configuration *config.Configuration, rules []config.MirrorConfig, logger *logrus.Entry) error {
+	var mirrorErrors MirrorErrors
+	for _, mirrorConfig := range rules {
+		if !mirrorConfig.PropagateDeletion {
+			continue
+		}
+		to := mirrorConfig.To
+		ruleLogger := logger.WithFields(logrus.Fields{"rule": mirrorConfig.String(), "target-namespace": to.Namespace, "target-secret": to.Name})
+		if err := c.deleteTarget(configuration, mirrorConfig, ruleLogger); err != nil {
+			mirrorErrors = append(mirrorErrors, &MirrorError{Rule: mirrorConfig.String(), Target: to.String(), Err: err})
+		}
+	}
+	if len(mirrorErrors) > 0 {
+		return mirrorErrors
+	}
+	return nil
+}
+
+// deleteTarget deletes the target of the rule. Targets the controller did
+// not write, targets that are held or protected and targets that rules
+// mirroring from other sources write to are left alone, and nothing is
+// deleted while writes are suspended.
+func (c *SecretMirror) deleteTarget(configuration *config.Configuration, mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	to := mirrorConfig.To
+	for _, other := range configuration.Secrets {
+		if other.IsPattern() {
+			if _, matches := other.Instantiate(mirrorConfig.From); matches {
+				continue
+			}
+		} else if other.From.Equals(mirrorConfig.From) {
+			continue
+		}
+		if other.WritesTo(to) {
+			logger.WithField("other-rule", other.String()).Info("not deleting target secret as a rule mirroring from another source writes to it")
+			return nil
+		}
+	}
+	target, err := c.getTarget(to)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get target secret: %v", err)
+	}
+	_, err = c.deleteManagedTarget(to, target, logger)
+	return err
+}
+
+// deleteManagedTarget deletes the target if the controller wrote it,
+// returning whether it was deleted.
+func (c *SecretMirror) deleteManagedTarget(to config.SecretLocation, target *coreapi.Secret, logger *logrus.Entry) (bool, error) {
+	if reason := deletionGuard(to, target, c.config(), c.protectedTargets); reason != "" {
+		logger.Infof("not deleting target secret as %s", reason)
+		return false, nil
+	}
+	if c.reportOnly || c.freeze.isFrozen() {
+		logger.Info("not deleting target secret while writes are suspended")
+		return false, nil
+	}
+	targets, err := c.targetClient(to)
+	if err != nil {
+		return false, err
+	}
+	// the target may have been replaced since we observed it
+	options := &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &target.UID}}
+	if err := targets.CoreV1().Secrets(to.Namespace).Delete(to.Name, options); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to delete target secret: %v", err)
+	}
+	c.applied.forget(to.String())
+	c.recordAudit(auditlog.Entry{Operation: auditlog.OperationDelete, Source: target.Annotations[mirrorSourceAnnotation], Target: to.String(), Hash: c.auditHash(target.Annotations[lastAppliedHashAnnotation])})
+	logger.Info("deleted target secret")
+	return true, nil
+}
+
+// deletionGuard returns why the controller must leave the target alone
+// under the configuration, or the empty string if it may delete it: only
+// targets it wrote, that are not annotated to be left alone, that are not
+// protected by the patterns and that live in namespaces the policy allows
+// may be deleted.
+func deletionGuard(to config.SecretLocation, target *coreapi.Secret, configuration *config.Configuration, protectedPatterns []string) string {
+	if pattern, protected := matchProtected(protectedPatterns, to); protected {
+		return fmt.Sprintf("it is protected by pattern %s", pattern)
+	}
+	if !configuration.Policy.AllowsNamespace(to.Namespace) {
+		return "the policy does not allow its namespace"
+	}
+	if _, managed := target.Annotations[lastAppliedHashAnnotation]; !managed {
+		return "the controller did not write it"
+	}
+	if target.Annotations[doNotOverwriteAnnotation] == "true" {
+		return fmt.Sprintf("it is annotated with %s", doNotOverwriteAnnotation)
+	}
+	return ""
+}
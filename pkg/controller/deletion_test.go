@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestPropagateDeletion(t *testing.T) {
+	managed := func(namespace string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "token", Annotations: map[string]string{lastAppliedHashAnnotation: "hash"}},
+			Data:       map[string][]byte{"key": []byte("value")},
+		}
+	}
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, managed("propagated"))
+	for _, target := range []*v1.Secret{
+		managed("kept"),
+		managed("held"),
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "unmanaged", Name: "token"}},
+	} {
+		if target.Namespace == "held" {
+			target.Annotations[doNotOverwriteAnnotation] = "true"
+		}
+		if _, err := client.CoreV1().Secrets(target.Namespace).Create(target); err != nil {
+			t.Fatal(err)
+		}
+		if err := informer.Informer().GetIndexer().Add(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	from := config.SecretLocation{Namespace: "ci", Name: "token"}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{From: from, To: config.SecretLocation{Namespace: "propagated", Name: "token"}, PropagateDeletion: true},
+		{From: from, To: config.SecretLocation{Namespace: "kept", Name: "token"}},
+		{From: from, To: config.SecretLocation{Namespace: "held", Name: "token"}, PropagateDeletion: true},
+		{From: from, To: config.SecretLocation{Namespace: "unmanaged", Name: "token"}, PropagateDeletion: true},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	if err := c.reconcile("ci/token"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	for namespace, expected := range map[string]bool{"propagated": false, "kept": true, "held": true, "unmanaged": true} {
+		if _, err := client.CoreV1().Secrets(namespace).Get("token", metav1.GetOptions{}); (err == nil) != expected {
+			t.Errorf("expected the target in %s to exist: %t, got error %v", namespace, expected, err)
+		}
+	}
+}
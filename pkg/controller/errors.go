@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// MirrorError is the failure of one rule to mirror its source into its target.
+type MirrorError struct {
+	Rule   string
+	Target string
+	Err    error
+}
+
+func (e *MirrorError) Error() string {
+	return fmt.Sprintf("failed to mirror into %s: %v", e.Target, e.Err)
+}
+
+// MirrorErrors aggregates the failures to mirror a source into its targets,
+// so that the failure of one target does not hide which of them broke.
+type MirrorErrors []*MirrorError
+
+func (e MirrorErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("failed to mirror secret into %d target(s): %s", len(e), strings.Join(messages, "; "))
+}
+
+// lastErrors records the last failure of every rule that is failing.
+type lastErrors struct {
+	lock   sync.RWMutex
+	errors map[string]string
+}
+
+func (l *lastErrors) get(rule string) string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.errors[rule]
+}
+
+func (l *lastErrors) set(rule string, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.errors == nil {
+		l.errors = map[string]string{}
+	}
+	if err == nil {
+		delete(l.errors, rule)
+		return
+	}
+	l.errors[rule] = err.Error()
+}
+
+// recordResult surfaces the outcome of mirroring into one target in the
+// status of the rule and in metrics, and records failures as events on
+// the source.
+func (c *SecretMirror) recordResult(source *coreapi.Secret, mirrorConfig config.MirrorConfig, err error) {
+	rule, target := mirrorConfig.String(), mirrorConfig.To.String()
+	c.lastErrors.set(rule, err)
+	c.rollout.observe(mirrorConfig, err)
+	if err == nil {
+		c.lastSyncs.set(rule, time.Now())
+		failingTargets.delete(target)
+		return
+	}
+	failingTargets.set(target, 1)
+	c.recorder.Eventf(source, coreapi.EventTypeWarning, reasonFailed, "Failed to mirror into %s: %v", target, err)
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileAggregatesErrorsPerTarget(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	working := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}
+	broken := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "default-token-x7k2p"},
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{working, broken}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+
+	err := c.reconcile("test-ns/src")
+	mirrorErrors, ok := err.(MirrorErrors)
+	if !ok {
+		t.Fatalf("expected the failure to be reported per target, got %T: %v", err, err)
+	}
+	if len(mirrorErrors) != 1 || mirrorErrors[0].Target != "test-ns/default-token-x7k2p" || mirrorErrors[0].Rule != broken.String() {
+		t.Errorf("expected only the broken target to be reported, got %v", mirrorErrors)
+	}
+	var failures []string
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, reasonFailed) {
+			failures = append(failures, event)
+		}
+	}
+	if len(failures) != 1 {
+		t.Errorf("expected one event about the broken target, got %v", failures)
+	}
+	for _, status := range c.Rules() {
+		if failing := status.LastError != ""; failing != (status.Rule == broken.String()) {
+			t.Errorf("expected only the broken rule to report its last error, got %q for %s", status.LastError, status.Rule)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// Reasons of the events recorded on sources and targets, kept together
+// so that everything watching them can rely on a fixed set.
+const (
+	// reasonCreated is recorded on the source and the target when
+	// the target is created
+	reasonCreated = "MirroringCreated"
+	// reasonUpdated is recorded on the source and the target when
+	// the target is updated or recreated
+	reasonUpdated = "MirroringUpdated"
+	// reasonFailed is recorded on the source when a target could
+	// not be written
+	reasonFailed = "MirroringFailed"
+	// reasonHeld is recorded on targets suspended by an annotation
+	reasonHeld = "MirroringHeld"
+	// reasonCollision is recorded on the source when rules write
+	// conflicting data to the same target
+	reasonCollision = "MirroringCollision"
+	// reasonDrift is recorded on the source when its target drifted
+	// in report-only mode
+	reasonDrift = "MirroringDrift"
+	// reasonApprovalRequired is recorded on the source when a change
+	// to its target awaits approval
+	reasonApprovalRequired = "ApprovalRequired"
+)
+
+// recordWrite records that the target was written from the source as
+// events on both and in the audit log. Targets in other clusters only
+// get the event on the source, as events are recorded in the local
+// cluster.
+func (c *SecretMirror) recordWrite(source, target *coreapi.Secret, mirrorConfig config.MirrorConfig, reason, hash string) {
+	verb, operation := "Updated", auditlog.OperationUpdate
+	if reason == reasonCreated {
+		verb, operation = "Created", auditlog.OperationCreate
+	}
+	c.recordAudit(auditlog.Entry{Operation: operation, Rule: mirrorConfig.String(), Source: formatSources(mirrorConfig.Sources()), Target: mirrorConfig.To.String(), Hash: c.auditHash(hash)})
+	c.recorder.Eventf(source, coreapi.EventTypeNormal, reason, "%s %s from this secret", verb, mirrorConfig.To.String())
+	// targets written as SealedSecrets are not the Secrets events refer to
+	if mirrorConfig.To.Cluster == "" && mirrorConfig.To.Provider() == "" && mirrorConfig.Output != config.OutputSealedSecret && target != nil {
+		c.recorder.Eventf(target, coreapi.EventTypeNormal, reason, "%s from %s", verb, mirrorConfig.From.String())
+	}
+}
+
+// auditHash keys the hash of data recorded in the audit log, which is
+// readable by those who may not read the data. Without a hash key, no
+// hash is recorded.
+func (c *SecretMirror) auditHash(hash string) string {
+	if len(c.hashKey) == 0 || hash == "" {
+		return ""
+	}
+	return keyedHash(c.hashKey, hash)
+}
+
+// recordAudit appends the entry to the audit log.
+func (c *SecretMirror) recordAudit(entry auditlog.Entry) {
+	recordAudit(c.auditLog, entry, c.logger)
+}
+
+// recordAudit appends the entry to the audit log and exposes how many
+// entries it holds, so that logs that lost entries can be told apart.
+// Writes that cannot be logged are not undone, but fail loudly.
+func recordAudit(log *auditlog.Log, entry auditlog.Entry, logger *logrus.Entry) {
+	if log == nil {
+		return
+	}
+	if err := log.Record(entry); err != nil {
+		logger.WithError(err).WithField("target", entry.Target).Error("failed to record the write in the audit log")
+		auditLogFailures.Inc()
+	}
+	auditLogEntries.Set(float64(log.Entries()))
+}
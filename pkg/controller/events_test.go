@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileRecordsWrites(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+	expectWrites := func(reason string) {
+		t.Helper()
+		recorded := events()
+		if len(recorded) != 2 {
+			t.Fatalf("expected %s events on the source and the target, got %v", reason, recorded)
+		}
+		for _, event := range recorded {
+			if !strings.HasPrefix(event, v1.EventTypeNormal+" "+reason+" ") {
+				t.Errorf("expected a %s event, got %q", reason, event)
+			}
+		}
+	}
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	expectWrites(reasonCreated)
+
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.Data = map[string][]byte{"key": []byte("edited")}
+	edited, err := client.CoreV1().Secrets("test-ns").Update(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(edited); err != nil {
+		t.Fatal(err)
+	}
+	c.applied.forget("test-ns/dst")
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	expectWrites(reasonUpdated)
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if recorded := events(); len(recorded) != 0 {
+		t.Errorf("expected no events when the target is unchanged, got %v", recorded)
+	}
+}
+
+func TestAuditLogRecordsWrites(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("confidential")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}}})
+	var log bytes.Buffer
+	c := NewSecretMirror(informer, client, ca.Config, Options{AuditLog: auditlog.New(&log, "controller", []byte("audit")), HashKey: []byte("key")})
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.Data = map[string][]byte{"key": []byte("edited")}
+	edited, err := client.CoreV1().Secrets("test-ns").Update(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(edited); err != nil {
+		t.Fatal(err)
+	}
+	c.applied.forget("test-ns/dst")
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err = client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.deleteManagedTarget(config.SecretLocation{Namespace: "test-ns", Name: "dst"}, target, c.logger); err != nil {
+		t.Fatalf("failed to delete the target: %v", err)
+	}
+
+	if _, err := auditlog.Verify(bytes.NewReader(log.Bytes()), []byte("audit")); err != nil {
+		t.Errorf("expected a valid audit log: %v", err)
+	}
+	var operations []string
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var entry auditlog.Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Target != "test-ns/dst" || entry.Source != "test-ns/src" || entry.Hash != keyedHash([]byte("key"), target.Annotations[lastAppliedHashAnnotation]) || entry.Actor != "controller" {
+			t.Errorf("expected the entry to record the source, target, keyed hash and actor, got %+v", entry)
+		}
+		operations = append(operations, entry.Operation)
+	}
+	if expected := []string{auditlog.OperationCreate, auditlog.OperationUpdate, auditlog.OperationDelete}; !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected operations %v, got %v", expected, operations)
+	}
+	if strings.Contains(log.String(), "confidential") || strings.Contains(log.String(), base64.StdEncoding.EncodeToString([]byte("confidential"))) {
+		t.Errorf("expected the audit log to never hold values, got %s", log.String())
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/sirupsen/logrus"
+)
+
+// credentialExpiry finds credentials that expire in the value, which may
+// be a JWT or a kubeconfig holding tokens or client certificates, and
+// returns the earliest expiry among them.
+func credentialExpiry(value []byte) (time.Time, bool) {
+	if expiry, ok := tokenExpiry(string(value)); ok {
+		return expiry, true
+	}
+	return kubeconfigExpiry(value)
+}
+
+// tokenExpiry returns the expiry recorded in the claims of a JWT.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if !decodeSegment(parts[0], &header) || header.Algorithm == "" {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Expiry *float64 `json:"exp"`
+	}
+	if !decodeSegment(parts[1], &claims) || claims.Expiry == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(*claims.Expiry), 0), true
+}
+
+func decodeSegment(segment string, into interface{}) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(raw, into) == nil
+}
+
+// kubeconfigExpiry returns the earliest expiry among the tokens and
+// client certificates of the users in a kubeconfig.
+func kubeconfigExpiry(value []byte) (time.Time, bool) {
+	if !bytes.Contains(value, []byte("users")) {
+		return time.Time{}, false
+	}
+	var kubeconfig struct {
+		Users []struct {
+			User struct {
+				Token                 string `json:"token"`
+				ClientCertificateData []byte `json:"client-certificate-data"`
+			} `json:"user"`
+		} `json:"users"`
+	}
+	if err := yaml.Unmarshal(value, &kubeconfig); err != nil {
+		return time.Time{}, false
+	}
+	var earliest time.Time
+	found := false
+	consider := func(expiry time.Time) {
+		if !found || expiry.Before(earliest) {
+			earliest, found = expiry, true
+		}
+	}
+	for _, user := range kubeconfig.Users {
+		if expiry, ok := tokenExpiry(user.User.Token); ok {
+			consider(expiry)
+		}
+		if block, _ := pem.Decode(user.User.ClientCertificateData); block != nil {
+			if certificate, err := x509.ParseCertificate(block.Bytes); err == nil {
+				consider(certificate.NotAfter)
+			}
+		}
+	}
+	return earliest, found
+}
+
+// expiries exports the expiry of credentials mirrored into targets and
+// remembers which keys held them, so that series for credentials that
+// are no longer mirrored are removed.
+type expiries struct {
+	lock sync.Mutex
+	// warning is how long before expiry we start to warn
+	warning time.Duration
+	keys    map[string][]string
+}
+
+// observe exports the expiry of credentials in the data mirrored into the
+// target, warning about those that are expired or close to expiry.
+func (e *expiries) observe(target string, data map[string][]byte, logger *logrus.Entry) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.keys == nil {
+		e.keys = map[string][]string{}
+	}
+	for _, key := range e.keys[target] {
+		credentialExpiryTimestamp.delete(target, key)
+	}
+	var keys []string
+	for key, value := range data {
+		expiry, ok := credentialExpiry(value)
+		if !ok {
+			continue
+		}
+		keys = append(keys, key)
+		credentialExpiryTimestamp.set(target, float64(expiry.Unix()), key)
+		keyLogger := logger.WithFields(logrus.Fields{"key": key, "expiry": expiry.Format(time.RFC3339)})
+		if remaining := time.Until(expiry); remaining <= 0 {
+			keyLogger.Warn("mirrored credential has expired")
+		} else if remaining <= e.warning {
+			keyLogger.Warnf("mirrored credential expires in %s", remaining.Round(time.Minute))
+		}
+	}
+	sort.Strings(keys)
+	e.keys[target] = keys
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func testToken(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return fmt.Sprintf("%s.%s.%s", encode([]byte(`{"alg":"RS256","kid":"test"}`)), encode([]byte(claims)), encode([]byte("signature")))
+}
+
+func TestCredentialExpiry(t *testing.T) {
+	root := issueCertificate(t, "root", 1, nil)
+	certificateData := base64.StdEncoding.EncodeToString([]byte(root.pem))
+	token := testToken(`{"iss":"kubernetes/serviceaccount","exp":1500000000}`)
+	expiry := time.Unix(1500000000, 0)
+
+	var testCases = []struct {
+		name     string
+		value    string
+		expected *time.Time
+	}{
+		{
+			name:     "a token with an expiry",
+			value:    token + "\n",
+			expected: &expiry,
+		},
+		{
+			name:  "a token without an expiry",
+			value: testToken(`{"iss":"kubernetes/serviceaccount"}`),
+		},
+		{
+			name:  "dotted text is not a token",
+			value: "registry.example.com",
+		},
+		{
+			name: "a kubeconfig with a token",
+			value: fmt.Sprintf(`apiVersion: v1
+kind: Config
+users:
+- name: robot
+  user:
+    token: %s
+`, token),
+			expected: &expiry,
+		},
+		{
+			name: "a kubeconfig with a client certificate",
+			value: fmt.Sprintf(`apiVersion: v1
+kind: Config
+users:
+- name: admin
+  user:
+    client-certificate-data: %s
+`, certificateData),
+			expected: &root.certificate.NotAfter,
+		},
+		{
+			name: "the earliest expiry in a kubeconfig wins",
+			value: fmt.Sprintf(`users:
+- name: admin
+  user:
+    client-certificate-data: %s
+- name: robot
+  user:
+    token: %s
+`, certificateData, token),
+			expected: &expiry,
+		},
+		{
+			name: "a kubeconfig without expiring credentials",
+			value: `users:
+- name: basic
+  user:
+    username: admin
+`,
+		},
+		{
+			name:  "arbitrary data",
+			value: "users: are friends",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, found := credentialExpiry([]byte(testCase.value))
+			if testCase.expected == nil {
+				if found {
+					t.Errorf("%s: expected no expiry, got %s", testCase.name, actual)
+				}
+				return
+			}
+			if !found {
+				t.Fatalf("%s: expected an expiry, got none", testCase.name)
+			}
+			if !actual.Equal(*testCase.expected) {
+				t.Errorf("%s: expected expiry %s, got %s", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpiriesForgetKeys(t *testing.T) {
+	e := &expiries{warning: time.Hour}
+	logger := logrus.WithField("test", t.Name())
+	token := []byte(testToken(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(time.Minute).Unix())))
+
+	e.observe("ns/name", map[string][]byte{"token": token, "other": token, "plain": []byte("value")}, logger)
+	if expected := []string{"other", "token"}; !reflect.DeepEqual(e.keys["ns/name"], expected) {
+		t.Errorf("expected keys %v to be tracked, got %v", expected, e.keys["ns/name"])
+	}
+	e.observe("ns/name", map[string][]byte{"token": token}, logger)
+	if expected := []string{"token"}; !reflect.DeepEqual(e.keys["ns/name"], expected) {
+		t.Errorf("expected keys %v to be tracked, got %v", expected, e.keys["ns/name"])
+	}
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/externalsecrets"
+)
+
+// externalSecretSyncPeriod is how often the ExternalSecrets of rules are
+// brought up to date with the configuration.
+const externalSecretSyncPeriod = time.Minute
+
+// ExternalSecrets reads and writes the ExternalSecrets that the External
+// Secrets Operator fetches the data of targets from.
+type ExternalSecrets interface {
+	// Fetch returns the ExternalSecret with the name in the namespace.
+	Fetch(namespace, name string) (*externalsecrets.ExternalSecret, error)
+	// Store creates the ExternalSecret, or replaces it if it was
+	// fetched, returning it as stored.
+	Store(external *externalsecrets.ExternalSecret) (*externalsecrets.ExternalSecret, error)
+}
+
+// runExternalSecrets brings the ExternalSecrets of every rule with
+// output: ExternalSecret up to date, logging the failures.
+func (c *SecretMirror) runExternalSecrets() {
+	if err := c.syncExternalSecrets(); err != nil {
+		c.logger.WithError(err).Error("failed to write ExternalSecrets")
+	}
+}
+
+// syncExternalSecrets writes the ExternalSecret of every rule with
+// output: ExternalSecret. Those rules are not reconciled when their
+// source changes, as the operator fetches the data of the source, so
+// their ExternalSecrets only follow the configuration.
+func (c *SecretMirror) syncExternalSecrets() error {
+	configuration := c.config()
+	var errs []error
+	for _, mirrorConfig := range c.concreteRules(configuration) {
+		if mirrorConfig.Output != config.OutputExternalSecret {
+			continue
+		}
+		rule := mirrorConfig.String()
+		logger := c.logger.WithFields(logrus.Fields{"rule": rule, "target-namespace": mirrorConfig.To.Namespace, "target-secret": mirrorConfig.To.Name})
+		if c.pauses.isPaused(rule) {
+			logger.Debug("not writing ExternalSecret because the rule is paused")
+			continue
+		}
+		err := c.writeExternalSecret(configuration.Resolve(mirrorConfig), logger)
+		c.lastErrors.set(rule, err)
+		if err != nil {
+			failingTargets.set(mirrorConfig.To.String(), 1)
+			errs = append(errs, fmt.Errorf("rule %s: %v", rule, err))
+			continue
+		}
+		failingTargets.delete(mirrorConfig.To.String())
+		c.lastSyncs.set(rule, time.Now())
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// externalSecretSpec determines the ExternalSecret that makes the
+// operator fetch every key of the source into the target, with the
+// metadata and type the rule sets.
+func externalSecretSpec(mirrorConfig config.MirrorConfig) externalsecrets.ExternalSecretSpec {
+	key, version := mirrorConfig.From.RemoteKey()
+	spec := externalsecrets.ExternalSecretSpec{
+		SecretStoreRef: externalsecrets.SecretStoreRef{
+			Name: mirrorConfig.ExternalSecret.Store,
+			Kind: mirrorConfig.ExternalSecret.Kind(),
+		},
+		Target: externalsecrets.Target{
+			Name:           mirrorConfig.To.Name,
+			CreationPolicy: externalsecrets.CreationPolicyOwner,
+		},
+		RefreshInterval: mirrorConfig.ExternalSecret.RefreshInterval,
+		DataFrom:        []externalsecrets.DataFromRemoteRef{{Extract: &externalsecrets.RemoteRef{Key: key, Version: version}}},
+	}
+	if len(mirrorConfig.Labels) != 0 || len(mirrorConfig.Annotations) != 0 || mirrorConfig.TargetType != "" {
+		spec.Target.Template = &externalsecrets.Template{
+			Type: coreapi.SecretType(mirrorConfig.TargetType),
+			Metadata: externalsecrets.TemplateMetadata{
+				Labels:      mirrorConfig.Labels,
+				Annotations: mirrorConfig.Annotations,
+			},
+		}
+	}
+	return spec
+}
+
+// specHash identifies the spec of an ExternalSecret. The operator
+// defaults fields of the spec that the controller leaves unset, so the
+// hash recorded on the ExternalSecret tells whether it is up to date
+// rather than its spec.
+func specHash(spec externalsecrets.ExternalSecretSpec) (string, error) {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(raw)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// writeExternalSecret writes the ExternalSecret of the rule unless it is
+// up to date, going through the same checks as writing the target.
+func (c *SecretMirror) writeExternalSecret(mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	if c.externalSecrets == nil {
+		return fmt.Errorf("the rule writes an ExternalSecret but ExternalSecrets are not enabled")
+	}
+	to := mirrorConfig.To
+	if pattern, protected := c.protectedPattern(to); protected {
+		return fmt.Errorf("refusing to write ExternalSecret as the name of the target matches the protected pattern %q", pattern)
+	}
+	if !c.config().Policy.AllowsNamespace(to.Namespace) {
+		return fmt.Errorf("refusing to write ExternalSecret as the policy does not allow namespace %s", to.Namespace)
+	}
+	if mirrorConfig.RequireApproval {
+		// rules fanning out may reach namespaces that loading
+		// the configuration could not tell apart
+		return fmt.Errorf("refusing to write ExternalSecret as changes to namespace %s require approval, which the operator does not wait for", to.Namespace)
+	}
+	spec := externalSecretSpec(mirrorConfig)
+	hash, err := specHash(spec)
+	if err != nil {
+		return fmt.Errorf("failed to hash ExternalSecret: %v", err)
+	}
+	applied := fingerprint(mirrorConfig, hash)
+	if c.applied.matches(to.String(), applied) {
+		return nil
+	}
+
+	operation := auditlog.OperationCreate
+	existing, err := c.externalSecrets.Fetch(to.Namespace, to.Name)
+	switch {
+	case err == nil:
+		if !mirrorConfig.AdoptExisting && !managedTarget(existing) {
+			return fmt.Errorf("refusing to overwrite ExternalSecret as the controller did not create it, set adoptExisting: true to take it over")
+		}
+		if existing.Annotations[lastAppliedHashAnnotation] == hash && containsAll(existing.Labels, mirrorConfig.Labels) {
+			logger.Debug("not updating ExternalSecret as it already matches the rule")
+			noopSyncs.WithLabelValues(noopReasonUnchanged).Inc()
+			c.inventory.record(mirrorConfig, false)
+			c.applied.record(to.String(), applied, existing.ResourceVersion)
+			return nil
+		}
+		operation = auditlog.OperationUpdate
+	case errors.IsNotFound(err):
+		existing = &externalsecrets.ExternalSecret{ObjectMeta: metav1.ObjectMeta{Name: to.Name, Namespace: to.Namespace}}
+	default:
+		return fmt.Errorf("failed to get ExternalSecret: %v", err)
+	}
+	if c.reportOnly || c.freeze.isFrozen() {
+		logger.Warn("not writing ExternalSecret that differs from the rule as writes are disabled")
+		return nil
+	}
+
+	destination := existing.DeepCopy()
+	destination.Spec = spec
+	destination.Labels = withEntries(destination.Labels, mirrorConfig.Labels)
+	destination.Labels = withEntries(destination.Labels, map[string]string{managedByLabel: managedByValue})
+	destination.Annotations = withEntries(destination.Annotations, map[string]string{
+		lastAppliedHashAnnotation: hash,
+		mirrorSourceAnnotation:    formatSources(mirrorConfig.Sources()),
+	})
+	if operation == auditlog.OperationCreate {
+		logger.Info("creating ExternalSecret")
+	} else {
+		logger.Info("updating ExternalSecret")
+	}
+	stored, err := c.externalSecrets.Store(destination)
+	if err != nil {
+		return fmt.Errorf("failed to write ExternalSecret: %v", err)
+	}
+	c.inventory.record(mirrorConfig, true)
+	c.recordAudit(auditlog.Entry{Operation: operation, Rule: mirrorConfig.String(), Source: formatSources(mirrorConfig.Sources()), Target: to.String(), Hash: hash})
+	c.applied.record(to.String(), applied, stored.ResourceVersion)
+	return nil
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/externalsecrets"
+)
+
+// fakeExternalSecrets holds ExternalSecrets
+type fakeExternalSecrets struct {
+	external map[string]*externalsecrets.ExternalSecret
+	stores   int
+}
+
+func (f *fakeExternalSecrets) Fetch(namespace, name string) (*externalsecrets.ExternalSecret, error) {
+	external, found := f.external[namespace+"/"+name]
+	if !found {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: externalsecrets.GroupName, Resource: externalsecrets.Resource}, name)
+	}
+	return external.DeepCopy(), nil
+}
+
+func (f *fakeExternalSecrets) Store(external *externalsecrets.ExternalSecret) (*externalsecrets.ExternalSecret, error) {
+	f.stores++
+	stored := external.DeepCopy()
+	stored.ResourceVersion = strconv.Itoa(f.stores)
+	f.external[external.Namespace+"/"+external.Name] = stored
+	return stored.DeepCopy(), nil
+}
+
+func TestSyncExternalSecrets(t *testing.T) {
+	vault := config.SecretLocation{Vault: config.VaultLocation{Path: "secret/ci/registry"}}
+	var testCases = []struct {
+		name           string
+		from           config.SecretLocation
+		existing       *externalsecrets.ExternalSecret
+		adoptExisting  bool
+		approval       bool
+		expectedKey    string
+		expectedStores int
+		expectedErr    string
+	}{
+		{
+			name:           "missing ExternalSecret is created",
+			from:           config.SecretLocation{Namespace: "ci", Name: "registry"},
+			expectedKey:    "registry",
+			expectedStores: 1,
+		},
+		{
+			name:           "ExternalSecret fetches from Vault",
+			from:           vault,
+			expectedKey:    "ci/registry",
+			expectedStores: 1,
+		},
+		{
+			name: "managed ExternalSecret is updated",
+			from: vault,
+			existing: &externalsecrets.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "registry", Labels: map[string]string{managedByLabel: managedByValue}},
+			},
+			expectedKey:    "ci/registry",
+			expectedStores: 1,
+		},
+		{
+			name: "unmanaged ExternalSecret is not overwritten",
+			from: vault,
+			existing: &externalsecrets.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "registry"},
+			},
+			expectedErr: "adoptExisting",
+		},
+		{
+			name: "unmanaged ExternalSecret is adopted",
+			from: vault,
+			existing: &externalsecrets.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "registry"},
+			},
+			adoptExisting:  true,
+			expectedKey:    "ci/registry",
+			expectedStores: 1,
+		},
+		{
+			name:        "ExternalSecret in a namespace requiring approval is not written",
+			from:        vault,
+			approval:    true,
+			expectedErr: "require approval",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "registry"},
+				Data:       map[string][]byte{"key": []byte("value")},
+			})
+			var approvalNamespaces []string
+			if testCase.approval {
+				approvalNamespaces = []string{"team"}
+			}
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{ApprovalNamespaces: approvalNamespaces, Secrets: []config.MirrorConfig{
+				{
+					From:           testCase.from,
+					To:             config.SecretLocation{Namespace: "team", Name: "registry"},
+					Labels:         map[string]string{"team": "ci"},
+					Output:         config.OutputExternalSecret,
+					ExternalSecret: &config.ExternalSecretConfig{Store: "ci-secrets"},
+					AdoptExisting:  testCase.adoptExisting,
+				},
+			}})
+			external := &fakeExternalSecrets{external: map[string]*externalsecrets.ExternalSecret{}}
+			if testCase.existing != nil {
+				external.external["team/registry"] = testCase.existing
+			}
+			c := NewSecretMirror(informer, client, ca.Config, Options{ExternalSecrets: external})
+			defer c.queue.ShutDown()
+
+			// the source is not mirrored by the controller
+			client.ClearActions()
+			if err := c.reconcile("ci/registry"); err != nil {
+				t.Fatalf("expected the source to be left to the operator, got %v", err)
+			}
+			for _, action := range client.Actions() {
+				if action.GetResource().Resource == "secrets" && action.GetVerb() != "get" {
+					t.Errorf("expected no secret to be written, got %v", action)
+				}
+			}
+
+			err := c.syncExternalSecrets()
+			if testCase.expectedErr == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Fatalf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+			if external.stores != testCase.expectedStores {
+				t.Errorf("expected %d writes, got %d", testCase.expectedStores, external.stores)
+			}
+			if err != nil {
+				return
+			}
+
+			written := external.external["team/registry"]
+			expected := externalsecrets.ExternalSecretSpec{
+				SecretStoreRef: externalsecrets.SecretStoreRef{Name: "ci-secrets", Kind: externalsecrets.KindClusterSecretStore},
+				Target: externalsecrets.Target{
+					Name:           "registry",
+					CreationPolicy: externalsecrets.CreationPolicyOwner,
+					Template:       &externalsecrets.Template{Metadata: externalsecrets.TemplateMetadata{Labels: map[string]string{"team": "ci"}}},
+				},
+				DataFrom: []externalsecrets.DataFromRemoteRef{{Extract: &externalsecrets.RemoteRef{Key: testCase.expectedKey}}},
+			}
+			if !reflect.DeepEqual(written.Spec, expected) {
+				t.Errorf("expected the ExternalSecret to fetch the source, got %+v", written.Spec)
+			}
+			if !managedTarget(written) {
+				t.Error("expected the ExternalSecret to be marked as managed")
+			}
+
+			// the ExternalSecret is not written again unless the rule changes
+			c.applied.forget("team/registry")
+			if err := c.syncExternalSecrets(); err != nil || external.stores != testCase.expectedStores {
+				t.Errorf("expected the ExternalSecret not to be written again, got %d writes and %v", external.stores, err)
+			}
+		})
+	}
+}
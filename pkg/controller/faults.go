@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Faults that can be injected into requests to the API server
+const (
+	faultError     = "error"
+	faultLatency   = "latency"
+	faultWatchDrop = "watch_drop"
+)
+
+// injectedErrorBody is the Status the API server would respond with
+// when it fails to handle a request.
+const injectedErrorBody = `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"fault injected by the secret mirroring controller","reason":"InternalError","code":500}`
+
+// FaultInjector injects faults into requests to the API server, so that
+// retries, back-offs and quarantine can be exercised in staging. Rates
+// are the probability of a request being affected, between 0 and 1.
+type FaultInjector struct {
+	// ErrorRate fails requests with an internal server error
+	// without sending them to the API server
+	ErrorRate float64
+	// LatencyRate delays requests by Latency
+	LatencyRate float64
+	Latency     time.Duration
+	// WatchDropRate drops watches after a random
+	// period of up to WatchDropAfter
+	WatchDropRate  float64
+	WatchDropAfter time.Duration
+
+	lock   sync.Mutex
+	random *rand.Rand
+}
+
+// Enabled determines if the injector injects any faults.
+func (f *FaultInjector) Enabled() bool {
+	return f.ErrorRate > 0 || (f.LatencyRate > 0 && f.Latency > 0) || (f.WatchDropRate > 0 && f.WatchDropAfter > 0)
+}
+
+// WrapTransport injects faults into the requests made through the
+// transport, for use as the WrapTransport of a client config.
+func (f *FaultInjector) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		if f.Latency > 0 && f.roll(f.LatencyRate) {
+			injectedFaults.WithLabelValues(faultLatency).Inc()
+			time.Sleep(f.Latency)
+		}
+		if f.roll(f.ErrorRate) {
+			injectedFaults.WithLabelValues(faultError).Inc()
+			return &http.Response{
+				Status:     "500 Internal Server Error",
+				StatusCode: http.StatusInternalServerError,
+				Proto:      request.Proto,
+				ProtoMajor: request.ProtoMajor,
+				ProtoMinor: request.ProtoMinor,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(injectedErrorBody)),
+				Request:    request,
+			}, nil
+		}
+		response, err := rt.RoundTrip(request)
+		if err != nil || request.URL.Query().Get("watch") != "true" || f.WatchDropAfter <= 0 || !f.roll(f.WatchDropRate) {
+			return response, err
+		}
+		after := time.Duration(f.float() * float64(f.WatchDropAfter))
+		response.Body = dropAfter(response.Body, after)
+		return response, nil
+	})
+}
+
+// roll determines if a fault with the given rate is injected.
+func (f *FaultInjector) roll(rate float64) bool {
+	return rate > 0 && f.float() < rate
+}
+
+func (f *FaultInjector) float() float64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.random == nil {
+		f.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return f.random.Float64()
+}
+
+// droppedBody is the body of a watch that is closed
+// early to simulate the connection being dropped.
+type droppedBody struct {
+	io.ReadCloser
+	timer *time.Timer
+}
+
+func dropAfter(body io.ReadCloser, after time.Duration) io.ReadCloser {
+	return &droppedBody{ReadCloser: body, timer: time.AfterFunc(after, func() {
+		injectedFaults.WithLabelValues(faultWatchDrop).Inc()
+		body.Close()
+	})}
+}
+
+func (b *droppedBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
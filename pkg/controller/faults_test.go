@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector(t *testing.T) {
+	var served int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&served, 1)
+		if r.URL.Query().Get("watch") != "true" {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	get := func(injector *FaultInjector, query string) (*http.Response, error) {
+		client := &http.Client{Transport: injector.WrapTransport(http.DefaultTransport)}
+		return client.Get(server.URL + query)
+	}
+
+	disabled := &FaultInjector{}
+	if disabled.Enabled() {
+		t.Error("expected an injector without rates to be disabled")
+	}
+	response, err := get(disabled, "")
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("expected requests to pass without faults, got %v: %v", response, err)
+	}
+	response.Body.Close()
+
+	failing := &FaultInjector{ErrorRate: 1}
+	before := atomic.LoadInt32(&served)
+	response, err = get(failing, "")
+	if err != nil {
+		t.Fatalf("expected an error response, got %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected an injected internal server error, got %d", response.StatusCode)
+	}
+	if atomic.LoadInt32(&served) != before {
+		t.Error("expected failed requests not to reach the server")
+	}
+
+	slow := &FaultInjector{LatencyRate: 1, Latency: 100 * time.Millisecond}
+	start := time.Now()
+	response, err = get(slow, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected the request to be delayed, took %s", elapsed)
+	}
+
+	dropping := &FaultInjector{WatchDropRate: 1, WatchDropAfter: 100 * time.Millisecond}
+	response, err = get(dropping, "?watch=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	read := make(chan error)
+	go func() {
+		_, err := ioutil.ReadAll(response.Body)
+		read <- err
+	}()
+	select {
+	case <-read:
+	case <-time.After(3 * time.Second):
+		t.Error("expected the watch to be dropped")
+	}
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// writeFiles materializes the mirrored data in the directory of the file
+// sink of the rule, if it has one. Every key is written to a file of the
+// same name, atomically replacing files whose content or mode changed,
+// and files for keys that are not mirrored anymore are removed.
+func (c *SecretMirror) writeFiles(data map[string][]byte, mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	sink := mirrorConfig.Files
+	if sink == nil {
+		return nil
+	}
+	if c.fileSinkDirectory == "" {
+		return errors.New("the rule writes files but no file sink directory is configured")
+	}
+	directory := filepath.Join(c.fileSinkDirectory, sink.Directory)
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return fmt.Errorf("failed to create file sink directory: %v", err)
+	}
+
+	mode := sink.FileMode()
+	for key, value := range data {
+		path := filepath.Join(directory, key)
+		if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, value) {
+			if info, err := os.Stat(path); err == nil && info.Mode().Perm() == mode {
+				continue
+			}
+		}
+		logger.WithField("file", path).Info("writing mirrored data to file")
+		if err := writeFileAtomically(path, value, mode); err != nil {
+			return fmt.Errorf("failed to write file for key %s: %v", key, err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return fmt.Errorf("failed to list file sink directory: %v", err)
+	}
+	for _, file := range files {
+		if _, mirrored := data[file.Name()]; mirrored || !file.Mode().IsRegular() {
+			continue
+		}
+		logger.WithField("file", filepath.Join(directory, file.Name())).Info("removing file for key that is not mirrored anymore")
+		if err := os.Remove(filepath.Join(directory, file.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove file %s: %v", file.Name(), err)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomically writes the file next to the path first and
+// renames it into place, so that readers never see partial content.
+func writeFileAtomically(path string, data []byte, mode os.FileMode) error {
+	temporary, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	// the temporary file is only left behind if writing it failed
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(data); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Chmod(mode); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), path)
+}
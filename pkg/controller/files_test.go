@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestWriteFiles(t *testing.T) {
+	directory, err := ioutil.TempDir("", "file-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	logger := logrus.WithField("test", t.Name())
+	c := &SecretMirror{fileSinkDirectory: directory}
+	mirrorConfig := config.MirrorConfig{Files: &config.FileSink{Directory: "agent", Mode: "0640"}}
+
+	if err := c.writeFiles(map[string][]byte{"token": []byte("first"), "user": []byte("robot")}, mirrorConfig, logger); err != nil {
+		t.Fatalf("failed to write files: %v", err)
+	}
+	if err := c.writeFiles(map[string][]byte{"token": []byte("second")}, mirrorConfig, logger); err != nil {
+		t.Fatalf("failed to write files: %v", err)
+	}
+
+	token := filepath.Join(directory, "agent", "token")
+	if raw, err := ioutil.ReadFile(token); err != nil || string(raw) != "second" {
+		t.Errorf("expected the file to hold the updated value, got %q: %v", string(raw), err)
+	}
+	if info, err := os.Stat(token); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("expected the file to have the configured mode, got %v: %v", info.Mode(), err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, "agent", "user")); !os.IsNotExist(err) {
+		t.Errorf("expected the file for the key that is not mirrored anymore to be removed, got %v", err)
+	}
+	files, err := ioutil.ReadDir(filepath.Join(directory, "agent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected no temporary files to be left behind, got %d files", len(files))
+	}
+
+	if err := (&SecretMirror{}).writeFiles(nil, mirrorConfig, logger); err == nil {
+		t.Error("expected writing files without a file sink directory to fail")
+	}
+}
+
+func TestReconcileOnlyWritesFiles(t *testing.T) {
+	directory, err := ioutil.TempDir("", "file-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:  config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:    config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+			Files: &config.FileSink{Directory: "agent", SkipTarget: true},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{FileSinkDirectory: directory})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	if raw, err := ioutil.ReadFile(filepath.Join(directory, "agent", "key")); err != nil || string(raw) != "value" {
+		t.Errorf("expected the file to hold the source data, got %q: %v", string(raw), err)
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected no target secret to be written, got %v", err)
+	}
+}
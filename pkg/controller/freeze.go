@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// HeldChange is a change to a target that is held back while frozen.
+type HeldChange struct {
+	Rule   string `json:"rule"`
+	Target string `json:"target"`
+	// Change is how the target differs from what the rule would write
+	Change string `json:"change"`
+	// Keys would be written or removed
+	Keys []string `json:"keys,omitempty"`
+	// Since is when the change was first held back
+	Since time.Time `json:"since"`
+}
+
+// freeze suspends every write, e.g. during release freezes, while the
+// changes that would be written are held back for operators to review.
+// Unlike pausing a rule, reconciling keeps computing what would change.
+type freeze struct {
+	lock   sync.RWMutex
+	frozen bool
+	held   map[string]HeldChange
+}
+
+func (f *freeze) isFrozen() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.frozen
+}
+
+// set freezes or unfreezes writes, returning false if nothing changed.
+// Held changes are forgotten when writes are unfrozen.
+func (f *freeze) set(frozen bool) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.frozen == frozen {
+		return false
+	}
+	f.frozen = frozen
+	f.held = map[string]HeldChange{}
+	heldChanges.reset()
+	if frozen {
+		frozenWrites.Set(1)
+	} else {
+		frozenWrites.Set(0)
+	}
+	return true
+}
+
+// hold records the change for the rule, keeping the time it was
+// first held back, or forgets it if the change is empty.
+func (f *freeze) hold(change HeldChange) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.held == nil {
+		f.held = map[string]HeldChange{}
+	}
+	if change.Change == "" {
+		delete(f.held, change.Rule)
+		heldChanges.delete(change.Rule)
+		return
+	}
+	if held, exists := f.held[change.Rule]; exists {
+		change.Since = held.Since
+	}
+	f.held[change.Rule] = change
+	heldChanges.set(change.Rule, 1)
+}
+
+func (f *freeze) changes() []HeldChange {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	changes := []HeldChange{}
+	for _, change := range f.held {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Rule < changes[j].Rule })
+	return changes
+}
+
+func (f *freeze) heldChange(rule string) string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.held[rule].Change
+}
+
+// holdChange computes the change the rule would write to
+// its target instead of writing it, while writes are frozen.
+func (c *SecretMirror) holdChange(source *coreapi.Secret, rule string, mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	change, keys, _, err := c.compareTarget(source, mirrorConfig, c.getTarget)
+	if err != nil {
+		return err
+	}
+	if change != "" {
+		logger.WithFields(logrus.Fields{"rule": rule, "change": change}).Info("not writing target secret while writes are frozen")
+	}
+	c.freeze.hold(HeldChange{Rule: rule, Target: mirrorConfig.To.String(), Change: change, Keys: keys, Since: time.Now()})
+	return nil
+}
+
+// Freeze suspends every write until writes are unfrozen, holding back
+// the changes that would be written. It returns false if writes are
+// already frozen.
+func (c *SecretMirror) Freeze() bool {
+	if !c.freeze.set(true) {
+		return false
+	}
+	c.logger.Warn("froze writes")
+	// compute the changes held back right away
+	c.enqueueAll()
+	return true
+}
+
+// Unfreeze resumes writes, reconciling every source to write the
+// changes that were held back. It returns false if writes are not
+// frozen.
+func (c *SecretMirror) Unfreeze() bool {
+	if !c.freeze.set(false) {
+		return false
+	}
+	c.logger.Info("unfroze writes")
+	c.enqueueAll()
+	return true
+}
+
+// HeldChanges returns the changes held back while writes are frozen.
+func (c *SecretMirror) HeldChanges() []HeldChange {
+	return c.freeze.changes()
+}
+
+// FreezeHandler lists the changes held back on GET, and freezes or
+// unfreezes writes on POST depending on the `frozen` parameter.
+func (c *SecretMirror) FreezeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(struct {
+				Frozen  bool         `json:"frozen"`
+				Changes []HeldChange `json:"changes"`
+			}{Frozen: c.freeze.isFrozen(), Changes: c.HeldChanges()}); err != nil {
+				c.logger.WithError(err).Error("failed to write held changes")
+			}
+		case http.MethodPost:
+			frozen, err := strconv.ParseBool(r.FormValue("frozen"))
+			if err != nil {
+				http.Error(w, "the frozen parameter must be true or false", http.StatusBadRequest)
+				return
+			}
+			if frozen {
+				c.Freeze()
+			} else {
+				c.Unfreeze()
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestFreezeHoldsChanges(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value"), "other": []byte("value")},
+	})
+	mirrorConfig := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{mirrorConfig}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{Frozen: true})
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the target not to be written while writes are frozen")
+	}
+	changes := c.HeldChanges()
+	if len(changes) != 1 {
+		t.Fatalf("expected one held change, got %v", changes)
+	}
+	if actual := changes[0]; actual.Rule != mirrorConfig.String() || actual.Change != driftMissing || !reflect.DeepEqual(actual.Keys, []string{"key", "other"}) {
+		t.Errorf("expected the creation of the target to be held, got %+v", actual)
+	}
+
+	if c.Freeze() {
+		t.Error("expected freezing frozen writes to do nothing")
+	}
+	if !c.Unfreeze() {
+		t.Fatal("expected writes to be unfrozen")
+	}
+	if changes := c.HeldChanges(); len(changes) != 0 {
+		t.Errorf("expected held changes to be forgotten once unfrozen, got %v", changes)
+	}
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the target to be written once writes are unfrozen: %v", err)
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	current := map[string][]byte{"same": []byte("a"), "changed": []byte("a"), "removed": []byte("a")}
+	desired := map[string][]byte{"same": []byte("a"), "changed": []byte("b"), "added": []byte("a")}
+	if actual, expected := changedKeys(current, desired), []string{"added", "changed", "removed"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected changed keys %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// doNotOverwriteAnnotation on a target set to "true" suspends writes to it,
+// e.g. while an admin is debugging with hand-edited data.
+const doNotOverwriteAnnotation = "ci.openshift.io/do-not-overwrite"
+
+// heldBack determines if writes to the target are suspended, recording
+// held targets in metrics and in events on the target itself.
+func (c *SecretMirror) heldBack(target *coreapi.Secret, mirrorConfig config.MirrorConfig) bool {
+	if target.Annotations[doNotOverwriteAnnotation] != "true" {
+		heldTargets.delete(mirrorConfig.To.String())
+		return false
+	}
+	heldTargets.set(mirrorConfig.To.String(), 1)
+	c.recorder.Eventf(target, coreapi.EventTypeWarning, reasonHeld, "Not mirroring %s into this secret as it is annotated with %s", mirrorConfig.From.String(), doNotOverwriteAnnotation)
+	return true
+}
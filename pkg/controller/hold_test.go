@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileHonorsDoNotOverwrite(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		annotations  map[string]string
+		expectedData map[string][]byte
+		expectedHeld bool
+	}{
+		{
+			name:         "targets without the annotation are overwritten",
+			expectedData: map[string][]byte{"key": []byte("new")},
+		},
+		{
+			name:         "targets with the annotation set to true are left alone",
+			annotations:  map[string]string{doNotOverwriteAnnotation: "true"},
+			expectedData: map[string][]byte{"key": []byte("debugging")},
+			expectedHeld: true,
+		},
+		{
+			name:         "targets with the annotation set to false are overwritten",
+			annotations:  map[string]string{doNotOverwriteAnnotation: "false"},
+			expectedData: map[string][]byte{"key": []byte("new")},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset(&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst", Annotations: testCase.annotations},
+				Data:       map[string][]byte{"key": []byte("debugging")},
+			})
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+				Data:       map[string][]byte{"key": []byte("new")},
+			})
+			if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				_, err := informer.Lister().Secrets("test-ns").Get("dst")
+				return err == nil, nil
+			}); err != nil {
+				t.Fatalf("%s: informer did not observe the target: %v", testCase.name, err)
+			}
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+				{
+					From:          config.SecretLocation{Namespace: "test-ns", Name: "src"},
+					To:            config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+					AdoptExisting: true,
+				},
+			}})
+			c := NewSecretMirror(informer, client, ca.Config, Options{})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+			if err := c.reconcile("test-ns/src"); err != nil {
+				t.Fatalf("%s: failed to reconcile: %v", testCase.name, err)
+			}
+			target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(target.Data, testCase.expectedData) {
+				t.Errorf("%s: expected data %v, got %v", testCase.name, testCase.expectedData, target.Data)
+			}
+			held := false
+			for len(recorder.Events) > 0 {
+				held = held || strings.Contains(<-recorder.Events, reasonHeld)
+			}
+			if held != testCase.expectedHeld {
+				t.Errorf("%s: expected an event about the held target to be %t, got %t", testCase.name, testCase.expectedHeld, held)
+			}
+		})
+	}
+}
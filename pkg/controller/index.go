@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// ruleIndex indexes the rules of one generation of the configuration by
+// their source, so that reconciling a secret does not scan every rule,
+// and by their target, so that changes to targets find their sources.
+// The index is rebuilt when the configuration is reloaded and holds the
+// positions of rules rather than copies, so that large configurations
+// are not held in memory twice.
+type ruleIndex struct {
+	lock     sync.Mutex
+	bySource map[config.SecretLocation][]int
+	patterns []int
+	byTarget map[config.SecretLocation][]int
+	// wildcards are the rules whose targets are not known up front
+	wildcards  []int
+	generation *config.Configuration
+}
+
+// from returns the rules mirroring or merging from the location, in the
+// order they are configured, followed by the rules selecting it by
+// pattern, which are instantiated for it.
+func (i *ruleIndex) from(generation *config.Configuration, location config.SecretLocation) []config.MirrorConfig {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.sync(generation)
+	var rules []config.MirrorConfig
+	for _, position := range i.bySource[location] {
+		rules = append(rules, generation.Secrets[position])
+	}
+	for _, position := range i.patterns {
+		if instance, matches := generation.Secrets[position].Instantiate(location); matches {
+			rules = append(rules, instance)
+		}
+	}
+	return rules
+}
+
+// isSource determines if any rule mirrors from the location.
+func (i *ruleIndex) isSource(generation *config.Configuration, location config.SecretLocation) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.sync(generation)
+	if len(i.bySource[location]) > 0 {
+		return true
+	}
+	for _, position := range i.patterns {
+		if _, matches := generation.Secrets[position].Instantiate(location); matches {
+			return true
+		}
+	}
+	return false
+}
+
+// sourcesWritingTo returns the sources of the rules writing to the
+// location, in the order the rules are configured, for rules selecting
+// their sources by pattern instantiated for the target.
+func (i *ruleIndex) sourcesWritingTo(generation *config.Configuration, location config.SecretLocation) []config.SecretLocation {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.sync(generation)
+	var sources []config.SecretLocation
+	for _, position := range i.byTarget[location] {
+		sources = append(sources, generation.Secrets[position].From)
+	}
+	for _, position := range i.wildcards {
+		mirrorConfig := generation.Secrets[position]
+		if !mirrorConfig.WritesTo(location) {
+			continue
+		}
+		source := mirrorConfig.From
+		if mirrorConfig.IsPattern() {
+			source.Name, source.NamePattern = location.Name, ""
+		}
+		if source.Equals(location) {
+			// the source is never its own target
+			continue
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// sync rebuilds the index if the configuration has been reloaded
+// since the last time we looked at it. The lock must be held.
+func (i *ruleIndex) sync(generation *config.Configuration) {
+	if i.generation == generation {
+		return
+	}
+	i.generation = generation
+	i.bySource, i.patterns = map[config.SecretLocation][]int{}, nil
+	i.byTarget, i.wildcards = map[config.SecretLocation][]int{}, nil
+	for position, mirrorConfig := range generation.Secrets {
+		if mirrorConfig.IsPattern() || mirrorConfig.FansOut() {
+			i.wildcards = append(i.wildcards, position)
+		} else {
+			i.byTarget[mirrorConfig.To] = append(i.byTarget[mirrorConfig.To], position)
+		}
+		if mirrorConfig.IsPattern() {
+			i.patterns = append(i.patterns, position)
+			continue
+		}
+		for _, source := range mirrorConfig.Sources() {
+			i.bySource[source] = append(i.bySource[source], position)
+		}
+	}
+}
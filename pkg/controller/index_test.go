@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRuleIndex(t *testing.T) {
+	local := config.SecretLocation{Namespace: "test-ns", Name: "src"}
+	remote := config.SecretLocation{Cluster: "remote", Namespace: "test-ns", Name: "src"}
+	first := config.MirrorConfig{From: local, To: config.SecretLocation{Namespace: "a", Name: "dst"}}
+	second := config.MirrorConfig{From: local, To: config.SecretLocation{Namespace: "b", Name: "dst"}}
+	fromRemote := config.MirrorConfig{From: remote, To: config.SecretLocation{Namespace: "c", Name: "dst"}}
+	other := config.MirrorConfig{From: config.SecretLocation{Namespace: "test-ns", Name: "other"}, To: config.SecretLocation{Namespace: "d", Name: "dst"}}
+
+	index := &ruleIndex{}
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{first, other, fromRemote, second}}
+	if actual, expected := index.from(configuration, local), []config.MirrorConfig{first, second}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected rules %v for the local source, got %v", expected, actual)
+	}
+	if actual, expected := index.from(configuration, remote), []config.MirrorConfig{fromRemote}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected rules %v for the remote source, got %v", expected, actual)
+	}
+	if actual := index.from(configuration, config.SecretLocation{Namespace: "test-ns", Name: "unknown"}); len(actual) != 0 {
+		t.Errorf("expected no rules for an unknown source, got %v", actual)
+	}
+
+	reloaded := &config.Configuration{Secrets: []config.MirrorConfig{second}}
+	if actual, expected := index.from(reloaded, local), []config.MirrorConfig{second}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected the index to be rebuilt on reload with rules %v, got %v", expected, actual)
+	}
+}
+
+func TestRuleIndexInstantiatesPatterns(t *testing.T) {
+	named := config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", Name: "ci-token-a"}, To: config.SecretLocation{Namespace: "a", Name: "token"}}
+	pattern := config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: config.SecretLocation{Namespace: "b"}}
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{pattern, named}}
+	index := &ruleIndex{}
+
+	source := config.SecretLocation{Namespace: "ci", Name: "ci-token-a"}
+	instance := config.MirrorConfig{From: source, To: config.SecretLocation{Namespace: "b", Name: "ci-token-a"}}
+	if actual, expected := index.from(configuration, source), []config.MirrorConfig{named, instance}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected rules %v for the source, got %v", expected, actual)
+	}
+	for _, unmatched := range []config.SecretLocation{
+		{Namespace: "ci", Name: "other"},
+		{Namespace: "ci", Name: "prefix-ci-token-a"},
+		{Namespace: "other", Name: "ci-token-a"},
+	} {
+		if index.isSource(configuration, unmatched) {
+			t.Errorf("expected %s not to match the pattern", unmatched.String())
+		}
+	}
+}
+
+func TestEventHandlersFilterUnmatchedSecrets(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "unrelated"}})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+		{
+			From: config.SecretLocation{Cluster: "remote", Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "remote-dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+
+	unrelated := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "unrelated"}}
+	c.add(unrelated)
+	c.update(unrelated, unrelated)
+	c.enqueueRemote("remote", unrelated)
+	if depth := c.queue.Len(); depth != 0 {
+		t.Errorf("expected secrets no rule mirrors from to be dropped, got %d queued keys", depth)
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"}}
+	c.update(source, source)
+	c.enqueueRemote("remote", source)
+	if depth := c.queue.Len(); depth != 2 {
+		t.Errorf("expected the local and remote sources to be queued, got %d queued keys", depth)
+	}
+}
+
+func TestRuleIndexSourcesWritingTo(t *testing.T) {
+	named := config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "a", Name: "token"}}
+	merged := config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", Name: "other"}, MergeFrom: []config.SecretLocation{{Namespace: "ci", Name: "src"}}, To: config.SecretLocation{Namespace: "a", Name: "token"}}
+	pattern := config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: config.SecretLocation{Namespace: "b"}}
+	fanOut := config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Name: "shared", NamespaceSelector: "team=a"}}
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{named, pattern, merged, fanOut}}
+	index := &ruleIndex{}
+
+	var testCases = []struct {
+		target   config.SecretLocation
+		expected []config.SecretLocation
+	}{
+		{target: config.SecretLocation{Namespace: "a", Name: "token"}, expected: []config.SecretLocation{named.From, merged.From}},
+		{target: config.SecretLocation{Namespace: "b", Name: "ci-token-a"}, expected: []config.SecretLocation{{Namespace: "ci", Name: "ci-token-a"}}},
+		{target: config.SecretLocation{Namespace: "team-a", Name: "shared"}, expected: []config.SecretLocation{fanOut.From}},
+		{target: config.SecretLocation{Namespace: "b", Name: "other"}},
+		{target: config.SecretLocation{Cluster: "remote", Namespace: "a", Name: "token"}},
+	}
+	for _, testCase := range testCases {
+		if actual := index.sourcesWritingTo(configuration, testCase.target); !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("expected sources %v writing to %s, got %v", testCase.expected, testCase.target.String(), actual)
+		}
+	}
+}
+
+func TestEventHandlersRepairTargets(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"}})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	c.applied.record("test-ns/dst", "fingerprint", "1")
+
+	written := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst", ResourceVersion: "1"}}
+	c.update(written, written)
+	c.update(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst"}}, written)
+	if depth := c.queue.Len(); depth != 0 {
+		t.Errorf("expected resyncs and our own writes to be ignored, got %d queued keys", depth)
+	}
+
+	edited := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst", ResourceVersion: "2"}}
+	c.update(written, edited)
+	if key, _ := c.queue.Get(); key != "test-ns/src" {
+		t.Errorf("expected the source of the edited target to be queued, got %v", key)
+	} else {
+		c.queue.Done(key)
+	}
+
+	c.delete(edited)
+	if key, _ := c.queue.Get(); key != "test-ns/src" {
+		t.Errorf("expected the source of the deleted target to be queued, got %v", key)
+	}
+}
+
+func TestReconcileUnmatchedSource(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "unrelated"}})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	client.ClearActions()
+	if err := c.reconcile("test-ns/unrelated"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected a secret no rule mirrors from to be left alone, got %v", actions)
+	}
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// InventoryConfigMap is the name of the ConfigMap recording every target
+// the controller manages, in the namespace configured for the inventory.
+const InventoryConfigMap = "secret-mirror-inventory"
+
+// InventoryEntry records how a target is managed. Entries are recorded
+// under InventoryKey of their target.
+type InventoryEntry struct {
+	// Rule is the rule that last wrote the target
+	Rule string `json:"rule"`
+	// ConfigHash identifies the configuration the target was last written with
+	ConfigHash string `json:"configHash"`
+	// Created is when the target was first recorded
+	Created time.Time `json:"created"`
+	// Updated is when the target was last written
+	Updated time.Time `json:"updated"`
+}
+
+// InventoryKey is the key of a target in the inventory. Namespaces may not
+// contain dots, so the key is unambiguous.
+func InventoryKey(to config.SecretLocation) string {
+	return fmt.Sprintf("%s.%s", to.Namespace, to.Name)
+}
+
+// inventoryFlushPeriod is how often recorded targets are written
+// to the inventory ConfigMap.
+const inventoryFlushPeriod = 10 * time.Second
+
+// inventory maintains the inventory ConfigMap. Targets are staged as they
+// are reconciled and written in batches, so that large rule sets do not
+// cost one write per target, and entries are cached so that targets are
+// only staged when their entry changes.
+type inventory struct {
+	lock sync.Mutex
+
+	// namespace holds the inventory; it is disabled if empty
+	namespace  string
+	recorded   map[string]InventoryEntry
+	pending    map[string]pendingEntry
+	configHash string
+	generation *config.Configuration
+}
+
+// pendingEntry is an entry waiting to be written to the inventory.
+type pendingEntry struct {
+	entry InventoryEntry
+	// written is set if the target was written to, otherwise
+	// the entry is only recorded if it is missing or stale
+	written bool
+}
+
+func newInventory(namespace string) *inventory {
+	return &inventory{
+		namespace: namespace,
+		recorded:  map[string]InventoryEntry{},
+		pending:   map[string]pendingEntry{},
+	}
+}
+
+// sync identifies the configuration that targets are written with.
+func (i *inventory) sync(generation *config.Configuration) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.namespace == "" || i.generation == generation {
+		return
+	}
+	i.generation = generation
+	i.configHash = generation.Hash()
+}
+
+// record stages the target of the rule to be recorded in the inventory.
+// Targets that were not written to are only recorded if they are missing
+// or were written by a different rule, so that reloading the configuration
+// does not rewrite the whole inventory.
+func (i *inventory) record(mirrorConfig config.MirrorConfig, written bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	// remote targets are not pruned, so they are not recorded
+	if i.namespace == "" || mirrorConfig.To.Cluster != "" {
+		return
+	}
+	key, rule := InventoryKey(mirrorConfig.To), mirrorConfig.String()
+	if entry, cached := i.recorded[key]; cached && entry.Rule == rule && !written {
+		return
+	}
+	if staged, pending := i.pending[key]; pending && staged.entry.Rule == rule && !written {
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	entry := InventoryEntry{Rule: rule, ConfigHash: i.configHash, Created: now, Updated: now}
+	if recorded, cached := i.recorded[key]; cached {
+		entry.Created = recorded.Created
+	}
+	i.pending[key] = pendingEntry{entry: entry, written: written || i.pending[key].written}
+}
+
+// flushInventory writes the staged targets to the inventory ConfigMap.
+// Targets that fail to be written stay staged for the next flush.
+func (c *SecretMirror) flushInventory() {
+	i := c.inventory
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.namespace == "" || len(i.pending) == 0 {
+		return
+	}
+	logger := c.logger.WithField("inventory", fmt.Sprintf("%s/%s", i.namespace, InventoryConfigMap))
+
+	configMaps := c.writeClient.CoreV1().ConfigMaps(i.namespace)
+	existing, err := configMaps.Get(InventoryConfigMap, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		logger.WithError(err).Error("failed to get the inventory")
+		return
+	}
+	notFound := errors.IsNotFound(err)
+
+	updated := &coreapi.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: i.namespace, Name: InventoryConfigMap}}
+	if !notFound {
+		updated = existing.DeepCopy()
+	}
+	if updated.Data == nil {
+		updated.Data = map[string]string{}
+	}
+	flushed := map[string]InventoryEntry{}
+	for key, staged := range i.pending {
+		entry := staged.entry
+		var recorded InventoryEntry
+		if raw, present := updated.Data[key]; present && json.Unmarshal([]byte(raw), &recorded) == nil {
+			if recorded.Rule == entry.Rule && !staged.written {
+				flushed[key] = recorded
+				continue
+			}
+			entry.Created = recorded.Created
+		}
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			logger.WithError(err).Error("failed to serialize inventory entry")
+			continue
+		}
+		updated.Data[key] = string(raw)
+		flushed[key] = entry
+	}
+
+	if notFound {
+		_, err = configMaps.Create(updated)
+	} else {
+		_, err = configMaps.Update(updated)
+	}
+	if err != nil {
+		logger.WithError(err).Error("failed to write the inventory")
+		return
+	}
+	for key, entry := range flushed {
+		i.recorded[key] = entry
+		delete(i.pending, key)
+	}
+	logger.Debugf("recorded %d targets in the inventory", len(flushed))
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileRecordsInventory(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	mirrorConfig := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{mirrorConfig}}
+	ca := &config.Agent{}
+	ca.Set(configuration)
+	c := NewSecretMirror(informer, client, ca.Config, Options{InventoryNamespace: "ci"})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("ci").Get(InventoryConfigMap, metav1.GetOptions{}); err == nil {
+		t.Error("expected targets to be staged until the inventory is flushed")
+	}
+	c.flushInventory()
+
+	inventory, err := client.CoreV1().ConfigMaps("ci").Get(InventoryConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the inventory to be created: %v", err)
+	}
+	var entry InventoryEntry
+	if err := json.Unmarshal([]byte(inventory.Data["test-ns.dst"]), &entry); err != nil {
+		t.Fatalf("failed to parse the inventory entry: %v", err)
+	}
+	if entry.Rule != mirrorConfig.String() || entry.ConfigHash != configuration.Hash() || entry.Created.IsZero() {
+		t.Errorf("expected the target to be recorded with its rule and the configuration hash, got %+v", entry)
+	}
+
+	client.ClearActions()
+	c.inventory.record(mirrorConfig, false)
+	c.flushInventory()
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected a recorded target that was not written to be left alone, got %v", actions)
+	}
+}
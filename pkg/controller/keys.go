@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// filteredData returns the source data with only the keys
+// selected by the key filter configured for the rule.
+func filteredData(data map[string][]byte, mirrorConfig config.MirrorConfig) map[string][]byte {
+	if mirrorConfig.Keys == nil {
+		return data
+	}
+	filtered := map[string][]byte{}
+	for key, value := range data {
+		if mirrorConfig.Keys.Selects(key) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// prefixedData returns the data with the target key prefix configured
+// for the rule prepended to every key.
+func prefixedData(data map[string][]byte, mirrorConfig config.MirrorConfig) map[string][]byte {
+	if len(mirrorConfig.TargetKeyPrefix) == 0 {
+		return data
+	}
+	prefixed := make(map[string][]byte, len(data))
+	for key, value := range data {
+		prefixed[mirrorConfig.TargetKeyPrefix+key] = value
+	}
+	return prefixed
+}
+
+// strippedData returns the source data with the source key prefix
+// configured for the rule removed from every key that has it. Keys
+// without the prefix are kept as they are.
+func strippedData(data map[string][]byte, mirrorConfig config.MirrorConfig) (map[string][]byte, error) {
+	prefix := mirrorConfig.StripSourceKeyPrefix
+	if len(prefix) == 0 {
+		return data, nil
+	}
+	stripped := make(map[string][]byte, len(data))
+	origins := map[string]string{}
+	for key, value := range data {
+		strippedKey := key
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			strippedKey = strings.TrimPrefix(key, prefix)
+		}
+		if origin, clash := origins[strippedKey]; clash {
+			if origin > key {
+				origin, key = key, origin
+			}
+			return nil, fmt.Errorf("source keys %q and %q both map to %q once the prefix %q is stripped", origin, key, strippedKey, prefix)
+		}
+		origins[strippedKey] = key
+		stripped[strippedKey] = value
+	}
+	return stripped, nil
+}
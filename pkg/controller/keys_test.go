@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestPrefixedData(t *testing.T) {
+	data := map[string][]byte{"token": []byte("secret"), "user": []byte("ci")}
+	if actual := prefixedData(data, config.MirrorConfig{}); !reflect.DeepEqual(actual, data) {
+		t.Errorf("expected data without a prefix to be unchanged, got %q", actual)
+	}
+	expected := map[string][]byte{"prod-token": []byte("secret"), "prod-user": []byte("ci")}
+	if actual := prefixedData(data, config.MirrorConfig{TargetKeyPrefix: "prod-"}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestStrippedData(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		data        map[string][]byte
+		prefix      string
+		expected    map[string][]byte
+		expectedErr bool
+	}{
+		{
+			name:     "without a prefix the data is unchanged",
+			data:     map[string][]byte{"prod_token": []byte("secret")},
+			expected: map[string][]byte{"prod_token": []byte("secret")},
+		},
+		{
+			name:     "the prefix is stripped and other keys are kept",
+			data:     map[string][]byte{"prod_token": []byte("secret"), "user": []byte("ci"), "prod_": []byte("bare")},
+			prefix:   "prod_",
+			expected: map[string][]byte{"token": []byte("secret"), "user": []byte("ci"), "prod_": []byte("bare")},
+		},
+		{
+			name:        "collisions are an error",
+			data:        map[string][]byte{"prod_token": []byte("secret"), "token": []byte("other")},
+			prefix:      "prod_",
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := strippedData(testCase.data, config.MirrorConfig{StripSourceKeyPrefix: testCase.prefix})
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !testCase.expectedErr && !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("%s: expected %q, got %q", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFilteredData(t *testing.T) {
+	data := map[string][]byte{"token": []byte("secret"), "user": []byte("ci"), "ca.crt": []byte("ca")}
+	var testCases = []struct {
+		name     string
+		keys     *config.KeyFilter
+		expected map[string][]byte
+	}{
+		{
+			name:     "without a filter the data is unchanged",
+			expected: data,
+		},
+		{
+			name:     "only included keys are kept",
+			keys:     &config.KeyFilter{Include: []string{"token", "missing"}},
+			expected: map[string][]byte{"token": []byte("secret")},
+		},
+		{
+			name:     "excluded keys are dropped",
+			keys:     &config.KeyFilter{Exclude: []string{"ca.crt"}},
+			expected: map[string][]byte{"token": []byte("secret"), "user": []byte("ci")},
+		},
+		{
+			name:     "exclusion wins over inclusion",
+			keys:     &config.KeyFilter{Include: []string{"token", "user"}, Exclude: []string{"user"}},
+			expected: map[string][]byte{"token": []byte("secret")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := filteredData(data, config.MirrorConfig{Keys: testCase.keys}); !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("%s: expected %q, got %q", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
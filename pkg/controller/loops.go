@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// mirrorLoop follows the sources recorded on the source of the rule, and
+// on theirs in turn, to find whether the controller wrote the source from
+// the target of the rule. Validation rejects cycles between named secrets,
+// but not those through rules selecting their sources by patterns that
+// match each other's targets, which would keep mirroring the data around.
+// Only writes made by rules that are still configured are followed, so
+// that targets left over from reversed rules do not count. The loop is
+// returned starting at the target.
+func (c *SecretMirror) mirrorLoop(source *coreapi.Secret, mirrorConfig config.MirrorConfig) ([]string, bool) {
+	if mirrorConfig.From.Cluster != "" || mirrorConfig.To.Cluster != "" {
+		// the sources of remote secrets are not followed
+		return nil, false
+	}
+	configuration, target := c.config(), mirrorConfig.To.String()
+	paths := map[string][]string{location(source): {location(source)}}
+	pending := []*coreapi.Secret{source}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if !managedTarget(current) || current.Annotations[mirrorSourceAnnotation] == "" {
+			continue
+		}
+		configured := map[string]bool{}
+		for _, writer := range c.rules.sourcesWritingTo(configuration, config.SecretLocation{Namespace: current.Namespace, Name: current.Name}) {
+			configured[writer.String()] = true
+		}
+		for _, written := range strings.Split(current.Annotations[mirrorSourceAnnotation], ",") {
+			if _, seen := paths[written]; seen || !configured[written] {
+				continue
+			}
+			path := append([]string{written}, paths[location(current)]...)
+			if written == target {
+				return append(path, target), true
+			}
+			paths[written] = path
+			parts := strings.Split(written, "/")
+			if len(parts) != 2 || strings.Contains(written, ":") {
+				continue
+			}
+			if secret, err := c.lister.Secrets(parts[0]).Get(parts[1]); err == nil {
+				pending = append(pending, secret)
+			}
+		}
+	}
+	return nil, false
+}
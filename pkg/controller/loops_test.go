@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestMirrorLoopIsRefused(t *testing.T) {
+	toTeam := config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", NamePattern: "ci-token-.*"}, To: config.SecretLocation{Namespace: "team"}}
+	toCI := config.MirrorConfig{From: config.SecretLocation{Namespace: "team", NamePattern: "ci-token-.*"}, To: config.SecretLocation{Namespace: "ci"}, AdoptExisting: true}
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "ci-token-a"},
+		Data:       map[string][]byte{"token": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{toTeam, toCI}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+
+	if err := c.reconcile("ci/ci-token-a"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("team").Get("ci-token-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(target); err != nil {
+		t.Fatal(err)
+	}
+	err = c.reconcile("team/ci-token-a")
+	if err == nil || !strings.Contains(err.Error(), "[ci/ci-token-a -> team/ci-token-a -> ci/ci-token-a]") {
+		t.Errorf("expected the loop to be refused, got %v", err)
+	}
+
+	// once the rule the target was written by is gone, it is a plain source
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{toCI}})
+	if err := c.reconcile("team/ci-token-a"); err != nil {
+		t.Errorf("expected targets of rules that are no longer configured to be mirrored, got %v", err)
+	}
+}
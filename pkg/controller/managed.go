@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+const (
+	// managedByLabel identifies the controller as the manager of the
+	// targets it writes, following the Kubernetes recommended labels
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "ci-secret-mirroring-controller"
+
+	// mirrorSourceAnnotation records the sources of a target, formatted
+	// like config.SecretLocation and separated by commas
+	mirrorSourceAnnotation = "ci.openshift.io/mirror-source"
+
+	// lastSyncedAnnotation records when the controller last
+	// wrote to a target, in RFC 3339 format
+	lastSyncedAnnotation = "ci.openshift.io/mirror-last-synced"
+
+	// mirrorSourceVersionAnnotation records the resourceVersion of
+	// the source that the controller last wrote the target from
+	mirrorSourceVersionAnnotation = "ci.openshift.io/mirror-source-version"
+
+	// lastSyncedHashAnnotation records on a source the hash of the
+	// data that the controller last wrote to one of its targets
+	lastSyncedHashAnnotation = "ci.openshift.io/mirror-last-synced-hash"
+
+	// configRevisionAnnotation records the commit of the configuration
+	// that the controller last wrote a target with, when the
+	// configuration is loaded from a Git repository
+	configRevisionAnnotation = "ci.openshift.io/mirror-config-revision"
+)
+
+// managedTarget determines if the controller created or adopted the target.
+// Targets written before they were labelled carry the last-applied hash.
+func managedTarget(target metav1.Object) bool {
+	_, hashed := target.GetAnnotations()[lastAppliedHashAnnotation]
+	return hashed || target.GetLabels()[managedByLabel] == managedByValue
+}
+
+// formatSources serializes sources for the source annotation. Locations
+// never contain a comma, so the value is unambiguous.
+func formatSources(sources []config.SecretLocation) string {
+	formatted := make([]string, 0, len(sources))
+	for _, source := range sources {
+		formatted = append(formatted, source.String())
+	}
+	return strings.Join(formatted, ",")
+}
+
+// stamped determines if the target identifies the controller and
+// the sources, regardless of when it was last synced.
+func stamped(target metav1.Object, sources []config.SecretLocation) bool {
+	return target.GetLabels()[managedByLabel] == managedByValue && target.GetAnnotations()[mirrorSourceAnnotation] == formatSources(sources)
+}
+
+// stamp marks a target the controller is about to write with the
+// controller, the sources, the version of the source, the revision of
+// the configuration if any and the time.
+func stamp(target metav1.Object, sources []config.SecretLocation, sourceVersion, revision string, now time.Time) {
+	labels := target.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[managedByLabel] = managedByValue
+	target.SetLabels(labels)
+	annotations := target.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[mirrorSourceAnnotation] = formatSources(sources)
+	annotations[mirrorSourceVersionAnnotation] = sourceVersion
+	annotations[lastSyncedAnnotation] = now.UTC().Format(time.RFC3339)
+	if revision != "" {
+		annotations[configRevisionAnnotation] = revision
+	} else {
+		delete(annotations, configRevisionAnnotation)
+	}
+	target.SetAnnotations(annotations)
+}
+
+// annotateSource records on the source that its data was written to the
+// target of the rule, if the rule asks for it. The target is already
+// written, so failures are only logged; the annotations are refreshed
+// on the next write.
+func (c *SecretMirror) annotateSource(source *coreapi.Secret, mirrorConfig config.MirrorConfig, hash string, logger *logrus.Entry) {
+	if !mirrorConfig.AnnotateSource {
+		return
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				lastSyncedAnnotation:     time.Now().UTC().Format(time.RFC3339),
+				lastSyncedHashAnnotation: hash,
+			},
+		},
+	})
+	if err != nil {
+		logger.WithError(err).Warn("failed to serialize the annotations of the source")
+		return
+	}
+	// a merge patch leaves the data alone should the source change meanwhile
+	if _, err := c.writeClient.CoreV1().Secrets(source.Namespace).Patch(source.Name, types.MergePatchType, patch); err != nil {
+		logger.WithError(err).Warn("failed to annotate the source with the last sync")
+	}
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestStamp(t *testing.T) {
+	sources := []config.SecretLocation{{Namespace: "ci", Name: "registry"}, {Cluster: "build01", Namespace: "ci", Name: "credentials"}}
+	target := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "ci"}}}
+	if stamped(target, sources) {
+		t.Fatal("expected a target without the markers not to be stamped")
+	}
+	stamp(target, sources, "42", "4f0c2b1", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	if !stamped(target, sources) {
+		t.Errorf("expected the target to be stamped, got labels %v and annotations %v", target.Labels, target.Annotations)
+	}
+	if actual, expected := target.Annotations[mirrorSourceAnnotation], "ci/registry,build01:ci/credentials"; actual != expected {
+		t.Errorf("expected the sources to be recorded as %s, got %s", expected, actual)
+	}
+	if actual, expected := target.Annotations[lastSyncedAnnotation], "2020-01-02T03:04:05Z"; actual != expected {
+		t.Errorf("expected the sync to be recorded as %s, got %s", expected, actual)
+	}
+	if actual, expected := target.Annotations[mirrorSourceVersionAnnotation], "42"; actual != expected {
+		t.Errorf("expected the source version to be recorded as %s, got %s", expected, actual)
+	}
+	if actual, expected := target.Annotations[configRevisionAnnotation], "4f0c2b1"; actual != expected {
+		t.Errorf("expected the config revision to be recorded as %s, got %s", expected, actual)
+	}
+	if target.Labels["team"] != "ci" {
+		t.Error("expected other labels to be kept")
+	}
+	if stamped(target, sources[:1]) {
+		t.Error("expected a target stamped with other sources not to be stamped")
+	}
+	stamp(target, sources, "43", "", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	if revision, recorded := target.Annotations[configRevisionAnnotation]; recorded {
+		t.Errorf("expected the config revision to be dropped without one, got %s", revision)
+	}
+}
+
+func TestReconcileStampsExistingTargets(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be created: %v", err)
+	}
+	if !stamped(target, []config.SecretLocation{{Namespace: "test-ns", Name: "src"}}) || target.Annotations[lastSyncedAnnotation] == "" {
+		t.Fatalf("expected the created target to be stamped, got labels %v and annotations %v", target.Labels, target.Annotations)
+	}
+
+	// targets written before they were stamped are stamped once
+	delete(target.Labels, managedByLabel)
+	if target, err = client.CoreV1().Secrets("test-ns").Update(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(target); err != nil {
+		t.Fatal(err)
+	}
+	c.applied.forget(target.Namespace + "/" + target.Name)
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	restamped, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restamped.Labels[managedByLabel] != managedByValue {
+		t.Errorf("expected the target to be stamped again, got labels %v", restamped.Labels)
+	}
+}
+
+func TestAdoptExisting(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		labels       map[string]string
+		annotations  map[string]string
+		adopt        bool
+		expectedErr  bool
+		expectedData string
+	}{
+		{
+			name:         "targets the controller did not create are refused",
+			expectedErr:  true,
+			expectedData: "manual",
+		},
+		{
+			name:         "targets the controller did not create are adopted when allowed",
+			adopt:        true,
+			expectedData: "mirrored",
+		},
+		{
+			name:         "targets written before they were labelled are managed",
+			annotations:  map[string]string{lastAppliedHashAnnotation: "hash"},
+			expectedData: "mirrored",
+		},
+		{
+			name:         "labelled targets are managed",
+			labels:       map[string]string{managedByLabel: managedByValue},
+			expectedData: "mirrored",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+				Data:       map[string][]byte{"key": []byte("mirrored")},
+			})
+			target := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst", Labels: testCase.labels, Annotations: testCase.annotations},
+				Data:       map[string][]byte{"key": []byte("manual")},
+			}
+			if _, err := client.CoreV1().Secrets("test-ns").Create(target); err != nil {
+				t.Fatal(err)
+			}
+			if err := informer.Informer().GetIndexer().Add(target); err != nil {
+				t.Fatal(err)
+			}
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{
+				Secrets: []config.MirrorConfig{{
+					From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+					To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+				}},
+				Defaults: config.Defaults{AdoptExisting: testCase.adopt},
+			})
+			c := NewSecretMirror(informer, client, ca.Config, Options{})
+			if err := c.reconcile("test-ns/src"); (err != nil) != testCase.expectedErr {
+				t.Errorf("%s: expected error %t, got %v", testCase.name, testCase.expectedErr, err)
+			}
+			actual, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual.Data["key"]) != testCase.expectedData {
+				t.Errorf("%s: expected the target to hold %q, got %q", testCase.name, testCase.expectedData, string(actual.Data["key"]))
+			}
+		})
+	}
+}
+
+func TestAnnotateSource(t *testing.T) {
+	for _, annotateSource := range []bool{false, true} {
+		client := testclient.NewSimpleClientset()
+		informer := syncedInformer(t, client, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+			Data:       map[string][]byte{"key": []byte("value")},
+		})
+		ca := &config.Agent{}
+		ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+			From:           config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:             config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+			AnnotateSource: annotateSource,
+		}}})
+		c := NewSecretMirror(informer, client, ca.Config, Options{})
+		if err := c.reconcile("test-ns/src"); err != nil {
+			t.Fatalf("failed to reconcile: %v", err)
+		}
+
+		cached, err := informer.Lister().Secrets("test-ns").Get("src")
+		if err != nil {
+			t.Fatal(err)
+		}
+		target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected the target to be created: %v", err)
+		}
+		if actual, expected := target.Annotations[mirrorSourceVersionAnnotation], cached.ResourceVersion; actual != expected {
+			t.Errorf("expected the target to record source version %q, got %q", expected, actual)
+		}
+
+		source, err := client.CoreV1().Secrets("test-ns").Get("src", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, annotated := source.Annotations[lastSyncedHashAnnotation]
+		if annotated != annotateSource {
+			t.Errorf("annotateSource=%v: expected the source to be annotated %v, got annotations %v", annotateSource, annotateSource, source.Annotations)
+		}
+		if annotateSource && (hash != target.Annotations[lastAppliedHashAnnotation] || source.Annotations[lastSyncedAnnotation] == "") {
+			t.Errorf("expected the source to record the sync of hash %s, got annotations %v", target.Annotations[lastAppliedHashAnnotation], source.Annotations)
+		}
+	}
+}
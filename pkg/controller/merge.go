@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"sort"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// lastAppliedKeysAnnotation records the keys the controller last wrote to
+// a target secret, so that a three-way merge can tell keys that were removed
+// from the source apart from keys that other parties added to the target.
+const lastAppliedKeysAnnotation = "ci.openshift.io/mirror-last-applied-keys"
+
+// formatKeys serializes data keys for the last-applied keys annotation. Secret
+// keys may only contain alphanumerics, '-', '_' and '.', so a comma is safe.
+func formatKeys(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// lastAppliedKeys returns the keys the controller last wrote to the target.
+func lastAppliedKeys(target *coreapi.Secret) []string {
+	value, recorded := target.Annotations[lastAppliedKeysAnnotation]
+	if !recorded || value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// applicableData determines the subset of the source data that the
+// rule may apply to the target.
+func applicableData(source map[string][]byte, mirrorConfig config.MirrorConfig) map[string][]byte {
+	if len(mirrorConfig.IgnoreTargetKeys) == 0 {
+		return source
+	}
+	data := map[string][]byte{}
+	for key, value := range source {
+		data[key] = value
+	}
+	for _, key := range mirrorConfig.IgnoreTargetKeys {
+		delete(data, key)
+	}
+	return data
+}
+
+// desiredData determines the data the target should hold, given the data
+// applicable from the source. Without merging, the target simply holds the
+// source data. When merging, the source data is applied on top of the current
+// target data and keys we applied before but which are no longer in the source
+// are removed, while keys that other parties added are left alone. In either
+// case, ignored target keys are never touched.
+func desiredData(source map[string][]byte, target *coreapi.Secret, mirrorConfig config.MirrorConfig) map[string][]byte {
+	if target == nil {
+		return source
+	}
+	desired := map[string][]byte{}
+	if mirrorConfig.Merge {
+		for key, value := range target.Data {
+			desired[key] = value
+		}
+		for _, key := range lastAppliedKeys(target) {
+			if _, inSource := source[key]; !inSource {
+				delete(desired, key)
+			}
+		}
+	}
+	for key, value := range source {
+		desired[key] = value
+	}
+	for _, key := range mirrorConfig.IgnoreTargetKeys {
+		if value, exists := target.Data[key]; exists {
+			desired[key] = value
+		}
+	}
+	return desired
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestDesiredData(t *testing.T) {
+	source := map[string][]byte{"token": []byte("new"), "user": []byte("ci")}
+	target := &coreapi.Secret{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{lastAppliedKeysAnnotation: "password,token"}},
+		Data: map[string][]byte{
+			"password": []byte("stale"),
+			"token":    []byte("old"),
+			"ca.crt":   []byte("injected"),
+		},
+	}
+	var testCases = []struct {
+		name     string
+		target   *coreapi.Secret
+		mirror   config.MirrorConfig
+		expected map[string][]byte
+	}{
+		{
+			name:     "without merging the source replaces the target",
+			target:   target,
+			expected: source,
+		},
+		{
+			name:     "merging into a missing target yields the source",
+			mirror:   config.MirrorConfig{Merge: true},
+			expected: source,
+		},
+		{
+			name:   "merging keeps foreign keys and drops keys removed from the source",
+			target: target,
+			mirror: config.MirrorConfig{Merge: true},
+			expected: map[string][]byte{
+				"token":  []byte("new"),
+				"user":   []byte("ci"),
+				"ca.crt": []byte("injected"),
+			},
+		},
+		{
+			name:   "merging without a record of applied keys deletes nothing",
+			target: &coreapi.Secret{Data: map[string][]byte{"password": []byte("stale")}},
+			mirror: config.MirrorConfig{Merge: true},
+			expected: map[string][]byte{
+				"password": []byte("stale"),
+				"token":    []byte("new"),
+				"user":     []byte("ci"),
+			},
+		},
+		{
+			name:   "ignored keys survive a replace",
+			target: target,
+			mirror: config.MirrorConfig{IgnoreTargetKeys: []string{"ca.crt", "missing"}},
+			expected: map[string][]byte{
+				"token":  []byte("new"),
+				"user":   []byte("ci"),
+				"ca.crt": []byte("injected"),
+			},
+		},
+		{
+			name:   "ignored keys survive a merge even if they were applied before",
+			target: target,
+			mirror: config.MirrorConfig{Merge: true, IgnoreTargetKeys: []string{"password"}},
+			expected: map[string][]byte{
+				"password": []byte("stale"),
+				"token":    []byte("new"),
+				"user":     []byte("ci"),
+				"ca.crt":   []byte("injected"),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := desiredData(applicableData(source, testCase.mirror), testCase.target, testCase.mirror); !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("%s: unexpected data: %s", testCase.name, diff.ObjectReflectDiff(testCase.expected, actual))
+			}
+		})
+	}
+}
+
+func TestFormatKeys(t *testing.T) {
+	keys := formatKeys(map[string][]byte{"b": nil, "a.crt": nil, "c": nil})
+	if keys != "a.crt,b,c" {
+		t.Errorf("expected sorted keys, got %q", keys)
+	}
+	target := &coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{lastAppliedKeysAnnotation: keys}}}
+	if parsed := lastAppliedKeys(target); !reflect.DeepEqual(parsed, []string{"a.crt", "b", "c"}) {
+		t.Errorf("expected keys to round-trip, got %v", parsed)
+	}
+}
+
+func TestApplicableData(t *testing.T) {
+	source := map[string][]byte{"token": []byte("value"), "ca.crt": []byte("ca")}
+	data := applicableData(source, config.MirrorConfig{IgnoreTargetKeys: []string{"ca.crt"}})
+	if !reflect.DeepEqual(data, map[string][]byte{"token": []byte("value")}) {
+		t.Errorf("expected ignored keys to be dropped, got %v", data)
+	}
+	if len(source) != 2 {
+		t.Error("expected the source data not to be modified")
+	}
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// containsAll determines if every entry in desired is set in actual.
+func containsAll(actual, desired map[string]string) bool {
+	for key, value := range desired {
+		if current, set := actual[key]; !set || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// withEntries returns the existing entries with the desired ones set,
+// without modifying the existing map. Nil is returned if both are empty.
+func withEntries(existing, desired map[string]string) map[string]string {
+	if len(existing) == 0 && len(desired) == 0 {
+		return existing
+	}
+	merged := make(map[string]string, len(existing)+len(desired))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for key, value := range desired {
+		merged[key] = value
+	}
+	return merged
+}
+
+// withSourceMetadata returns the rule with the labels and annotations of
+// the source that it copies added to those it sets, so that they are
+// written, compared and fingerprinted like them. Labels and annotations
+// that are removed from the source are left on the target.
+func withSourceMetadata(source *coreapi.Secret, mirrorConfig config.MirrorConfig) config.MirrorConfig {
+	if mirrorConfig.CopyMetadata == nil {
+		return mirrorConfig
+	}
+	labels, annotations := map[string]string{}, map[string]string{}
+	for key, value := range source.Labels {
+		if mirrorConfig.CopyMetadata.CopiesLabel(key) {
+			labels[key] = value
+		}
+	}
+	for key, value := range source.Annotations {
+		if mirrorConfig.CopyMetadata.CopiesAnnotation(key) {
+			annotations[key] = value
+		}
+	}
+	mirrorConfig.Labels = withEntries(labels, mirrorConfig.Labels)
+	mirrorConfig.Annotations = withEntries(annotations, mirrorConfig.Annotations)
+	return mirrorConfig
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestWithEntries(t *testing.T) {
+	existing := map[string]string{"app": "web", "team": "ci"}
+	merged := withEntries(existing, map[string]string{"team": "release"})
+	if expected := map[string]string{"app": "web", "team": "release"}; !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v, got %v", expected, merged)
+	}
+	if existing["team"] != "ci" {
+		t.Error("expected the existing entries not to be modified")
+	}
+	if !containsAll(merged, map[string]string{"team": "release"}) || containsAll(existing, map[string]string{"team": "release"}) {
+		t.Error("expected only the merged entries to contain the desired entries")
+	}
+	if withEntries(nil, nil) != nil {
+		t.Error("expected no entries to stay nil")
+	}
+}
+
+func TestWithSourceMetadata(t *testing.T) {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{"team": "ci", "app.kubernetes.io/name": "registry", managedByLabel: "argocd"},
+		Annotations: map[string]string{
+			"owner":    "dptp",
+			"internal": "true",
+			"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			doNotOverwriteAnnotation:                           "true",
+		},
+	}}
+	mirrorConfig := config.MirrorConfig{
+		Labels: map[string]string{"team": "release"},
+		CopyMetadata: &config.CopyMetadata{
+			Labels:      []string{"*"},
+			Annotations: []string{"*"},
+			Exclude:     []string{"internal"},
+		},
+	}
+	copied := withSourceMetadata(source, mirrorConfig)
+	if expected := map[string]string{"team": "release", "app.kubernetes.io/name": "registry"}; !reflect.DeepEqual(copied.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, copied.Labels)
+	}
+	if expected := map[string]string{"owner": "dptp"}; !reflect.DeepEqual(copied.Annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, copied.Annotations)
+	}
+	if !reflect.DeepEqual(mirrorConfig.Labels, map[string]string{"team": "release"}) {
+		t.Error("expected the labels of the rule not to be modified")
+	}
+}
+
+func TestReconcileSetsMetadata(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{
+		Secrets: []config.MirrorConfig{
+			{
+				From:   config.SecretLocation{Namespace: "test-ns", Name: "src"},
+				To:     config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+				Labels: map[string]string{"classification": "restricted"},
+			},
+		},
+		Defaults: config.Defaults{Labels: map[string]string{"team": "ci"}, Annotations: map[string]string{"example.com/ttl": "24h"}},
+	})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be created: %v", err)
+	}
+	if expected := map[string]string{"team": "ci", "classification": "restricted", managedByLabel: managedByValue}; !reflect.DeepEqual(target.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, target.Labels)
+	}
+	if target.Annotations["example.com/ttl"] != "24h" || target.Annotations[lastAppliedHashAnnotation] == "" {
+		t.Errorf("expected configured and controller annotations, got %v", target.Annotations)
+	}
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons that syncs had nothing to do
+const (
+	noopReasonUnmatched = "unmatched"
+	noopReasonUnchanged = "unchanged"
+)
+
+var (
+	queueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_queue_depth",
+		Help: "Number of keys waiting in the work queue.",
+	})
+	queueSaturation = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_queue_saturation_ratio",
+		Help: "Ratio of the work queue depth to its configured maximum.",
+	})
+	queueShedKeys = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_mirror_queue_shed_keys_total",
+		Help: "Number of keys dropped because the work queue was saturated.",
+	})
+	quarantinedRules = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_quarantined_rule",
+		Help: "Number of mirroring rules under the label that are quarantined after exhausting their failure budget.",
+	}, metricLabels.rule, false, "rule")
+	pausedRules = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_paused_rule",
+		Help: "Number of mirroring rules under the label that have been paused by hand.",
+	}, metricLabels.rule, false, "rule")
+	validationBlockedRules = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_validation_blocked_rule",
+		Help: "Number of mirroring rules under the label whose last write was blocked because the data failed validation.",
+	}, metricLabels.rule, false, "rule")
+	validationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_mirror_validation_failures_total",
+		Help: "Number of writes blocked because a key failed a validator.",
+	}, []string{"rule", "key", "validator"})
+	credentialExpiryTimestamp = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_credential_expiry_timestamp_seconds",
+		Help: "Earliest expiry of tokens and client certificates mirrored into a key of the targets under the label, in seconds since the epoch.",
+	}, metricLabels.target, true, "target", "key")
+	heldTargets = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_held_target",
+		Help: "Number of targets under the label that are not written to because they are annotated to not be overwritten.",
+	}, metricLabels.target, false, "target")
+	collidingRules = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_colliding_rule",
+		Help: "Number of mirroring rules under the label that are halted because they write conflicting data to the same target as another rule.",
+	}, metricLabels.rule, false, "rule")
+	failingTargets = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_failing_target",
+		Help: "Number of targets under the label that the last attempt to mirror into failed.",
+	}, metricLabels.target, false, "target")
+	orphanedTargets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_orphaned_targets",
+		Help: "Number of secrets marked as written by the controller that no rule writes to anymore.",
+	})
+	prunedTargets = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_mirror_pruned_targets_total",
+		Help: "Number of orphaned targets deleted by pruning.",
+	})
+	driftedRules = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_drifted_rule",
+		Help: "Number of mirroring rules under the label whose target had drifted from the source in the last audit sweep.",
+	}, metricLabels.rule, false, "rule")
+	driftedTargets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_drifted_targets",
+		Help: "Number of targets that had drifted from their source in the last audit sweep.",
+	})
+	lastAuditTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_last_audit_timestamp_seconds",
+		Help: "Completion of the last audit sweep, in seconds since the epoch.",
+	})
+	injectedFaults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_mirror_injected_faults_total",
+		Help: "Number of faults injected into requests to the API server, by fault.",
+	}, []string{"fault"})
+	lastBackupTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_last_backup_timestamp_seconds",
+		Help: "Creation of the last archive of targets that was backed up successfully, in seconds since the epoch.",
+	})
+	stagedConfiguration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_staged_configuration",
+		Help: "Set while a reloaded configuration is rolled out to the canaries only.",
+	})
+	revertedConfigurations = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_mirror_reverted_configurations_total",
+		Help: "Number of reloaded configurations that were reverted because a canary failed.",
+	})
+	pendingApprovals = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_pending_approval_rule",
+		Help: "Number of mirroring rules under the label with a change to their target awaiting approval.",
+	}, metricLabels.rule, false, "rule")
+	frozenWrites = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_frozen",
+		Help: "Set while writes are frozen.",
+	})
+	heldChanges = newAggregatedGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_held_change_rule",
+		Help: "Number of mirroring rules under the label with a change to their target held back while writes are frozen.",
+	}, metricLabels.rule, false, "rule")
+	noopSyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_mirror_noop_syncs_total",
+		Help: "Number of syncs that had nothing to do, by reason: unmatched sources that no rule mirrors from or unchanged targets that already match their source.",
+	}, []string{"reason"})
+	throttledRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_mirror_throttled_requests_total",
+		Help: "Number of requests the API server throttled, making every worker back off.",
+	})
+	auditLogFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_mirror_audit_log_failures_total",
+		Help: "Number of writes to targets that could not be recorded in the audit log.",
+	})
+	ignoredTransformFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_mirror_ignored_transform_failures_total",
+		Help: "Number of transform failures that were ignored as the failure policy of the transform asked for, mirroring the data untransformed.",
+	}, []string{"transform"})
+	auditLogEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_mirror_audit_log_entries",
+		Help: "Number of entries in the audit log, which a log that lost its last entries falls short of.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth)
+	prometheus.MustRegister(queueSaturation)
+	prometheus.MustRegister(queueShedKeys)
+	prometheus.MustRegister(quarantinedRules.vec)
+	prometheus.MustRegister(pausedRules.vec)
+	prometheus.MustRegister(validationBlockedRules.vec)
+	prometheus.MustRegister(validationFailures)
+	prometheus.MustRegister(credentialExpiryTimestamp.vec)
+	prometheus.MustRegister(heldTargets.vec)
+	prometheus.MustRegister(collidingRules.vec)
+	prometheus.MustRegister(failingTargets.vec)
+	prometheus.MustRegister(orphanedTargets)
+	prometheus.MustRegister(prunedTargets)
+	prometheus.MustRegister(throttledRequests)
+	prometheus.MustRegister(auditLogFailures)
+	prometheus.MustRegister(ignoredTransformFailures)
+	prometheus.MustRegister(auditLogEntries)
+	prometheus.MustRegister(noopSyncs)
+	prometheus.MustRegister(driftedRules.vec)
+	prometheus.MustRegister(driftedTargets)
+	prometheus.MustRegister(lastAuditTimestamp)
+	prometheus.MustRegister(lastBackupTimestamp)
+	prometheus.MustRegister(injectedFaults)
+	prometheus.MustRegister(stagedConfiguration)
+	prometheus.MustRegister(revertedConfigurations)
+	prometheus.MustRegister(pendingApprovals.vec)
+	prometheus.MustRegister(frozenWrites)
+	prometheus.MustRegister(heldChanges.vec)
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// NewNamespacedSecretMirror returns a new *SecretMirror that only watches
+// secrets in the namespaces of the informers, each of which must only
+// inform about its namespace, rather than every secret in the cluster.
+// Rules reading or writing secrets in any other namespace fail, so rules
+// selecting their target namespaces by label never write anywhere.
+// Namespaces are watched and unwatched with WatchNamespace and
+// UnwatchNamespace as the configuration changes.
+func NewNamespacedSecretMirror(informers map[string]coreinformers.SecretInformer, client kubeclientset.Interface, config config.Getter, options Options) *SecretMirror {
+	listers := &namespacedSecretLister{listers: map[string]namespaceLister{}}
+	for namespace, informer := range informers {
+		listers.watch(namespace, informer)
+	}
+	c := newSecretMirror(listers, client, config, options)
+	c.namespaced = listers
+	for _, informer := range informers {
+		c.watchSecrets(informer)
+	}
+	c.watchOptions(options)
+	return c
+}
+
+// WatchNamespace starts mirroring secrets in the namespace, e.g. once a
+// reloaded configuration references it, from the informer, which must
+// only inform about the namespace. The caller starts the informer; rules
+// reading or writing secrets in the namespace fail until it has synced.
+func (c *SecretMirror) WatchNamespace(namespace string, informer coreinformers.SecretInformer) {
+	c.namespaced.watch(namespace, informer)
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.add,
+		UpdateFunc: c.update,
+		DeleteFunc: c.delete,
+	})
+}
+
+// UnwatchNamespace stops reading secrets in the namespace, e.g. once a
+// reloaded configuration no longer references it. The caller stops
+// the informer of the namespace.
+func (c *SecretMirror) UnwatchNamespace(namespace string) {
+	c.namespaced.unwatch(namespace)
+}
+
+// SecretNamespaces returns the namespaces whose secrets the rules in
+// effect or the configuration loaded last read or write, which differ
+// while a configuration is rolled out to canaries.
+func (c *SecretMirror) SecretNamespaces() []string {
+	namespaces := sets.NewString()
+	for _, configuration := range []*config.Configuration{c.config(), c.rollout.source()} {
+		if configuration != nil {
+			namespaces.Insert(configuration.SecretNamespaces()...)
+		}
+	}
+	return namespaces.List()
+}
+
+// namespaceLister lists the secrets in a namespace once its informer synced.
+type namespaceLister struct {
+	lister corelisters.SecretLister
+	synced cache.InformerSynced
+}
+
+// namespacedSecretLister lists secrets from the lister of their namespace.
+type namespacedSecretLister struct {
+	lock    sync.RWMutex
+	listers map[string]namespaceLister
+}
+
+func (l *namespacedSecretLister) watch(namespace string, informer coreinformers.SecretInformer) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.listers[namespace] = namespaceLister{lister: informer.Lister(), synced: informer.Informer().HasSynced}
+}
+
+func (l *namespacedSecretLister) unwatch(namespace string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.listers, namespace)
+}
+
+// List lists the secrets in every watched namespace, failing while
+// the informer of any of them has not synced.
+func (l *namespacedSecretLister) List(selector labels.Selector) ([]*coreapi.Secret, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	var secrets []*coreapi.Secret
+	for namespace, watched := range l.listers {
+		if !watched.synced() {
+			return nil, unsyncedNamespace(namespace).err()
+		}
+		listed, err := watched.lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, listed...)
+	}
+	return secrets, nil
+}
+
+// Secrets lists and gets the secrets in the namespace, failing for
+// every secret if the namespace is not watched or not synced yet, as
+// a secret missing from the cache may not be missing from the cluster.
+func (l *namespacedSecretLister) Secrets(namespace string) corelisters.SecretNamespaceLister {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	watched, ok := l.listers[namespace]
+	if !ok {
+		return unwatchedNamespace(namespace)
+	}
+	if !watched.synced() {
+		return unsyncedNamespace(namespace)
+	}
+	return watched.lister.Secrets(namespace)
+}
+
+// unwatchedNamespace is a namespace whose secrets are not watched.
+type unwatchedNamespace string
+
+func (n unwatchedNamespace) List(labels.Selector) ([]*coreapi.Secret, error) {
+	return nil, n.err()
+}
+
+func (n unwatchedNamespace) Get(string) (*coreapi.Secret, error) {
+	return nil, n.err()
+}
+
+func (n unwatchedNamespace) err() error {
+	return fmt.Errorf("secrets in namespace %s are not watched as the configuration does not reference the namespace", string(n))
+}
+
+// unsyncedNamespace is a namespace whose secrets are not listed yet.
+type unsyncedNamespace string
+
+func (n unsyncedNamespace) List(labels.Selector) ([]*coreapi.Secret, error) {
+	return nil, n.err()
+}
+
+func (n unsyncedNamespace) Get(string) (*coreapi.Secret, error) {
+	return nil, n.err()
+}
+
+func (n unsyncedNamespace) err() error {
+	return fmt.Errorf("secrets in namespace %s are not listed yet", string(n))
+}
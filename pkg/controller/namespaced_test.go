@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// namespaceInformer returns an informer for the secrets in the namespace,
+// which is started and synced unless stop is nil.
+func namespaceInformer(t *testing.T, client *testclient.Clientset, namespace string, stop chan struct{}) coreinformers.SecretInformer {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 5*time.Minute, informers.WithNamespace(namespace))
+	informer := factory.Core().V1().Secrets()
+	// requesting the informer makes the factory start it
+	informer.Informer()
+	if stop != nil {
+		factory.Start(stop)
+		if !cache.WaitForCacheSync(stop, informer.Informer().HasSynced) {
+			t.Fatalf("failed to sync the informer of namespace %s", namespace)
+		}
+	}
+	return informer
+}
+
+func TestNamespacedSecretMirror(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	stop := make(chan struct{})
+	defer close(stop)
+	namespaced := map[string]coreinformers.SecretInformer{}
+	for _, namespace := range []string{"source-ns", "target-ns"} {
+		namespaced[namespace] = namespaceInformer(t, client, namespace, stop)
+	}
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "source-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	if err := namespaced["source-ns"].Informer().GetIndexer().Add(source); err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "source-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "target-ns", Name: "dst"},
+		},
+		{
+			From: config.SecretLocation{Namespace: "source-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "other-ns", Name: "dst"},
+		},
+	}})
+	c := NewNamespacedSecretMirror(namespaced, client, ca.Config, Options{})
+	defer c.queue.ShutDown()
+	if len(c.synced) != len(namespaced) {
+		t.Errorf("expected to wait for %d informers to sync, got %d", len(namespaced), len(c.synced))
+	}
+
+	err := c.reconcile("source-ns/src")
+	if err == nil || !strings.Contains(err.Error(), "namespace other-ns are not watched") {
+		t.Errorf("expected the rule writing to an unwatched namespace to fail, got %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("target-ns").Get("dst", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the target in a watched namespace to be created: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("other-ns").Get("dst", metav1.GetOptions{}); err == nil {
+		t.Error("expected no target to be written to an unwatched namespace")
+	}
+
+	secrets, err := c.lister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("failed to list secrets: %v", err)
+	}
+	if len(secrets) != 1 {
+		t.Errorf("expected to list the secrets of every watched namespace, got %v", secrets)
+	}
+}
+
+func TestWatchNamespace(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	stop := make(chan struct{})
+	defer close(stop)
+	source := namespaceInformer(t, client, "source-ns", stop)
+	if err := source.Informer().GetIndexer().Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "source-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "source-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "new-ns", Name: "dst"},
+		},
+	}})
+	c := NewNamespacedSecretMirror(map[string]coreinformers.SecretInformer{"source-ns": source}, client, ca.Config, Options{})
+	defer c.queue.ShutDown()
+	if expected, actual := []string{"new-ns", "source-ns"}, c.SecretNamespaces(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected the namespaces of the configuration %v, got %v", expected, actual)
+	}
+
+	if err := c.reconcile("source-ns/src"); err == nil || !strings.Contains(err.Error(), "namespace new-ns are not watched") {
+		t.Errorf("expected the rule writing to an unwatched namespace to fail, got %v", err)
+	}
+	informer := namespaceInformer(t, client, "new-ns", nil)
+	c.WatchNamespace("new-ns", informer)
+	if err := c.reconcile("source-ns/src"); err == nil || !strings.Contains(err.Error(), "namespace new-ns are not listed yet") {
+		t.Errorf("expected the rule writing to a namespace that is not synced yet to fail, got %v", err)
+	}
+	go informer.Informer().Run(stop)
+	if !cache.WaitForCacheSync(stop, informer.Informer().HasSynced) {
+		t.Fatal("failed to sync the informer of the new namespace")
+	}
+	if err := c.reconcile("source-ns/src"); err != nil {
+		t.Errorf("expected the rule writing to the newly watched namespace to succeed, got %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("new-ns").Get("dst", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the target in the newly watched namespace to be created: %v", err)
+	}
+
+	c.UnwatchNamespace("new-ns")
+	if _, err := c.lister.Secrets("new-ns").Get("dst"); err == nil || !strings.Contains(err.Error(), "namespace new-ns are not watched") {
+		t.Errorf("expected secrets in the unwatched namespace not to be read, got %v", err)
+	}
+}
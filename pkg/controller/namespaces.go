@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"sort"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// fanOut returns the rule instantiated for every namespace in the cache
+// that it selects by labels and that the policy allows, in order of their
+// names, or the rule itself if it names its target namespace.
+func (c *SecretMirror) fanOut(mirrorConfig config.MirrorConfig) []config.MirrorConfig {
+	if !mirrorConfig.FansOut() {
+		return []config.MirrorConfig{mirrorConfig}
+	}
+	if c.namespaceLister == nil {
+		return nil
+	}
+	namespaces, err := c.namespaceLister.List(labels.Everything())
+	if err != nil {
+		c.logger.WithError(err).WithField("rule", mirrorConfig.String()).Warn("failed to list the namespaces matching the selector of the rule")
+		return nil
+	}
+	policy := c.config().Policy
+	var instances []config.MirrorConfig
+	for _, namespace := range namespaces {
+		if namespace.Status.Phase == coreapi.NamespaceTerminating || !policy.AllowsNamespace(namespace.Name) {
+			continue
+		}
+		if instance, matches := mirrorConfig.InstantiateTarget(namespace.Name, namespace.Labels); matches {
+			instances = append(instances, instance)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].To.Namespace < instances[j].To.Namespace })
+	return instances
+}
+
+// namespaceChanged mirrors into namespaces that rules start selecting and
+// removes what was mirrored into namespaces that rules stop selecting.
+func (c *SecretMirror) namespaceChanged(old, namespace *coreapi.Namespace) {
+	configuration := c.config()
+	for _, mirrorConfig := range configuration.Secrets {
+		if !mirrorConfig.FansOut() {
+			continue
+		}
+		instance, matches := mirrorConfig.InstantiateTarget(namespace.Name, namespace.Labels)
+		var previous config.MirrorConfig
+		matched := false
+		if old != nil {
+			previous, matched = mirrorConfig.InstantiateTarget(old.Name, old.Labels)
+		}
+		switch {
+		case matches && !matched:
+			c.enqueueKey(instance.From.String())
+		case matched && !matches:
+			c.removeTarget(configuration, previous)
+		}
+	}
+}
+
+// removeTarget deletes the target of a rule that no longer selects
+// its namespace.
+func (c *SecretMirror) removeTarget(configuration *config.Configuration, mirrorConfig config.MirrorConfig) {
+	to := mirrorConfig.To
+	logger := c.logger.WithFields(logrus.Fields{"rule": mirrorConfig.String(), "target-namespace": to.Namespace, "target-secret": to.Name})
+	logger.Info("removing the target in a namespace that is no longer selected")
+	if err := c.deleteTarget(configuration, mirrorConfig, logger); err != nil {
+		logger.WithError(err).Warn("failed to remove the target in a namespace that is no longer selected")
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestFanOutToSelectedNamespaces(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "token"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	selected := map[string]string{"team-secrets": "true"}
+	namespaces := informers.NewSharedInformerFactory(client, 0).Core().V1().Namespaces()
+	for _, namespace := range []*v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: selected}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: selected}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-c", Labels: selected}, Status: v1.NamespaceStatus{Phase: v1.NamespaceTerminating}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "openshift-config", Labels: selected}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	} {
+		if err := namespaces.Informer().GetIndexer().Add(namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{
+		Secrets: []config.MirrorConfig{{
+			From: config.SecretLocation{Namespace: "ci", Name: "token"},
+			To:   config.SecretLocation{NamespaceSelector: "team-secrets=true", Name: "ci-token"},
+		}},
+		Policy: &config.Policy{DeniedNamespaces: []string{"openshift-*"}},
+	})
+	c := NewSecretMirror(informer, client, ca.Config, Options{Namespaces: namespaces})
+	if err := c.reconcile("ci/token"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	for namespace, expected := range map[string]bool{"team-a": true, "team-b": true, "team-c": false, "openshift-config": false, "other": false} {
+		target, err := client.CoreV1().Secrets(namespace).Get("ci-token", metav1.GetOptions{})
+		if (err == nil) != expected {
+			t.Errorf("expected a target in %s: %t, got error %v", namespace, expected, err)
+		}
+		if err == nil && string(target.Data["key"]) != "value" {
+			t.Errorf("expected the target in %s to hold the source data, got %q", namespace, string(target.Data["key"]))
+		}
+	}
+
+	// a namespace that stops matching loses the target written there
+	target, err := client.CoreV1().Secrets("team-b").Get("ci-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(target); err != nil {
+		t.Fatal(err)
+	}
+	c.namespaceChanged(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: selected}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+	)
+	if _, err := client.CoreV1().Secrets("team-b").Get("ci-token", metav1.GetOptions{}); err == nil {
+		t.Error("expected the target in a namespace that is no longer selected to be removed")
+	}
+	if _, err := client.CoreV1().Secrets("team-a").Get("ci-token", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the target in a namespace that is still selected to be kept: %v", err)
+	}
+}
+
+func TestFanOutWithoutNamespaces(t *testing.T) {
+	c := &SecretMirror{}
+	mirrorConfig := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "ci", Name: "token"},
+		To:   config.SecretLocation{NamespaceSelector: "team-secrets=true", Name: "ci-token"},
+	}
+	if instances := c.fanOut(mirrorConfig); len(instances) != 0 {
+		t.Errorf("expected no targets without watching namespaces, got %v", instances)
+	}
+	mirrorConfig.To = config.SecretLocation{Namespace: "team", Name: "ci-token"}
+	if instances := c.fanOut(mirrorConfig); len(instances) != 1 || instances[0].To != mirrorConfig.To {
+		t.Errorf("expected a rule naming its target namespace to be left alone, got %v", instances)
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// MirrorOnce mirrors the source of the rule to its target once, going
+// through the same checks as the controller, e.g. refusing to write
+// protected targets or targets that opted out of being overwritten.
+// The source and target are read from the API server directly instead
+// of being watched, so that no informer needs to sync first.
+func MirrorOnce(client kubeclientset.Interface, mirrorConfig config.MirrorConfig, options Options) error {
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{mirrorConfig}}
+	if err := configuration.Validate(); err != nil {
+		return fmt.Errorf("invalid rule: %v", err)
+	}
+	locations := append(mirrorConfig.Sources(), mirrorConfig.To)
+	for _, location := range locations {
+		if location.Cluster != "" || location.Provider() != "" {
+			return fmt.Errorf("mirroring once is only supported within the cluster")
+		}
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, location := range locations {
+		secret, err := client.CoreV1().Secrets(location.Namespace).Get(location.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			if !location.Equals(mirrorConfig.To) {
+				return fmt.Errorf("source secret %s does not exist", location.String())
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get secret %s: %v", location.String(), err)
+		}
+		if err := indexer.Add(secret); err != nil {
+			return fmt.Errorf("failed to index secret %s: %v", location.String(), err)
+		}
+	}
+
+	c := newSecretMirror(corelisters.NewSecretLister(indexer), client, func() *config.Configuration { return configuration }, options)
+	if mirrorConfig.Output == config.OutputExternalSecret {
+		return c.syncExternalSecrets()
+	}
+	return c.reconcile(mirrorConfig.From.String())
+}
+
+// RunOnce reconciles the source of every configured rule once after the
+// caches are synced and returns the failures, instead of watching sources
+// like Run. Failures are not retried. Polled sources are fetched first,
+// as no informer holds them, and ExternalSecrets are written last.
+func (c *SecretMirror) RunOnce(stopCh <-chan struct{}) error {
+	c.logger.Infof("Waiting for caches to reconcile for %s controller", secretMirrorname)
+	if !cache.WaitForCacheSync(stopCh, c.synced...) {
+		return fmt.Errorf("unable to reconcile caches for %s controller", secretMirrorname)
+	}
+	c.poll()
+	var errs []error
+	for _, key := range c.sourceKeys() {
+		if err := c.reconcile(key); err != nil {
+			c.logger.WithField("source", key).WithError(err).Error("failed to reconcile source")
+			errs = append(errs, fmt.Errorf("source %s: %v", key, err))
+		}
+	}
+	if err := c.syncExternalSecrets(); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
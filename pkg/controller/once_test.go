@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestMirrorOnce(t *testing.T) {
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "src-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	var testCases = []struct {
+		name           string
+		to             config.SecretLocation
+		secrets        []*v1.Secret
+		adoptExisting  bool
+		expectedErr    bool
+		expectedTarget bool
+	}{
+		{
+			name:           "target is created",
+			to:             config.SecretLocation{Namespace: "dst-ns", Name: "dst"},
+			secrets:        []*v1.Secret{source},
+			expectedTarget: true,
+		},
+		{
+			name: "existing target is updated",
+			to:   config.SecretLocation{Namespace: "dst-ns", Name: "dst"},
+			secrets: []*v1.Secret{source, {
+				ObjectMeta: metav1.ObjectMeta{Namespace: "dst-ns", Name: "dst"},
+				Data:       map[string][]byte{"key": []byte("old")},
+			}},
+			adoptExisting:  true,
+			expectedTarget: true,
+		},
+		{
+			name:        "missing source",
+			to:          config.SecretLocation{Namespace: "dst-ns", Name: "dst"},
+			expectedErr: true,
+		},
+		{
+			name:        "protected target",
+			to:          config.SecretLocation{Namespace: "dst-ns", Name: "default-token-abcde"},
+			secrets:     []*v1.Secret{source},
+			expectedErr: true,
+		},
+		{
+			name:        "target is the source",
+			to:          config.SecretLocation{Namespace: "src-ns", Name: "src"},
+			secrets:     []*v1.Secret{source},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			for _, secret := range testCase.secrets {
+				if _, err := client.CoreV1().Secrets(secret.Namespace).Create(secret.DeepCopy()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := MirrorOnce(client, config.MirrorConfig{From: config.SecretLocation{Namespace: "src-ns", Name: "src"}, To: testCase.to, AdoptExisting: testCase.adoptExisting}, Options{})
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+
+			target, err := client.CoreV1().Secrets(testCase.to.Namespace).Get(testCase.to.Name, metav1.GetOptions{})
+			if !testCase.expectedTarget {
+				if testCase.to.Name != "src" && !errors.IsNotFound(err) {
+					t.Errorf("%s: expected no target to be written, got %v", testCase.name, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: expected the target to be written: %v", testCase.name, err)
+			}
+			if actual := string(target.Data["key"]); actual != "value" {
+				t.Errorf("%s: expected the target to hold the source data, got %q", testCase.name, actual)
+			}
+		})
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "src-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	taken := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "dst-ns", Name: "taken"}}
+	if _, err := client.CoreV1().Secrets(taken.Namespace).Create(taken); err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(taken); err != nil {
+		t.Fatal(err)
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{From: config.SecretLocation{Namespace: "src-ns", Name: "src"}, To: config.SecretLocation{Namespace: "dst-ns", Name: "dst"}},
+		{From: config.SecretLocation{Namespace: "src-ns", Name: "src"}, To: config.SecretLocation{Namespace: "dst-ns", Name: "taken"}},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	defer c.queue.ShutDown()
+
+	if err := c.RunOnce(make(chan struct{})); err == nil {
+		t.Error("expected the rule refusing to overwrite its target to fail the run")
+	}
+	target, err := client.CoreV1().Secrets("dst-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the other rule to write its target: %v", err)
+	}
+	if actual := string(target.Data["key"]); actual != "value" {
+		t.Errorf("expected the target to hold the source data, got %q", actual)
+	}
+}
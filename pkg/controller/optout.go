@@ -0,0 +1,18 @@
+package controller
+
+import (
+	coreapi "k8s.io/api/core/v1"
+)
+
+const (
+	// sourceOptOutAnnotation lets the owner of a source secret halt its
+	// propagation without changing the configuration, e.g. during an
+	// incident, by setting it to sourceOptOutValue
+	sourceOptOutAnnotation = "ci.openshift.io/mirroring"
+	sourceOptOutValue      = "disabled"
+)
+
+// optedOut determines if the source is excluded from all rules.
+func optedOut(source *coreapi.Secret) bool {
+	return source.Annotations[sourceOptOutAnnotation] == sourceOptOutValue
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileHonorsOptOut(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		annotations     map[string]string
+		expectedCreated bool
+	}{
+		{
+			name:            "sources without the annotation are mirrored",
+			expectedCreated: true,
+		},
+		{
+			name:            "sources with mirroring enabled are mirrored",
+			annotations:     map[string]string{sourceOptOutAnnotation: "enabled"},
+			expectedCreated: true,
+		},
+		{
+			name:        "sources with mirroring disabled are not mirrored",
+			annotations: map[string]string{sourceOptOutAnnotation: sourceOptOutValue},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src", Annotations: testCase.annotations},
+				Data:       map[string][]byte{"key": []byte("value")},
+			})
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+				{
+					From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+					To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+				},
+			}})
+			c := NewSecretMirror(informer, client, ca.Config, Options{})
+			if err := c.reconcile("test-ns/src"); err != nil {
+				t.Fatalf("%s: failed to reconcile: %v", testCase.name, err)
+			}
+			_, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+			if created := err == nil; created != testCase.expectedCreated {
+				t.Errorf("%s: expected the target to be created to be %t, got %t", testCase.name, testCase.expectedCreated, created)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/sirupsen/logrus"
+)
+
+// orphanCountPeriod is how often the controller counts orphaned targets.
+const orphanCountPeriod = time.Minute
+
+// countOrphans exports the number of orphaned targets in the cluster.
+func (c *SecretMirror) countOrphans() {
+	secrets, err := c.lister.List(labels.Everything())
+	if err != nil {
+		c.logger.WithError(err).Error("failed to list secrets to count orphaned targets")
+		return
+	}
+	orphanedTargets.Set(float64(len(orphans(secrets, c.config()))))
+}
+
+// orphans returns the secrets that carry the marker the controller leaves on
+// targets it writes but which no rule in the configuration writes to.
+func orphans(secrets []*coreapi.Secret, configuration *config.Configuration) []*coreapi.Secret {
+	configured := newTargetSet(configuration)
+	var orphaned []*coreapi.Secret
+	for _, secret := range secrets {
+		if _, managed := secret.Annotations[lastAppliedHashAnnotation]; managed && !configured.contains(config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}) {
+			orphaned = append(orphaned, secret)
+		}
+	}
+	return orphaned
+}
+
+// prunableOrphans returns the orphans that pruning deletes under the
+// configuration, i.e. those the controller may delete at all. Pruning
+// and its dry run both go through it so that they cannot disagree.
+func prunableOrphans(secrets []*coreapi.Secret, configuration *config.Configuration, protectedPatterns []string) []*coreapi.Secret {
+	var prunable []*coreapi.Secret
+	for _, secret := range orphans(secrets, configuration) {
+		to := config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}
+		if deletionGuard(to, secret, configuration, protectedPatterns) == "" {
+			prunable = append(prunable, secret)
+		}
+	}
+	return prunable
+}
+
+// pruneOrphanedTargets deletes the targets that the configuration orphans
+// were last pruned against wrote to but the current configuration does
+// not. Only the difference between generations is pruned; on startup, the
+// inventory stands in for the configuration the controller ran with before,
+// so targets orphaned while it was down are pruned if it recorded them and
+// other targets that were orphaned before are left alone. While writes are
+// frozen, pruning waits so that no difference is lost.
+func (c *SecretMirror) pruneOrphanedTargets() {
+	current, previous := c.config(), c.prunedGeneration
+	if current == previous {
+		return
+	}
+	if c.freeze.isFrozen() && (previous != nil || c.inventory.namespace != "") {
+		return
+	}
+	if previous != nil {
+		c.pruneRemovedTargets(newTargetSet(previous).contains, current)
+	} else if c.inventory.namespace != "" {
+		inventoried, err := c.inventoriedTargets()
+		if err != nil {
+			// retried on the next period, so no orphan is missed
+			c.logger.WithError(err).Error("failed to read the inventory to prune orphaned targets")
+			return
+		}
+		c.pruneRemovedTargets(func(to config.SecretLocation) bool { return inventoried[to] }, current)
+	}
+	c.prunedGeneration = current
+}
+
+// inventoriedTargets returns the targets recorded in the inventory.
+func (c *SecretMirror) inventoriedTargets() (map[config.SecretLocation]bool, error) {
+	inventory, err := c.writeClient.CoreV1().ConfigMaps(c.inventory.namespace).Get(InventoryConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	targets := map[config.SecretLocation]bool{}
+	for key := range inventory.Data {
+		parts := strings.SplitN(key, ".", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed inventory key %q", key)
+		}
+		targets[config.SecretLocation{Namespace: parts[0], Name: parts[1]}] = true
+	}
+	return targets, nil
+}
+
+// pruneRemovedTargets deletes the managed targets that were removed, i.e.
+// that the previous configuration or the inventory held, and that the
+// current configuration does not write to.
+func (c *SecretMirror) pruneRemovedTargets(removed func(config.SecretLocation) bool, current *config.Configuration) {
+	secrets, err := c.lister.List(labels.Everything())
+	if err != nil {
+		c.logger.WithError(err).Error("failed to list secrets to prune orphaned targets")
+		return
+	}
+	for _, secret := range prunableOrphans(secrets, current, c.protectedTargets) {
+		to := config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}
+		if !removed(to) {
+			continue
+		}
+		logger := c.logger.WithFields(logrus.Fields{"target-namespace": to.Namespace, "target-secret": to.Name})
+		logger.Info("pruning target secret as no rule writes to it anymore")
+		deleted, err := c.deleteManagedTarget(to, secret, logger)
+		if err != nil {
+			logger.WithError(err).Warn("failed to prune orphaned target")
+			continue
+		}
+		if deleted {
+			prunedTargets.Inc()
+		}
+	}
+}
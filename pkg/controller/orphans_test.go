@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestOrphans(t *testing.T) {
+	managed := map[string]string{lastAppliedHashAnnotation: "abc"}
+	secrets := []*v1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "configured", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "orphaned", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "unmanaged"}},
+	}
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "configured"},
+		},
+	}}
+
+	orphaned := orphans(secrets, configuration)
+	if len(orphaned) != 1 || orphaned[0].Name != "orphaned" {
+		var names []string
+		for _, secret := range orphaned {
+			names = append(names, secret.Name)
+		}
+		t.Errorf("expected only the managed target without a rule to be orphaned, got %v", names)
+	}
+}
+
+func TestPruneOrphanedTargets(t *testing.T) {
+	managed := map[string]string{lastAppliedHashAnnotation: "abc"}
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"}})
+	for _, secret := range []*v1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "kept", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "removed", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "orphaned-before", Annotations: managed}},
+	} {
+		if _, err := client.CoreV1().Secrets(secret.Namespace).Create(secret); err != nil {
+			t.Fatal(err)
+		}
+		if err := informer.Informer().GetIndexer().Add(secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rule := func(target string) config.MirrorConfig {
+		return config.MirrorConfig{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: target},
+		}
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{rule("kept"), rule("removed")}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{PruneOrphans: true})
+
+	// the first configuration is only remembered
+	c.pruneOrphanedTargets()
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{rule("kept")}})
+	c.pruneOrphanedTargets()
+
+	for name, expected := range map[string]bool{"kept": true, "removed": false, "orphaned-before": true} {
+		if _, err := client.CoreV1().Secrets("test-ns").Get(name, metav1.GetOptions{}); (err == nil) != expected {
+			t.Errorf("expected target %s to exist: %t, got error %v", name, expected, err)
+		}
+	}
+}
+
+func TestPruneOrphanedTargetsFromInventory(t *testing.T) {
+	managed := map[string]string{lastAppliedHashAnnotation: "abc"}
+	client := testclient.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: InventoryConfigMap},
+		Data: map[string]string{
+			"test-ns.kept":               `{"rule":"(test-ns/src -> test-ns/kept)"}`,
+			"test-ns.orphaned-when-down": `{"rule":"(test-ns/src -> test-ns/orphaned-when-down)"}`,
+		},
+	})
+	informer := syncedInformer(t, client, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"}})
+	for _, secret := range []*v1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "kept", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "orphaned-when-down", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "not-inventoried", Annotations: managed}},
+	} {
+		if _, err := client.CoreV1().Secrets(secret.Namespace).Create(secret); err != nil {
+			t.Fatal(err)
+		}
+		if err := informer.Informer().GetIndexer().Add(secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "kept"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{PruneOrphans: true, InventoryNamespace: "ci"})
+
+	// the rule of the orphan was removed before the controller started
+	c.pruneOrphanedTargets()
+
+	for name, expected := range map[string]bool{"kept": true, "orphaned-when-down": false, "not-inventoried": true} {
+		if _, err := client.CoreV1().Secrets("test-ns").Get(name, metav1.GetOptions{}); (err == nil) != expected {
+			t.Errorf("expected target %s to exist: %t, got error %v", name, expected, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/pager"
+)
+
+// UsePagedSecretInformer makes the secret informer of the factory list
+// secrets in pages of pageSize instead of all at once, so that relisting
+// does not require the API server to hold every secret in memory. Paged
+// lists are served by etcd rather than the watch cache of the API server,
+// as the watch cache does not support paging. It must be called before the
+// secret informer of the factory is first requested. A pageSize of zero
+// leaves the informer untouched.
+func UsePagedSecretInformer(factory informers.SharedInformerFactory, pageSize int64) {
+	UsePagedFilteredSecretInformer(factory, metav1.NamespaceAll, "", pageSize)
+}
+
+// UsePagedFilteredSecretInformer is UsePagedSecretInformer for a factory
+// restricted to the namespace, which the factory does not pass on to the
+// informers it builds. The secret informer also only watches secrets
+// matching the label selector, if any, while the other informers of the
+// factory are not filtered. The informer is left untouched only if it is
+// neither paged nor filtered.
+func UsePagedFilteredSecretInformer(factory informers.SharedInformerFactory, namespace, labelSelector string, pageSize int64) {
+	if pageSize <= 0 && labelSelector == "" {
+		return
+	}
+	factory.InformerFor(&coreapi.Secret{}, func(client kubeclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+		secrets := client.CoreV1().Secrets(namespace)
+		list := func(options metav1.ListOptions) (runtime.Object, error) {
+			return secrets.List(options)
+		}
+		if pageSize > 0 {
+			listPager := pager.New(pager.SimplePageFunc(list))
+			listPager.PageSize = pageSize
+			list = func(options metav1.ListOptions) (runtime.Object, error) {
+				// lists from the watch cache ignore the limit
+				options.ResourceVersion = ""
+				return listPager.List(context.Background(), options)
+			}
+		}
+		return cache.NewSharedIndexInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = labelSelector
+				return list(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = labelSelector
+				return secrets.Watch(options)
+			},
+		}, &coreapi.Secret{}, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestUsePagedSecretInformer(t *testing.T) {
+	client := testclient.NewSimpleClientset(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "first", Name: "secret"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "second", Name: "secret"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "third", Name: "secret"}},
+	)
+	factory := informers.NewSharedInformerFactory(client, time.Minute)
+	UsePagedSecretInformer(factory, 2)
+	informer := factory.Core().V1().Secrets()
+	informer.Informer()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, informer.Informer().HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+
+	all, err := informer.Lister().List(labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected the informer to list all three secrets, got %d", len(all))
+	}
+	namespaced, err := informer.Lister().Secrets("second").List(labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaced) != 1 {
+		t.Errorf("expected the namespaced lister to find one secret, got %d", len(namespaced))
+	}
+}
+
+func TestUsePagedFilteredSecretInformer(t *testing.T) {
+	client := testclient.NewSimpleClientset(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "first", Name: "labelled", Labels: map[string]string{"ci.openshift.io/mirror": "true"}}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "first", Name: "unlabelled"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "second", Name: "labelled", Labels: map[string]string{"ci.openshift.io/mirror": "true"}}},
+	)
+	for _, pageSize := range []int64{0, 1} {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, time.Minute, informers.WithNamespace("first"))
+		UsePagedFilteredSecretInformer(factory, "first", "ci.openshift.io/mirror=true", pageSize)
+		informer := factory.Core().V1().Secrets()
+		informer.Informer()
+
+		stop := make(chan struct{})
+		factory.Start(stop)
+		if !cache.WaitForCacheSync(stop, informer.Informer().HasSynced) {
+			t.Fatal("informer did not sync")
+		}
+		close(stop)
+
+		secrets, err := informer.Lister().List(labels.Everything())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(secrets) != 1 || secrets[0].Namespace != "first" || secrets[0].Name != "labelled" {
+			t.Errorf("page size %d: expected the informer to only list the labelled secret in its namespace, got %v", pageSize, secrets)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// instances returns the rule instantiated for every secret in the cache
+// that it selects by pattern, in order of their names, or the rule fanned
+// out to the namespaces it selects if it names its source.
+func (c *SecretMirror) instances(mirrorConfig config.MirrorConfig) []config.MirrorConfig {
+	if !mirrorConfig.IsPattern() {
+		return c.fanOut(mirrorConfig)
+	}
+	from := mirrorConfig.From
+	lister := c.lister
+	if from.Cluster != "" {
+		remote, configured := c.remoteListers[from.Cluster]
+		if !configured {
+			return nil
+		}
+		lister = remote
+	}
+	secrets, err := lister.Secrets(from.Namespace).List(labels.Everything())
+	if err != nil {
+		c.logger.WithError(err).WithField("rule", mirrorConfig.String()).Warn("failed to list the sources matching the pattern of the rule")
+		return nil
+	}
+	var instances []config.MirrorConfig
+	for _, secret := range secrets {
+		if instance, matches := mirrorConfig.Instantiate(config.SecretLocation{Cluster: from.Cluster, Namespace: secret.Namespace, Name: secret.Name}); matches {
+			instances = append(instances, instance)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].From.Name < instances[j].From.Name })
+	return instances
+}
+
+// concreteRules returns the rules of the configuration, with rules
+// selecting their sources by pattern instantiated for every match.
+func (c *SecretMirror) concreteRules(configuration *config.Configuration) []config.MirrorConfig {
+	var rules []config.MirrorConfig
+	for _, mirrorConfig := range configuration.Secrets {
+		rules = append(rules, c.instances(mirrorConfig)...)
+	}
+	return rules
+}
+
+// targetSet determines if any rule of a configuration writes to a target
+// without matching every target against every rule.
+type targetSet struct {
+	named    map[config.SecretLocation]bool
+	patterns []config.MirrorConfig
+}
+
+func newTargetSet(configuration *config.Configuration) targetSet {
+	targets := targetSet{named: map[config.SecretLocation]bool{}}
+	for _, mirrorConfig := range configuration.Secrets {
+		if mirrorConfig.IsPattern() || mirrorConfig.FansOut() {
+			targets.patterns = append(targets.patterns, mirrorConfig)
+			continue
+		}
+		targets.named[mirrorConfig.To] = true
+	}
+	return targets
+}
+
+// contains determines if any rule writes to the target.
+func (s targetSet) contains(to config.SecretLocation) bool {
+	if s.named[to] {
+		return true
+	}
+	for _, mirrorConfig := range s.patterns {
+		if mirrorConfig.WritesTo(to) {
+			return true
+		}
+	}
+	return false
+}
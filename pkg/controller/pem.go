@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// pemKeys are the keys holding TLS materials that are normalized
+var pemKeys = []string{coreapi.TLSCertKey, coreapi.ServiceAccountRootCAKey}
+
+// normalizedData returns the data with the PEM content of TLS materials
+// normalized, if the rule asks for it. The data is not modified in place.
+func normalizedData(data map[string][]byte, mirrorConfig config.MirrorConfig) map[string][]byte {
+	if !mirrorConfig.NormalizePEM {
+		return data
+	}
+	normalized := map[string][]byte{}
+	for key, value := range data {
+		normalized[key] = value
+	}
+	for _, key := range pemKeys {
+		if value, present := data[key]; present {
+			normalized[key] = normalizePEM(value, key == coreapi.TLSCertKey)
+		}
+	}
+	return normalized
+}
+
+// normalizePEM re-encodes the PEM blocks in value, dropping any text
+// around them and normalizing line endings. Certificate chains are also
+// ordered leaf-first when requested, with every certificate followed by
+// its issuer. Values we cannot make sense of are returned untouched and
+// left for validation to reject.
+func normalizePEM(value []byte, orderChain bool) []byte {
+	var blocks []*pem.Block
+	for rest := value; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return value
+	}
+
+	if orderChain {
+		var certificates []*x509.Certificate
+		var certificateBlocks, otherBlocks []*pem.Block
+		for _, block := range blocks {
+			if block.Type != "CERTIFICATE" {
+				otherBlocks = append(otherBlocks, block)
+				continue
+			}
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return value
+			}
+			certificates = append(certificates, certificate)
+			certificateBlocks = append(certificateBlocks, block)
+		}
+		blocks = append(orderLeafFirst(certificates, certificateBlocks), otherBlocks...)
+	}
+
+	var normalized bytes.Buffer
+	for _, block := range blocks {
+		if err := pem.Encode(&normalized, block); err != nil {
+			return value
+		}
+	}
+	return normalized.Bytes()
+}
+
+// orderLeafFirst orders the certificate blocks starting from the first
+// certificate that issued none of the others, followed by its issuers.
+// Certificates that are not part of that chain keep their relative order
+// and follow it.
+func orderLeafFirst(certificates []*x509.Certificate, blocks []*pem.Block) []*pem.Block {
+	issuedOther := func(i int) bool {
+		for j, other := range certificates {
+			if i != j && bytes.Equal(other.RawIssuer, certificates[i].RawSubject) && !bytes.Equal(other.RawIssuer, other.RawSubject) {
+				return true
+			}
+		}
+		return false
+	}
+	leaf := -1
+	for i := range certificates {
+		if !issuedOther(i) {
+			leaf = i
+			break
+		}
+	}
+	if leaf == -1 {
+		return blocks
+	}
+
+	used := make([]bool, len(certificates))
+	var ordered []*pem.Block
+	for current := leaf; current != -1; {
+		used[current] = true
+		ordered = append(ordered, blocks[current])
+		next := -1
+		if !bytes.Equal(certificates[current].RawIssuer, certificates[current].RawSubject) {
+			for j, candidate := range certificates {
+				if !used[j] && bytes.Equal(candidate.RawSubject, certificates[current].RawIssuer) {
+					next = j
+					break
+				}
+			}
+		}
+		current = next
+	}
+	for i, block := range blocks {
+		if !used[i] {
+			ordered = append(ordered, block)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// pollPeriod is how often the controller checks for polled sources that are due.
+const pollPeriod = 10 * time.Second
+
+// isPolled determines if any of the rules mirroring from a source polls
+// it. Sources in stores outside of Kubernetes are always polled, unless
+// the External Secrets Operator fetches them.
+func isPolled(rules []config.MirrorConfig) bool {
+	for _, mirrorConfig := range rules {
+		if mirrorConfig.Output == config.OutputExternalSecret {
+			continue
+		}
+		if mirrorConfig.PollInterval != nil || mirrorConfig.From.Provider() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// polledSource is the last observed state of a polled source.
+type polledSource struct {
+	secret *coreapi.Secret
+	polled time.Time
+}
+
+// polledSources holds sources fetched by polling, as no informer holds them.
+type polledSources struct {
+	lock    sync.RWMutex
+	sources map[config.SecretLocation]polledSource
+}
+
+func (p *polledSources) get(location config.SecretLocation) (*coreapi.Secret, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if source, polled := p.sources[location]; polled && source.secret != nil {
+		return source.secret, nil
+	}
+	return nil, errors.NewNotFound(coreapi.Resource("secrets"), location.Name)
+}
+
+func (p *polledSources) due(location config.SecretLocation, interval time.Duration) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	source, polled := p.sources[location]
+	return !polled || time.Since(source.polled) >= interval
+}
+
+// store records the outcome of polling the source, which is nil if it does
+// not exist, returning true if the source changed since it was last polled.
+func (p *polledSources) store(location config.SecretLocation, secret *coreapi.Secret) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.sources == nil {
+		p.sources = map[config.SecretLocation]polledSource{}
+	}
+	previous, polled := p.sources[location]
+	p.sources[location] = polledSource{secret: secret, polled: time.Now()}
+	if !polled || previous.secret == nil || secret == nil {
+		return previous.secret != secret
+	}
+	return previous.secret.ResourceVersion != secret.ResourceVersion
+}
+
+// attempted records a failed poll, so that the source is not retried before it is due.
+func (p *polledSources) attempted(location config.SecretLocation) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.sources == nil {
+		p.sources = map[config.SecretLocation]polledSource{}
+	}
+	source := p.sources[location]
+	source.polled = time.Now()
+	p.sources[location] = source
+}
+
+// retain forgets every source not in the set of polled locations.
+func (p *polledSources) retain(locations map[config.SecretLocation]time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for location := range p.sources {
+		if _, polled := locations[location]; !polled {
+			delete(p.sources, location)
+		}
+	}
+}
+
+// poll fetches every polled source that is due and enqueues those that changed.
+func (c *SecretMirror) poll() {
+	intervals := map[config.SecretLocation]time.Duration{}
+	for _, mirrorConfig := range c.config().Secrets {
+		if !isPolled([]config.MirrorConfig{mirrorConfig}) {
+			continue
+		}
+		pollInterval := defaultProviderPollInterval
+		if mirrorConfig.PollInterval != nil {
+			pollInterval = mirrorConfig.PollInterval.Duration
+		}
+		// poll as often as the most demanding rule for the source asks
+		if interval, recorded := intervals[mirrorConfig.From]; !recorded || pollInterval < interval {
+			intervals[mirrorConfig.From] = pollInterval
+		}
+	}
+	c.polled.retain(intervals)
+
+	for location, interval := range intervals {
+		if !c.polled.due(location, interval) {
+			continue
+		}
+		logger := c.logger.WithField("source", location.String())
+		var secret *coreapi.Secret
+		var err error
+		if location.Provider() != "" {
+			secret, err = c.readProvider(location)
+		} else {
+			var client kubeclientset.Interface = c.client
+			if location.Cluster != "" {
+				remote, configured := c.remoteClients[location.Cluster]
+				if !configured {
+					logger.Warn("not polling secret because its cluster is not configured")
+					c.polled.attempted(location)
+					continue
+				}
+				client = remote
+			}
+			secret, err = client.CoreV1().Secrets(location.Namespace).Get(location.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				secret, err = nil, nil
+			}
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to poll secret")
+			c.polled.attempted(location)
+			continue
+		}
+		if c.polled.store(location, secret) {
+			logger.Debug("enqueueing polled secret")
+			c.enqueueKey(location.String())
+		}
+	}
+}
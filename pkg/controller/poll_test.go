@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestPolledSourcesStore(t *testing.T) {
+	location := config.SecretLocation{Namespace: "ns", Name: "name"}
+	sources := polledSources{}
+	if !sources.due(location, time.Hour) {
+		t.Error("expected a source that was never polled to be due")
+	}
+	if sources.store(location, nil) {
+		t.Error("expected a missing source not to count as a change")
+	}
+	if sources.due(location, time.Hour) {
+		t.Error("expected a source polled just now not to be due")
+	}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}}
+	if !sources.store(location, secret) {
+		t.Error("expected a new source to count as a change")
+	}
+	if sources.store(location, secret.DeepCopy()) {
+		t.Error("expected the same resource version not to count as a change")
+	}
+	secret.ResourceVersion = "2"
+	if !sources.store(location, secret) {
+		t.Error("expected a new resource version to count as a change")
+	}
+	if !sources.store(location, nil) {
+		t.Error("expected a deletion to count as a change")
+	}
+	sources.retain(map[config.SecretLocation]time.Duration{})
+	if !sources.due(location, time.Hour) {
+		t.Error("expected sources which are no longer polled to be forgotten")
+	}
+}
+
+func TestPoll(t *testing.T) {
+	client := testclient.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src", ResourceVersion: "1"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	// the informer is never started, as if the controller could not watch secrets
+	informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:         config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:           config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+			PollInterval: &metav1.Duration{Duration: time.Minute},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	defer c.queue.ShutDown()
+
+	c.poll()
+	if depth := c.queue.Len(); depth != 1 {
+		t.Fatalf("expected the polled source to be enqueued, got %d queued keys", depth)
+	}
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the target to be created from the polled source: %v", err)
+	}
+
+	client.ClearActions()
+	c.poll()
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected no requests before the source is due, got %v", actions)
+	}
+}
+
+type fakeProvider map[string]map[string][]byte
+
+func (p fakeProvider) Read(location config.SecretLocation) (map[string][]byte, string, error) {
+	return p[location.Vault.Path], "3", nil
+}
+
+func TestPollProvider(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Vault: config.VaultLocation{Path: "secret/ci/registry"}},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	provider := fakeProvider{"secret/ci/registry": {"key": []byte("value")}}
+	c := NewSecretMirror(informer, client, ca.Config, Options{Providers: map[string]Provider{config.ProviderVault: provider}})
+	defer c.queue.ShutDown()
+
+	c.poll()
+	if depth := c.queue.Len(); depth != 1 {
+		t.Fatalf("expected the source in Vault to be enqueued, got %d queued keys", depth)
+	}
+	key, _ := c.queue.Get()
+	if key != "vault://secret/ci/registry" {
+		t.Fatalf("expected the key of the source in Vault, got %v", key)
+	}
+	if err := c.reconcile(key.(string)); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be created from the source in Vault: %v", err)
+	}
+	if string(target.Data["key"]) != "value" {
+		t.Errorf("expected the data of the source in Vault, got %v", target.Data)
+	}
+	if version := target.Annotations[mirrorSourceVersionAnnotation]; version != "3" {
+		t.Errorf("expected the version of the source in Vault to be recorded, got %q", version)
+	}
+}
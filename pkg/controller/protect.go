@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"path"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// DefaultProtectedTargetPatterns match the names of secrets that the
+// cluster generates for service accounts. They are never written to,
+// whatever the configuration says.
+var DefaultProtectedTargetPatterns = []string{
+	"*-dockercfg-*",
+	"default-token-*",
+	"builder-token-*",
+	"deployer-token-*",
+}
+
+// protectedPattern returns the first protected pattern that
+// matches the name of the target, if any.
+func (c *SecretMirror) protectedPattern(to config.SecretLocation) (string, bool) {
+	return matchProtected(c.protectedTargets, to)
+}
+
+// matchProtected returns the first of the patterns
+// that matches the name of the target, if any.
+func matchProtected(patterns []string, to config.SecretLocation) (string, bool) {
+	for _, pattern := range patterns {
+		// patterns are validated when the controller is configured
+		if matches, _ := path.Match(pattern, to.Name); matches {
+			return pattern, true
+		}
+	}
+	return "", false
+}
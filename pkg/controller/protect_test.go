@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReconcileSkipsProtectedTargets(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		target      string
+		patterns    []string
+		expectedErr bool
+	}{
+		{
+			name:   "unprotected targets are written",
+			target: "dst",
+		},
+		{
+			name:        "generated pull secrets are protected by default",
+			target:      "builder-dockercfg-x7k2p",
+			expectedErr: true,
+		},
+		{
+			name:        "generated tokens are protected by default",
+			target:      "default-token-x7k2p",
+			expectedErr: true,
+		},
+		{
+			name:        "configured patterns are protected in addition to the defaults",
+			target:      "cluster-admin-kubeconfig",
+			patterns:    []string{"*-kubeconfig"},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+				Data:       map[string][]byte{"key": []byte("value")},
+			})
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+				{
+					From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+					To:   config.SecretLocation{Namespace: "test-ns", Name: testCase.target},
+				},
+			}})
+			c := NewSecretMirror(informer, client, ca.Config, Options{ProtectedTargetPatterns: testCase.patterns})
+			err := c.reconcile("test-ns/src")
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			_, getErr := client.CoreV1().Secrets("test-ns").Get(testCase.target, metav1.GetOptions{})
+			if written := getErr == nil; written == testCase.expectedErr {
+				t.Errorf("%s: expected the target to be written to be %t, got %t", testCase.name, !testCase.expectedErr, written)
+			}
+		})
+	}
+}
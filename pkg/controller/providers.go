@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// defaultProviderPollInterval is how often sources in stores outside of
+// Kubernetes are polled if their rules do not set a poll interval.
+const defaultProviderPollInterval = 5 * time.Minute
+
+// Provider reads secrets from a store outside of Kubernetes, which
+// cannot be watched, so its secrets are polled.
+type Provider interface {
+	// Read returns the data of the secret at the location and the
+	// version of the data, or no data if the secret does not exist.
+	Read(location config.SecretLocation) (map[string][]byte, string, error)
+}
+
+// readProvider reads the source from its provider, returning it as a
+// secret that no cluster holds, or nil if it does not exist. The version
+// of the data stands in for the resourceVersion, so that new versions
+// are picked up and recorded on the targets.
+func (c *SecretMirror) readProvider(location config.SecretLocation) (*coreapi.Secret, error) {
+	provider, configured := c.providers[location.Provider()]
+	if !configured {
+		return nil, fmt.Errorf("%s is not configured", location.Provider())
+	}
+	data, version, err := provider.Read(location)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return &coreapi.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: location.String(), ResourceVersion: version},
+		Type:       coreapi.SecretTypeOpaque,
+		Data:       data,
+	}, nil
+}
+
+// Writer writes secrets to a store outside of Kubernetes, recording in
+// the store whether the controller wrote them.
+type Writer interface {
+	// Target returns the data of the secret at the location, its
+	// version and whether the controller wrote it, or no data if the
+	// secret does not exist.
+	Target(location config.SecretLocation) (map[string][]byte, string, bool, error)
+	// Write replaces the data of the secret at the location, recording
+	// the controller and the source as its writer, and returns the
+	// version written.
+	Write(location config.SecretLocation, data map[string][]byte, source string) (string, error)
+}
+
+// writer returns the writer of the store holding the target.
+func (c *SecretMirror) writer(to config.SecretLocation) (Writer, error) {
+	writer, writes := c.providers[to.Provider()].(Writer)
+	if !writes {
+		return nil, fmt.Errorf("%s is not configured to be written to", to.Provider())
+	}
+	return writer, nil
+}
+
+// providerTarget reads the target from its store, returning it as a
+// secret that no cluster holds, labelled as managed if the controller
+// wrote it, so that it can be compared to its source like any target.
+func (c *SecretMirror) providerTarget(to config.SecretLocation) (*coreapi.Secret, error) {
+	writer, err := c.writer(to)
+	if err != nil {
+		return nil, err
+	}
+	data, version, managed, err := writer.Target(to)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errors.NewNotFound(coreapi.Resource("secrets"), to.String())
+	}
+	target := &coreapi.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: to.String(), ResourceVersion: version},
+		Type:       coreapi.SecretTypeOpaque,
+		Data:       data,
+	}
+	if managed {
+		target.Labels = map[string]string{managedByLabel: managedByValue}
+	}
+	return target, nil
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// PrunableTarget is a target recorded in the inventory that no rule
+// in the configuration writes to anymore and that pruning deletes.
+type PrunableTarget struct {
+	// Target is the location of the target, as namespace/name
+	Target string `json:"target"`
+	// Entry is what the inventory recorded about the target
+	Entry InventoryEntry `json:"entry"`
+}
+
+// PrunableTargets lists the targets that pruning would delete under the
+// configuration: targets recorded in the inventory in the given namespace
+// which still exist, are not written to by any rule and which the
+// controller may delete, given the protected patterns in addition to
+// DefaultProtectedTargetPatterns. Nothing is deleted.
+func PrunableTargets(client kubeclientset.Interface, inventoryNamespace string, configuration *config.Configuration, protectedPatterns []string) ([]PrunableTarget, error) {
+	inventory, err := client.CoreV1().ConfigMaps(inventoryNamespace).Get(InventoryConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the inventory: %v", err)
+	}
+
+	keys := make([]string, 0, len(inventory.Data))
+	for key := range inventory.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var targets []*coreapi.Secret
+	for _, key := range keys {
+		parts := strings.SplitN(key, ".", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed inventory key %q", key)
+		}
+		target := config.SecretLocation{Namespace: parts[0], Name: parts[1]}
+		secret, err := client.CoreV1().Secrets(target.Namespace).Get(target.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get target %s: %v", target.String(), err)
+		}
+		targets = append(targets, secret)
+	}
+
+	patterns := append(append([]string{}, DefaultProtectedTargetPatterns...), protectedPatterns...)
+	var prunable []PrunableTarget
+	for _, secret := range prunableOrphans(targets, configuration, patterns) {
+		target := config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}
+		key := InventoryKey(target)
+		var entry InventoryEntry
+		if err := json.Unmarshal([]byte(inventory.Data[key]), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse inventory entry for %s: %v", key, err)
+		}
+		prunable = append(prunable, PrunableTarget{Target: target.String(), Entry: entry})
+	}
+	return prunable, nil
+}
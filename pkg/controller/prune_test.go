@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestPrunableTargets(t *testing.T) {
+	client := testclient.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: InventoryConfigMap},
+			Data: map[string]string{
+				"test-ns.configured": `{"rule":"(test-ns/src -> test-ns/configured)","configHash":"abc"}`,
+				"test-ns.orphaned":   `{"rule":"(test-ns/old -> test-ns/orphaned)","configHash":"abc"}`,
+				"test-ns.deleted":    `{"rule":"(test-ns/old -> test-ns/deleted)","configHash":"abc"}`,
+			},
+		},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "configured"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "orphaned", Annotations: map[string]string{lastAppliedHashAnnotation: "abc"}}},
+	)
+	configuration := &config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "configured"},
+		},
+	}}
+
+	prunable, err := PrunableTargets(client, "ci", configuration, nil)
+	if err != nil {
+		t.Fatalf("failed to list prunable targets: %v", err)
+	}
+	expected := []PrunableTarget{{Target: "test-ns/orphaned", Entry: InventoryEntry{Rule: "(test-ns/old -> test-ns/orphaned)", ConfigHash: "abc"}}}
+	if !reflect.DeepEqual(prunable, expected) {
+		t.Errorf("expected prunable targets %+v, got %+v", expected, prunable)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("expected the dry run to only read, got %v", action)
+		}
+	}
+
+	if prunable, err := PrunableTargets(client, "other", configuration, nil); err != nil || len(prunable) != 0 {
+		t.Errorf("expected nothing to be prunable without an inventory, got %v and %v", prunable, err)
+	}
+}
+
+func TestPrunableTargetsAgreesWithPruning(t *testing.T) {
+	managed := map[string]string{lastAppliedHashAnnotation: "abc"}
+	targets := []*v1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "kept", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "orphaned", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "unmanaged"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "held", Annotations: map[string]string{lastAppliedHashAnnotation: "abc", doNotOverwriteAnnotation: "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "protected-target", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "default-token-abc", Annotations: managed}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "denied", Name: "orphaned", Annotations: managed}},
+	}
+	inventory := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: InventoryConfigMap}, Data: map[string]string{}}
+	for _, target := range targets {
+		inventory.Data[InventoryKey(config.SecretLocation{Namespace: target.Namespace, Name: target.Name})] = `{"rule":"(test-ns/src -> test-ns/old)"}`
+	}
+	client := testclient.NewSimpleClientset(inventory)
+	informer := syncedInformer(t, client, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"}})
+	for _, target := range targets {
+		if _, err := client.CoreV1().Secrets(target.Namespace).Create(target); err != nil {
+			t.Fatal(err)
+		}
+		if err := informer.Informer().GetIndexer().Add(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configuration := &config.Configuration{
+		Secrets: []config.MirrorConfig{{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "kept"},
+		}},
+		Policy: &config.Policy{DeniedNamespaces: []string{"denied"}},
+	}
+	protected := []string{"protected-*"}
+
+	prunable, err := PrunableTargets(client, "ci", configuration, protected)
+	if err != nil {
+		t.Fatalf("failed to list prunable targets: %v", err)
+	}
+	reported := map[string]bool{}
+	for _, target := range prunable {
+		reported[target.Target] = true
+	}
+
+	ca := &config.Agent{}
+	ca.Set(configuration)
+	c := NewSecretMirror(informer, client, ca.Config, Options{PruneOrphans: true, InventoryNamespace: "ci", ProtectedTargetPatterns: protected})
+	c.pruneOrphanedTargets()
+	pruned := map[string]bool{}
+	for _, target := range targets {
+		to := config.SecretLocation{Namespace: target.Namespace, Name: target.Name}
+		if _, err := client.CoreV1().Secrets(to.Namespace).Get(to.Name, metav1.GetOptions{}); err != nil {
+			pruned[to.String()] = true
+		}
+	}
+
+	if expected := map[string]bool{"test-ns/orphaned": true}; !reflect.DeepEqual(pruned, expected) {
+		t.Errorf("expected pruning to delete %v, got %v", expected, pruned)
+	}
+	if !reflect.DeepEqual(reported, pruned) {
+		t.Errorf("expected the dry run to report what pruning deletes, %v, got %v", pruned, reported)
+	}
+}
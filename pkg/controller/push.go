@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// pushSecret mirrors the source into a target in a store outside of
+// Kubernetes. The store holds nothing but the data of the target, so
+// unlike mirrorSecret, the data is written as it is and the store
+// records that the controller wrote it.
+func (c *SecretMirror) pushSecret(source *coreapi.Secret, mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	to := mirrorConfig.To
+	logger = logger.WithField("target", to.String())
+	logger.Debug("processing push request")
+
+	if len(source.Data) == 0 {
+		logger.Info("not updating target secret as source has no data")
+		return nil
+	}
+	sourceData, _, err := c.mirroredData(source.Data, mirrorConfig)
+	if err != nil {
+		return err
+	}
+	if len(sourceData) == 0 {
+		logger.Info("not updating target secret as the rule ignores all of the source data")
+		return nil
+	}
+	if err := validateData(sourceData, mirrorConfig); err != nil {
+		return fmt.Errorf("not updating target secret: %v", err)
+	}
+	if err := c.writeFiles(sourceData, mirrorConfig, logger); err != nil {
+		return err
+	}
+	if mirrorConfig.Files != nil && mirrorConfig.Files.SkipTarget {
+		logger.Debug("not updating target secret as the rule only writes files")
+		return nil
+	}
+	if other, halted, _ := c.collisions.record(mirrorConfig, sourceData); halted {
+		return fmt.Errorf("not updating target secret as the rule collides with rule %s, both are halted until the configuration is reloaded", other)
+	}
+
+	hash := dataHash(sourceData)
+	applied := fingerprint(mirrorConfig, hash)
+	if c.applied.matches(to.String(), applied) {
+		logger.Debug("not updating target secret as neither the source nor the rule changed since it was last applied")
+		return nil
+	}
+	writer, err := c.writer(to)
+	if err != nil {
+		return err
+	}
+	reason := reasonCreated
+	target, err := c.providerTarget(to)
+	switch {
+	case err == nil:
+		if !mirrorConfig.AdoptExisting && !managedTarget(target) {
+			return fmt.Errorf("refusing to overwrite target secret as the controller did not write it, set adoptExisting: true to take it over")
+		}
+		if reflect.DeepEqual(target.Data, sourceData) {
+			logger.Debug("not updating target secret as it already matches the source")
+			c.approvals.settle(mirrorConfig.String())
+			noopSyncs.WithLabelValues(noopReasonUnchanged).Inc()
+			c.applied.record(to.String(), applied, target.ResourceVersion)
+			return nil
+		}
+		reason = reasonUpdated
+	case !errors.IsNotFound(err):
+		return err
+	}
+	if awaiting, err := c.awaitingApproval(source, mirrorConfig, applied, logger); err != nil || awaiting {
+		return err
+	}
+
+	logger.Info("writing target secret")
+	version, err := writer.Write(to, sourceData, mirrorConfig.From.String())
+	if err != nil {
+		return err
+	}
+	written := &coreapi.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:            to.String(),
+		ResourceVersion: version,
+		Annotations:     map[string]string{lastAppliedHashAnnotation: hash},
+	}}
+	c.approvals.settle(mirrorConfig.String())
+	c.recordWrite(source, written, mirrorConfig, reason, hash)
+	c.annotateSource(source, mirrorConfig, hash, logger)
+	c.applied.record(to.String(), applied, version)
+	return nil
+}
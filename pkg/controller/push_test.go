@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// fakeWriter holds secrets written to Vault
+type fakeWriter struct {
+	data    map[string][]byte
+	managed bool
+	writes  int
+}
+
+func (w *fakeWriter) Read(location config.SecretLocation) (map[string][]byte, string, error) {
+	return w.data, strconv.Itoa(w.writes), nil
+}
+
+func (w *fakeWriter) Target(location config.SecretLocation) (map[string][]byte, string, bool, error) {
+	return w.data, strconv.Itoa(w.writes), w.managed, nil
+}
+
+func (w *fakeWriter) Write(location config.SecretLocation, data map[string][]byte, source string) (string, error) {
+	w.data, w.managed = data, true
+	w.writes++
+	return strconv.Itoa(w.writes), nil
+}
+
+func TestPushSecret(t *testing.T) {
+	to := config.SecretLocation{Vault: config.VaultLocation{Path: "secret/ci/published"}}
+	var testCases = []struct {
+		name           string
+		existing       *fakeWriter
+		adoptExisting  bool
+		expectedWrites int
+		expectedErr    string
+	}{
+		{
+			name:           "missing target is written",
+			existing:       &fakeWriter{},
+			expectedWrites: 1,
+		},
+		{
+			name:           "managed target is updated",
+			existing:       &fakeWriter{data: map[string][]byte{"key": []byte("old")}, managed: true},
+			expectedWrites: 1,
+		},
+		{
+			name:     "matching target is left alone",
+			existing: &fakeWriter{data: map[string][]byte{"key": []byte("value")}, managed: true},
+		},
+		{
+			name:        "unmanaged target is not overwritten",
+			existing:    &fakeWriter{data: map[string][]byte{"key": []byte("other")}},
+			expectedErr: "adoptExisting",
+		},
+		{
+			name:           "unmanaged target is adopted",
+			existing:       &fakeWriter{data: map[string][]byte{"key": []byte("other")}},
+			adoptExisting:  true,
+			expectedWrites: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "token"},
+				Data:       map[string][]byte{"key": []byte("value")},
+			})
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+				{From: config.SecretLocation{Namespace: "ci", Name: "token"}, To: to, AdoptExisting: testCase.adoptExisting},
+			}})
+			writer := testCase.existing
+			c := NewSecretMirror(informer, client, ca.Config, Options{Providers: map[string]Provider{config.ProviderVault: writer}})
+			defer c.queue.ShutDown()
+
+			client.ClearActions()
+			err := c.reconcile("ci/token")
+			if testCase.expectedErr == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Fatalf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+			if writer.writes != testCase.expectedWrites {
+				t.Errorf("expected %d writes, got %d", testCase.expectedWrites, writer.writes)
+			}
+			if err == nil && !reflect.DeepEqual(writer.data, map[string][]byte{"key": []byte("value")}) {
+				t.Errorf("expected the target to hold the source data, got %v", writer.data)
+			}
+			for _, action := range client.Actions() {
+				if action.GetResource().Resource == "secrets" && action.GetVerb() != "get" {
+					t.Errorf("expected no secret to be written in the cluster, got %v", action)
+				}
+			}
+
+			if err := c.reconcile("ci/token"); err == nil && writer.writes != testCase.expectedWrites {
+				t.Errorf("expected the target not to be written again, got %d writes", writer.writes)
+			}
+		})
+	}
+}
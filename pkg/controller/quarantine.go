@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// QuarantinedRule describes a mirroring rule that exhausted its failure budget.
+type QuarantinedRule struct {
+	Rule  string    `json:"rule"`
+	Since time.Time `json:"since"`
+}
+
+// quarantine tracks consecutive mirroring failures per rule and stops
+// retrying rules that have exhausted their failure budget. Quarantined
+// rules are released when the configuration is reloaded or when they
+// are resumed by hand.
+type quarantine struct {
+	lock sync.Mutex
+
+	// threshold is the number of consecutive failures after
+	// which a rule is quarantined; zero disables quarantine
+	threshold  int
+	failures   map[string]int
+	since      map[string]time.Time
+	generation *config.Configuration
+}
+
+func newQuarantine(threshold int) *quarantine {
+	return &quarantine{
+		threshold: threshold,
+		failures:  map[string]int{},
+		since:     map[string]time.Time{},
+	}
+}
+
+// sync forgets all failure state if the configuration has been reloaded
+// since the last time we looked at it.
+func (q *quarantine) sync(generation *config.Configuration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.generation == generation {
+		return
+	}
+	q.generation = generation
+	q.failures = map[string]int{}
+	q.since = map[string]time.Time{}
+	quarantinedRules.reset()
+}
+
+func (q *quarantine) isQuarantined(rule string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, quarantined := q.since[rule]
+	return quarantined
+}
+
+func (q *quarantine) recordSuccess(rule string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.failures, rule)
+}
+
+// recordFailure returns true if this failure exhausted the budget
+// for the rule and it has been quarantined as a result.
+func (q *quarantine) recordFailure(rule string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.failures[rule]++
+	if q.threshold == 0 || q.failures[rule] < q.threshold {
+		return false
+	}
+	if _, quarantined := q.since[rule]; quarantined {
+		return false
+	}
+	q.since[rule] = time.Now()
+	quarantinedRules.set(rule, 1)
+	return true
+}
+
+// resume releases a rule from quarantine, returning false if
+// the rule was not quarantined.
+func (q *quarantine) resume(rule string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, quarantined := q.since[rule]; !quarantined {
+		return false
+	}
+	delete(q.since, rule)
+	delete(q.failures, rule)
+	quarantinedRules.delete(rule)
+	return true
+}
+
+// status returns the number of consecutive failures for the rule
+// and the time it was quarantined, if it was.
+func (q *quarantine) status(rule string) (int, *time.Time) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if since, quarantined := q.since[rule]; quarantined {
+		return q.failures[rule], &since
+	}
+	return q.failures[rule], nil
+}
+
+func (q *quarantine) list() []QuarantinedRule {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	rules := []QuarantinedRule{}
+	for rule, since := range q.since {
+		rules = append(rules, QuarantinedRule{Rule: rule, Since: since})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Rule < rules[j].Rule })
+	return rules
+}
+
+// QuarantineHandler lists quarantined rules on GET and resumes
+// the rule named by the `rule` parameter on POST.
+func (c *SecretMirror) QuarantineHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(c.quarantine.list()); err != nil {
+				c.logger.WithError(err).Error("failed to write quarantined rules")
+			}
+		case http.MethodPost:
+			rule := r.FormValue("rule")
+			if !c.quarantine.resume(rule) {
+				http.Error(w, "rule is not quarantined", http.StatusNotFound)
+				return
+			}
+			c.logger.WithField("rule", rule).Info("resumed quarantined rule")
+			c.enqueueRule(rule)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
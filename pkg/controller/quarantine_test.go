@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestQuarantine(t *testing.T) {
+	const rule = "(from-ns/from -> to-ns/to)"
+	q := newQuarantine(3)
+	q.sync(&config.Configuration{})
+
+	q.recordFailure(rule)
+	q.recordFailure(rule)
+	q.recordSuccess(rule)
+	if q.recordFailure(rule) || q.isQuarantined(rule) {
+		t.Fatal("expected a success to reset the failure budget")
+	}
+	q.recordFailure(rule)
+	if !q.recordFailure(rule) {
+		t.Fatal("expected the rule to be quarantined after exhausting its budget")
+	}
+	if q.recordFailure(rule) {
+		t.Error("expected an already quarantined rule not to be reported again")
+	}
+	if rules := q.list(); len(rules) != 1 || rules[0].Rule != rule {
+		t.Errorf("expected only %s to be listed, got %v", rule, rules)
+	}
+
+	if !q.resume(rule) || q.isQuarantined(rule) {
+		t.Error("expected the rule to be resumed")
+	}
+	if q.resume(rule) {
+		t.Error("expected resuming a healthy rule to fail")
+	}
+
+	for i := 0; i < 3; i++ {
+		q.recordFailure(rule)
+	}
+	q.sync(&config.Configuration{})
+	if q.isQuarantined(rule) {
+		t.Error("expected a configuration reload to release the rule")
+	}
+
+	disabled := newQuarantine(0)
+	for i := 0; i < 100; i++ {
+		if disabled.recordFailure(rule) {
+			t.Fatal("expected a zero threshold to disable quarantine")
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package controller
+
+import "sync"
+
+// queuedKeys tracks the keys waiting in the work queue, which merges
+// duplicate keys, so that keys that are already queued are not counted
+// against its limit. Keys requeued after failures only reach the queue
+// once their delay passed and are not tracked.
+type queuedKeys struct {
+	lock sync.Mutex
+	keys map[string]bool
+}
+
+// add records that the key was added to the queue.
+func (q *queuedKeys) add(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.keys == nil {
+		q.keys = map[string]bool{}
+	}
+	q.keys[key] = true
+}
+
+// remove records that a worker took the key from the queue.
+func (q *queuedKeys) remove(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.keys, key)
+}
+
+// has determines if the key waits in the queue.
+func (q *queuedKeys) has(key string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.keys[key]
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// readiness tracks the initial reconciliation of every configured source,
+// which starts once the caches are synced. Sources count as reconciled
+// after their first attempt, whether it succeeded or not, so that a
+// failing rule does not hold the controller back from becoming ready.
+// Sources shed while the work queue is saturated are still awaited and
+// enqueued again once it has room.
+type readiness struct {
+	lock    sync.Mutex
+	started bool
+	pending map[string]bool
+	shed    map[string]bool
+}
+
+// start records the keys that must be reconciled before we are ready.
+func (r *readiness) start(keys []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.started = true
+	r.pending = map[string]bool{}
+	r.shed = map[string]bool{}
+	for _, key := range keys {
+		r.pending[key] = true
+	}
+}
+
+// done records that the key was reconciled or will not be.
+func (r *readiness) done(key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.pending, key)
+	delete(r.shed, key)
+}
+
+// shedKey records that the key was shed, returning whether it still
+// awaits its initial reconciliation and must be enqueued again.
+func (r *readiness) shedKey(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.pending[key] {
+		return false
+	}
+	r.shed[key] = true
+	return true
+}
+
+// takeShed returns at most limit of the shed keys that still await
+// their initial reconciliation, forgetting that they were shed.
+func (r *readiness) takeShed(limit int) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var keys []string
+	for key := range r.shed {
+		if len(keys) >= limit {
+			break
+		}
+		keys = append(keys, key)
+		delete(r.shed, key)
+	}
+	return keys
+}
+
+// ready returns why we are not ready yet, or an empty string.
+func (r *readiness) ready() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.started {
+		return "caches are not synced"
+	}
+	if len(r.pending) > 0 {
+		return fmt.Sprintf("%d sources await their initial reconciliation", len(r.pending))
+	}
+	return ""
+}
+
+// HealthzHandler reports that the controller is alive.
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// ReadyzHandler reports whether the caches are synced and every configured
+// source was reconciled once, failing with 503 Service Unavailable until then.
+func (c *SecretMirror) ReadyzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if reason := c.ready.ready(); reason != "" {
+			http.Error(w, reason, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReadyzHandler(t *testing.T) {
+	configuration := &config.Configuration{
+		Secrets: []config.MirrorConfig{
+			{
+				From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+				To:   config.SecretLocation{Namespace: "test-ns", Name: "dst-1"},
+			},
+			{
+				From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+				To:   config.SecretLocation{Namespace: "test-ns", Name: "dst-2"},
+			},
+			{
+				From: config.SecretLocation{Namespace: "other-ns", Name: "src"},
+				To:   config.SecretLocation{Namespace: "test-ns", Name: "dst-3"},
+			},
+		},
+	}
+	client := testclient.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(configuration)
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	defer c.queue.ShutDown()
+
+	probe := func() int {
+		recorder := httptest.NewRecorder()
+		c.ReadyzHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return recorder.Code
+	}
+
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d before the caches are synced, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	keys := c.sourceKeys()
+	if len(keys) != 2 {
+		t.Fatalf("expected every source once, got %v", keys)
+	}
+	c.ready.start(keys)
+	c.ready.done(keys[0])
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d while a source awaits its initial reconciliation, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	c.ready.done(keys[1])
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("expected status %d after every source was reconciled, got %d", http.StatusOK, code)
+	}
+}
+
+func TestRunWaitsForTheConfiguration(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}}})
+	var synced int32
+	c := NewSecretMirror(informer, client, ca.Config, Options{ConfigSynced: func() bool { return atomic.LoadInt32(&synced) == 1 }})
+
+	client.ClearActions()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx, 1)
+	time.Sleep(200 * time.Millisecond)
+	if reason := c.ready.ready(); reason == "" {
+		t.Error("expected not to be ready before the configuration is synced")
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "list" && action.GetVerb() != "watch" {
+			t.Errorf("expected nothing to be written before the configuration is synced, got %s", action.GetVerb())
+		}
+	}
+
+	atomic.StoreInt32(&synced, 1)
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+		return err == nil, nil
+	}); err != nil {
+		t.Errorf("expected the source to be reconciled once the configuration is synced: %v", err)
+	}
+}
+
+func TestHealthzHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	HealthzHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
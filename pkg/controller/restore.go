@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"fmt"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/backup"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// Outcomes of restoring a target from a backup archive
+const (
+	// RestoreCreated means the target was created from the archive
+	RestoreCreated = "created"
+	// RestoreSkipped means the target was left alone
+	RestoreSkipped = "skipped"
+)
+
+// RestoredTarget reports what restoring a target from an archive did.
+type RestoredTarget struct {
+	// Target is the location of the target, as namespace/name
+	Target string `json:"target"`
+	// Outcome is one of RestoreCreated or RestoreSkipped
+	Outcome string `json:"outcome"`
+	// Reason explains why the target was skipped
+	Reason string `json:"reason,omitempty"`
+}
+
+// RestoreTargets creates the targets held by the archive that are
+// missing from the cluster. Only targets that a rule in the configuration
+// still writes to are restored, and the same checks as for mirroring
+// apply: protected targets, targets in namespaces the policy does not
+// allow, targets of rules whose changes require an approval and rules that
+// only write files are skipped. Targets that
+// exist are left for the controller to reconcile from their source.
+// Nothing is written with dryRun.
+func RestoreTargets(client kubeclientset.Interface, configuration *config.Configuration, archive backup.Archive, protectedPatterns []string, dryRun bool) ([]RestoredTarget, error) {
+	rules := map[config.SecretLocation]config.MirrorConfig{}
+	var patternRules []config.MirrorConfig
+	for _, mirrorConfig := range configuration.Secrets {
+		if mirrorConfig.IsPattern() || mirrorConfig.FansOut() {
+			patternRules = append(patternRules, mirrorConfig)
+			continue
+		}
+		rules[mirrorConfig.To] = configuration.Resolve(mirrorConfig)
+	}
+	patterns := append(append([]string{}, DefaultProtectedTargetPatterns...), protectedPatterns...)
+
+	restored := []RestoredTarget{}
+	for _, target := range archive.Targets {
+		to := config.SecretLocation{Namespace: target.Namespace, Name: target.Name}
+		skip := func(reason string) {
+			restored = append(restored, RestoredTarget{Target: to.String(), Outcome: RestoreSkipped, Reason: reason})
+		}
+		mirrorConfig, configured := rules[to]
+		for _, patternRule := range patternRules {
+			if configured {
+				break
+			}
+			if patternRule.FansOut() {
+				if !patternRule.WritesTo(to) {
+					continue
+				}
+				namespace, err := client.CoreV1().Namespaces().Get(to.Namespace, metav1.GetOptions{})
+				if err != nil {
+					if errors.IsNotFound(err) {
+						continue
+					}
+					return nil, fmt.Errorf("failed to get the namespace of target %s: %v", to.String(), err)
+				}
+				if instance, matches := patternRule.InstantiateTarget(namespace.Name, namespace.Labels); matches {
+					mirrorConfig, configured = configuration.Resolve(instance), true
+				}
+				continue
+			}
+			// targets of rules selecting their sources by pattern are named after them
+			source := config.SecretLocation{Cluster: patternRule.From.Cluster, Namespace: patternRule.From.Namespace, Name: to.Name}
+			if instance, matches := patternRule.Instantiate(source); matches && instance.To.Equals(to) {
+				mirrorConfig, configured = configuration.Resolve(instance), true
+			}
+		}
+		switch {
+		case !configured:
+			skip("no rule writes to the target")
+			continue
+		case mirrorConfig.Files != nil && mirrorConfig.Files.SkipTarget:
+			skip("the rule only writes files")
+			continue
+		case mirrorConfig.Output == config.OutputSealedSecret:
+			skip("the rule writes a SealedSecret")
+			continue
+		case mirrorConfig.Output == config.OutputExternalSecret:
+			skip("the External Secrets Operator writes the target")
+			continue
+		case mirrorConfig.RequireApproval:
+			skip("changes to the target require an approval")
+			continue
+		}
+		if pattern, protected := matchProtected(patterns, to); protected {
+			skip(fmt.Sprintf("the name of the target matches the protected pattern %q", pattern))
+			continue
+		}
+		if !configuration.Policy.AllowsNamespace(to.Namespace) {
+			skip(fmt.Sprintf("the policy does not allow namespace %s", to.Namespace))
+			continue
+		}
+
+		_, err := client.CoreV1().Secrets(to.Namespace).Get(to.Name, metav1.GetOptions{})
+		if err == nil {
+			skip("the target exists")
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return restored, fmt.Errorf("failed to get target %s: %v", to.String(), err)
+		}
+		if !dryRun {
+			if _, err := client.CoreV1().Secrets(to.Namespace).Create(&coreapi.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   to.Namespace,
+					Name:        to.Name,
+					Labels:      target.Labels,
+					Annotations: target.Annotations,
+				},
+				Type: coreapi.SecretType(target.Type),
+				Data: target.Data,
+			}); err != nil {
+				return restored, fmt.Errorf("failed to create target %s: %v", to.String(), err)
+			}
+		}
+		restored = append(restored, RestoredTarget{Target: to.String(), Outcome: RestoreCreated})
+	}
+	return restored, nil
+}
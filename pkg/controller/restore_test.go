@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/backup"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRestoreTargets(t *testing.T) {
+	rule := func(namespace, name string) config.MirrorConfig {
+		return config.MirrorConfig{
+			From: config.SecretLocation{Namespace: "ci", Name: "src"},
+			To:   config.SecretLocation{Namespace: namespace, Name: name},
+		}
+	}
+	fanOut := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "ci", Name: "src"},
+		To:   config.SecretLocation{NamespaceSelector: "team-secrets=true", Name: "shared"},
+	}
+	configuration := &config.Configuration{
+		Secrets:            []config.MirrorConfig{rule("team", "missing"), rule("team", "existing"), rule("team", "pinned-dst"), rule("prod", "dst"), fanOut},
+		ApprovalNamespaces: []string{"prod"},
+		Policy:             &config.Policy{DeniedNamespaces: []string{"kube-*"}},
+	}
+	target := func(namespace, name string) backup.Target {
+		return backup.Target{Namespace: namespace, Name: name, Data: map[string][]byte{"token": []byte("value")}}
+	}
+	archive := backup.Archive{Targets: []backup.Target{
+		target("team", "missing"),
+		target("team", "existing"),
+		target("team", "pinned-dst"),
+		target("prod", "dst"),
+		target("team", "unconfigured"),
+		target("team", "shared"),
+		target("kube-system", "shared"),
+	}}
+	namespace := func(name string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team-secrets": "true"}}}
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		client := testclient.NewSimpleClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "existing"}}, namespace("team"), namespace("kube-system"))
+		restored, err := RestoreTargets(client, configuration, archive, []string{"pinned-*"}, dryRun)
+		if err != nil {
+			t.Fatalf("failed to restore targets: %v", err)
+		}
+		expected := []RestoredTarget{
+			{Target: "team/missing", Outcome: RestoreCreated},
+			{Target: "team/existing", Outcome: RestoreSkipped, Reason: "the target exists"},
+			{Target: "team/pinned-dst", Outcome: RestoreSkipped, Reason: `the name of the target matches the protected pattern "pinned-*"`},
+			{Target: "prod/dst", Outcome: RestoreSkipped, Reason: "changes to the target require an approval"},
+			{Target: "team/unconfigured", Outcome: RestoreSkipped, Reason: "no rule writes to the target"},
+			{Target: "team/shared", Outcome: RestoreCreated},
+			{Target: "kube-system/shared", Outcome: RestoreSkipped, Reason: "the policy does not allow namespace kube-system"},
+		}
+		if !reflect.DeepEqual(restored, expected) {
+			t.Errorf("dry run %t: expected restored targets\n%v\ngot\n%v", dryRun, expected, restored)
+		}
+
+		created, err := client.CoreV1().Secrets("team").Get("missing", metav1.GetOptions{})
+		if dryRun {
+			if err == nil {
+				t.Error("expected nothing to be created in a dry run")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected the missing target to be created: %v", err)
+		}
+		if string(created.Data["token"]) != "value" {
+			t.Errorf("expected the target to be restored with its data, got %v", created.Data)
+		}
+	}
+}
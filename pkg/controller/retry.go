@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// RetryPolicy determines how keys that fail to be reconciled are retried:
+// first after BaseDelay, then after twice as long with every consecutive
+// failure up to MaxDelay, until they failed MaxRetries times and are
+// dropped until they change again. Fields that are zero take their value
+// from DefaultRetryPolicy.
+type RetryPolicy struct {
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	MaxRetries int
+}
+
+// DefaultRetryPolicy retries keys after 5ms, 10ms, 20ms, 40ms, 80ms, 160ms,
+// 320ms, 640ms, 1.3s, 2.6s, 5.1s, 10.2s, 20.4s, 41s and 82s.
+var DefaultRetryPolicy = RetryPolicy{BaseDelay: 5 * time.Millisecond, MaxDelay: 1000 * time.Second, MaxRetries: 15}
+
+// withDefaults fills the fields that are zero from DefaultRetryPolicy.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.BaseDelay == 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if p.MaxRetries == 0 {
+		p.MaxRetries = DefaultRetryPolicy.MaxRetries
+	}
+	return p
+}
+
+// queue returns a named work queue delaying retries by the policy. Like
+// the default rate limiter of controllers, retries of all keys together
+// are also limited to 10 per second with bursts of 100.
+func (p RetryPolicy) queue(name string) workqueue.RateLimitingInterface {
+	return workqueue.NewNamedRateLimitingQueue(workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(p.BaseDelay, p.MaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	), name)
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRetryPolicyWithDefaults(t *testing.T) {
+	policy := RetryPolicy{MaxDelay: time.Minute}.withDefaults()
+	expected := RetryPolicy{BaseDelay: DefaultRetryPolicy.BaseDelay, MaxDelay: time.Minute, MaxRetries: DefaultRetryPolicy.MaxRetries}
+	if policy != expected {
+		t.Errorf("expected zero fields to take their default, got %+v", policy)
+	}
+}
+
+func TestHandleErrDropsAfterMaxRetries(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{})
+	c := NewSecretMirror(informer, client, ca.Config, Options{Retry: RetryPolicy{BaseDelay: time.Millisecond, MaxRetries: 2}})
+	defer c.queue.ShutDown()
+
+	key := "test-ns/src"
+	for attempt := 1; attempt <= 2; attempt++ {
+		c.handleErr(errors.New("failed"), key)
+		if requeues := c.queue.NumRequeues(key); requeues != attempt {
+			t.Fatalf("attempt %d: expected the key to be retried, got %d requeues", attempt, requeues)
+		}
+	}
+	c.handleErr(errors.New("failed"), key)
+	if requeues := c.queue.NumRequeues(key); requeues != 0 {
+		t.Errorf("expected the key to be dropped after the last retry, got %d requeues", requeues)
+	}
+}
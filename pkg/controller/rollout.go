@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// rolloutCheckPeriod is how often a configuration
+// rolled out to canaries is verified.
+const rolloutCheckPeriod = 10 * time.Second
+
+// rollout stages reloaded configurations that set up canaries: changes
+// are rolled out to the canaries first, and to every rule once the
+// canaries mirrored successfully and without failures for the
+// verification period.
+// Configurations whose canaries fail are reverted until the configuration
+// is changed again. Other configurations take effect right away.
+type rollout struct {
+	lock sync.Mutex
+
+	source config.Getter
+	// namespaceLabels looks up the namespaces that canaries select
+	namespaceLabels config.NamespaceLabels
+	// loaded is the configuration last loaded from the source
+	loaded *config.Configuration
+	// applied is the configuration in effect
+	applied *config.Configuration
+	// stable is the configuration last applied to every rule
+	stable *config.Configuration
+
+	// candidate is rolled out to the canaries since started, if set
+	candidate *config.Configuration
+	// canaries are the rules of the candidate that it is rolled out to
+	canaries []config.MirrorConfig
+	started  time.Time
+	// announced is set once the sources of the canaries were enqueued
+	announced bool
+	// succeeded is set once a canary mirrored successfully
+	succeeded bool
+	failure   string
+}
+
+// Outcomes of verifying a rollout
+type rolloutOutcome int
+
+const (
+	rolloutUnchanged rolloutOutcome = iota
+	rolloutStarted
+	rolloutPromoted
+	rolloutReverted
+)
+
+// config returns the configuration in effect, staging
+// the configuration loaded from the source if it changed.
+func (r *rollout) config() *config.Configuration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	loaded := r.source()
+	if loaded == r.loaded {
+		return r.applied
+	}
+	r.loaded = loaded
+	r.candidate, r.canaries, r.announced, r.succeeded, r.failure = nil, nil, false, false, ""
+	if r.stable == nil || loaded == nil || loaded.Canary == nil {
+		r.applied, r.stable = loaded, loaded
+		return r.applied
+	}
+
+	var canaries []config.MirrorConfig
+	for _, mirrorConfig := range loaded.Secrets {
+		if loaded.IsCanary(mirrorConfig, r.namespaceLabels) {
+			canaries = append(canaries, mirrorConfig)
+		}
+	}
+	if len(canaries) == 0 {
+		// without canaries, the rollout could never be verified
+		logrus.Warn("no rule of the configuration is a canary, applying the configuration to every rule at once")
+		r.applied, r.stable = loaded, loaded
+		return r.applied
+	}
+	staged := loaded.Staged(r.stable, r.namespaceLabels)
+	if err := staged.Validate(); err != nil {
+		logrus.WithError(err).Warn("configuration staged for the canaries is invalid, applying the configuration to every rule at once")
+		r.applied, r.stable = loaded, loaded
+		return r.applied
+	}
+	r.canaries = canaries
+	r.candidate, r.applied, r.started = loaded, staged, time.Now()
+	stagedConfiguration.Set(1)
+	return r.applied
+}
+
+// observe records the outcome of mirroring the instance of a rule,
+// failing the rollout if it is a canary that failed to mirror.
+func (r *rollout) observe(instance config.MirrorConfig, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.candidate == nil || r.failure != "" || !r.isCanaryLocked(instance) {
+		return
+	}
+	if err != nil {
+		r.failure = instance.String() + ": " + err.Error()
+		return
+	}
+	r.succeeded = true
+}
+
+// verify promotes the candidate to every rule once a canary mirrored
+// successfully and none failed for the verification period, or reverts
+// to the stable configuration as soon as a canary failed.
+func (r *rollout) verify(now time.Time) (rolloutOutcome, string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	switch {
+	case r.candidate == nil:
+		return rolloutUnchanged, ""
+	case r.failure != "":
+		r.applied, r.candidate = r.stable, nil
+		stagedConfiguration.Set(0)
+		revertedConfigurations.Inc()
+		return rolloutReverted, r.failure
+	case !r.announced:
+		r.announced = true
+		return rolloutStarted, ""
+	case r.succeeded && now.Sub(r.started) >= r.candidate.Canary.Period.Duration:
+		r.applied, r.stable, r.candidate = r.candidate, r.candidate, nil
+		stagedConfiguration.Set(0)
+		return rolloutPromoted, ""
+	default:
+		return rolloutUnchanged, ""
+	}
+}
+
+// isCanary determines if the rule, or the rule it was instantiated
+// from, is a canary of the configuration that is being rolled out.
+func (r *rollout) isCanary(rule config.MirrorConfig) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.candidate != nil && r.isCanaryLocked(rule)
+}
+
+func (r *rollout) isCanaryLocked(rule config.MirrorConfig) bool {
+	for _, canary := range r.canaries {
+		if canary.Instantiates(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRollout verifies the rollout of the configuration, reconciling
+// the canaries when the rollout starts and every source once it is
+// promoted or reverted.
+func (c *SecretMirror) checkRollout() {
+	c.config()
+	outcome, failure := c.rollout.verify(time.Now())
+	switch outcome {
+	case rolloutStarted:
+		c.logger.Info("rolling the configuration out to the canaries")
+		for _, mirrorConfig := range c.config().Secrets {
+			if c.rollout.isCanary(mirrorConfig) {
+				for _, instance := range c.instances(mirrorConfig) {
+					c.enqueueKey(instance.From.String())
+				}
+			}
+		}
+	case rolloutPromoted:
+		c.logger.Info("canaries mirrored successfully, rolling the configuration out to every rule")
+		c.enqueueAll()
+	case rolloutReverted:
+		c.logger.WithField("failure", failure).Error("canary failed to mirror, reverting to the previous configuration until the configuration changes")
+		c.enqueueAll()
+	}
+}
+
+// namespaceLabels looks up the labels of the namespace in the cache.
+func (c *SecretMirror) namespaceLabels(name string) (map[string]string, bool) {
+	if c.namespaceLister == nil {
+		return nil, false
+	}
+	namespace, err := c.namespaceLister.Get(name)
+	if err != nil {
+		return nil, false
+	}
+	return namespace.Labels, true
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRollout(t *testing.T) {
+	mirror := func(to, name string) config.MirrorConfig {
+		return config.MirrorConfig{
+			From: config.SecretLocation{Namespace: "ci", Name: "src"},
+			To:   config.SecretLocation{Namespace: to, Name: name},
+		}
+	}
+	canary := &config.Canary{Namespaces: []string{"canary"}, Period: metav1.Duration{Duration: time.Minute}}
+	stable := &config.Configuration{Secrets: []config.MirrorConfig{mirror("canary", "old"), mirror("prod", "old")}}
+	changed := func() *config.Configuration {
+		return &config.Configuration{Secrets: []config.MirrorConfig{mirror("canary", "new"), mirror("prod", "new")}, Canary: canary}
+	}
+	names := func(configuration *config.Configuration) []string {
+		var names []string
+		for _, mirrorConfig := range configuration.Secrets {
+			names = append(names, mirrorConfig.To.String())
+		}
+		return names
+	}
+
+	loaded := stable
+	r := &rollout{source: func() *config.Configuration { return loaded }}
+	if r.config() != stable {
+		t.Fatal("expected the first configuration to take effect right away")
+	}
+
+	loaded = changed()
+	if actual := names(r.config()); len(actual) != 2 || actual[0] != "prod/old" || actual[1] != "canary/new" {
+		t.Fatalf("expected the change to be rolled out to the canary only, got %v", actual)
+	}
+	if outcome, _ := r.verify(time.Now()); outcome != rolloutStarted {
+		t.Errorf("expected the rollout to start, got %v", outcome)
+	}
+	if outcome, _ := r.verify(time.Now()); outcome != rolloutUnchanged {
+		t.Errorf("expected the rollout to wait for the verification period, got %v", outcome)
+	}
+	if outcome, _ := r.verify(time.Now().Add(time.Hour)); outcome != rolloutUnchanged {
+		t.Errorf("expected the rollout to wait for a canary to mirror successfully, got %v", outcome)
+	}
+	r.observe(mirror("prod", "new"), nil)
+	if outcome, _ := r.verify(time.Now().Add(time.Hour)); outcome != rolloutUnchanged {
+		t.Errorf("expected successes of other rules to be ignored, got %v", outcome)
+	}
+	r.observe(mirror("canary", "new"), nil)
+	if outcome, _ := r.verify(time.Now().Add(time.Hour)); outcome != rolloutPromoted {
+		t.Errorf("expected the rollout to be promoted after the verification period, got %v", outcome)
+	}
+	if r.config() != loaded {
+		t.Error("expected the promoted configuration to take effect for every rule")
+	}
+
+	promoted := loaded
+	loaded = changed()
+	loaded.Secrets[0].To.Name = "broken"
+	r.config()
+	r.verify(time.Now())
+	prod, broken := mirror("prod", "new"), mirror("canary", "broken")
+	r.observe(prod, errors.New("not a canary"))
+	if outcome, _ := r.verify(time.Now()); outcome != rolloutUnchanged {
+		t.Errorf("expected failures of other rules to be ignored, got %v", outcome)
+	}
+	r.observe(broken, errors.New("boom"))
+	if outcome, failure := r.verify(time.Now()); outcome != rolloutReverted || failure == "" {
+		t.Errorf("expected the rollout to be reverted after the canary failed, got %v (%q)", outcome, failure)
+	}
+	if r.config() != promoted {
+		t.Error("expected the previous configuration to take effect again until the configuration changes")
+	}
+}
+
+func TestRolloutInstances(t *testing.T) {
+	fanOut := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "ci", Name: "src"},
+		To:   config.SecretLocation{NamespaceSelector: "team=ci", Name: "dst"},
+	}
+	pattern := config.MirrorConfig{
+		From: config.SecretLocation{Namespace: "ci", NamePattern: "token-.*"},
+		To:   config.SecretLocation{Namespace: "canary"},
+	}
+	stable := &config.Configuration{Secrets: []config.MirrorConfig{fanOut, pattern}}
+	changed := func() *config.Configuration {
+		configuration := &config.Configuration{
+			Secrets: []config.MirrorConfig{fanOut, pattern},
+			Canary:  &config.Canary{Namespaces: []string{"canary"}, Period: metav1.Duration{Duration: time.Minute}},
+		}
+		for i := range configuration.Secrets {
+			configuration.Secrets[i].Labels = map[string]string{"version": "new"}
+		}
+		return configuration
+	}
+
+	loaded := stable
+	r := &rollout{
+		source: func() *config.Configuration { return loaded },
+		namespaceLabels: func(namespace string) (map[string]string, bool) {
+			return map[string]string{"team": "ci"}, namespace == "canary"
+		},
+	}
+	r.config()
+	loaded = changed()
+	r.config()
+	r.verify(time.Now())
+	fanOutInstance, _ := fanOut.InstantiateTarget("prod", map[string]string{"team": "ci"})
+	patternInstance, _ := pattern.Instantiate(config.SecretLocation{Namespace: "ci", Name: "token-a"})
+	if !r.isCanary(fanOut) || !r.isCanary(fanOutInstance) || !r.isCanary(patternInstance) {
+		t.Fatal("expected the rule selecting the canary namespace, the rule writing to it and their instances to be canaries")
+	}
+	r.observe(patternInstance, nil)
+	if outcome, _ := r.verify(time.Now().Add(time.Hour)); outcome != rolloutPromoted {
+		t.Errorf("expected the success of an instance of a canary to promote the rollout, got %v", outcome)
+	}
+
+	loaded = changed()
+	r.config()
+	r.verify(time.Now())
+	r.observe(fanOutInstance, errors.New("boom"))
+	if outcome, failure := r.verify(time.Now()); outcome != rolloutReverted || failure == "" {
+		t.Errorf("expected the failure of an instance of a canary to revert the rollout, got %v (%q)", outcome, failure)
+	}
+
+	r.namespaceLabels = func(string) (map[string]string, bool) { return nil, false }
+	loaded = &config.Configuration{Secrets: []config.MirrorConfig{fanOut}, Canary: changed().Canary}
+	if r.config() != loaded {
+		t.Error("expected a configuration without canaries to take effect right away")
+	}
+}
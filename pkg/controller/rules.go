@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// RuleStatus describes the state of one configured mirroring rule.
+type RuleStatus struct {
+	Rule                string     `json:"rule"`
+	From                string     `json:"from"`
+	To                  string     `json:"to"`
+	Paused              bool       `json:"paused"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	QuarantinedSince    *time.Time `json:"quarantinedSince,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	// LastSynced is when the rule last mirrored successfully
+	// since the controller started
+	LastSynced *time.Time `json:"lastSynced,omitempty"`
+	// Target is the state of the target, nil if it is not cached
+	Target *TargetState `json:"target,omitempty"`
+	// Drift is how the target had drifted from the source
+	// in the last audit sweep, empty if it had not
+	Drift string `json:"drift,omitempty"`
+	// PendingChange identifies the change to the target
+	// awaiting approval, empty if there is none
+	PendingChange string `json:"pendingChange,omitempty"`
+	// HeldChange is how the target differs from what the rule
+	// would write while writes are frozen, empty otherwise
+	HeldChange string `json:"heldChange,omitempty"`
+}
+
+// pauses records the rules an operator has paused by hand.
+type pauses struct {
+	lock  sync.RWMutex
+	rules map[string]bool
+}
+
+func (p *pauses) isPaused(rule string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.rules[rule]
+}
+
+func (p *pauses) set(rule string, paused bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.rules == nil {
+		p.rules = map[string]bool{}
+	}
+	if paused {
+		p.rules[rule] = true
+		pausedRules.set(rule, 1)
+	} else {
+		delete(p.rules, rule)
+		pausedRules.delete(rule)
+	}
+}
+
+// Rules returns the status of every configured rule.
+func (c *SecretMirror) Rules() []RuleStatus {
+	var statuses []RuleStatus
+	drift := c.drift.get()
+	for _, mirrorConfig := range c.concreteRules(c.config()) {
+		rule := mirrorConfig.String()
+		failures, since := c.quarantine.status(rule)
+		statuses = append(statuses, RuleStatus{
+			Rule:                rule,
+			From:                mirrorConfig.From.String(),
+			To:                  mirrorConfig.To.String(),
+			Paused:              c.pauses.isPaused(rule),
+			ConsecutiveFailures: failures,
+			QuarantinedSince:    since,
+			LastError:           c.lastErrors.get(rule),
+			LastSynced:          c.lastSyncs.get(rule),
+			Target:              c.targetState(mirrorConfig.To),
+			Drift:               drift.Drifted[rule],
+			PendingChange:       c.approvals.pendingChange(rule),
+			HeldChange:          c.freeze.heldChange(rule),
+		})
+	}
+	return statuses
+}
+
+// Sync triggers an immediate reconciliation of the named rule or, if
+// the name is empty, of every rule. It returns false for unknown rules.
+func (c *SecretMirror) Sync(rule string) bool {
+	if rule == "" {
+		c.enqueueAll()
+		return true
+	}
+	return c.enqueueRule(rule)
+}
+
+// Pause stops mirroring for the named rule until it is resumed.
+// It returns false for unknown rules.
+func (c *SecretMirror) Pause(rule string) bool {
+	if !c.isConfigured(rule) {
+		return false
+	}
+	c.pauses.set(rule, true)
+	c.logger.WithField("rule", rule).Info("paused rule")
+	return true
+}
+
+// Resume restarts mirroring for the named rule if it was paused or
+// quarantined and triggers a sync. It returns false for unknown rules.
+func (c *SecretMirror) Resume(rule string) bool {
+	if !c.isConfigured(rule) {
+		return false
+	}
+	c.pauses.set(rule, false)
+	c.quarantine.resume(rule)
+	c.logger.WithField("rule", rule).Info("resumed rule")
+	return c.enqueueRule(rule)
+}
+
+func (c *SecretMirror) isConfigured(rule string) bool {
+	for _, mirrorConfig := range c.concreteRules(c.config()) {
+		if mirrorConfig.String() == rule {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestPauseAndResume(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{QuarantineThreshold: 1})
+	defer c.queue.ShutDown()
+	const rule = "(test-ns/src -> test-ns/dst)"
+
+	if c.Pause("missing") || c.Resume("missing") {
+		t.Error("expected unknown rules to be rejected")
+	}
+	if !c.Pause(rule) {
+		t.Fatal("expected the rule to be paused")
+	}
+	c.quarantine.sync(ca.Config())
+	c.quarantine.recordFailure(rule)
+	rules := c.Rules()
+	if len(rules) != 1 || !rules[0].Paused || rules[0].QuarantinedSince == nil {
+		t.Fatalf("expected the rule to be paused and quarantined, got %v", rules)
+	}
+
+	if !c.Resume(rule) {
+		t.Fatal("expected the rule to be resumed")
+	}
+	rules = c.Rules()
+	if rules[0].Paused || rules[0].QuarantinedSince != nil {
+		t.Errorf("expected the rule to be healthy after resuming, got %v", rules)
+	}
+	if depth := c.queue.Len(); depth != 1 {
+		t.Errorf("expected resuming to enqueue the source, got %d queued keys", depth)
+	}
+}
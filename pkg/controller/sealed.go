@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/sealedsecrets"
+)
+
+// SealedSecrets seals data with the sealing key of the cluster and
+// writes SealedSecrets, which the sealed-secrets controller decrypts
+// into Secrets.
+type SealedSecrets interface {
+	// Seal encrypts every value of the data for the secret with the
+	// name in the namespace.
+	Seal(namespace, name string, data map[string][]byte) (map[string]string, error)
+	// Fetch returns the SealedSecret with the name in the namespace.
+	Fetch(namespace, name string) (*sealedsecrets.SealedSecret, error)
+	// Store creates the SealedSecret, or replaces it if it was
+	// fetched, returning it as stored.
+	Store(sealed *sealedsecrets.SealedSecret) (*sealedsecrets.SealedSecret, error)
+}
+
+// sealSecret writes the data mirrored from the source as a SealedSecret
+// instead of a Secret, for clusters where controllers must not create
+// Secrets holding plaintext. Sealing is not deterministic, so whether
+// the SealedSecret is up to date is determined from the keyed hash of
+// the data recorded on it, and it is sealed anew whenever the data changes.
+func (c *SecretMirror) sealSecret(source *coreapi.Secret, sourceData map[string][]byte, targetType coreapi.SecretType, mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	if c.sealedSecrets == nil {
+		return fmt.Errorf("the rule writes a SealedSecret but no sealing certificate is configured")
+	}
+	if len(c.hashKey) == 0 {
+		return fmt.Errorf("the rule writes a SealedSecret but no hash key is configured")
+	}
+	to := mirrorConfig.To
+	hash := dataHash(sourceData)
+	// SealedSecrets are readable by those who may not read the data, so
+	// they record a keyed hash rather than the plain one
+	sealedHash := keyedHash(c.hashKey, hash)
+	applied := fingerprint(mirrorConfig, hash)
+	keys := formatKeys(sourceData)
+
+	reason := reasonCreated
+	sealed, err := c.sealedSecrets.Fetch(to.Namespace, to.Name)
+	switch {
+	case err == nil:
+		if !mirrorConfig.AdoptExisting && !managedTarget(sealed) {
+			return fmt.Errorf("refusing to overwrite target SealedSecret as the controller did not create it, set adoptExisting: true to take it over")
+		}
+		template := sealed.Spec.Template
+		if sealed.Annotations[lastAppliedHashAnnotation] == sealedHash && sealed.Annotations[lastAppliedKeysAnnotation] == keys && template.Type == targetType && containsAll(template.Labels, mirrorConfig.Labels) && containsAll(template.Annotations, mirrorConfig.Annotations) {
+			logger.Debug("not updating target SealedSecret as it already matches the source")
+			c.approvals.settle(mirrorConfig.String())
+			noopSyncs.WithLabelValues(noopReasonUnchanged).Inc()
+			c.inventory.record(mirrorConfig, false)
+			if err := c.propagate(mirrorConfig, hash, false); err != nil {
+				return err
+			}
+			c.applied.record(to.String(), applied, sealed.ResourceVersion)
+			return nil
+		}
+		reason = reasonUpdated
+	case errors.IsNotFound(err):
+		sealed = &sealedsecrets.SealedSecret{ObjectMeta: metav1.ObjectMeta{Name: to.Name, Namespace: to.Namespace}}
+	default:
+		return fmt.Errorf("failed to get target SealedSecret: %v", err)
+	}
+	if awaiting, err := c.awaitingApproval(source, mirrorConfig, applied, logger); err != nil || awaiting {
+		return err
+	}
+
+	encrypted, err := c.sealedSecrets.Seal(to.Namespace, to.Name, sourceData)
+	if err != nil {
+		return fmt.Errorf("failed to seal target data: %v", err)
+	}
+	// the metadata of the Secret is recorded the same way as on the
+	// SealedSecret, so that the Secret is recognized as managed
+	now := time.Now()
+	destination := sealed.DeepCopy()
+	destination.Labels = withEntries(destination.Labels, mirrorConfig.Labels)
+	destination.Annotations = withEntries(destination.Annotations, mirrorConfig.Annotations)
+	destination.Spec = sealedsecrets.SealedSecretSpec{
+		Template: sealedsecrets.SecretTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:      withEntries(nil, mirrorConfig.Labels),
+				Annotations: withEntries(nil, mirrorConfig.Annotations),
+			},
+			Type: targetType,
+		},
+		EncryptedData: encrypted,
+	}
+	for _, object := range []metav1.Object{destination, &destination.Spec.Template} {
+		annotations := withEntries(object.GetAnnotations(), map[string]string{lastAppliedHashAnnotation: sealedHash, lastAppliedKeysAnnotation: keys})
+		object.SetAnnotations(annotations)
+		stamp(object, mirrorConfig.Sources(), source.ResourceVersion, c.config().Revision, now)
+	}
+
+	if reason == reasonCreated {
+		logger.Info("creating target SealedSecret")
+	} else {
+		logger.Info("updating target SealedSecret")
+	}
+	stored, err := c.sealedSecrets.Store(destination)
+	if err != nil {
+		return fmt.Errorf("failed to write target SealedSecret: %v", err)
+	}
+	written := &coreapi.Secret{ObjectMeta: stored.ObjectMeta}
+	c.inventory.record(mirrorConfig, true)
+	c.approvals.settle(mirrorConfig.String())
+	c.recordWrite(source, written, mirrorConfig, reason, hash)
+	c.annotateSource(source, mirrorConfig, hash, logger)
+	if err := c.propagate(mirrorConfig, hash, true); err != nil {
+		return err
+	}
+	c.applied.record(to.String(), applied, stored.ResourceVersion)
+	return nil
+}
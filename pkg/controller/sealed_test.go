@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/sealedsecrets"
+)
+
+// fakeSealedSecrets holds SealedSecrets, sealing values by prefixing
+// them with the secret they are sealed for
+type fakeSealedSecrets struct {
+	sealed map[string]*sealedsecrets.SealedSecret
+	stores int
+}
+
+func (f *fakeSealedSecrets) Seal(namespace, name string, data map[string][]byte) (map[string]string, error) {
+	encrypted := map[string]string{}
+	for key, value := range data {
+		encrypted[key] = namespace + "/" + name + ":" + string(value)
+	}
+	return encrypted, nil
+}
+
+func (f *fakeSealedSecrets) Fetch(namespace, name string) (*sealedsecrets.SealedSecret, error) {
+	sealed, found := f.sealed[namespace+"/"+name]
+	if !found {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: sealedsecrets.GroupName, Resource: sealedsecrets.Resource}, name)
+	}
+	return sealed.DeepCopy(), nil
+}
+
+func (f *fakeSealedSecrets) Store(sealed *sealedsecrets.SealedSecret) (*sealedsecrets.SealedSecret, error) {
+	f.stores++
+	stored := sealed.DeepCopy()
+	stored.ResourceVersion = strconv.Itoa(f.stores)
+	f.sealed[sealed.Namespace+"/"+sealed.Name] = stored
+	return stored.DeepCopy(), nil
+}
+
+func TestSealSecret(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		existing       *sealedsecrets.SealedSecret
+		adoptExisting  bool
+		expectedStores int
+		expectedErr    string
+	}{
+		{
+			name:           "missing target is sealed",
+			expectedStores: 1,
+		},
+		{
+			name: "managed target is sealed anew",
+			existing: &sealedsecrets.SealedSecret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "token", Labels: map[string]string{managedByLabel: managedByValue}},
+			},
+			expectedStores: 1,
+		},
+		{
+			name: "unmanaged target is not overwritten",
+			existing: &sealedsecrets.SealedSecret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "token"},
+			},
+			expectedErr: "adoptExisting",
+		},
+		{
+			name: "unmanaged target is adopted",
+			existing: &sealedsecrets.SealedSecret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "token", Labels: map[string]string{"owner": "team"}},
+			},
+			adoptExisting:  true,
+			expectedStores: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "token", ResourceVersion: "5"},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{"key": []byte("value")},
+			})
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+				{
+					From:          config.SecretLocation{Namespace: "ci", Name: "token"},
+					To:            config.SecretLocation{Namespace: "team", Name: "token"},
+					Labels:        map[string]string{"team": "ci"},
+					Output:        config.OutputSealedSecret,
+					AdoptExisting: testCase.adoptExisting,
+				},
+			}})
+			sealed := &fakeSealedSecrets{sealed: map[string]*sealedsecrets.SealedSecret{}}
+			if testCase.existing != nil {
+				sealed.sealed["team/token"] = testCase.existing
+			}
+			c := NewSecretMirror(informer, client, ca.Config, Options{SealedSecrets: sealed, HashKey: []byte("key")})
+			defer c.queue.ShutDown()
+
+			client.ClearActions()
+			err := c.reconcile("ci/token")
+			if testCase.expectedErr == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if testCase.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testCase.expectedErr)) {
+				t.Fatalf("expected an error about %s, got %v", testCase.expectedErr, err)
+			}
+			if sealed.stores != testCase.expectedStores {
+				t.Errorf("expected %d writes, got %d", testCase.expectedStores, sealed.stores)
+			}
+			for _, action := range client.Actions() {
+				if action.GetResource().Resource == "secrets" && action.GetVerb() != "get" {
+					t.Errorf("expected no secret to be written, got %v", action)
+				}
+			}
+			if err != nil {
+				return
+			}
+
+			target := sealed.sealed["team/token"]
+			if value := target.Spec.EncryptedData["key"]; value != "team/token:value" {
+				t.Errorf("expected the data to be sealed for the target, got %q", value)
+			}
+			template := target.Spec.Template
+			if template.Type != v1.SecretTypeOpaque || template.Labels["team"] != "ci" || !managedTarget(&template) || !managedTarget(target) {
+				t.Errorf("expected the target and its template to carry the metadata of the rule, got %+v", target)
+			}
+			hash := dataHash(map[string][]byte{"key": []byte("value")})
+			for _, annotations := range []map[string]string{target.Annotations, template.Annotations} {
+				if annotations[lastAppliedHashAnnotation] != keyedHash([]byte("key"), hash) {
+					t.Errorf("expected the keyed hash of the data to be recorded rather than its hash, got %v", annotations)
+				}
+			}
+			if template.Annotations[mirrorSourceVersionAnnotation] != "5" {
+				t.Errorf("expected the template to record the version of the source, got %v", template.Annotations)
+			}
+			if testCase.adoptExisting && target.Labels["owner"] != "team" {
+				t.Errorf("expected the labels of the adopted target to be kept, got %v", target.Labels)
+			}
+
+			// the data is not sealed again unless it changes
+			c.applied.forget("team/token")
+			if err := c.reconcile("ci/token"); err != nil || sealed.stores != testCase.expectedStores {
+				t.Errorf("expected the target not to be sealed again, got %d writes and %v", sealed.stores, err)
+			}
+		})
+	}
+}
+
+func TestSealSecretWithoutCertificate(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "token"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:   config.SecretLocation{Namespace: "ci", Name: "token"},
+			To:     config.SecretLocation{Namespace: "team", Name: "token"},
+			Output: config.OutputSealedSecret,
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	defer c.queue.ShutDown()
+
+	if err := c.reconcile("ci/token"); err == nil || !strings.Contains(err.Error(), "sealing certificate") {
+		t.Errorf("expected an error about the missing sealing certificate, got %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("team").Get("token", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected no Secret to be written in place of the SealedSecret, got %v", err)
+	}
+
+	sealed := &fakeSealedSecrets{sealed: map[string]*sealedsecrets.SealedSecret{}}
+	c = NewSecretMirror(informer, client, ca.Config, Options{SealedSecrets: sealed})
+	defer c.queue.ShutDown()
+	if err := c.reconcile("ci/token"); err == nil || !strings.Contains(err.Error(), "hash key") {
+		t.Errorf("expected an error about the missing hash key, got %v", err)
+	}
+	if sealed.stores != 0 {
+		t.Errorf("expected nothing to be sealed without a hash key, got %d writes", sealed.stores)
+	}
+}
@@ -1,106 +1,492 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"filippo.io/age"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/backup"
 	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/notify"
 	"github.com/sirupsen/logrus"
 
 	coreapi "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
 	coreclient "k8s.io/client-go/kubernetes/typed/core/v1"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 	"reflect"
 )
 
 const (
-	// maxRetries is the number of times a service will be retried before it is dropped out of the queue.
-	// With the current rate-limiter in use (5ms*2^(maxRetries-1)) the following numbers represent the
-	// sequence of delays between successive queuings of a service.
-	//
-	// 5ms, 10ms, 20ms, 40ms, 80ms, 160ms, 320ms, 640ms, 1.3s, 2.6s, 5.1s, 10.2s, 20.4s, 41s, 82s
-	maxRetries = 15
-
 	secretMirrorname = "secret-mirroring-manager"
 )
 
-// NewSecretMirror returns a new *SecretMirror to generate deletion requests.
-func NewSecretMirror(informer coreinformers.SecretInformer, client kubeclientset.Interface, config config.Getter) *SecretMirror {
-	logger := logrus.WithField("controller", secretMirrorname)
-	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(logger.Infof)
-	eventBroadcaster.StartRecordingToSink(&coreclient.EventSinkImpl{Interface: coreclient.New(client.CoreV1().RESTClient()).Events("")})
+// Options holds the tunables for the SecretMirror controller.
+type Options struct {
+	// MaxQueueDepth bounds the number of keys waiting in the work queue.
+	// Once the queue is saturated, new keys are shed and will be picked up
+	// again on the next informer resync, except for sources awaiting their
+	// initial reconciliation, which are enqueued again once the queue has
+	// room. Zero disables the limit.
+	MaxQueueDepth int
 
-	c := &SecretMirror{
-		config: config,
-		client: client,
-		queue:  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), secretMirrorname),
-		logger: logger,
-		lister: informer.Lister(),
-		synced: informer.Informer().HasSynced,
-	}
+	// QuarantineThreshold is the number of consecutive failures after which
+	// a rule is quarantined until the configuration is reloaded or the rule
+	// is resumed by hand. Zero disables quarantine.
+	QuarantineThreshold int
+
+	// Notifier delivers notifications about quarantined rules to the
+	// destinations routed for them. Notifications are disabled if nil.
+	Notifier *notify.Notifier
+
+	// WriteClient is used for every mutation, i.e. for writing targets and
+	// recording events, so that the client passed to NewSecretMirror may
+	// be read-only. If nil, that client is used for writes, too.
+	WriteClient kubeclientset.Interface
+
+	// RemoteClusters maps cluster names to remote clusters
+	// which rules may mirror from with `from.cluster`.
+	RemoteClusters map[string]RemoteCluster
+
+	// Namespaces lets rules select the namespaces they write to by
+	// their labels. Rules with a namespace selector write nowhere
+	// if nil.
+	Namespaces coreinformers.NamespaceInformer
+
+	// ConfigSynced reports whether the configuration is complete, e.g.
+	// once the SecretMirror objects merged into it have been listed.
+	// Nothing is reconciled or pruned before it is. The configuration is
+	// complete from the start if nil.
+	ConfigSynced cache.InformerSynced
+
+	// TargetClusters maps cluster names to clients for remote
+	// clusters which rules may mirror to with `to.cluster`.
+	TargetClusters map[string]kubeclientset.Interface
+
+	// Providers maps the names of stores outside of Kubernetes,
+	// e.g. config.ProviderVault, to the providers reading from them.
+	// Polling sources in a store without a provider fails, as does
+	// writing targets in a store whose provider is no Writer.
+	Providers map[string]Provider
+
+	// SealedSecrets seals the data of rules with output: SealedSecret
+	// and writes their SealedSecrets. Such rules fail if nil.
+	SealedSecrets SealedSecrets
+
+	// ExternalSecrets writes the ExternalSecrets of rules with
+	// output: ExternalSecret. Such rules fail if nil.
+	ExternalSecrets ExternalSecrets
+
+	// CredentialExpiryWarning is how long before mirrored tokens and
+	// client certificates expire that the controller starts to warn
+	// about them. Expired credentials are always warned about.
+	CredentialExpiryWarning time.Duration
+
+	// PublishVersions maintains a ConfigMap in every target namespace
+	// mapping target names to the hash of their data and the time it
+	// last changed. Requires HashKey.
+	PublishVersions bool
+
+	// HashKey keys the hashes of data recorded where the data itself
+	// cannot be read: on SealedSecrets, in the versions ConfigMap and
+	// in pod templates. Rules writing SealedSecrets or injecting
+	// checksums fail without it.
+	HashKey []byte
+
+	// ProtectedTargetPatterns are glob patterns matching the names of
+	// targets that must never be written to, in addition to the
+	// DefaultProtectedTargetPatterns. They must be valid patterns.
+	ProtectedTargetPatterns []string
+
+	// InventoryNamespace holds the inventory ConfigMap recording every
+	// target the controller manages. The inventory is disabled if empty.
+	InventoryNamespace string
+
+	// WarmStart is the period over which the initial reconciliation of
+	// every source is spread after the caches synced, so that restarts
+	// do not load the API server with every request at once. Zero
+	// reconciles every source right away.
+	WarmStart time.Duration
+
+	// AuditPeriod is how often the target of every rule is compared to its
+	// source, reading both from the API server, to repair drift that the
+	// watch missed. Zero disables auditing.
+	AuditPeriod time.Duration
+
+	// ReportOnly never writes targets, but reports targets that are missing
+	// or drifted from their source in metrics, events and the status API,
+	// e.g. while another system still owns the targets.
+	ReportOnly bool
+
+	// PruneOrphans deletes targets that the controller wrote when
+	// the rules writing to them are removed from the configuration.
+	PruneOrphans bool
+
+	// Frozen starts the controller with writes frozen, as if
+	// Freeze was called before the first reconciliation.
+	Frozen bool
+
+	// FileSinkDirectory holds the directories that rules with a
+	// file sink write their data to. File sinks fail without it.
+	FileSinkDirectory string
+
+	// BackupStore receives an archive of every target once per
+	// BackupPeriod, encrypted to the BackupRecipients. Only the newest
+	// BackupRetention archives are kept, or all of them if zero.
+	// Backups are disabled without a store.
+	BackupStore      backup.Store
+	BackupRecipients []age.Recipient
+	BackupPeriod     time.Duration
+	BackupRetention  int
+
+	// FilteredSecrets tells that the informer only holds some secrets,
+	// e.g. those carrying a label. Sources must then be among them,
+	// while targets are read from the API server.
+	FilteredSecrets bool
+
+	// AuditLog records every write to a target if set.
+	AuditLog *auditlog.Log
 
+	// Retry determines how failing sources are retried,
+	// DefaultRetryPolicy if it is zero.
+	Retry RetryPolicy
+
+	// Throttle makes every worker back off when the API server throttles
+	// the clients it observes. Workers do not back off together if nil.
+	Throttle *Throttle
+}
+
+// RemoteCluster holds read-only access to secrets in a remote cluster.
+type RemoteCluster struct {
+	Client  kubeclientset.Interface
+	Secrets coreinformers.SecretInformer
+}
+
+// NewSecretMirror returns a new *SecretMirror to generate deletion requests.
+func NewSecretMirror(informer coreinformers.SecretInformer, client kubeclientset.Interface, config config.Getter, options Options) *SecretMirror {
+	c := newSecretMirror(informer.Lister(), client, config, options)
+	c.watchSecrets(informer)
+	c.watchOptions(options)
+	return c
+}
+
+// watchSecrets reconciles the sources that the informer observes changing.
+func (c *SecretMirror) watchSecrets(informer coreinformers.SecretInformer) {
+	c.synced = append(c.synced, informer.Informer().HasSynced)
 	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.add,
 		UpdateFunc: c.update,
+		DeleteFunc: c.delete,
 	})
+}
 
+// watchOptions watches the namespaces and remote clusters of the options.
+func (c *SecretMirror) watchOptions(options Options) {
+	if options.ConfigSynced != nil {
+		c.synced = append(c.synced, options.ConfigSynced)
+	}
+	if options.Namespaces != nil {
+		// namespaces only matter to rules selecting them, so
+		// mirroring does not wait for them to be listed
+		c.namespaceLister = options.Namespaces.Lister()
+		options.Namespaces.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.namespaceChanged(nil, obj.(*coreapi.Namespace)) },
+			UpdateFunc: func(old, obj interface{}) { c.namespaceChanged(old.(*coreapi.Namespace), obj.(*coreapi.Namespace)) },
+		})
+	}
+
+	for cluster, remote := range options.RemoteClusters {
+		cluster := cluster
+		c.remoteListers[cluster] = remote.Secrets.Lister()
+		c.remoteClients[cluster] = remote.Client
+		c.synced = append(c.synced, remote.Secrets.Informer().HasSynced)
+		remote.Secrets.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueueRemote(cluster, obj.(*coreapi.Secret)) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueueRemote(cluster, obj.(*coreapi.Secret)) },
+			DeleteFunc: func(obj interface{}) {
+				if secret, ok := deletedSecret(obj); ok {
+					c.enqueueRemote(cluster, secret)
+				}
+			},
+		})
+	}
+}
+
+// newSecretMirror returns a *SecretMirror reading local secrets
+// from the lister, without watching for changes to them.
+func newSecretMirror(lister corelisters.SecretLister, client kubeclientset.Interface, config config.Getter, options Options) *SecretMirror {
+	writeClient := options.WriteClient
+	if writeClient == nil {
+		writeClient = client
+	}
+
+	retry := options.Retry.withDefaults()
+	logger := logrus.WithField("controller", secretMirrorname)
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(logger.Infof)
+	eventBroadcaster.StartRecordingToSink(&coreclient.EventSinkImpl{Interface: writeClient.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, coreapi.EventSource{Component: secretMirrorname})
+
+	c := &SecretMirror{
+		rollout:           &rollout{source: config},
+		client:            client,
+		writeClient:       writeClient,
+		retry:             retry,
+		auditLog:          options.AuditLog,
+		queue:             retry.queue(secretMirrorname),
+		maxQueueDepth:     options.MaxQueueDepth,
+		warmStart:         options.WarmStart,
+		auditPeriod:       options.AuditPeriod,
+		reportOnly:        options.ReportOnly,
+		pruneOrphans:      options.PruneOrphans,
+		liveTargets:       options.FilteredSecrets,
+		throttle:          options.Throttle,
+		quarantine:        newQuarantine(options.QuarantineThreshold),
+		notifier:          options.Notifier,
+		expiries:          &expiries{warning: options.CredentialExpiryWarning},
+		publishVersions:   options.PublishVersions,
+		hashKey:           options.HashKey,
+		fileSinkDirectory: options.FileSinkDirectory,
+		backupStore:       options.BackupStore,
+		backupRecipients:  options.BackupRecipients,
+		backupPeriod:      options.BackupPeriod,
+		backupRetention:   options.BackupRetention,
+		inventory:         newInventory(options.InventoryNamespace),
+		protectedTargets:  append(append([]string{}, DefaultProtectedTargetPatterns...), options.ProtectedTargetPatterns...),
+		recorder:          recorder,
+		logger:            logger,
+		lister:            lister,
+		remoteListers:     map[string]corelisters.SecretLister{},
+		remoteClients:     map[string]kubeclientset.Interface{},
+		targetClients:     options.TargetClusters,
+		providers:         options.Providers,
+		sealedSecrets:     options.SealedSecrets,
+		externalSecrets:   options.ExternalSecrets,
+	}
+	// reloaded configurations that set up canaries are staged
+	c.rollout.namespaceLabels = c.namespaceLabels
+	c.config = c.rollout.config
+	c.freeze.set(options.Frozen)
 	return c
 }
 
 // SecretMirror manages deletion requests for namespaces.
 type SecretMirror struct {
-	config config.Getter
-	client kubeclientset.Interface
+	config      config.Getter
+	client      kubeclientset.Interface
+	writeClient kubeclientset.Interface
+
+	lister          corelisters.SecretLister
+	remoteListers   map[string]corelisters.SecretLister
+	remoteClients   map[string]kubeclientset.Interface
+	targetClients   map[string]kubeclientset.Interface
+	providers       map[string]Provider
+	sealedSecrets   SealedSecrets
+	externalSecrets ExternalSecrets
+	namespaceLister corelisters.NamespaceLister
+	polled          polledSources
+	queue           workqueue.RateLimitingInterface
+	retry           RetryPolicy
+	synced          []cache.InformerSynced
+	// namespaced holds the listers of the watched namespaces
+	// if only the namespaces of the configuration are watched
+	namespaced *namespacedSecretLister
 
-	lister corelisters.SecretLister
-	queue  workqueue.RateLimitingInterface
-	synced cache.InformerSynced
+	maxQueueDepth int
+	warmStart     time.Duration
+	auditPeriod   time.Duration
+	reportOnly    bool
+	pruneOrphans  bool
+	// liveTargets reads local targets from the API server
+	// as the cache may not hold them
+	liveTargets bool
+	// prunedGeneration is the configuration orphans were last pruned against
+	prunedGeneration *config.Configuration
+	throttle         *Throttle
+	quarantine       *quarantine
+	pauses           pauses
+	applied          appliedStore
+	pendingRollouts  pendingRollouts
+	transforms       transforms
+	collisions       collisions
+	ready            readiness
+	queued           queuedKeys
+	rules            ruleIndex
+	lastErrors       lastErrors
+	lastSyncs        syncTimes
+	inventory        *inventory
+	notifier         *notify.Notifier
+	expiries         *expiries
+	approvals        approvals
+	freeze           freeze
+	rollout          *rollout
+	drift            driftReports
 
-	logger *logrus.Entry
+	publishVersions   bool
+	hashKey           []byte
+	protectedTargets  []string
+	fileSinkDirectory string
+
+	backupStore      backup.Store
+	backupRecipients []age.Recipient
+	backupPeriod     time.Duration
+	backupRetention  int
+
+	auditLog *auditlog.Log
+	recorder record.EventRecorder
+	logger   *logrus.Entry
 }
 
 func (c *SecretMirror) add(obj interface{}) {
 	secret := obj.(*coreapi.Secret)
+	if !c.isSource(config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}) {
+		return
+	}
 	c.logger.Debugf("enqueueing added secret %s/%s", secret.GetNamespace(), secret.GetName())
 	c.enqueue(secret)
 }
 
 func (c *SecretMirror) update(old, obj interface{}) {
 	secret := obj.(*coreapi.Secret)
+	// resyncs do not change the target and our own writes are expected
+	if secret.ResourceVersion != old.(*coreapi.Secret).ResourceVersion && !c.applied.wrote(location(secret), secret.ResourceVersion) {
+		c.repairTarget(secret, "changed")
+	}
+	c.applied.observe(location(secret), secret.ResourceVersion)
+	if !c.isSource(config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}) {
+		return
+	}
 	c.logger.Debugf("enqueueing updated secret %s/%s", secret.GetNamespace(), secret.GetName())
 	c.enqueue(secret)
 }
 
-// Run runs c; will not return until stopCh is closed. workers determines how
-// many clusters will be handled in parallel.
-func (c *SecretMirror) Run(workers int, stopCh <-chan struct{}) {
+func (c *SecretMirror) delete(obj interface{}) {
+	secret, ok := deletedSecret(obj)
+	if !ok {
+		return
+	}
+	c.applied.forget(location(secret))
+	c.repairTarget(secret, "deleted")
+	if !c.isSource(config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}) {
+		return
+	}
+	// rules propagating the deletion remove their targets
+	c.logger.Debugf("enqueueing deleted secret %s/%s", secret.GetNamespace(), secret.GetName())
+	c.enqueue(secret)
+}
+
+// repairTarget enqueues the sources of the rules writing to the secret,
+// so that a target changed or deleted by someone else is restored right
+// away instead of when its source next changes. Reconciling the sources
+// writes nothing if the target still matches, e.g. if only metadata we
+// do not manage was changed, and respects targets that are held.
+func (c *SecretMirror) repairTarget(secret *coreapi.Secret, change string) {
+	for _, source := range c.rules.sourcesWritingTo(c.config(), config.SecretLocation{Namespace: secret.Namespace, Name: secret.Name}) {
+		c.logger.Debugf("enqueueing source %s of %s target %s/%s", source.String(), change, secret.Namespace, secret.Name)
+		c.enqueueKey(source.String())
+	}
+}
+
+// deletedSecret returns the secret of a deletion event, which may be
+// wrapped in a tombstone if the deletion was missed.
+func deletedSecret(obj interface{}) (*coreapi.Secret, bool) {
+	secret, ok := obj.(*coreapi.Secret)
+	if ok {
+		return secret, true
+	}
+	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("couldn't get object from tombstone %#v", obj))
+		return nil, false
+	}
+	if secret, ok = tombstone.Obj.(*coreapi.Secret); !ok {
+		utilruntime.HandleError(fmt.Errorf("tombstone contained object that is not a secret %#v", obj))
+		return nil, false
+	}
+	return secret, true
+}
+
+// enqueueRemote enqueues a secret from a remote source cluster.
+func (c *SecretMirror) enqueueRemote(cluster string, secret *coreapi.Secret) {
+	location := config.SecretLocation{Cluster: cluster, Namespace: secret.Namespace, Name: secret.Name}
+	if !c.isSource(location) {
+		return
+	}
+	c.logger.Debugf("enqueueing remote secret %s", location.String())
+	c.enqueueKey(location.String())
+}
+
+// isSource determines if any rule mirrors from the location. Events for
+// other secrets are dropped instead of being enqueued; secrets that become
+// sources when the configuration is reloaded are enqueued by Sync.
+func (c *SecretMirror) isSource(location config.SecretLocation) bool {
+	return c.rules.isSource(c.config(), location)
+}
+
+// location identifies a secret in the same format as config.SecretLocation.String()
+func location(secret *coreapi.Secret) string {
+	return fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
+}
+
+// Run runs c; will not return until ctx is cancelled and the sources being
+// reconciled are done, while sources that are still queued are dropped.
+// workers determines how many sources will be handled in parallel.
+func (c *SecretMirror) Run(ctx context.Context, workers int) {
+	stopCh := ctx.Done()
 	defer utilruntime.HandleCrash()
-	defer c.queue.ShutDown()
 
 	c.logger.Infof("starting %s controller", secretMirrorname)
 	defer c.logger.Infof("shutting down %s controller", secretMirrorname)
 
 	c.logger.Infof("Waiting for caches to reconcile for %s controller", secretMirrorname)
-	if !cache.WaitForCacheSync(stopCh, c.synced) {
+	if !cache.WaitForCacheSync(stopCh, c.synced...) {
 		utilruntime.HandleError(fmt.Errorf("unable to reconcile caches for %s controller", secretMirrorname))
 	}
 	c.logger.Infof("Caches are synced for %s controller", secretMirrorname)
+	// every rule is reconciled on start, so that targets that went missing
+	// while we were down are restored without waiting for their source to
+	// change; sources that do not exist were never enqueued by the informers,
+	// yet we are not ready before we reconciled them once
+	c.ready.start(c.sourceKeys())
+	c.enqueueAll()
+	c.stagger()
 
+	var running background
 	for i := 0; i < workers; i++ {
-		go wait.Until(c.worker, time.Second, stopCh)
+		running.run(func() { wait.Until(func() { c.worker(ctx) }, time.Second, stopCh) })
 	}
+	running.run(func() { wait.Until(c.poll, pollPeriod, stopCh) })
+	running.run(func() { wait.Until(c.countOrphans, orphanCountPeriod, stopCh) })
+	if c.pruneOrphans {
+		running.run(func() { wait.Until(c.pruneOrphanedTargets, orphanCountPeriod, stopCh) })
+	}
+	running.run(func() { wait.Until(c.flushInventory, inventoryFlushPeriod, stopCh) })
+	running.run(func() { c.runAudits(stopCh) })
+	running.run(func() { wait.Until(c.checkRollout, rolloutCheckPeriod, stopCh) })
+	running.run(func() { c.runBackups(stopCh) })
+	running.run(func() { wait.Until(c.runExternalSecrets, externalSecretSyncPeriod, stopCh) })
 
 	<-stopCh
+	c.logger.Info("waiting for the sources being reconciled")
+	// workers blocked on an empty queue return once it is shut down
+	c.queue.ShutDown()
+	running.wait()
 }
 
 func (c *SecretMirror) enqueue(obj metav1.Object) {
@@ -109,14 +495,71 @@ func (c *SecretMirror) enqueue(obj metav1.Object) {
 		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %#v: %v", obj, err))
 		return
 	}
+	c.enqueueKey(key)
+}
 
+// enqueueRule enqueues the sources of the named rule, returning
+// false if no such rule is configured. Rules selecting their sources
+// by pattern may be named as configured or as instantiated.
+func (c *SecretMirror) enqueueRule(rule string) bool {
+	for _, mirrorConfig := range c.config().Secrets {
+		instances := c.instances(mirrorConfig)
+		if mirrorConfig.String() == rule {
+			for _, instance := range instances {
+				c.enqueueKey(instance.From.String())
+			}
+			return true
+		}
+		for _, instance := range instances {
+			if instance.String() == rule {
+				c.enqueueKey(instance.From.String())
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *SecretMirror) enqueueKey(key string) {
+	// the work queue already merges duplicate keys, so only
+	// new keys can grow it; shed those while we are saturated
+	if c.maxQueueDepth > 0 && !c.queued.has(key) && c.queue.Len() >= c.maxQueueDepth {
+		queueShedKeys.Inc()
+		if c.ready.shedKey(key) {
+			c.logger.WithField("key", key).Warn("work queue is saturated, deferring the initial reconciliation of key")
+			return
+		}
+		c.logger.WithField("key", key).Warn("work queue is saturated, shedding key")
+		return
+	}
+	c.queued.add(key)
 	c.queue.Add(key)
+	c.recordQueueDepth()
+}
+
+// enqueueShed enqueues the sources that were shed before their initial
+// reconciliation, as far as the work queue has room for them.
+func (c *SecretMirror) enqueueShed() {
+	if c.maxQueueDepth == 0 {
+		return
+	}
+	for _, key := range c.ready.takeShed(c.maxQueueDepth - c.queue.Len()) {
+		c.enqueueKey(key)
+	}
 }
 
-// worker runs a worker thread that just dequeues items, processes them, and marks them done.
-// It enforces that the syncHandler is never invoked concurrently with the same key.
-func (c *SecretMirror) worker() {
-	for c.processNextWorkItem() {
+// recordQueueDepth exposes how close the work queue is to its limit.
+func (c *SecretMirror) recordQueueDepth() {
+	depth := c.queue.Len()
+	queueDepth.Set(float64(depth))
+	if c.maxQueueDepth > 0 {
+		queueSaturation.Set(float64(depth) / float64(c.maxQueueDepth))
+	}
+}
+
+// worker reconciles queued sources until ctx is cancelled.
+func (c *SecretMirror) worker(ctx context.Context) {
+	for ctx.Err() == nil && c.processNextWorkItem() {
 	}
 }
 
@@ -126,9 +569,15 @@ func (c *SecretMirror) processNextWorkItem() bool {
 		return false
 	}
 	defer c.queue.Done(key)
+	c.queued.remove(key.(string))
+	c.recordQueueDepth()
 
+	release := c.throttle.acquire()
 	err := c.reconcile(key.(string))
+	release()
 	c.handleErr(err, key)
+	c.ready.done(key.(string))
+	c.enqueueShed()
 
 	return true
 }
@@ -142,7 +591,7 @@ func (c *SecretMirror) handleErr(err error, key interface{}) {
 	logger := c.logger.WithField("secret", key)
 
 	logger.Errorf("error syncing secret: %v", err)
-	if c.queue.NumRequeues(key) < maxRetries {
+	if c.queue.NumRequeues(key) < c.retry.MaxRetries {
 		logger.Errorf("retrying secret")
 		c.queue.AddRateLimited(key)
 		return
@@ -157,19 +606,64 @@ func (c *SecretMirror) handleErr(err error, key interface{}) {
 // are reaped when they are past their hard or soft TTLs
 func (c *SecretMirror) reconcile(key string) error {
 	logger := c.logger.WithField("key", key)
-	logger.Infof("reconciling secret")
-	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	logger.Debug("reconciling secret")
+	location, err := splitSourceKey(key)
 	if err != nil {
 		return err
 	}
+	namespace, name := location.Namespace, location.Name
 	logger = logger.WithFields(logrus.Fields{
 		"source-namespace": namespace, "source-secret": name,
 	})
 
-	source, err := c.lister.Secrets(namespace).Get(name)
+	lister := c.lister
+	if location.Cluster != "" {
+		logger = logger.WithField("source-cluster", location.Cluster)
+		remote, configured := c.remoteListers[location.Cluster]
+		if !configured {
+			logger.Warn("not doing work for secret because its cluster is not configured")
+			return nil
+		}
+		lister = remote
+	}
+
+	configuration := c.config()
+	var rules []config.MirrorConfig
+	for _, mirrorConfig := range c.rules.from(configuration, location) {
+		// the operator fetches the sources of ExternalSecrets itself
+		if mirrorConfig.Output == config.OutputExternalSecret {
+			continue
+		}
+		rules = append(rules, c.fanOut(mirrorConfig)...)
+	}
+	if len(rules) == 0 {
+		logger.Debug("not doing work for secret because no rule mirrors from it")
+		noopSyncs.WithLabelValues(noopReasonUnmatched).Inc()
+		return nil
+	}
+	// rules merging several sources are reconciled from their first source
+	var ownRules []config.MirrorConfig
+	for _, mirrorConfig := range rules {
+		if !mirrorConfig.From.Equals(location) {
+			c.enqueueKey(mirrorConfig.From.String())
+			continue
+		}
+		ownRules = append(ownRules, mirrorConfig)
+	}
+	if rules = ownRules; len(rules) == 0 {
+		logger.Debug("not doing work for secret because it is only merged into the targets of rules")
+		return nil
+	}
+
+	var source *coreapi.Secret
+	if isPolled(rules) {
+		source, err = c.polled.get(location)
+	} else {
+		source, err = lister.Secrets(namespace).Get(name)
+	}
 	if errors.IsNotFound(err) {
 		logger.Info("not doing work for secret because it has been deleted")
-		return nil
+		return c.propagateDeletion(configuration, rules, logger)
 	}
 	if err != nil {
 		logger.WithError(err).Errorf("unable to retrieve secret from store")
@@ -179,56 +673,424 @@ func (c *SecretMirror) reconcile(key string) error {
 		logger.Info("not doing work for secret because it is being deleted")
 		return nil
 	}
+	if optedOut(source) {
+		logger.Warnf("not doing work for secret because it is annotated with %s: %s", sourceOptOutAnnotation, sourceOptOutValue)
+		return nil
+	}
 
-	var mirrorErrors []error
-	for _, mirrorConfig := range c.config().Secrets {
-		if mirrorConfig.From.Namespace == namespace && mirrorConfig.From.Name == name {
-			if err := c.mirrorSecret(source, mirrorConfig.To, logger); err != nil {
-				mirrorErrors = append(mirrorErrors, err)
+	c.quarantine.sync(configuration)
+	c.collisions.sync(configuration)
+	c.inventory.sync(configuration)
+	metricLabels.sync(configuration)
+	for _, mirrorConfig := range rules {
+		metricLabels.label(configuration, mirrorConfig)
+	}
+
+	var mirrorErrors MirrorErrors
+	for _, mirrorConfig := range rules {
+		rule := mirrorConfig.String()
+		merged, err := c.mergedSource(source, mirrorConfig)
+		if err != nil {
+			c.recordResult(source, mirrorConfig, err)
+			mirrorErrors = append(mirrorErrors, &MirrorError{Rule: rule, Target: mirrorConfig.To.String(), Err: err})
+			continue
+		}
+		if c.reportOnly {
+			if err := c.reportDrift(merged, rule, configuration.Resolve(mirrorConfig), logger); err != nil {
+				mirrorErrors = append(mirrorErrors, &MirrorError{Rule: rule, Target: mirrorConfig.To.String(), Err: err})
+			}
+			continue
+		}
+		if c.freeze.isFrozen() {
+			if err := c.holdChange(merged, rule, configuration.Resolve(mirrorConfig), logger); err != nil {
+				mirrorErrors = append(mirrorErrors, &MirrorError{Rule: rule, Target: mirrorConfig.To.String(), Err: err})
+			}
+			continue
+		}
+		if c.pauses.isPaused(rule) {
+			logger.WithField("rule", rule).Info("not mirroring secret because the rule is paused")
+			continue
+		}
+		if c.quarantine.isQuarantined(rule) {
+			logger.WithField("rule", rule).Warn("not mirroring secret because the rule is quarantined")
+			continue
+		}
+		err = c.mirrorSecret(merged, configuration.Resolve(mirrorConfig), logger)
+		c.recordResult(source, mirrorConfig, err)
+		if err != nil {
+			mirrorErrors = append(mirrorErrors, &MirrorError{Rule: rule, Target: mirrorConfig.To.String(), Err: err})
+			if c.quarantine.recordFailure(rule) {
+				logger.WithField("rule", rule).WithError(err).Errorf("rule failed %d consecutive times, quarantining it until the configuration is reloaded or it is resumed", c.quarantine.threshold)
+				c.notifyQuarantined(configuration, mirrorConfig, err)
 			}
+			continue
 		}
+		c.quarantine.recordSuccess(rule)
 	}
 
-	logger.Info("finished handling secret")
+	logger.Debug("finished handling secret")
 	if len(mirrorErrors) > 0 {
-		return fmt.Errorf("failed to mirror secret: %v", mirrorErrors)
+		return mirrorErrors
 	}
 	return nil
 }
 
-func (c *SecretMirror) mirrorSecret(source *coreapi.Secret, to config.SecretLocation, logger *logrus.Entry) error {
+// notifyQuarantined lets the owners of a rule know that it was quarantined.
+// Delivery happens in the background so that workers are not held up.
+func (c *SecretMirror) notifyQuarantined(configuration *config.Configuration, mirrorConfig config.MirrorConfig, cause error) {
+	routes := configuration.NotificationsFor(mirrorConfig)
+	if c.notifier == nil || routes == nil {
+		return
+	}
+	notification := notify.Notification{
+		Rule:    mirrorConfig.String(),
+		Subject: fmt.Sprintf("Secret mirroring rule %s is quarantined", mirrorConfig.String()),
+		Message: fmt.Sprintf("The rule failed %d consecutive times and will not be retried until the configuration is reloaded or it is resumed. Last error: %v", c.quarantine.threshold, cause),
+	}
+	go func() {
+		if err := c.notifier.Notify(*routes, notification); err != nil {
+			c.logger.WithField("rule", notification.Rule).WithError(err).Error("failed to send failure notification")
+		}
+	}()
+}
+
+// mirroredData determines the data the rule mirrors from the source data
+// and the type of the target, if the rule determines it.
+func (c *SecretMirror) mirroredData(source map[string][]byte, mirrorConfig config.MirrorConfig) (map[string][]byte, coreapi.SecretType, error) {
+	stripped, err := strippedData(filteredData(source, mirrorConfig), mirrorConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	converted, targetType, err := convertData(stripped, mirrorConfig.Conversion)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to convert source data: %v", err)
+	}
+	transformed, err := c.transformData(converted, mirrorConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to transform source data: %v", err)
+	}
+	return applicableData(prefixedData(normalizedData(transformed, mirrorConfig), mirrorConfig), mirrorConfig), targetType, nil
+}
+
+func (c *SecretMirror) mirrorSecret(source *coreapi.Secret, mirrorConfig config.MirrorConfig, logger *logrus.Entry) error {
+	to := mirrorConfig.To
 	logger = logger.WithFields(logrus.Fields{
 		"target-namespace": to.Namespace, "target-secret": to.Name},
 	)
-	logger.Info("processing mirror request")
+	logger.Debug("processing mirror request")
+	if to.Provider() != "" {
+		return c.pushSecret(source, mirrorConfig, logger)
+	}
+
+	if pattern, protected := c.protectedPattern(to); protected {
+		return fmt.Errorf("refusing to write target secret as its name matches the protected pattern %q", pattern)
+	}
+	if !c.config().Policy.AllowsNamespace(to.Namespace) {
+		return fmt.Errorf("refusing to write target secret as the policy does not allow namespace %s", to.Namespace)
+	}
+	if loop, found := c.mirrorLoop(source, mirrorConfig); found {
+		return fmt.Errorf("refusing to write target secret as the controller wrote its source from it through [%s], which would mirror the data around forever", strings.Join(loop, " -> "))
+	}
 
 	if len(source.Data) == 0 {
 		logger.Info("not updating target secret as source has no data")
 		return nil
 	}
+	mirrorConfig = withSourceMetadata(source, mirrorConfig)
 
-	if secret, getErr := c.lister.Secrets(to.Namespace).Get(to.Name); getErr == nil {
-		if reflect.DeepEqual(secret.Data, source.Data) {
-			logger.Info("not updating target secret as it already matches the source")
+	sourceData, convertedType, err := c.mirroredData(source.Data, mirrorConfig)
+	if err != nil {
+		return err
+	}
+	targetType, enforceType := desiredType(source, convertedType, mirrorConfig)
+	if len(sourceData) == 0 {
+		logger.Info("not updating target secret as the rule ignores all of the source data")
+		return nil
+	}
+
+	if err := validateData(sourceData, mirrorConfig); err != nil {
+		return fmt.Errorf("not updating target secret: %v", err)
+	}
+	c.expiries.observe(to.String(), sourceData, logger)
+	if err := c.writeFiles(sourceData, mirrorConfig, logger); err != nil {
+		return err
+	}
+	if mirrorConfig.Files != nil && mirrorConfig.Files.SkipTarget {
+		logger.Debug("not updating target secret as the rule only writes files")
+		return nil
+	}
+	if other, halted, detected := c.collisions.record(mirrorConfig, sourceData); halted {
+		if detected {
+			logger.WithField("colliding-rule", other).Error("rules write conflicting data to the same target, halting both")
+			c.recorder.Eventf(source, coreapi.EventTypeWarning, reasonCollision, "Rules %s and %s write conflicting data to %s, both are halted until the configuration is reloaded", mirrorConfig.String(), other, to.String())
+		}
+		return fmt.Errorf("not updating target secret as the rule collides with rule %s, both are halted until the configuration is reloaded", other)
+	}
+
+	hash := dataHash(sourceData)
+	applied := fingerprint(mirrorConfig, hash)
+	if c.watchesTarget(to) && c.applied.matches(to.String(), applied) {
+		logger.Debug("not updating target secret as neither the source nor the rule changed since it was last applied")
+		return nil
+	}
+	if mirrorConfig.Output == config.OutputSealedSecret {
+		return c.sealSecret(source, sourceData, targetType, mirrorConfig, logger)
+	}
+
+	targets, err := c.targetClient(to)
+	if err != nil {
+		return err
+	}
+	keys := formatKeys(sourceData)
+	if secret, getErr := c.getTarget(to); getErr == nil {
+		if c.heldBack(secret, mirrorConfig) {
+			logger.Warnf("not updating target secret as it is annotated with %s", doNotOverwriteAnnotation)
 			return nil
 		}
-		logger.Info("updating target secret")
-		destination := secret.DeepCopy()
-		destination.Data = source.Data
-		_, updateErr := c.client.CoreV1().Secrets(to.Namespace).Update(destination)
-		return updateErr
+		if !mirrorConfig.AdoptExisting && !managedTarget(secret) {
+			return fmt.Errorf("refusing to overwrite target secret as the controller did not create it, set adoptExisting: true to take it over")
+		}
+		// the data of immutable targets cannot be changed in place
+		recreate := mirrorConfig.ImmutableTarget
+		if targetType != "" && secret.Type != targetType {
+			switch {
+			case mirrorConfig.UpdateStrategy == config.UpdateStrategyRecreate:
+				recreate = true
+			case enforceType:
+				return fmt.Errorf("target secret has type %s but the rule converts to %s, which cannot be changed in place without updateStrategy: %s", secret.Type, targetType, config.UpdateStrategyRecreate)
+			default:
+				logger.Warnf("target secret has type %s unlike its source of type %s, set updateStrategy: %s to recreate it with the type of the source", secret.Type, targetType, config.UpdateStrategyRecreate)
+				targetType = secret.Type
+			}
+		}
+		data := desiredData(sourceData, secret, mirrorConfig)
+		matches := reflect.DeepEqual(secret.Data, data) && secret.Annotations[lastAppliedHashAnnotation] == hash && secret.Annotations[lastAppliedKeysAnnotation] == keys && containsAll(secret.Labels, mirrorConfig.Labels) && containsAll(secret.Annotations, mirrorConfig.Annotations) && stamped(secret, mirrorConfig.Sources())
+		if matches && mirrorConfig.ImmutableTarget && !markedImmutable(secret) {
+			// the target was created, but marking it immutable failed
+			logger.Info("marking target secret immutable")
+			marked, err := c.markImmutable(targets, secret)
+			if err != nil {
+				return err
+			}
+			secret = marked
+		}
+		if matches {
+			logger.Debug("not updating target secret as it already matches the source")
+			c.approvals.settle(mirrorConfig.String())
+			noopSyncs.WithLabelValues(noopReasonUnchanged).Inc()
+			c.inventory.record(mirrorConfig, false)
+			if err := c.propagate(mirrorConfig, hash, false); err != nil {
+				return err
+			}
+			c.applied.record(to.String(), applied, secret.ResourceVersion)
+			return nil
+		}
+		if awaiting, err := c.awaitingApproval(source, mirrorConfig, applied, logger); err != nil || awaiting {
+			return err
+		}
+		destination := updatedTarget(secret, data, targetType, hash, keys, source.ResourceVersion, c.config().Revision, mirrorConfig)
+		var updated *coreapi.Secret
+		// conflicts with other writers, e.g. when the target was replaced
+		// since it was observed, are resolved against the live target here
+		// instead of waiting for the key to be requeued
+		writeErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			var err error
+			updated, recreate, err = c.writeTarget(targets, secret, destination, recreate, mirrorConfig, logger)
+			if !errors.IsConflict(err) {
+				return err
+			}
+			logger.Debug("target secret was changed concurrently, retrying with the live target")
+			live, getErr := targets.CoreV1().Secrets(to.Namespace).Get(to.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return fmt.Errorf("failed to get target secret after a conflict: %v", getErr)
+			}
+			if !mirrorConfig.AdoptExisting && !managedTarget(live) {
+				return fmt.Errorf("refusing to overwrite target secret as it was replaced by one the controller did not create, set adoptExisting: true to take it over")
+			}
+			secret = live
+			destination = updatedTarget(live, desiredData(sourceData, live, mirrorConfig), targetType, hash, keys, source.ResourceVersion, c.config().Revision, mirrorConfig)
+			return err
+		})
+		if writeErr != nil {
+			return writeErr
+		}
+		if recreate && mirrorConfig.ImmutableTarget {
+			var markErr error
+			if updated, markErr = c.markImmutable(targets, updated); markErr != nil {
+				return markErr
+			}
+		}
+		c.inventory.record(mirrorConfig, true)
+		c.approvals.settle(mirrorConfig.String())
+		c.recordWrite(source, updated, mirrorConfig, reasonUpdated, hash)
+		c.annotateSource(source, mirrorConfig, hash, logger)
+		if err := c.propagate(mirrorConfig, hash, true); err != nil {
+			return err
+		}
+		c.applied.record(to.String(), applied, updated.ResourceVersion)
+		return nil
 	} else if errors.IsNotFound(getErr) {
+		heldTargets.delete(to.String())
+		if awaiting, err := c.awaitingApproval(source, mirrorConfig, applied, logger); err != nil || awaiting {
+			return err
+		}
 		logger.Info("creating target secret")
 		destination := &coreapi.Secret{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      to.Name,
-				Namespace: to.Namespace,
+				Name:        to.Name,
+				Namespace:   to.Namespace,
+				Labels:      withEntries(nil, mirrorConfig.Labels),
+				Annotations: withEntries(mirrorConfig.Annotations, map[string]string{lastAppliedHashAnnotation: hash, lastAppliedKeysAnnotation: keys}),
 			},
-			Data: source.Data,
+			Type: targetType,
+			Data: sourceData,
 		}
-		_, createErr := c.client.CoreV1().Secrets(to.Namespace).Create(destination)
-		return createErr
+		stamp(destination, mirrorConfig.Sources(), source.ResourceVersion, c.config().Revision, time.Now())
+		created, createErr := targets.CoreV1().Secrets(to.Namespace).Create(destination)
+		if createErr != nil {
+			return createErr
+		}
+		if mirrorConfig.ImmutableTarget {
+			if created, createErr = c.markImmutable(targets, created); createErr != nil {
+				return createErr
+			}
+		}
+		c.inventory.record(mirrorConfig, true)
+		c.approvals.settle(mirrorConfig.String())
+		c.recordWrite(source, created, mirrorConfig, reasonCreated, hash)
+		c.annotateSource(source, mirrorConfig, hash, logger)
+		if err := c.propagate(mirrorConfig, hash, true); err != nil {
+			return err
+		}
+		c.applied.record(to.String(), applied, created.ResourceVersion)
+		return nil
 	} else {
 		return getErr
 	}
 }
+
+// writeTarget writes the destination over the target, patching it in
+// place unless it has to be recreated or the server refuses to change it
+// in place and the rule allows recreating it. It returns the target as
+// written and whether it was recreated.
+func (c *SecretMirror) writeTarget(targets kubeclientset.Interface, secret, destination *coreapi.Secret, recreate bool, mirrorConfig config.MirrorConfig, logger *logrus.Entry) (*coreapi.Secret, bool, error) {
+	if !recreate {
+		logger.Info("updating target secret")
+		updated, err := patchTarget(targets, secret, destination)
+		if err == nil {
+			return updated, false, nil
+		}
+		if !errors.IsInvalid(err) || mirrorConfig.UpdateStrategy != config.UpdateStrategyRecreate {
+			return nil, false, err
+		}
+		logger.WithError(err).Info("target secret cannot be updated in place")
+	}
+	logger.Info("recreating target secret")
+	updated, err := c.recreate(targets, secret, destination, mirrorConfig)
+	return updated, true, err
+}
+
+// updatedTarget builds the target from the existing secret, carrying the
+// data mirrored into it along with the metadata the rule sets.
+func updatedTarget(secret *coreapi.Secret, data map[string][]byte, targetType coreapi.SecretType, hash, keys, sourceVersion, revision string, mirrorConfig config.MirrorConfig) *coreapi.Secret {
+	destination := secret.DeepCopy()
+	destination.Data = data
+	if targetType != "" {
+		destination.Type = targetType
+	}
+	destination.Annotations = withEntries(destination.Annotations, mirrorConfig.Annotations)
+	if destination.Annotations == nil {
+		destination.Annotations = map[string]string{}
+	}
+	destination.Annotations[lastAppliedHashAnnotation] = hash
+	destination.Annotations[lastAppliedKeysAnnotation] = keys
+	destination.Labels = withEntries(destination.Labels, mirrorConfig.Labels)
+	stamp(destination, mirrorConfig.Sources(), sourceVersion, revision, time.Now())
+	return destination
+}
+
+// patchTarget writes only what changed between the observed target and
+// the destination, as a strategic merge patch. Patches do not carry the
+// resource version, so they do not conflict with writes to other keys or
+// metadata of the target since it was observed, which are kept, while
+// every key that the patch touches is replaced and every key that the
+// destination no longer has is removed.
+func patchTarget(targets kubeclientset.Interface, target, destination *coreapi.Secret) (*coreapi.Secret, error) {
+	original, err := json.Marshal(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode target secret: %v", err)
+	}
+	modified, err := json.Marshal(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode updated target secret: %v", err)
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(original, modified, coreapi.Secret{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute patch for target secret: %v", err)
+	}
+	return targets.CoreV1().Secrets(target.Namespace).Patch(target.Name, types.StrategicMergePatchType, patch)
+}
+
+// propagate lets consumers of the target know about its data. Finding the
+// workloads that consume the target lists every workload in its namespace,
+// so they are only rolled out once the target was written, or again if
+// that failed before.
+func (c *SecretMirror) propagate(mirrorConfig config.MirrorConfig, hash string, wrote bool) error {
+	if wrote || c.pendingRollouts.pending(mirrorConfig.To.String()) {
+		if err := c.rollOut(mirrorConfig, hash); err != nil {
+			return err
+		}
+	}
+	return c.publishVersion(mirrorConfig.To, hash)
+}
+
+// recreate replaces the target with the destination by deleting and then
+// creating it, for changes that the server refuses to make in place. The
+// deletion is conditional on the target not having been replaced since we
+// observed it.
+func (c *SecretMirror) recreate(targets kubeclientset.Interface, target, destination *coreapi.Secret, mirrorConfig config.MirrorConfig) (*coreapi.Secret, error) {
+	options := &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &target.UID}}
+	if err := targets.CoreV1().Secrets(target.Namespace).Delete(target.Name, options); err != nil && !errors.IsNotFound(err) {
+		if errors.IsConflict(err) {
+			// the target was replaced since we observed it
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to delete target secret: %v", err)
+	}
+	c.recordAudit(auditlog.Entry{Operation: auditlog.OperationDelete, Rule: mirrorConfig.String(), Source: formatSources(mirrorConfig.Sources()), Target: mirrorConfig.To.String(), Hash: c.auditHash(target.Annotations[lastAppliedHashAnnotation])})
+	replacement := destination.DeepCopy()
+	replacement.ResourceVersion = ""
+	replacement.UID = ""
+	replacement.CreationTimestamp = metav1.Time{}
+	// the replacement is only immutable once it is marked again
+	delete(replacement.Annotations, immutableAnnotation)
+	created, err := targets.CoreV1().Secrets(target.Namespace).Create(replacement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create target secret after deleting it: %v", err)
+	}
+	return created, nil
+}
+
+// immutableAnnotation records that a target was marked immutable. The
+// field is newer than the API types we build against, so it cannot be
+// read back from the target; the annotation is set in the same patch,
+// so that it is only present once the patch was applied.
+const immutableAnnotation = "ci.openshift.io/mirror-immutable"
+
+// immutablePatch marks a secret immutable. The field is set with a patch
+// after the target is created; servers that do not know the field ignore it.
+var immutablePatch = []byte(fmt.Sprintf(`{"immutable":true,"metadata":{"annotations":{%q:"true"}}}`, immutableAnnotation))
+
+// markedImmutable determines if the target was marked immutable.
+func markedImmutable(target *coreapi.Secret) bool {
+	return target.Annotations[immutableAnnotation] == "true"
+}
+
+// markImmutable protects a freshly created target from in-place edits.
+func (c *SecretMirror) markImmutable(targets kubeclientset.Interface, target *coreapi.Secret) (*coreapi.Secret, error) {
+	patched, err := targets.CoreV1().Secrets(target.Namespace).Patch(target.Name, types.MergePatchType, immutablePatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark target secret immutable: %v", err)
+	}
+	return patched, nil
+}
@@ -1,20 +1,28 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	testclient "k8s.io/client-go/kubernetes/fake"
 	clientgo_testing "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/auditlog"
 	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
 )
 
@@ -68,7 +76,7 @@ func TestMirrorSecret(t *testing.T) {
 		<-ctx.Done()
 		ca := &config.Agent{}
 		ca.Set(&configuration)
-		c := NewSecretMirror(informer, client, ca.Config)
+		c := NewSecretMirror(informer, client, ca.Config, Options{})
 		if tc.shouldErr {
 			client.Fake.PrependReactor(
 				"create", "secrets",
@@ -88,3 +96,432 @@ func TestMirrorSecret(t *testing.T) {
 		}
 	}
 }
+
+func TestEnqueueShedsWhenSaturated(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{})
+	c := NewSecretMirror(informer, client, ca.Config, Options{MaxQueueDepth: 2})
+	defer c.queue.ShutDown()
+
+	shed := func() float64 {
+		var metric dto.Metric
+		if err := queueShedKeys.Write(&metric); err != nil {
+			t.Fatal(err)
+		}
+		return metric.GetCounter().GetValue()
+	}
+	before := shed()
+	for _, name := range []string{"first", "second", "second", "third", "first"} {
+		c.enqueue(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: name}})
+	}
+	if depth := c.queue.Len(); depth != 2 {
+		t.Errorf("expected the queue to hold 2 keys, got %d", depth)
+	}
+	if actual := shed() - before; actual != 1 {
+		t.Errorf("expected only the new key to be shed, got %v shed keys", actual)
+	}
+}
+
+func TestInitialReconciliationWaitsForShedKeys(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+	var secrets []config.MirrorConfig
+	for _, name := range []string{"first", "second", "third"} {
+		secrets = append(secrets, config.MirrorConfig{
+			From: config.SecretLocation{Namespace: "test-ns", Name: name},
+			To:   config.SecretLocation{Namespace: "other-ns", Name: name},
+		})
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: secrets})
+	c := NewSecretMirror(informer, client, ca.Config, Options{MaxQueueDepth: 2})
+	defer c.queue.ShutDown()
+
+	c.ready.start(c.sourceKeys())
+	c.enqueueAll()
+	if depth := c.queue.Len(); depth != 2 {
+		t.Fatalf("expected the queue to hold 2 keys, got %d", depth)
+	}
+	for i := 0; i < 2; i++ {
+		if reason := c.ready.ready(); reason == "" {
+			t.Fatalf("expected not to be ready while %d sources await their initial reconciliation", 3-i)
+		}
+		c.processNextWorkItem()
+	}
+	if depth := c.queue.Len(); depth != 1 {
+		t.Fatalf("expected the shed source to be enqueued again once the queue had room, got %d keys", depth)
+	}
+	c.processNextWorkItem()
+	if reason := c.ready.ready(); reason != "" {
+		t.Errorf("expected to be ready once every source was reconciled, got %q", reason)
+	}
+}
+
+func TestUpdateStrategy(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		target          v1.Secret
+		conversion      *config.Conversion
+		strategy        string
+		immutable       bool
+		rejectUpdates   bool
+		expectedErr     bool
+		expectedDeleted bool
+	}{
+		{
+			name:   "targets are updated in place",
+			target: v1.Secret{Data: map[string][]byte{"key": []byte("old")}},
+		},
+		{
+			name:        "changing the type fails without recreating",
+			target:      v1.Secret{Type: v1.SecretTypeOpaque, Data: map[string][]byte{"key": []byte("old")}},
+			conversion:  &config.Conversion{Type: config.ConversionDockerConfigJSON},
+			expectedErr: true,
+		},
+		{
+			name:            "changing the type recreates the target",
+			target:          v1.Secret{Type: v1.SecretTypeOpaque, Data: map[string][]byte{"key": []byte("old")}},
+			conversion:      &config.Conversion{Type: config.ConversionDockerConfigJSON},
+			strategy:        config.UpdateStrategyRecreate,
+			expectedDeleted: true,
+		},
+		{
+			name:          "rejected updates fail without recreating",
+			target:        v1.Secret{Data: map[string][]byte{"key": []byte("old")}},
+			rejectUpdates: true,
+			expectedErr:   true,
+		},
+		{
+			name:            "rejected updates recreate the target",
+			target:          v1.Secret{Data: map[string][]byte{"key": []byte("old")}},
+			strategy:        config.UpdateStrategyRecreate,
+			rejectUpdates:   true,
+			expectedDeleted: true,
+		},
+		{
+			name:            "immutable targets are recreated and marked immutable",
+			target:          v1.Secret{Data: map[string][]byte{"key": []byte("old")}},
+			strategy:        config.UpdateStrategyRecreate,
+			immutable:       true,
+			expectedDeleted: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			target := testCase.target.DeepCopy()
+			target.ObjectMeta = metav1.ObjectMeta{Namespace: "test-ns", Name: "dst", UID: "original"}
+			client := testclient.NewSimpleClientset(target)
+			applyStrategicMergePatches(client)
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+				Data: map[string][]byte{
+					"registry": []byte("quay.io"),
+					"username": []byte("robot"),
+					"password": []byte("hunter2"),
+				},
+			})
+			if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				_, srcErr := informer.Lister().Secrets("test-ns").Get("src")
+				_, dstErr := informer.Lister().Secrets("test-ns").Get("dst")
+				return srcErr == nil && dstErr == nil, nil
+			}); err != nil {
+				t.Fatalf("%s: informer did not observe the secrets: %v", testCase.name, err)
+			}
+			if testCase.rejectUpdates {
+				client.Fake.PrependReactor("patch", "secrets", func(clientgo_testing.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.NewInvalid(v1.SchemeGroupVersion.WithKind("Secret").GroupKind(), "dst", nil)
+				})
+			}
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+				{
+					From:            config.SecretLocation{Namespace: "test-ns", Name: "src"},
+					To:              config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+					Conversion:      testCase.conversion,
+					UpdateStrategy:  testCase.strategy,
+					ImmutableTarget: testCase.immutable,
+					AdoptExisting:   true,
+				},
+			}})
+			var log bytes.Buffer
+			c := NewSecretMirror(informer, client, ca.Config, Options{AuditLog: auditlog.New(&log, "controller", []byte("audit"))})
+			client.ClearActions()
+			err := c.reconcile("test-ns/src")
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			deleted, markedImmutable := false, false
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "delete" {
+					deleted = true
+				}
+				if patch, ok := action.(clientgo_testing.PatchAction); ok && string(patch.GetPatch()) == string(immutablePatch) {
+					markedImmutable = true
+				}
+			}
+			if deleted != testCase.expectedDeleted {
+				t.Errorf("%s: expected deletion to be %t, got %t", testCase.name, testCase.expectedDeleted, deleted)
+			}
+			if audited := strings.Contains(log.String(), `"operation":"delete"`); audited != deleted {
+				t.Errorf("%s: expected the deletion to be audited to be %t, got %t", testCase.name, deleted, audited)
+			}
+			if markedImmutable != testCase.immutable {
+				t.Errorf("%s: expected the target to be marked immutable to be %t, got %t", testCase.name, testCase.immutable, markedImmutable)
+			}
+			if testCase.expectedErr {
+				return
+			}
+			updated, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("%s: expected the target to exist: %v", testCase.name, err)
+			}
+			if _, stale := updated.Data["key"]; stale {
+				t.Errorf("%s: expected the target to be replaced with the source data, got keys %s", testCase.name, formatKeys(updated.Data))
+			}
+			if testCase.conversion != nil && updated.Type != v1.SecretTypeDockerConfigJson {
+				t.Errorf("%s: expected the target to have type %s, got %s", testCase.name, v1.SecretTypeDockerConfigJson, updated.Type)
+			}
+		})
+	}
+}
+
+func TestImmutableTargetsAreOnlyRecreatedOnChange(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	applyStrategicMergePatches(client)
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	markingFails := true
+	client.Fake.PrependReactor("patch", "secrets", func(action clientgo_testing.Action) (bool, runtime.Object, error) {
+		if markingFails && string(action.(clientgo_testing.PatchAction).GetPatch()) == string(immutablePatch) {
+			return true, nil, fmt.Errorf("injected failure")
+		}
+		return false, nil, nil
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:            config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:              config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+			UpdateStrategy:  config.UpdateStrategyRecreate,
+			ImmutableTarget: true,
+		},
+	}})
+	if err := NewSecretMirror(informer, client, ca.Config, Options{}).reconcile("test-ns/src"); err == nil {
+		t.Fatal("expected failing to mark the target immutable to fail the reconcile")
+	}
+
+	// the caches of new controllers are empty, so reconciles compare the target
+	reconcile := func() {
+		target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected the target to exist: %v", err)
+		}
+		if err := informer.Informer().GetIndexer().Update(target); err != nil {
+			t.Fatal(err)
+		}
+		client.ClearActions()
+		if err := NewSecretMirror(informer, client, ca.Config, Options{}).reconcile("test-ns/src"); err != nil {
+			t.Fatalf("failed to reconcile: %v", err)
+		}
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "delete" || action.GetVerb() == "create" {
+				t.Fatalf("expected the unchanged target not to be recreated, got %s", action.GetVerb())
+			}
+		}
+	}
+	markingFails = false
+	reconcile()
+	if target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); err != nil || !markedImmutable(target) {
+		t.Fatalf("expected the target to be marked immutable once marking it succeeds, got %v", err)
+	}
+	reconcile()
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" {
+			t.Errorf("expected the unchanged immutable target not to be written, got a patch")
+		}
+	}
+}
+
+func TestPatchTargetKeepsConcurrentChanges(t *testing.T) {
+	observed := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst", ResourceVersion: "1"},
+		Data:       map[string][]byte{"changed": []byte("old"), "removed": []byte("old"), "concurrent": []byte("old")},
+	}
+	live := observed.DeepCopy()
+	live.ResourceVersion = "2"
+	live.Data["concurrent"] = []byte("new")
+	client := testclient.NewSimpleClientset(live)
+	applyStrategicMergePatches(client)
+
+	destination := observed.DeepCopy()
+	destination.Data = map[string][]byte{"changed": []byte("new"), "concurrent": []byte("old")}
+	patched, err := patchTarget(client, observed, destination)
+	if err != nil {
+		t.Fatalf("failed to patch the target: %v", err)
+	}
+	expected := map[string][]byte{"changed": []byte("new"), "concurrent": []byte("new")}
+	if !reflect.DeepEqual(patched.Data, expected) {
+		t.Errorf("expected only the changed keys to be written, got %v", patched.Data)
+	}
+
+	var patch map[string]interface{}
+	for _, action := range client.Actions() {
+		if action, ok := action.(clientgo_testing.PatchAction); ok {
+			if err := json.Unmarshal(action.GetPatch(), &patch); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, versioned := patch["metadata"]; versioned {
+		t.Errorf("expected the patch not to carry the resource version, got %v", patch)
+	}
+	expectedData := map[string]interface{}{"changed": "bmV3", "removed": nil}
+	if !reflect.DeepEqual(patch["data"], expectedData) {
+		t.Errorf("expected the patch to remove the dropped key, got %v", patch["data"])
+	}
+}
+
+// applyStrategicMergePatches makes the client apply patches to secrets as
+// strategic merge patches, as the API server does for the patches that
+// the controller sends; the fake client decodes the patched secret onto
+// the stored one, so that removed keys are kept.
+func applyStrategicMergePatches(client *testclient.Clientset) {
+	// the object tracker, which the patched secret is read from and
+	// written to without recording more actions
+	chain := append([]clientgo_testing.Reactor{}, client.ReactionChain...)
+	react := func(action clientgo_testing.Action) (runtime.Object, error) {
+		for _, reactor := range chain {
+			if !reactor.Handles(action) {
+				continue
+			}
+			if handled, ret, err := reactor.React(action); handled {
+				return ret, err
+			}
+		}
+		return nil, fmt.Errorf("no reaction to %v", action)
+	}
+	resource := v1.SchemeGroupVersion.WithResource("secrets")
+	client.Fake.PrependReactor("patch", "secrets", func(action clientgo_testing.Action) (bool, runtime.Object, error) {
+		patchAction := action.(clientgo_testing.PatchAction)
+		stored, err := react(clientgo_testing.NewGetAction(resource, action.GetNamespace(), patchAction.GetName()))
+		if err != nil {
+			return true, nil, err
+		}
+		original, err := json.Marshal(stored)
+		if err != nil {
+			return true, nil, err
+		}
+		patched, err := strategicpatch.StrategicMergePatch(original, patchAction.GetPatch(), v1.Secret{})
+		if err != nil {
+			return true, nil, err
+		}
+		secret := &v1.Secret{}
+		if err := json.Unmarshal(patched, secret); err != nil {
+			return true, nil, err
+		}
+		updated, err := react(clientgo_testing.NewUpdateAction(resource, action.GetNamespace(), secret))
+		return true, updated, err
+	})
+}
+
+func TestWritesRetryOnConflict(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		verb            string
+		immutable       bool
+		expectedWrites  int
+		expectedDeletes int
+	}{
+		{
+			name:           "conflicting patch is retried",
+			verb:           "patch",
+			expectedWrites: 2,
+		},
+		{
+			name:            "conflicting deletion of a recreated target is retried",
+			verb:            "delete",
+			immutable:       true,
+			expectedDeletes: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset(&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst", UID: "original"},
+				Data:       map[string][]byte{"key": []byte("old")},
+			})
+			applyStrategicMergePatches(client)
+			informer := syncedInformer(t, client, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+				Data:       map[string][]byte{"key": []byte("new")},
+			})
+			if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				_, err := informer.Lister().Secrets("test-ns").Get("dst")
+				return err == nil, nil
+			}); err != nil {
+				t.Fatalf("informer did not observe the target: %v", err)
+			}
+			conflicts := 1
+			client.Fake.PrependReactor(testCase.verb, "secrets", func(clientgo_testing.Action) (bool, runtime.Object, error) {
+				if conflicts == 0 {
+					return false, nil, nil
+				}
+				conflicts--
+				return true, nil, errors.NewConflict(v1.Resource("secrets"), "dst", fmt.Errorf("the object has been modified"))
+			})
+			ca := &config.Agent{}
+			ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+				{
+					From:            config.SecretLocation{Namespace: "test-ns", Name: "src"},
+					To:              config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+					ImmutableTarget: testCase.immutable,
+					AdoptExisting:   true,
+				},
+			}})
+			c := NewSecretMirror(informer, client, ca.Config, Options{})
+			client.ClearActions()
+			if err := c.reconcile("test-ns/src"); err != nil {
+				t.Fatalf("expected the conflict to be retried, got: %v", err)
+			}
+
+			patches, deletes, gets := 0, 0, 0
+			for _, action := range client.Actions() {
+				if action.GetResource().Resource != "secrets" {
+					continue
+				}
+				switch action.GetVerb() {
+				case "patch":
+					if string(action.(clientgo_testing.PatchAction).GetPatch()) != string(immutablePatch) {
+						patches++
+					}
+				case "delete":
+					deletes++
+				case "get":
+					gets++
+				}
+			}
+			if patches != testCase.expectedWrites || deletes != testCase.expectedDeletes || gets != 1 {
+				t.Errorf("expected %d patches and %d deletions after getting the live target once, got %d patches, %d deletions and %d gets", testCase.expectedWrites, testCase.expectedDeletes, patches, deletes, gets)
+			}
+			target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := string(target.Data["key"]); actual != "new" {
+				t.Errorf("expected the target to hold the source data, got %q", actual)
+			}
+			if markedImmutable(target) != testCase.immutable {
+				t.Errorf("expected the target to be marked immutable to be %t, got %t", testCase.immutable, markedImmutable(target))
+			}
+		})
+	}
+}
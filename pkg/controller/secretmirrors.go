@@ -0,0 +1,353 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	mirrorapi "github.com/openshift/ci-secret-mirroring-controller/pkg/api/v1"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
+)
+
+// secretMirrorStatusPeriod is how often the conditions of SecretMirrors are
+// brought up to date with the configuration their rules were merged into.
+const secretMirrorStatusPeriod = 10 * time.Second
+
+// NewSecretMirrorClient returns a client for SecretMirror objects.
+func NewSecretMirrorClient(clusterConfig *rest.Config) (rest.Interface, error) {
+	scheme := runtime.NewScheme()
+	if err := mirrorapi.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	restConfig := *clusterConfig
+	restConfig.GroupVersion = &mirrorapi.SchemeGroupVersion
+	restConfig.APIPath = "/apis"
+	restConfig.ContentType = runtime.ContentTypeJSON
+	restConfig.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
+	return rest.RESTClientFor(&restConfig)
+}
+
+// SecretMirrorRules merges the rules declared by SecretMirror objects into
+// the configuration loaded from the configuration file. Objects whose rules
+// are invalid or conflict with the rest of the configuration are left out,
+// which is reported in their Accepted condition.
+type SecretMirrorRules struct {
+	client   rest.Interface
+	base     config.Getter
+	informer cache.SharedIndexInformer
+	// hasSynced reports whether the objects have been listed
+	hasSynced cache.InformerSynced
+	logger    *logrus.Entry
+
+	lock     sync.Mutex
+	onChange func()
+	// dirty is set when objects changed since the last merge
+	dirty      bool
+	mergedFrom *config.Configuration
+	merged     *config.Configuration
+	outcomes   map[string]secretMirrorOutcome
+}
+
+// secretMirrorOutcome records whether the rules of a generation of an
+// object were merged into the configuration.
+type secretMirrorOutcome struct {
+	generation int64
+	reason     string
+	message    string
+}
+
+// NewSecretMirrorRules watches SecretMirror objects in every namespace and
+// merges their rules into the configuration returned by base.
+func NewSecretMirrorRules(client rest.Interface, base config.Getter) *SecretMirrorRules {
+	r := &SecretMirrorRules{
+		client: client,
+		base:   base,
+		informer: cache.NewSharedIndexInformer(
+			cache.NewListWatchFromClient(client, mirrorapi.Resource, metav1.NamespaceAll, fields.Everything()),
+			&mirrorapi.SecretMirror{}, 0, cache.Indexers{},
+		),
+		logger: logrus.WithField("controller", "secret-mirror-rules"),
+		dirty:  true,
+	}
+	r.hasSynced = r.informer.HasSynced
+	r.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { r.changed() },
+		UpdateFunc: func(old, new interface{}) {
+			// status updates do not change the rules
+			if old.(*mirrorapi.SecretMirror).Generation != new.(*mirrorapi.SecretMirror).Generation {
+				r.changed()
+			}
+		},
+		DeleteFunc: func(interface{}) { r.changed() },
+	})
+	return r
+}
+
+// OnChange registers a function that is called whenever the rules
+// declared by SecretMirror objects may have changed.
+func (r *SecretMirrorRules) OnChange(onChange func()) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onChange = onChange
+}
+
+func (r *SecretMirrorRules) changed() {
+	r.lock.Lock()
+	r.dirty = true
+	onChange := r.onChange
+	r.lock.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// HasSynced returns true once the SecretMirror objects have been listed.
+func (r *SecretMirrorRules) HasSynced() bool {
+	return r.hasSynced()
+}
+
+// Config returns the configuration with the rules of accepted SecretMirror
+// objects merged in. It is a config.Getter; the same configuration is
+// returned until the base configuration or the rules change.
+func (r *SecretMirrorRules) Config() *config.Configuration {
+	base := r.base()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.dirty && base == r.mergedFrom {
+		return r.merged
+	}
+	var mirrors []*mirrorapi.SecretMirror
+	for _, obj := range r.informer.GetStore().List() {
+		mirrors = append(mirrors, obj.(*mirrorapi.SecretMirror))
+	}
+	merged, outcomes := mergeSecretMirrors(base, mirrors)
+	if !reflect.DeepEqual(merged, r.merged) {
+		r.merged = merged
+	}
+	r.mergedFrom, r.outcomes, r.dirty = base, outcomes, false
+	return r.merged
+}
+
+// MergeListedSecretMirrors lists the SecretMirror objects once and returns
+// the configuration with the rules of those that would be accepted merged
+// in, for tools that do not watch them.
+func MergeListedSecretMirrors(client rest.Interface, base *config.Configuration) (*config.Configuration, error) {
+	list := &mirrorapi.SecretMirrorList{}
+	if err := client.Get().Resource(mirrorapi.Resource).Do().Into(list); err != nil {
+		return nil, fmt.Errorf("failed to list SecretMirrors: %v", err)
+	}
+	mirrors := make([]*mirrorapi.SecretMirror, 0, len(list.Items))
+	for i := range list.Items {
+		mirrors = append(mirrors, &list.Items[i])
+	}
+	merged, _ := mergeSecretMirrors(base, mirrors)
+	return merged, nil
+}
+
+// Run watches SecretMirror objects and keeps their status up to date
+// until stopCh is closed.
+func (r *SecretMirrorRules) Run(stopCh <-chan struct{}) {
+	go r.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, r.informer.HasSynced) {
+		r.logger.Error("failed to wait for SecretMirrors to sync")
+		return
+	}
+	wait.Until(r.updateStatuses, secretMirrorStatusPeriod, stopCh)
+}
+
+// updateStatuses records the outcome of the last merge in the
+// status of every object whose status does not reflect it yet.
+func (r *SecretMirrorRules) updateStatuses() {
+	r.Config()
+	r.lock.Lock()
+	outcomes := r.outcomes
+	r.lock.Unlock()
+
+	now := metav1.Now()
+	for _, obj := range r.informer.GetStore().List() {
+		mirror := obj.(*mirrorapi.SecretMirror)
+		outcome, merged := outcomes[mirror.Namespace+"/"+mirror.Name]
+		if !merged || outcome.generation != mirror.Generation {
+			continue
+		}
+		status, changed := secretMirrorStatus(mirror.Status, outcome, now)
+		if !changed {
+			continue
+		}
+		updated := mirror.DeepCopy()
+		updated.Status = status
+		if err := r.client.Put().Namespace(mirror.Namespace).Resource(mirrorapi.Resource).Name(mirror.Name).SubResource("status").Body(updated).Do().Error(); err != nil {
+			r.logger.WithError(err).WithField("secretMirror", mirror.Namespace+"/"+mirror.Name).Warn("failed to update status")
+		}
+	}
+}
+
+// secretMirrorStatus determines the status that reports the outcome and
+// whether it differs from the current status.
+func secretMirrorStatus(current mirrorapi.SecretMirrorStatus, outcome secretMirrorOutcome, now metav1.Time) (mirrorapi.SecretMirrorStatus, bool) {
+	condition := mirrorapi.Condition{
+		Type:               mirrorapi.ConditionAccepted,
+		Status:             coreapi.ConditionTrue,
+		Reason:             outcome.reason,
+		Message:            outcome.message,
+		LastTransitionTime: now,
+	}
+	if outcome.reason != mirrorapi.ReasonAccepted {
+		condition.Status = coreapi.ConditionFalse
+	}
+
+	status := mirrorapi.SecretMirrorStatus{ObservedGeneration: outcome.generation}
+	changed := current.ObservedGeneration != outcome.generation
+	found := false
+	for _, existing := range current.Conditions {
+		if existing.Type != mirrorapi.ConditionAccepted {
+			status.Conditions = append(status.Conditions, existing)
+			continue
+		}
+		found = true
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		if existing.Status != condition.Status || existing.Reason != condition.Reason || existing.Message != condition.Message {
+			changed = true
+		}
+	}
+	status.Conditions = append(status.Conditions, condition)
+	return status, changed || !found
+}
+
+// mergeSecretMirrors appends the rules of the objects to a copy of the base
+// configuration in order of their namespace and name, so that the outcome
+// of a conflict between objects does not depend on the order they were
+// observed in.
+func mergeSecretMirrors(base *config.Configuration, mirrors []*mirrorapi.SecretMirror) (*config.Configuration, map[string]secretMirrorOutcome) {
+	merged := &config.Configuration{}
+	if base != nil {
+		*merged = *base
+	}
+	// taking over existing targets by default is left to the rules of the
+	// configuration file, objects may only write to targets that do not
+	// exist yet or that the controller manages
+	if merged.Defaults.AdoptExisting {
+		merged.Secrets = append([]config.MirrorConfig{}, merged.Secrets...)
+		for i := range merged.Secrets {
+			merged.Secrets[i].AdoptExisting = true
+		}
+		merged.Defaults.AdoptExisting = false
+	}
+	sort.Slice(mirrors, func(i, j int) bool {
+		if mirrors[i].Namespace != mirrors[j].Namespace {
+			return mirrors[i].Namespace < mirrors[j].Namespace
+		}
+		return mirrors[i].Name < mirrors[j].Name
+	})
+
+	outcomes := map[string]secretMirrorOutcome{}
+	for _, mirror := range mirrors {
+		outcome := secretMirrorOutcome{generation: mirror.Generation, reason: mirrorapi.ReasonAccepted}
+		rules, problems := secretMirrorRules(mirror, merged.Policy)
+		if len(problems) == 0 {
+			if err := (&config.Configuration{Secrets: rules}).Validate(); err != nil {
+				problems = append(problems, strings.TrimSpace(err.Error()))
+			}
+		}
+		if len(problems) > 0 {
+			outcome.reason, outcome.message = mirrorapi.ReasonInvalid, strings.Join(problems, "\n")
+		} else {
+			candidate := *merged
+			candidate.Secrets = append(append([]config.MirrorConfig{}, merged.Secrets...), rules...)
+			if err := candidate.Validate(); err != nil {
+				outcome.reason, outcome.message = mirrorapi.ReasonConflict, strings.TrimSpace(err.Error())
+			} else {
+				merged = &candidate
+			}
+		}
+		outcomes[mirror.Namespace+"/"+mirror.Name] = outcome
+	}
+	return merged, outcomes
+}
+
+// secretMirrorRules returns the rules of the object with the namespace of
+// their source defaulted, or the reasons they cannot be accepted. Owners of
+// a namespace may only mirror from it and may not reach outside of the
+// object, e.g. through groups, onto or from the filesystem of the
+// controller, into Vault, where targets are not scoped by namespace, or
+// through an ExternalSecret, which fetches from a store by name. They may
+// only write to the remote clusters that the policy allows, and may not
+// take over targets that the controller did not create.
+func secretMirrorRules(mirror *mirrorapi.SecretMirror, policy *config.Policy) ([]config.MirrorConfig, []string) {
+	if len(mirror.Spec.Secrets) == 0 {
+		return nil, []string{"spec.secrets: must not be empty"}
+	}
+	var problems []string
+	rules := mirror.DeepCopy().Spec.Secrets
+	for i := range rules {
+		rule, parent := &rules[i], fmt.Sprintf("spec.secrets[%d]", i)
+		if rule.From.Namespace == "" {
+			rule.From.Namespace = mirror.Namespace
+		}
+		if rule.From.Namespace != mirror.Namespace {
+			problems = append(problems, fmt.Sprintf("%s.from.namespace: must be the namespace of the SecretMirror, %s", parent, mirror.Namespace))
+		}
+		if rule.From.Cluster != "" {
+			problems = append(problems, fmt.Sprintf("%s.from.cluster: remote sources are not supported", parent))
+		}
+		if rule.To.Cluster != "" && !policy.AllowsSecretMirrorCluster(rule.To.Cluster) {
+			problems = append(problems, fmt.Sprintf("%s.to.cluster: %s is not allowed by the policy", parent, rule.To.Cluster))
+		}
+		if rule.To.Vault.Path != "" {
+			problems = append(problems, fmt.Sprintf("%s.to.vault: targets in Vault are not supported", parent))
+		}
+		if rule.Output == config.OutputExternalSecret {
+			problems = append(problems, fmt.Sprintf("%s.output: %s is not supported", parent, config.OutputExternalSecret))
+		}
+		for j := range rule.MergeFrom {
+			merged, field := &rule.MergeFrom[j], fmt.Sprintf("%s.mergeFrom[%d]", parent, j)
+			if merged.Namespace == "" {
+				merged.Namespace = mirror.Namespace
+			}
+			if merged.Namespace != mirror.Namespace {
+				problems = append(problems, fmt.Sprintf("%s.namespace: must be the namespace of the SecretMirror, %s", field, mirror.Namespace))
+			}
+			if merged.Cluster != "" {
+				problems = append(problems, fmt.Sprintf("%s.cluster: remote sources are not supported", field))
+			}
+		}
+		if rule.FromGroup != "" || rule.ToGroup != "" || rule.IgnoreTargetKeysGroup != "" {
+			problems = append(problems, fmt.Sprintf("%s: groups are not supported", parent))
+		}
+		if rule.AdoptExisting {
+			problems = append(problems, fmt.Sprintf("%s.adoptExisting: is not supported", parent))
+		}
+		if rule.SuffixSourceNamespace {
+			problems = append(problems, fmt.Sprintf("%s.suffixSourceNamespace: is not supported", parent))
+		}
+		if rule.Files != nil {
+			problems = append(problems, fmt.Sprintf("%s.files: is not supported", parent))
+		}
+		for j, t := range rule.Transforms {
+			if t.Name != transform.WebhookName {
+				continue
+			}
+			for _, field := range transform.WebhookFiles(t.Config) {
+				problems = append(problems, fmt.Sprintf("%s.transforms[%d].config.%s: files of the controller are not supported", parent, j, field))
+			}
+		}
+	}
+	return rules, problems
+}
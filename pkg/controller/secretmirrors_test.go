@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mirrorapi "github.com/openshift/ci-secret-mirroring-controller/pkg/api/v1"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func secretMirrorObject(namespace, name string, rules ...config.MirrorConfig) *mirrorapi.SecretMirror {
+	return &mirrorapi.SecretMirror{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Generation: 1},
+		Spec:       mirrorapi.SecretMirrorSpec{Secrets: rules},
+	}
+}
+
+func TestMergeSecretMirrors(t *testing.T) {
+	base := &config.Configuration{
+		Secrets: []config.MirrorConfig{
+			{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}},
+		},
+		Policy: &config.Policy{SecretMirrorClusters: []string{"build01"}},
+	}
+	var testCases = []struct {
+		name     string
+		mirrors  []*mirrorapi.SecretMirror
+		rules    int
+		expected map[string]string
+	}{
+		{
+			name: "source namespace defaults to the namespace of the object",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}}),
+			},
+			rules:    2,
+			expected: map[string]string{"team/share": mirrorapi.ReasonAccepted},
+		},
+		{
+			name: "mirroring from another namespace is invalid",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "team", Name: "stolen"}}),
+			},
+			rules:    1,
+			expected: map[string]string{"team/share": mirrorapi.ReasonInvalid},
+		},
+		{
+			name: "writing to Vault is invalid",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Vault: config.VaultLocation{Path: "secret/ci/token"}}}),
+			},
+			rules:    1,
+			expected: map[string]string{"team/share": mirrorapi.ReasonInvalid},
+		},
+		{
+			name: "writing an ExternalSecret is invalid",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}, Output: config.OutputExternalSecret, ExternalSecret: &config.ExternalSecretConfig{Store: "cluster"}}),
+			},
+			rules:    1,
+			expected: map[string]string{"team/share": mirrorapi.ReasonInvalid},
+		},
+		{
+			name: "writing to a remote cluster the policy does not allow is invalid",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Cluster: "build02", Namespace: "test-ns", Name: "token"}}),
+			},
+			rules:    1,
+			expected: map[string]string{"team/share": mirrorapi.ReasonInvalid},
+		},
+		{
+			name: "writing to a remote cluster the policy allows is accepted",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Cluster: "build01", Namespace: "test-ns", Name: "token"}}),
+			},
+			rules:    2,
+			expected: map[string]string{"team/share": mirrorapi.ReasonAccepted},
+		},
+		{
+			name: "adopting existing targets is invalid",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}, AdoptExisting: true}),
+			},
+			rules:    1,
+			expected: map[string]string{"team/share": mirrorapi.ReasonInvalid},
+		},
+		{
+			name:     "object without rules is invalid",
+			mirrors:  []*mirrorapi.SecretMirror{secretMirrorObject("team", "share")},
+			rules:    1,
+			expected: map[string]string{"team/share": mirrorapi.ReasonInvalid},
+		},
+		{
+			name: "writing to a target of the configuration file conflicts",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}}),
+			},
+			rules:    1,
+			expected: map[string]string{"team/share": mirrorapi.ReasonConflict},
+		},
+		{
+			name: "the first object in order of namespace and name wins a conflict",
+			mirrors: []*mirrorapi.SecretMirror{
+				secretMirrorObject("b", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}}),
+				secretMirrorObject("a", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}}),
+			},
+			rules:    2,
+			expected: map[string]string{"a/share": mirrorapi.ReasonAccepted, "b/share": mirrorapi.ReasonConflict},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			merged, outcomes := mergeSecretMirrors(base, testCase.mirrors)
+			if len(merged.Secrets) != testCase.rules {
+				t.Errorf("expected %d rules, got %d", testCase.rules, len(merged.Secrets))
+			}
+			if len(base.Secrets) != 1 {
+				t.Errorf("expected the base configuration to be left alone, got %d rules", len(base.Secrets))
+			}
+			for key, reason := range testCase.expected {
+				if actual := outcomes[key].reason; actual != reason {
+					t.Errorf("expected %s to be %s, got %s: %s", key, reason, actual, outcomes[key].message)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeSecretMirrorsDoNotAdoptByDefault(t *testing.T) {
+	base := &config.Configuration{
+		Secrets: []config.MirrorConfig{
+			{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}},
+		},
+		Defaults: config.Defaults{AdoptExisting: true},
+	}
+	merged, _ := mergeSecretMirrors(base, []*mirrorapi.SecretMirror{
+		secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}}),
+	})
+	if len(merged.Secrets) != 2 {
+		t.Fatalf("expected the rule of the object to be merged, got %d rules", len(merged.Secrets))
+	}
+	if !merged.Resolve(merged.Secrets[0]).AdoptExisting {
+		t.Error("expected the rule of the configuration file to adopt existing targets by default")
+	}
+	if merged.Resolve(merged.Secrets[1]).AdoptExisting {
+		t.Error("expected the rule of the object not to adopt existing targets")
+	}
+	if base.Secrets[0].AdoptExisting || !base.Defaults.AdoptExisting {
+		t.Error("expected the base configuration to be left alone")
+	}
+}
+
+func TestSecretMirrorRulesRejectFilesOfTheController(t *testing.T) {
+	mirror := secretMirrorObject("team", "share", config.MirrorConfig{
+		From: config.SecretLocation{Name: "token"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "token"},
+		Transforms: []config.TransformConfig{
+			{Name: "rename", Config: []byte(`{"keys":{"a":"b"}}`)},
+			{Name: "webhook", Config: []byte(`{"url":"https://example.com","certFile":"/etc/controller/tls.crt","keyFile":"/etc/controller/tls.key"}`)},
+		},
+	})
+	_, problems := secretMirrorRules(mirror, nil)
+	expected := []string{
+		"spec.secrets[0].transforms[1].config.certFile: files of the controller are not supported",
+		"spec.secrets[0].transforms[1].config.keyFile: files of the controller are not supported",
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected the files of the webhook to be rejected, got %v", problems)
+	}
+}
+
+func TestSecretMirrorRulesConfig(t *testing.T) {
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{From: config.SecretLocation{Namespace: "ci", Name: "src"}, To: config.SecretLocation{Namespace: "test-ns", Name: "dst"}},
+	}})
+	r := NewSecretMirrorRules(nil, ca.Config)
+	first := r.Config()
+	if second := r.Config(); second != first {
+		t.Error("expected the same configuration while nothing changed")
+	}
+
+	if err := r.informer.GetStore().Add(secretMirrorObject("team", "share", config.MirrorConfig{From: config.SecretLocation{Name: "token"}, To: config.SecretLocation{Namespace: "test-ns", Name: "token"}})); err != nil {
+		t.Fatal(err)
+	}
+	r.changed()
+	merged := r.Config()
+	if merged == first || len(merged.Secrets) != 2 {
+		t.Errorf("expected a new configuration with the rule of the object merged in, got %v", merged.Secrets)
+	}
+
+	r.changed()
+	if again := r.Config(); again != merged {
+		t.Error("expected the same configuration when the merge did not change it")
+	}
+}
+
+func TestSecretMirrorStatus(t *testing.T) {
+	before := metav1.NewTime(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(before.Add(time.Hour))
+	accepted := mirrorapi.SecretMirrorStatus{
+		ObservedGeneration: 1,
+		Conditions: []mirrorapi.Condition{
+			{Type: mirrorapi.ConditionAccepted, Status: coreapi.ConditionTrue, Reason: mirrorapi.ReasonAccepted, LastTransitionTime: before},
+		},
+	}
+
+	if _, changed := secretMirrorStatus(accepted, secretMirrorOutcome{generation: 1, reason: mirrorapi.ReasonAccepted}, now); changed {
+		t.Error("expected no change when the status reports the outcome")
+	}
+
+	status, changed := secretMirrorStatus(accepted, secretMirrorOutcome{generation: 2, reason: mirrorapi.ReasonAccepted}, now)
+	if !changed || status.ObservedGeneration != 2 {
+		t.Errorf("expected the new generation to be observed, got %d", status.ObservedGeneration)
+	}
+	if actual := status.Conditions[0].LastTransitionTime; !actual.Equal(&before) {
+		t.Errorf("expected the transition time to be kept while the condition holds, got %v", actual)
+	}
+
+	status, changed = secretMirrorStatus(accepted, secretMirrorOutcome{generation: 2, reason: mirrorapi.ReasonConflict, message: "taken"}, now)
+	if !changed || len(status.Conditions) != 1 {
+		t.Fatalf("expected a changed status with one condition, got %v", status.Conditions)
+	}
+	if condition := status.Conditions[0]; condition.Status != coreapi.ConditionFalse || condition.Reason != mirrorapi.ReasonConflict || !strings.Contains(condition.Message, "taken") || !condition.LastTransitionTime.Equal(&now) {
+		t.Errorf("expected the condition to transition to a conflict, got %v", condition)
+	}
+
+	if _, changed := secretMirrorStatus(mirrorapi.SecretMirrorStatus{}, secretMirrorOutcome{reason: mirrorapi.ReasonAccepted}, now); !changed {
+		t.Error("expected a status without conditions to change")
+	}
+}
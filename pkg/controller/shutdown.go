@@ -0,0 +1,21 @@
+package controller
+
+import "sync"
+
+// background runs functions in goroutines and waits for all of them to
+// return on shutdown, so that the work they hold is finished first.
+type background struct {
+	wg sync.WaitGroup
+}
+
+func (b *background) run(f func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		f()
+	}()
+}
+
+func (b *background) wait() {
+	b.wg.Wait()
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	clientgo_testing "k8s.io/client-go/testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRunFinishesReconcilesOnShutdown(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	started, release := make(chan struct{}), make(chan struct{})
+	client.Fake.PrependReactor("create", "secrets", func(clientgo_testing.Action) (bool, runtime.Object, error) {
+		close(started)
+		<-release
+		return false, nil, nil
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		c.Run(ctx, 1)
+		close(stopped)
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the source to be reconciled on start")
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("expected Run to wait for the source being reconciled")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Run to return once the source was reconciled")
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the reconciliation in flight to finish: %v", err)
+	}
+}
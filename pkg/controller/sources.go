@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"strings"
+
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// splitSourceKey parses a work queue key, formatted like the source
+// config.SecretLocation it identifies. Cluster names cannot contain
+// a colon and neither can namespaces, so the first one separates the
+// cluster from the namespace and name. Keys of secrets in stores outside
+// of Kubernetes start with the scheme of the store, whose colon is never
+// followed by a namespace.
+func splitSourceKey(key string) (config.SecretLocation, error) {
+	if location, ok := config.ParseProviderLocation(key); ok {
+		return location, nil
+	}
+	var location config.SecretLocation
+	if i := strings.Index(key, ":"); i != -1 {
+		location.Cluster, key = key[:i], key[i+1:]
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return location, err
+	}
+	location.Namespace, location.Name = namespace, name
+	return location, nil
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestSplitSourceKey(t *testing.T) {
+	var testCases = []struct {
+		key         string
+		expected    config.SecretLocation
+		expectedErr bool
+	}{
+		{
+			key:      "ns/name",
+			expected: config.SecretLocation{Namespace: "ns", Name: "name"},
+		},
+		{
+			key:      "remote:ns/name",
+			expected: config.SecretLocation{Cluster: "remote", Namespace: "ns", Name: "name"},
+		},
+		{
+			key:      "vault://secret/ci/registry",
+			expected: config.SecretLocation{Vault: config.VaultLocation{Path: "secret/ci/registry"}},
+		},
+		{
+			key:      "awssecretsmanager://arn:aws:secretsmanager:us-east-1:123456789012:secret:ci/registry",
+			expected: config.SecretLocation{AWSSecretsManager: config.AWSSecretsManagerLocation{ARN: "arn:aws:secretsmanager:us-east-1:123456789012:secret:ci/registry"}},
+		},
+		{
+			key:      "gcpsecretmanager://projects/ci/secrets/registry/versions/3",
+			expected: config.SecretLocation{GCPSecretManager: config.GCPSecretManagerLocation{Name: "projects/ci/secrets/registry", Version: "3"}},
+		},
+		{
+			key:      "azurekeyvault://ci-secrets/registry",
+			expected: config.SecretLocation{AzureKeyVault: config.AzureKeyVaultLocation{Vault: "ci-secrets", Name: "registry"}},
+		},
+		{
+			key:         "remote:ns/name/extra",
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		location, err := splitSourceKey(testCase.key)
+		if (err != nil) != testCase.expectedErr {
+			t.Errorf("%s: expectedErr is %t, got %v", testCase.key, testCase.expectedErr, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(location, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.key, testCase.expected, location)
+		}
+		if err == nil && location.String() != testCase.key {
+			t.Errorf("%s: expected the location to format back to its key, got %s", testCase.key, location.String())
+		}
+	}
+}
+
+// syncedInformer returns a secret informer for the client that has
+// observed the given secret in its cache.
+func syncedInformer(t testing.TB, client *testclient.Clientset, secret *v1.Secret) coreinformers.SecretInformer {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	factory := informers.NewSharedInformerFactory(client, 5*time.Minute)
+	informer := factory.Core().V1().Secrets()
+	informer.Informer().AddEventHandler(&cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { cancel() },
+	})
+	factory.Start(ctx.Done())
+	if _, err := client.CoreV1().Secrets(secret.Namespace).Create(secret); err != nil {
+		t.Fatal(err)
+	}
+	<-ctx.Done()
+	return informer
+}
+
+func TestReconcileRemoteSource(t *testing.T) {
+	remoteClient := testclient.NewSimpleClientset()
+	remote := syncedInformer(t, remoteClient, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("remote")},
+	})
+	client := testclient.NewSimpleClientset()
+	local := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Cluster: "remote", Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(local, client, ca.Config, Options{RemoteClusters: map[string]RemoteCluster{"remote": {Client: remoteClient, Secrets: remote}}})
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile local secret: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected a local secret not to match a rule for a remote source")
+	}
+	if err := c.reconcile("remote:test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile remote secret: %v", err)
+	}
+	dst, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be created: %v", err)
+	}
+	if string(dst.Data["key"]) != "remote" {
+		t.Errorf("expected the target to hold the remote data, got %v", dst.Data)
+	}
+	if err := c.reconcile("unknown:test-ns/src"); err != nil {
+		t.Errorf("expected secrets from unknown clusters to be ignored, got %v", err)
+	}
+}
+
+func TestWritesUseWriteClient(t *testing.T) {
+	readClient := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, readClient, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	writeClient := testclient.NewSimpleClientset()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, readClient, ca.Config, Options{WriteClient: writeClient})
+	readClient.ClearActions()
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if actions := readClient.Actions(); len(actions) != 0 {
+		t.Errorf("expected no requests with the read client, got %v", actions)
+	}
+	if _, err := writeClient.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the target to be written with the write client: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// TargetState describes the target of a rule as last observed by the
+// informer of the controller.
+type TargetState struct {
+	// Exists is false if the target is missing
+	Exists bool `json:"exists"`
+	// Managed is true if the controller created or adopted the target
+	Managed bool `json:"managed,omitempty"`
+	// Held is true if the target is annotated to not be overwritten
+	Held bool `json:"held,omitempty"`
+	// LastWritten is when the controller last wrote to the target
+	LastWritten *time.Time `json:"lastWritten,omitempty"`
+}
+
+// syncTimes records when every rule last mirrored successfully.
+type syncTimes struct {
+	lock  sync.RWMutex
+	times map[string]time.Time
+}
+
+func (s *syncTimes) get(rule string) *time.Time {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	synced, recorded := s.times[rule]
+	if !recorded {
+		return nil
+	}
+	return &synced
+}
+
+func (s *syncTimes) set(rule string, synced time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.times == nil {
+		s.times = map[string]time.Time{}
+	}
+	s.times[rule] = synced
+}
+
+// targetState returns the state of the target from the cache, or nil if
+// the cache does not tell: remote targets and, when only filtered secrets
+// are watched, local ones are read from their API server instead, while
+// targets outside of Kubernetes are read from their store.
+func (c *SecretMirror) targetState(to config.SecretLocation) *TargetState {
+	if to.Cluster != "" || to.Provider() != "" || c.liveTargets {
+		return nil
+	}
+	target, err := c.lister.Secrets(to.Namespace).Get(to.Name)
+	if errors.IsNotFound(err) {
+		return &TargetState{}
+	}
+	if err != nil {
+		return nil
+	}
+	state := &TargetState{
+		Exists:  true,
+		Managed: managedTarget(target),
+		Held:    target.Annotations[doNotOverwriteAnnotation] == "true",
+	}
+	if written, err := time.Parse(time.RFC3339, target.Annotations[lastSyncedAnnotation]); err == nil {
+		state.LastWritten = &written
+	}
+	return state
+}
+
+// MirrorsHandler serves the status of every configured rule as JSON,
+// so that dashboards can show the health of mirroring without access
+// to the cluster. It never returns secret data.
+func (c *SecretMirror) MirrorsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mirrors := c.Rules()
+		if mirrors == nil {
+			mirrors = []RuleStatus{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Mirrors []RuleStatus `json:"mirrors"`
+		}{Mirrors: mirrors}); err != nil {
+			c.logger.WithError(err).Error("failed to write the status of the mirrors")
+		}
+	}
+}
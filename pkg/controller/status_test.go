@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestMirrorsHandler(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("confidential")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "missing"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "other"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Informer().GetIndexer().Add(target); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	c.MirrorsHandler()(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/mirrors", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the status to be served, got %d", recorder.Code)
+	}
+	if strings.Contains(recorder.Body.String(), "confidential") {
+		t.Errorf("expected the status to never hold values, got %s", recorder.Body.String())
+	}
+	var response struct {
+		Mirrors []RuleStatus `json:"mirrors"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Mirrors) != 2 {
+		t.Fatalf("expected the status of both rules, got %+v", response.Mirrors)
+	}
+	synced, missing := response.Mirrors[0], response.Mirrors[1]
+	if synced.LastSynced == nil || synced.Target == nil || !synced.Target.Exists || !synced.Target.Managed || synced.Target.LastWritten == nil {
+		t.Errorf("expected the synced rule to report its sync and its managed target, got %+v", synced)
+	}
+	if missing.LastSynced != nil || missing.Target == nil || missing.Target.Exists {
+		t.Errorf("expected the rule that never synced to report its missing target, got %+v", missing)
+	}
+
+	recorder = httptest.NewRecorder()
+	c.MirrorsHandler()(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/mirrors", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected the API to be read-only, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// SyncHandler triggers an immediate reconciliation on POST, either of the
+// rule named by the `rule` parameter or of every configured rule.
+func (c *SecretMirror) SyncHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rule := r.FormValue("rule")
+		if !c.Sync(rule) {
+			http.Error(w, fmt.Sprintf("rule %s is not configured", rule), http.StatusNotFound)
+			return
+		}
+		c.logger.WithField("rule", rule).Info("sync requested")
+	}
+}
+
+// enqueueAll enqueues the source of every configured rule.
+func (c *SecretMirror) enqueueAll() {
+	for _, key := range c.sourceKeys() {
+		c.enqueueKey(key)
+	}
+}
+
+// sourceKeys returns the keys of the sources of every configured rule.
+func (c *SecretMirror) sourceKeys() []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, mirrorConfig := range c.concreteRules(c.config()) {
+		key := mirrorConfig.From.String()
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestSyncHandler(t *testing.T) {
+	configuration := &config.Configuration{
+		Secrets: []config.MirrorConfig{
+			{
+				From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+				To:   config.SecretLocation{Namespace: "test-ns", Name: "dst-1"},
+			},
+			{
+				From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+				To:   config.SecretLocation{Namespace: "test-ns", Name: "dst-2"},
+			},
+			{
+				From: config.SecretLocation{Namespace: "other-ns", Name: "src"},
+				To:   config.SecretLocation{Namespace: "test-ns", Name: "dst-3"},
+			},
+		},
+	}
+	var testCases = []struct {
+		name           string
+		method, target string
+		expectedStatus int
+		expectedDepth  int
+	}{
+		{
+			name:           "sync of everything enqueues every source once",
+			method:         http.MethodPost,
+			target:         "/sync",
+			expectedStatus: http.StatusOK,
+			expectedDepth:  2,
+		},
+		{
+			name:           "sync of a rule enqueues its source",
+			method:         http.MethodPost,
+			target:         "/sync?rule=(other-ns/src+->+test-ns/dst-3)",
+			expectedStatus: http.StatusOK,
+			expectedDepth:  1,
+		},
+		{
+			name:           "sync of an unknown rule fails",
+			method:         http.MethodPost,
+			target:         "/sync?rule=(other-ns/src+->+test-ns/dst-1)",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "sync must be posted",
+			method:         http.MethodGet,
+			target:         "/sync",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset()
+			informer := informers.NewSharedInformerFactory(client, 5*time.Minute).Core().V1().Secrets()
+			ca := &config.Agent{}
+			ca.Set(configuration)
+			c := NewSecretMirror(informer, client, ca.Config, Options{})
+			defer c.queue.ShutDown()
+
+			recorder := httptest.NewRecorder()
+			c.SyncHandler().ServeHTTP(recorder, httptest.NewRequest(testCase.method, testCase.target, nil))
+			if recorder.Code != testCase.expectedStatus {
+				t.Errorf("%s: expected status %d, got %d", testCase.name, testCase.expectedStatus, recorder.Code)
+			}
+			if depth := c.queue.Len(); depth != testCase.expectedDepth {
+				t.Errorf("%s: expected %d queued keys, got %d", testCase.name, testCase.expectedDepth, depth)
+			}
+		})
+	}
+}
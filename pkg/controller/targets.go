@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"fmt"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// targetClient returns the client that writes to the cluster of the target.
+func (c *SecretMirror) targetClient(to config.SecretLocation) (kubeclientset.Interface, error) {
+	if to.Cluster == "" {
+		return c.writeClient, nil
+	}
+	client, configured := c.targetClients[to.Cluster]
+	if !configured {
+		return nil, fmt.Errorf("target cluster %s is not configured", to.Cluster)
+	}
+	return client, nil
+}
+
+// getTarget reads the target from the cache. Secrets in remote clusters
+// are not watched, so those targets are read from their API server, as
+// are local targets if the cache only holds some secrets. Targets outside
+// of Kubernetes are read from their store.
+func (c *SecretMirror) getTarget(to config.SecretLocation) (*coreapi.Secret, error) {
+	if to.Provider() != "" {
+		return c.providerTarget(to)
+	}
+	if c.watchesTarget(to) {
+		return c.lister.Secrets(to.Namespace).Get(to.Name)
+	}
+	return c.liveTarget(to)
+}
+
+// watchesTarget determines if the cache holds the target secret, so that
+// changes to it are observed. Only then can reconciles be skipped when
+// neither the source nor the rule changed since the target was written,
+// as the applied state is forgotten when the target is seen to change.
+func (c *SecretMirror) watchesTarget(to config.SecretLocation) bool {
+	return to.Cluster == "" && !c.liveTargets
+}
+
+// liveTarget reads the target from the API server of its cluster.
+func (c *SecretMirror) liveTarget(to config.SecretLocation) (*coreapi.Secret, error) {
+	client := c.client
+	if to.Cluster != "" {
+		var err error
+		if client, err = c.targetClient(to); err != nil {
+			return nil, err
+		}
+	}
+	return client.CoreV1().Secrets(to.Namespace).Get(to.Name, metav1.GetOptions{})
+}
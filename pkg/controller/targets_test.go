@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestMirrorToRemoteCluster(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	remote := testclient.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "updated"},
+		Data:       map[string][]byte{"key": []byte("old")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Cluster: "build01", Namespace: "test-ns", Name: "created"},
+		},
+		{
+			From:          config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:            config.SecretLocation{Cluster: "build01", Namespace: "test-ns", Name: "updated"},
+			AdoptExisting: true,
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{TargetClusters: map[string]kubeclientset.Interface{"build01": remote}})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	for _, name := range []string{"created", "updated"} {
+		target, err := remote.CoreV1().Secrets("test-ns").Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected target %s in the remote cluster: %v", name, err)
+		}
+		if actual := string(target.Data["key"]); actual != "value" {
+			t.Errorf("expected target %s to hold the source data, got %q", name, actual)
+		}
+		if _, err := client.CoreV1().Secrets("test-ns").Get(name, metav1.GetOptions{}); err == nil {
+			t.Errorf("expected target %s not to be written to the local cluster", name)
+		}
+	}
+}
+
+func TestRemoteTargetsAreRestored(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	remote := testclient.NewSimpleClientset()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Cluster: "build01", Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{TargetClusters: map[string]kubeclientset.Interface{"build01": remote}})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	// changes in remote clusters are not observed, so the target must
+	// be compared again even though neither the source nor the rule changed
+	target, err := remote.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.Data = map[string][]byte{"key": []byte("changed")}
+	if _, err := remote.CoreV1().Secrets("test-ns").Update(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	restored, err := remote.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := string(restored.Data["key"]); actual != "value" {
+		t.Errorf("expected the remote target to be restored to the source data, got %q", actual)
+	}
+}
+
+func TestMirrorToUnknownCluster(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Cluster: "build01", Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	err := c.reconcile("test-ns/src")
+	if err == nil || !strings.Contains(err.Error(), "target cluster build01 is not configured") {
+		t.Errorf("expected an error for the unknown cluster, got %v", err)
+	}
+}
+
+func TestFilteredSecretsReadTargetsLive(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src", Labels: map[string]string{"ci.openshift.io/mirror": "true"}},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	// the target does not carry the label, so the informer never holds it
+	if _, err := client.CoreV1().Secrets("test-ns").Create(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "dst"},
+		Data:       map[string][]byte{"key": []byte("other")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{FilteredSecrets: true})
+	defer c.queue.ShutDown()
+
+	err := c.reconcile("test-ns/src")
+	if err == nil || !strings.Contains(err.Error(), "did not create it") {
+		t.Errorf("expected the existing target to be read from the API server and left alone, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultThrottleDelay is how long to back off when the API server
+	// throttles us without suggesting a delay.
+	defaultThrottleDelay = time.Second
+	// throttleCooldown is how long after the last throttled request
+	// workers keep reconciling one at a time.
+	throttleCooldown = time.Minute
+)
+
+// Throttle backs off every worker at once when the API server asks clients
+// to slow down, instead of letting every worker retry on its own and
+// amplify the pressure. While the API server recently throttled requests,
+// workers reconcile one at a time.
+type Throttle struct {
+	lock sync.Mutex
+	// until is when requests may be sent again
+	until time.Time
+	// throttled is when a request was last throttled
+	throttled time.Time
+
+	serial sync.Mutex
+}
+
+// NewThrottle returns a Throttle that has not observed any pressure.
+func NewThrottle() *Throttle {
+	return &Throttle{}
+}
+
+// WrapTransport observes the responses of the API server to requests made
+// through the transport, for use as the WrapTransport of a client config.
+func (t *Throttle) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		response, err := rt.RoundTrip(request)
+		if err == nil {
+			t.observe(response)
+		}
+		return response, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// observe backs off if the response throttles us, honoring the delay
+// the API server suggests with Retry-After.
+func (t *Throttle) observe(response *http.Response) {
+	if response.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+	delay := defaultThrottleDelay
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	now := time.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.throttled = now
+	if until := now.Add(delay); until.After(t.until) {
+		t.until = until
+	}
+	throttledRequests.Inc()
+}
+
+// acquire blocks until requests may be sent again and, while under
+// pressure, until no other worker is reconciling. The returned func
+// must be called once the worker is done.
+func (t *Throttle) acquire() func() {
+	if t == nil {
+		return func() {}
+	}
+	t.lock.Lock()
+	wait, pressured := time.Until(t.until), time.Since(t.throttled) < throttleCooldown
+	t.lock.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	if !pressured {
+		return func() {}
+	}
+	t.serial.Lock()
+	return t.serial.Unlock
+}
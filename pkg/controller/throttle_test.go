@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter := r.URL.Query().Get("retry-after"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	throttle := NewThrottle()
+	client := &http.Client{Transport: throttle.WrapTransport(http.DefaultTransport)}
+	request := func(query string) {
+		response, err := client.Get(server.URL + query)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		response.Body.Close()
+	}
+
+	request("")
+	start := time.Now()
+	release := throttle.acquire()
+	release()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected no back-off without throttling, waited %s", elapsed)
+	}
+
+	request("?retry-after=1")
+	start = time.Now()
+	release = throttle.acquire()
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("expected to back off for the suggested delay, waited %s", elapsed)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		throttle.acquire()()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Error("expected workers to reconcile one at a time while under pressure")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the next worker to proceed once the first is done")
+	}
+}
+
+func TestNilThrottle(t *testing.T) {
+	var throttle *Throttle
+	throttle.acquire()()
+}
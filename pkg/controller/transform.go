@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
+)
+
+// transforms holds the transforms built for one generation of the
+// configuration, so that they are built once per configuration load
+// rather than for every reconciliation: building a webhook transform
+// reads its certificates and sets up its client.
+type transforms struct {
+	lock       sync.Mutex
+	generation *config.Configuration
+	built      map[string]transform.Transform
+}
+
+// get returns the transform configured by the spec, building it if the
+// configuration has been reloaded since it was last built.
+func (t *transforms) get(generation *config.Configuration, spec config.TransformConfig) (transform.Transform, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.generation != generation || t.built == nil {
+		t.generation = generation
+		t.built = map[string]transform.Transform{}
+	}
+	key := spec.Name + "\x00" + string(spec.Config)
+	if built, ok := t.built[key]; ok {
+		return built, nil
+	}
+	built, err := transform.New(spec.Name, spec.Config)
+	if err != nil {
+		return nil, err
+	}
+	t.built[key] = built
+	return built, nil
+}
+
+// transformData applies the transforms configured for the rule in order.
+// Failures that a transform ignores are logged and counted, and the data
+// it returned is mirrored.
+func (c *SecretMirror) transformData(data map[string][]byte, mirrorConfig config.MirrorConfig) (map[string][]byte, error) {
+	var generation *config.Configuration
+	if c.config != nil {
+		generation = c.config()
+	}
+	for _, t := range mirrorConfig.Transforms {
+		tr, err := c.transforms.get(generation, t)
+		if err != nil {
+			return nil, err
+		}
+		transformed, err := tr.Transform(data)
+		if ignored, ok := err.(*transform.IgnoredError); ok {
+			c.logger.WithFields(logrus.Fields{"rule": mirrorConfig.String(), "transform": t.Name}).WithError(ignored.Err).Warn("mirroring data untransformed as the transform failed and its failure policy ignores failures")
+			ignoredTransformFailures.WithLabelValues(t.Name).Inc()
+			err = nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("transform %s failed: %v", t.Name, err)
+		}
+		data = transformed
+	}
+	return data, nil
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
+)
+
+func init() {
+	// a custom transform, as a downstream fork would compile in
+	transform.Register("test-upper", func(json.RawMessage) (transform.Transform, error) {
+		return transform.Func(func(data map[string][]byte) (map[string][]byte, error) {
+			upper := map[string][]byte{}
+			for key, value := range data {
+				upper[key] = bytes.ToUpper(value)
+			}
+			return upper, nil
+		}), nil
+	})
+	// a transform ignoring its failure, as webhooks may
+	transform.Register("test-ignore", func(json.RawMessage) (transform.Transform, error) {
+		return transform.Func(func(data map[string][]byte) (map[string][]byte, error) {
+			return data, &transform.IgnoredError{Err: errors.New("tokenizer unavailable")}
+		}), nil
+	})
+}
+
+func TestTransformData(t *testing.T) {
+	data := map[string][]byte{"prod_token": []byte("secret"), "user": []byte("ci")}
+	mirror := config.MirrorConfig{Transforms: []config.TransformConfig{
+		{Name: "filter", Config: json.RawMessage(`{"include":["prod_token"]}`)},
+		{Name: "rename", Config: json.RawMessage(`{"keys":{"prod_token":"token"}}`)},
+		{Name: "test-upper"},
+	}}
+	c := &SecretMirror{logger: logrus.NewEntry(logrus.StandardLogger())}
+	actual, err := c.transformData(data, mirror)
+	if err != nil {
+		t.Fatalf("failed to transform data: %v", err)
+	}
+	if expected := map[string][]byte{"token": []byte("SECRET")}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	if string(data["prod_token"]) != "secret" || len(data) != 2 {
+		t.Errorf("expected the source data not to be modified, got %q", data)
+	}
+}
+
+func TestTransformDataIgnoresFailures(t *testing.T) {
+	data := map[string][]byte{"token": []byte("secret")}
+	mirror := config.MirrorConfig{Transforms: []config.TransformConfig{{Name: "test-ignore"}, {Name: "test-upper"}}}
+	c := &SecretMirror{logger: logrus.NewEntry(logrus.StandardLogger())}
+	actual, err := c.transformData(data, mirror)
+	if err != nil {
+		t.Fatalf("expected the ignored failure not to fail the transform, got %v", err)
+	}
+	if expected := map[string][]byte{"token": []byte("SECRET")}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected the following transforms to apply to the data, got %q", actual)
+	}
+}
+
+func TestTransformsAreBuiltPerGeneration(t *testing.T) {
+	var cache transforms
+	spec := config.TransformConfig{Name: "filter", Config: json.RawMessage(`{"include":["token"]}`)}
+	first, second := &config.Configuration{}, &config.Configuration{}
+	built, err := cache.get(first, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.get(first, spec); again != built {
+		t.Error("expected the transform to be reused within a generation of the configuration")
+	}
+	if other, _ := cache.get(first, config.TransformConfig{Name: "filter", Config: json.RawMessage(`{"include":["user"]}`)}); other == built {
+		t.Error("expected transforms configured differently to be built separately")
+	}
+	if reloaded, _ := cache.get(second, spec); reloaded == built {
+		t.Error("expected the transform to be rebuilt once the configuration is reloaded")
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// validator checks a value that is about to be written to a target
+type validator func(value []byte) error
+
+// validators holds the implementation of every validator named in
+// config.Validators; keys that are missing from the data are only
+// checked by the nonEmpty validator
+var validators = map[string]validator{
+	config.ValidatorNonEmpty: validateNonEmpty,
+	config.ValidatorUTF8:     validateUTF8,
+	config.ValidatorJSON:     validateJSON,
+	config.ValidatorPEM:      validatePEM,
+}
+
+func validateNonEmpty(value []byte) error {
+	if len(value) == 0 {
+		return errors.New("value is empty")
+	}
+	return nil
+}
+
+func validateUTF8(value []byte) error {
+	if !utf8.Valid(value) {
+		return errors.New("value is not valid UTF-8")
+	}
+	return nil
+}
+
+func validateJSON(value []byte) error {
+	if !json.Valid(value) {
+		return errors.New("value is not valid JSON")
+	}
+	return nil
+}
+
+func validatePEM(value []byte) error {
+	rest := bytes.TrimSpace(value)
+	if len(rest) == 0 {
+		return errors.New("value holds no PEM blocks")
+	}
+	for len(rest) > 0 {
+		if !bytes.HasPrefix(rest, []byte("-----BEGIN ")) {
+			return errors.New("value holds data outside of PEM blocks")
+		}
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return errors.New("value holds a malformed PEM block")
+		}
+		rest = bytes.TrimSpace(rest)
+	}
+	return nil
+}
+
+// validateData runs the validators configured for the rule against the
+// data that would be written to the target. Failures are counted and
+// the rule is flagged as blocked until its data validates again.
+func validateData(data map[string][]byte, mirrorConfig config.MirrorConfig) error {
+	rule := mirrorConfig.String()
+	var failures []string
+	for _, validation := range mirrorConfig.Validations {
+		value, present := data[validation.Key]
+		for _, name := range validation.Validators {
+			if !present && name != config.ValidatorNonEmpty {
+				continue
+			}
+			check, known := validators[name]
+			if !known {
+				failures = append(failures, fmt.Sprintf("%s: unknown validator %q", validation.Key, name))
+				continue
+			}
+			if err := check(value); err != nil {
+				validationFailures.WithLabelValues(metricLabels.rule(rule), validation.Key, name).Inc()
+				failures = append(failures, fmt.Sprintf("%s: %s: %v", validation.Key, name, err))
+			}
+		}
+	}
+	if len(failures) > 0 {
+		validationBlockedRules.set(rule, 1)
+		return fmt.Errorf("data failed validation: %s", strings.Join(failures, "; "))
+	}
+	validationBlockedRules.delete(rule)
+	return nil
+}
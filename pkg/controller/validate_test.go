@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+const testCertificate = `-----BEGIN CERTIFICATE-----
+MIIBszCCAVmgAwIBAgIUQ2FsbGVkIGZvciB0ZXN0aW5nIG9ubHkwCgYIKoZIzj0E
+-----END CERTIFICATE-----
+`
+
+func TestValidateData(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		data        map[string][]byte
+		validations []config.KeyValidation
+		expectedErr bool
+	}{
+		{
+			name: "data without validations passes",
+			data: map[string][]byte{"key": nil},
+		},
+		{
+			name:        "an empty value fails nonEmpty",
+			data:        map[string][]byte{"key": nil},
+			validations: []config.KeyValidation{{Key: "key", Validators: []string{config.ValidatorNonEmpty}}},
+			expectedErr: true,
+		},
+		{
+			name:        "a missing key fails nonEmpty",
+			data:        map[string][]byte{"other": []byte("value")},
+			validations: []config.KeyValidation{{Key: "key", Validators: []string{config.ValidatorNonEmpty}}},
+			expectedErr: true,
+		},
+		{
+			name:        "a missing key is not checked by other validators",
+			data:        map[string][]byte{"other": []byte("value")},
+			validations: []config.KeyValidation{{Key: "key", Validators: []string{config.ValidatorJSON, config.ValidatorPEM}}},
+		},
+		{
+			name:        "invalid UTF-8 fails utf8",
+			data:        map[string][]byte{"key": {0xff, 0xfe}},
+			validations: []config.KeyValidation{{Key: "key", Validators: []string{config.ValidatorUTF8}}},
+			expectedErr: true,
+		},
+		{
+			name:        "a JSON document passes json",
+			data:        map[string][]byte{"key": []byte(`{"auths":{}}`)},
+			validations: []config.KeyValidation{{Key: "key", Validators: []string{config.ValidatorUTF8, config.ValidatorJSON}}},
+		},
+		{
+			name:        "truncated JSON fails json",
+			data:        map[string][]byte{"key": []byte(`{"auths":`)},
+			validations: []config.KeyValidation{{Key: "key", Validators: []string{config.ValidatorJSON}}},
+			expectedErr: true,
+		},
+		{
+			name:        "a certificate chain passes pem",
+			data:        map[string][]byte{"tls.crt": []byte(testCertificate + "\n" + testCertificate)},
+			validations: []config.KeyValidation{{Key: "tls.crt", Validators: []string{config.ValidatorPEM}}},
+		},
+		{
+			name:        "text around PEM blocks fails pem",
+			data:        map[string][]byte{"tls.crt": []byte("subject=CN=example\n" + testCertificate)},
+			validations: []config.KeyValidation{{Key: "tls.crt", Validators: []string{config.ValidatorPEM}}},
+			expectedErr: true,
+		},
+		{
+			name:        "a truncated PEM block fails pem",
+			data:        map[string][]byte{"tls.crt": []byte(testCertificate[:60])},
+			validations: []config.KeyValidation{{Key: "tls.crt", Validators: []string{config.ValidatorPEM}}},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mirror := config.MirrorConfig{
+				From:        config.SecretLocation{Namespace: "a", Name: "b"},
+				To:          config.SecretLocation{Namespace: "c", Name: "d"},
+				Validations: testCase.validations,
+			}
+			err := validateData(testCase.data, mirror)
+			if err == nil && testCase.expectedErr {
+				t.Errorf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// secretVersionsConfigMap is the name of the ConfigMap published in target
+// namespaces so that consumers can detect rotations without reading secrets.
+const secretVersionsConfigMap = "mirrored-secret-versions"
+
+// secretVersion is published for every target under its name.
+type secretVersion struct {
+	// Hash is the keyed hash of the data mirrored into the target
+	Hash string `json:"hash"`
+	// Updated is when the controller observed the data change
+	Updated time.Time `json:"updated"`
+}
+
+// publishVersion records the keyed hash of the data mirrored into the target in the
+// versions ConfigMap of its namespace, if publishing is enabled. The update
+// time is left untouched while the hash does not change.
+func (c *SecretMirror) publishVersion(to config.SecretLocation, hash string) error {
+	if !c.publishVersions {
+		return nil
+	}
+	if len(c.hashKey) == 0 {
+		return fmt.Errorf("secret versions are published but no hash key is configured")
+	}
+	// the ConfigMap is readable by those who may not read the secrets
+	hash = keyedHash(c.hashKey, hash)
+	client, err := c.targetClient(to)
+	if err != nil {
+		return err
+	}
+	configMaps := client.CoreV1().ConfigMaps(to.Namespace)
+	existing, err := configMaps.Get(secretVersionsConfigMap, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret versions: %v", err)
+	}
+	notFound := errors.IsNotFound(err)
+
+	if !notFound {
+		var published secretVersion
+		if raw, recorded := existing.Data[to.Name]; recorded && json.Unmarshal([]byte(raw), &published) == nil && published.Hash == hash {
+			return nil
+		}
+	}
+	raw, err := json.Marshal(secretVersion{Hash: hash, Updated: time.Now().UTC().Truncate(time.Second)})
+	if err != nil {
+		return fmt.Errorf("failed to serialize secret version: %v", err)
+	}
+
+	if notFound {
+		_, err = configMaps.Create(&coreapi.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: to.Namespace, Name: secretVersionsConfigMap},
+			Data:       map[string]string{to.Name: string(raw)},
+		})
+	} else {
+		updated := existing.DeepCopy()
+		if updated.Data == nil {
+			updated.Data = map[string]string{}
+		}
+		updated.Data[to.Name] = string(raw)
+		_, err = configMaps.Update(updated)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to publish secret version: %v", err)
+	}
+	return nil
+}
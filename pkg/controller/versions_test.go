@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestPublishVersion(t *testing.T) {
+	client := testclient.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: secretVersionsConfigMap},
+		Data:       map[string]string{"other": `{"hash":"abc","updated":"2018-01-01T00:00:00Z"}`},
+	})
+	informer := informers.NewSharedInformerFactory(client, 0).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{})
+	c := NewSecretMirror(informer, client, ca.Config, Options{PublishVersions: true, HashKey: []byte("key")})
+	to := config.SecretLocation{Namespace: "test-ns", Name: "dst"}
+
+	published := func() map[string]secretVersion {
+		configMap, err := client.CoreV1().ConfigMaps("test-ns").Get(secretVersionsConfigMap, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get published versions: %v", err)
+		}
+		versions := map[string]secretVersion{}
+		for name, raw := range configMap.Data {
+			var version secretVersion
+			if err := json.Unmarshal([]byte(raw), &version); err != nil {
+				t.Fatalf("failed to parse the version of %s: %v", name, err)
+			}
+			versions[name] = version
+		}
+		return versions
+	}
+
+	if err := c.publishVersion(to, "first"); err != nil {
+		t.Fatalf("failed to publish version: %v", err)
+	}
+	first := published()
+	if first["dst"].Hash != keyedHash([]byte("key"), "first") || first["dst"].Updated.IsZero() {
+		t.Errorf("expected the first hash to be published with a timestamp, got %v", first["dst"])
+	}
+	if first["other"].Hash != "abc" {
+		t.Errorf("expected versions of other targets to be kept, got %v", first["other"])
+	}
+
+	client.ClearActions()
+	if err := c.publishVersion(to, "first"); err != nil {
+		t.Fatalf("failed to publish version: %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("expected an unchanged hash not to be written, got %v", action)
+		}
+	}
+
+	if err := c.publishVersion(to, "second"); err != nil {
+		t.Fatalf("failed to publish version: %v", err)
+	}
+	if second := published(); second["dst"].Hash != keyedHash([]byte("key"), "second") {
+		t.Errorf("expected the second hash to be published, got %v", second["dst"])
+	}
+}
+
+func TestReconcilePublishesVersions(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+		},
+	}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{PublishVersions: true, HashKey: []byte("key")})
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	configMap, err := client.CoreV1().ConfigMaps("test-ns").Get(secretVersionsConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected versions to be published: %v", err)
+	}
+	var version secretVersion
+	if err := json.Unmarshal([]byte(configMap.Data["dst"]), &version); err != nil {
+		t.Fatalf("failed to parse the published version: %v", err)
+	}
+	hash := dataHash(map[string][]byte{"key": []byte("value")})
+	if expected := keyedHash([]byte("key"), hash); version.Hash != expected {
+		t.Errorf("expected the keyed hash %s to be published rather than the hash of the data, got %s", expected, version.Hash)
+	}
+
+	c = NewSecretMirror(informer, client, ca.Config, Options{PublishVersions: true})
+	if err := c.reconcile("test-ns/src"); err == nil {
+		t.Error("expected versions not to be published without a hash key")
+	}
+}
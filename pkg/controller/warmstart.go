@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"math/rand"
+	"time"
+)
+
+// stagger spreads the keys that were enqueued while the caches synced over
+// the warm start period, so that a restart does not reconcile every source
+// at once. It must be called before the workers are started.
+func (c *SecretMirror) stagger() {
+	if c.warmStart <= 0 {
+		return
+	}
+	pending := c.queue.Len()
+	c.logger.Infof("staggering the initial reconciliation of %d keys over %s", pending, c.warmStart)
+	for ; pending > 0; pending-- {
+		key, quit := c.queue.Get()
+		if quit {
+			return
+		}
+		c.queue.Done(key)
+		c.queued.remove(key.(string))
+		c.queue.AddAfter(key, time.Duration(rand.Int63n(int64(c.warmStart))))
+	}
+	c.recordQueueDepth()
+}
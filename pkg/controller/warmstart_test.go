@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestStagger(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 0).Core().V1().Secrets()
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{})
+	c := NewSecretMirror(informer, client, ca.Config, Options{WarmStart: 100 * time.Millisecond})
+	for _, key := range []string{"test-ns/a", "test-ns/b", "test-ns/c"} {
+		c.enqueueKey(key)
+	}
+
+	c.stagger()
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return c.queue.Len() == 3, nil
+	}); err != nil {
+		t.Errorf("expected every key to be enqueued again within the warm start period, got %d keys", c.queue.Len())
+	}
+}
+
+func TestRunReconcilesEveryRuleOnStart(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	// the source is in the cache, but the controller never saw an event for it
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{{
+		From: config.SecretLocation{Namespace: "test-ns", Name: "src"},
+		To:   config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+	}}})
+	c := NewSecretMirror(informer, client, ca.Config, Options{})
+	// drop the key if the informer replayed the source to the new handlers
+	_ = wait.PollImmediate(10*time.Millisecond, 100*time.Millisecond, func() (bool, error) { return c.queue.Len() > 0, nil })
+	for c.queue.Len() > 0 {
+		key, _ := c.queue.Get()
+		c.queue.Done(key)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx, 1)
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+		return err == nil, nil
+	}); err != nil {
+		t.Error("expected the target to be created on start")
+	}
+}
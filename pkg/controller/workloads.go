@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// checksumAnnotationPrefix prefixes the pod template annotation that holds
+// the checksum of a mirrored secret a workload consumes. Changing it makes
+// the workload controller roll out new pods.
+const checksumAnnotationPrefix = "ci.openshift.io/secret-checksum-"
+
+// checksumAnnotation returns the pod template annotation for the secret,
+// falling back to a digest of the name if it does not fit the 63 character
+// limit on the name part of annotation keys.
+func checksumAnnotation(secret string) string {
+	const prefix = "secret-checksum-"
+	if len(prefix)+len(secret) > 63 {
+		return fmt.Sprintf("%s%x", checksumAnnotationPrefix, sha256.Sum256([]byte(secret)))[:len("ci.openshift.io/")+63]
+	}
+	return checksumAnnotationPrefix + secret
+}
+
+// referencesSecret determines if pods created from the spec consume the secret.
+func referencesSecret(spec coreapi.PodSpec, secret string) bool {
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil && volume.Secret.SecretName == secret {
+			return true
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && source.Secret.Name == secret {
+					return true
+				}
+			}
+		}
+	}
+	for _, reference := range spec.ImagePullSecrets {
+		if reference.Name == secret {
+			return true
+		}
+	}
+	for _, container := range append(append([]coreapi.Container{}, spec.InitContainers...), spec.Containers...) {
+		for _, source := range container.EnvFrom {
+			if source.SecretRef != nil && source.SecretRef.Name == secret {
+				return true
+			}
+		}
+		for _, variable := range container.Env {
+			if variable.ValueFrom != nil && variable.ValueFrom.SecretKeyRef != nil && variable.ValueFrom.SecretKeyRef.Name == secret {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checksumPatch builds a merge patch setting the annotation on the pod template.
+func checksumPatch(annotation, checksum string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{annotation: checksum},
+				},
+			},
+		},
+	})
+}
+
+// injectChecksum records the checksum of the target secret in the pod template
+// of every Deployment and StatefulSet in its namespace that consumes it, so
+// that they roll out new pods when the secret is rotated. Workloads that
+// already carry the checksum are left alone.
+func (c *SecretMirror) injectChecksum(namespace, secret, checksum string) error {
+	annotation := checksumAnnotation(secret)
+	patch, err := checksumPatch(annotation, checksum)
+	if err != nil {
+		return fmt.Errorf("failed to build checksum patch: %v", err)
+	}
+
+	var errs []error
+	deployments, err := c.client.AppsV1().Deployments(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %v", err)
+	}
+	for _, deployment := range deployments.Items {
+		if !referencesSecret(deployment.Spec.Template.Spec, secret) || deployment.Spec.Template.Annotations[annotation] == checksum {
+			continue
+		}
+		c.logger.WithFields(logrus.Fields{"namespace": namespace, "deployment": deployment.Name}).Info("injecting secret checksum into deployment")
+		if _, err := c.writeClient.AppsV1().Deployments(namespace).Patch(deployment.Name, types.MergePatchType, patch); err != nil {
+			errs = append(errs, fmt.Errorf("failed to patch deployment %s: %v", deployment.Name, err))
+		}
+	}
+
+	statefulSets, err := c.client.AppsV1().StatefulSets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list stateful sets: %v", err)
+	}
+	for _, statefulSet := range statefulSets.Items {
+		if !referencesSecret(statefulSet.Spec.Template.Spec, secret) || statefulSet.Spec.Template.Annotations[annotation] == checksum {
+			continue
+		}
+		c.logger.WithFields(logrus.Fields{"namespace": namespace, "statefulset": statefulSet.Name}).Info("injecting secret checksum into stateful set")
+		if _, err := c.writeClient.AppsV1().StatefulSets(namespace).Patch(statefulSet.Name, types.MergePatchType, patch); err != nil {
+			errs = append(errs, fmt.Errorf("failed to patch stateful set %s: %v", statefulSet.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// pendingRollouts remembers the targets whose consumers could not be
+// rolled out after the target was written, so that the rollout is
+// retried even though the target matches its source by then.
+type pendingRollouts struct {
+	lock    sync.Mutex
+	targets map[string]bool
+}
+
+func (p *pendingRollouts) set(target string, pending bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.targets == nil {
+		p.targets = map[string]bool{}
+	}
+	if pending {
+		p.targets[target] = true
+	} else {
+		delete(p.targets, target)
+	}
+}
+
+func (p *pendingRollouts) pending(target string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.targets[target]
+}
+
+// rollOut injects the checksum of the target into the workloads
+// consuming it, if the rule asks for that. Pod templates are readable
+// by those who may not read the target, so the checksum is keyed.
+func (c *SecretMirror) rollOut(mirrorConfig config.MirrorConfig, hash string) error {
+	if !mirrorConfig.InjectChecksum {
+		return nil
+	}
+	if len(c.hashKey) == 0 {
+		return fmt.Errorf("the rule injects checksums but no hash key is configured")
+	}
+	target := mirrorConfig.To.String()
+	if err := c.injectChecksum(mirrorConfig.To.Namespace, mirrorConfig.To.Name, keyedHash(c.hashKey, hash)); err != nil {
+		c.pendingRollouts.set(target, true)
+		return fmt.Errorf("failed to inject checksum into consuming workloads: %v", err)
+	}
+	c.pendingRollouts.set(target, false)
+	return nil
+}
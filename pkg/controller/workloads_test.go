@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	appsapi "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	clientgo_testing "k8s.io/client-go/testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestReferencesSecret(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		spec     v1.PodSpec
+		expected bool
+	}{
+		{
+			name: "nothing references the secret",
+			spec: v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		},
+		{
+			name:     "secret volume",
+			spec:     v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "dst"}}}}},
+			expected: true,
+		},
+		{
+			name: "projected volume",
+			spec: v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{
+				{Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "dst"}}},
+			}}}}}},
+			expected: true,
+		},
+		{
+			name:     "image pull secret",
+			spec:     v1.PodSpec{ImagePullSecrets: []v1.LocalObjectReference{{Name: "dst"}}},
+			expected: true,
+		},
+		{
+			name: "environment from an init container",
+			spec: v1.PodSpec{InitContainers: []v1.Container{{EnvFrom: []v1.EnvFromSource{
+				{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "dst"}}},
+			}}}},
+			expected: true,
+		},
+		{
+			name: "environment variable referencing another secret",
+			spec: v1.PodSpec{Containers: []v1.Container{{Env: []v1.EnvVar{
+				{Name: "TOKEN", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "other"}, Key: "token"}}},
+			}}}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := referencesSecret(testCase.spec, "dst"); actual != testCase.expected {
+				t.Errorf("%s: expected %t, got %t", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChecksumAnnotation(t *testing.T) {
+	if actual, expected := checksumAnnotation("dst"), "ci.openshift.io/secret-checksum-dst"; actual != expected {
+		t.Errorf("expected annotation %s, got %s", expected, actual)
+	}
+	long := checksumAnnotation(strings.Repeat("a", 100))
+	if name := strings.TrimPrefix(long, "ci.openshift.io/"); len(name) != 63 {
+		t.Errorf("expected the name of the annotation for a long secret to be truncated to 63 characters, got %q", name)
+	}
+	if long == checksumAnnotation(strings.Repeat("b", 100)) {
+		t.Error("expected annotations for different long secrets to differ")
+	}
+}
+
+func TestReconcileInjectsChecksum(t *testing.T) {
+	consuming := v1.PodTemplateSpec{Spec: v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "dst"}}}}}}
+	client := testclient.NewSimpleClientset(
+		&appsapi.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "consumer"}, Spec: appsapi.DeploymentSpec{Template: consuming}},
+		&appsapi.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "bystander"}},
+		&appsapi.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "database"}, Spec: appsapi.StatefulSetSpec{Template: consuming}},
+	)
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:           config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:             config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+			InjectChecksum: true,
+		},
+	}})
+	if err := (&SecretMirror{}).rollOut(ca.Config().Secrets[0], "hash"); err == nil {
+		t.Error("expected injecting checksums without a hash key to fail")
+	}
+	c := NewSecretMirror(informer, client, ca.Config, Options{HashKey: []byte("key")})
+	client.PrependReactor("patch", "deployments", func(clientgo_testing.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("injected failure")
+	})
+	if err := c.reconcile("test-ns/src"); err == nil {
+		t.Fatal("expected the failure to inject the checksum to fail the reconcile")
+	}
+	client.ReactionChain = client.ReactionChain[1:]
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be written: %v", err)
+	}
+	if err := informer.Informer().GetIndexer().Add(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	checksum := keyedHash([]byte("key"), dataHash(map[string][]byte{"key": []byte("value")}))
+	annotation := checksumAnnotation("dst")
+	consumer, err := client.AppsV1().Deployments("test-ns").Get("consumer", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := consumer.Spec.Template.Annotations[annotation]; actual != checksum {
+		t.Errorf("expected the consuming deployment to carry checksum %q, got %q", checksum, actual)
+	}
+	database, err := client.AppsV1().StatefulSets("test-ns").Get("database", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := database.Spec.Template.Annotations[annotation]; actual != checksum {
+		t.Errorf("expected the consuming stateful set to carry checksum %q, got %q", checksum, actual)
+	}
+	bystander, err := client.AppsV1().Deployments("test-ns").Get("bystander", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bystander.Spec.Template.Annotations) != 0 {
+		t.Errorf("expected the unrelated deployment to be left alone, got annotations %v", bystander.Spec.Template.Annotations)
+	}
+}
+
+func TestUnchangedTargetsDoNotListWorkloads(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informer := syncedInformer(t, client, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "src"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	ca := &config.Agent{}
+	ca.Set(&config.Configuration{Secrets: []config.MirrorConfig{
+		{
+			From:           config.SecretLocation{Namespace: "test-ns", Name: "src"},
+			To:             config.SecretLocation{Namespace: "test-ns", Name: "dst"},
+			InjectChecksum: true,
+		},
+	}})
+	if err := NewSecretMirror(informer, client, ca.Config, Options{HashKey: []byte("key")}).reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	target, err := client.CoreV1().Secrets("test-ns").Get("dst", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the target to be written: %v", err)
+	}
+	if err := informer.Informer().GetIndexer().Add(target); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new controller does not know what it applied, so it compares the target
+	client.ClearActions()
+	if err := NewSecretMirror(informer, client, ca.Config, Options{HashKey: []byte("key")}).reconcile("test-ns/src"); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" {
+			t.Errorf("expected workloads not to be listed for a target that already matches its source, got a list of %s", action.GetResource().Resource)
+		}
+	}
+}
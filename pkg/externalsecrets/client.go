@@ -0,0 +1,55 @@
+package externalsecrets
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/rest"
+)
+
+// Client reads and writes ExternalSecrets.
+type Client struct {
+	client rest.Interface
+}
+
+// NewClient returns a Client for the cluster.
+func NewClient(clusterConfig *rest.Config) (*Client, error) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	restConfig := *clusterConfig
+	restConfig.GroupVersion = &SchemeGroupVersion
+	restConfig.APIPath = "/apis"
+	restConfig.ContentType = runtime.ContentTypeJSON
+	restConfig.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
+	client, err := rest.RESTClientFor(&restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{client: client}, nil
+}
+
+// Fetch returns the ExternalSecret with the name in the namespace.
+func (c *Client) Fetch(namespace, name string) (*ExternalSecret, error) {
+	external := &ExternalSecret{}
+	if err := c.client.Get().Namespace(namespace).Resource(Resource).Name(name).Do().Into(external); err != nil {
+		return nil, err
+	}
+	return external, nil
+}
+
+// Store creates the ExternalSecret, or replaces it if it was fetched
+// from the cluster, returning it as stored.
+func (c *Client) Store(external *ExternalSecret) (*ExternalSecret, error) {
+	external = external.DeepCopy()
+	external.APIVersion, external.Kind = SchemeGroupVersion.String(), "ExternalSecret"
+	request := c.client.Post().Namespace(external.Namespace).Resource(Resource)
+	if external.ResourceVersion != "" {
+		request = c.client.Put().Namespace(external.Namespace).Resource(Resource).Name(external.Name)
+	}
+	stored := &ExternalSecret{}
+	if err := request.Body(external).Do().Into(stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
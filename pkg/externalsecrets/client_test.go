@@ -0,0 +1,76 @@
+package externalsecrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestClient(t *testing.T) {
+	stored := map[string]*ExternalSecret{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		const prefix = "/apis/external-secrets.io/v1beta1/namespaces/ci/externalsecrets"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == prefix+"/registry":
+			external, found := stored["registry"]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound})
+				return
+			}
+			json.NewEncoder(w).Encode(external)
+		case (r.Method == http.MethodPost && r.URL.Path == prefix) || (r.Method == http.MethodPut && r.URL.Path == prefix+"/registry"):
+			external := &ExternalSecret{}
+			if err := json.NewDecoder(r.Body).Decode(external); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			if external.APIVersion != "external-secrets.io/v1beta1" || external.Kind != "ExternalSecret" {
+				t.Errorf("expected the kind of the object to be sent, got %s %s", external.APIVersion, external.Kind)
+			}
+			external.ResourceVersion += "1"
+			stored[external.Name] = external
+			json.NewEncoder(w).Encode(external)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := client.Fetch("ci", "registry"); !errors.IsNotFound(err) {
+		t.Fatalf("expected the ExternalSecret to be missing, got %v", err)
+	}
+	spec := ExternalSecretSpec{
+		SecretStoreRef: SecretStoreRef{Name: "vault", Kind: KindClusterSecretStore},
+		Target:         Target{Name: "registry", CreationPolicy: CreationPolicyOwner, Template: &Template{Metadata: TemplateMetadata{Labels: map[string]string{"team": "ci"}}}},
+		DataFrom:       []DataFromRemoteRef{{Extract: &RemoteRef{Key: "ci/registry"}}},
+	}
+	created, err := client.Store(&ExternalSecret{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "registry"}, Spec: spec})
+	if err != nil {
+		t.Fatalf("expected the ExternalSecret to be created, got %v", err)
+	}
+	fetched, err := client.Fetch("ci", "registry")
+	if err != nil {
+		t.Fatalf("expected the ExternalSecret to be fetched, got %v", err)
+	}
+	if fetched.ResourceVersion != created.ResourceVersion || !reflect.DeepEqual(fetched.Spec, spec) {
+		t.Errorf("expected the created ExternalSecret to be fetched, got %+v", fetched)
+	}
+	updated, err := client.Store(fetched)
+	if err != nil {
+		t.Fatalf("expected the ExternalSecret to be replaced, got %v", err)
+	}
+	if updated.ResourceVersion != "11" {
+		t.Errorf("expected the fetched ExternalSecret to be replaced, got version %q", updated.ResourceVersion)
+	}
+}
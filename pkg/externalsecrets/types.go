@@ -0,0 +1,157 @@
+package externalsecrets
+
+import (
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group of ExternalSecrets.
+const GroupName = "external-secrets.io"
+
+// Resource is the plural name ExternalSecrets are served under.
+const Resource = "externalsecrets"
+
+// SchemeGroupVersion is the version of the API ExternalSecrets are
+// written with.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1beta1"}
+
+var (
+	// SchemeBuilder registers the types of the API with a scheme.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme registers the types of the API with the scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &ExternalSecret{})
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
+
+// Kinds of stores an ExternalSecret can fetch from
+const (
+	// KindSecretStore is a store in the namespace of the ExternalSecret
+	KindSecretStore = "SecretStore"
+	// KindClusterSecretStore is a store serving every namespace
+	KindClusterSecretStore = "ClusterSecretStore"
+)
+
+// CreationPolicyOwner makes the External Secrets Operator create the
+// Secret and delete it along with the ExternalSecret.
+const CreationPolicyOwner = "Owner"
+
+// ExternalSecret makes the External Secrets Operator fetch secret data
+// from a store into a Secret. Only the fields the controller sets are
+// declared.
+type ExternalSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ExternalSecretSpec `json:"spec"`
+}
+
+// ExternalSecretSpec determines what is fetched and how the Secret is
+// created from it.
+type ExternalSecretSpec struct {
+	SecretStoreRef  SecretStoreRef   `json:"secretStoreRef"`
+	Target          Target           `json:"target,omitempty"`
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+	// DataFrom lists secrets whose every key is fetched
+	DataFrom []DataFromRemoteRef `json:"dataFrom,omitempty"`
+}
+
+// SecretStoreRef names the store the data is fetched from.
+type SecretStoreRef struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// Target is the Secret that is created.
+type Target struct {
+	Name           string    `json:"name,omitempty"`
+	CreationPolicy string    `json:"creationPolicy,omitempty"`
+	Template       *Template `json:"template,omitempty"`
+}
+
+// Template is the metadata and type of the Secret. Without templated
+// data, the fetched data is written as it is.
+type Template struct {
+	Type     coreapi.SecretType `json:"type,omitempty"`
+	Metadata TemplateMetadata   `json:"metadata,omitempty"`
+}
+
+// TemplateMetadata is the metadata of the Secret.
+type TemplateMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// DataFromRemoteRef fetches every key of a secret in the store.
+type DataFromRemoteRef struct {
+	Extract *RemoteRef `json:"extract,omitempty"`
+}
+
+// RemoteRef identifies a secret in the store.
+type RemoteRef struct {
+	Key     string `json:"key"`
+	Version string `json:"version,omitempty"`
+}
+
+// DeepCopyInto copies the object into out.
+func (in *ExternalSecret) DeepCopyInto(out *ExternalSecret) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopyInto copies the spec into out.
+func (in *ExternalSecretSpec) DeepCopyInto(out *ExternalSecretSpec) {
+	*out = *in
+	if in.RefreshInterval != nil {
+		interval := *in.RefreshInterval
+		out.RefreshInterval = &interval
+	}
+	if in.Target.Template != nil {
+		template := &Template{Type: in.Target.Template.Type}
+		template.Metadata.Labels = copyEntries(in.Target.Template.Metadata.Labels)
+		template.Metadata.Annotations = copyEntries(in.Target.Template.Metadata.Annotations)
+		out.Target.Template = template
+	}
+	if in.DataFrom != nil {
+		out.DataFrom = make([]DataFromRemoteRef, len(in.DataFrom))
+		for i, ref := range in.DataFrom {
+			if ref.Extract != nil {
+				extract := *ref.Extract
+				out.DataFrom[i].Extract = &extract
+			}
+		}
+	}
+}
+
+func copyEntries(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for key, value := range in {
+		out[key] = value
+	}
+	return out
+}
+
+// DeepCopy copies the object.
+func (in *ExternalSecret) DeepCopy() *ExternalSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the object.
+func (in *ExternalSecret) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
@@ -0,0 +1,103 @@
+package gcpsecretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+const (
+	// defaultEndpoint is the URL of the Secret Manager API
+	defaultEndpoint = "https://secretmanager.googleapis.com"
+	// ValueKey is the key under which secrets that do not hold a JSON
+	// object are mirrored.
+	ValueKey = "value"
+)
+
+// Client reads secrets from GCP Secret Manager.
+type Client struct {
+	tokens   TokenSource
+	client   *http.Client
+	endpoint string
+}
+
+// NewClient returns a Client that authorizes requests with access tokens
+// from the source.
+func NewClient(tokens TokenSource) *Client {
+	return &Client{
+		tokens:   tokens,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		endpoint: defaultEndpoint,
+	}
+}
+
+// Read returns the data of the version of the secret at the location
+// and the number of that version, which is the latest version unless
+// the location pins one, or no data if the secret or version does not
+// exist. Secrets holding a JSON object are mirrored key by key, encoding
+// values that are not strings as JSON, while any other secret is
+// mirrored under ValueKey.
+func (c *Client) Read(location config.SecretLocation) (map[string][]byte, string, error) {
+	token, err := c.tokens.Token()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not obtain an access token: %v", err)
+	}
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/%s:access", c.endpoint, location.GCPSecretManager.VersionName()), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&failure); err == nil && failure.Error.Message != "" {
+			return nil, "", fmt.Errorf("unexpected status %s: %s", response.Status, failure.Error.Message)
+		}
+		return nil, "", fmt.Errorf("unexpected status %s", response.Status)
+	}
+	var result struct {
+		Name    string `json:"name"`
+		Payload struct {
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("could not decode response: %v", err)
+	}
+	// the name of the version accessed holds its number even if the
+	// latest version was requested
+	version := result.Name[strings.LastIndex(result.Name, "/")+1:]
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(result.Payload.Data, &values); err != nil || values == nil {
+		return map[string][]byte{ValueKey: result.Payload.Data}, version, nil
+	}
+	data := map[string][]byte{}
+	for key, value := range values {
+		if s, ok := value.(string); ok {
+			data[key] = []byte(s)
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not encode %s: %v", key, err)
+		}
+		data[key] = raw
+	}
+	return data, version, nil
+}
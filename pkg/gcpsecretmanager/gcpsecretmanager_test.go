@@ -0,0 +1,92 @@
+package gcpsecretmanager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+func TestRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":401,"message":"invalid credentials"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/projects/ci/secrets/registry/versions/latest:access":
+			// {"user":"ci","port":5000}
+			w.Write([]byte(`{"name":"projects/123/secrets/registry/versions/4","payload":{"data":"eyJ1c2VyIjoiY2kiLCJwb3J0Ijo1MDAwfQ=="}}`))
+		case "/v1/projects/ci/secrets/registry/versions/3:access":
+			w.Write([]byte(`{"name":"projects/123/secrets/registry/versions/3","payload":{"data":"b2xk"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	var testCases = []struct {
+		name            string
+		token           string
+		location        config.GCPSecretManagerLocation
+		expectedData    map[string][]byte
+		expectedVersion string
+		expectedErr     bool
+	}{
+		{
+			name:            "latest version is mirrored by key",
+			token:           "token",
+			location:        config.GCPSecretManagerLocation{Name: "projects/ci/secrets/registry"},
+			expectedData:    map[string][]byte{"user": []byte("ci"), "port": []byte("5000")},
+			expectedVersion: "4",
+		},
+		{
+			name:            "pinned version is mirrored as a value",
+			token:           "token",
+			location:        config.GCPSecretManagerLocation{Name: "projects/ci/secrets/registry", Version: "3"},
+			expectedData:    map[string][]byte{ValueKey: []byte("old")},
+			expectedVersion: "3",
+		},
+		{
+			name:     "unknown secret is missing",
+			token:    "token",
+			location: config.GCPSecretManagerLocation{Name: "projects/ci/secrets/unknown"},
+		},
+		{
+			name:        "rejected token fails",
+			token:       "wrong",
+			location:    config.GCPSecretManagerLocation{Name: "projects/ci/secrets/registry"},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient(staticToken(testCase.token))
+			client.endpoint = server.URL
+			data, version, err := client.Read(config.SecretLocation{GCPSecretManager: testCase.location})
+			if err == nil && testCase.expectedErr {
+				t.Errorf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !reflect.DeepEqual(data, testCase.expectedData) {
+				t.Errorf("%s: expected data %v, got %v", testCase.name, testCase.expectedData, data)
+			}
+			if version != testCase.expectedVersion {
+				t.Errorf("%s: expected version %q, got %q", testCase.name, testCase.expectedVersion, version)
+			}
+		})
+	}
+}
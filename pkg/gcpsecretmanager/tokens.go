@@ -0,0 +1,205 @@
+package gcpsecretmanager
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// cloudPlatformScope grants access to every Google Cloud API that
+	// the IAM roles of the identity allow
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	// defaultMetadataURL is where the metadata server of GCE and GKE
+	// hands out tokens of the service account of the workload
+	defaultMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	// tokenExpiryMargin is how long before access tokens expire to
+	// fetch new ones.
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+// TokenSource provides the OAuth 2.0 access token to authorize a
+// request with.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// tokenCache reuses an access token until it is about to expire.
+type tokenCache struct {
+	lock       sync.Mutex
+	token      string
+	expiration time.Time
+}
+
+// get returns the cached token, or fetches a new one if it is about to
+// expire. The fetch returns the token and how long it is valid for.
+func (c *tokenCache) get(fetch func() (string, time.Duration, error)) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if time.Now().Add(tokenExpiryMargin).Before(c.expiration) {
+		return c.token, nil
+	}
+	token, validity, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expiration = token, time.Now().Add(validity)
+	return c.token, nil
+}
+
+// tokenResponse is the response of an OAuth 2.0 token endpoint
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// decodeTokenResponse decodes the token of a response from an OAuth 2.0
+// token endpoint.
+func decodeTokenResponse(response *http.Response) (string, time.Duration, error) {
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	var token tokenResponse
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", 0, fmt.Errorf("could not decode the access token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", 0, errors.New("no access token was issued")
+	}
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+// ServiceAccountKey authorizes requests as a service account by
+// exchanging assertions signed with a key of the service account for
+// access tokens.
+type ServiceAccountKey struct {
+	// ClientEmail identifies the service account
+	ClientEmail string `json:"client_email"`
+	// PrivateKeyID identifies the key of the service account
+	PrivateKeyID string `json:"private_key_id"`
+	// PrivateKey is the PEM-encoded private key
+	PrivateKey string `json:"private_key"`
+	// TokenURI is the endpoint issuing access tokens
+	TokenURI string `json:"token_uri"`
+
+	key    *rsa.PrivateKey
+	client *http.Client
+	cache  tokenCache
+}
+
+// LoadServiceAccountKey reads a JSON key of a service account, as
+// created by `gcloud iam service-accounts keys create`.
+func LoadServiceAccountKey(path string) (*ServiceAccountKey, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := &ServiceAccountKey{client: &http.Client{Timeout: 30 * time.Second}}
+	if err := json.Unmarshal(raw, key); err != nil {
+		return nil, fmt.Errorf("could not decode the service account key: %v", err)
+	}
+	if key.ClientEmail == "" || key.TokenURI == "" {
+		return nil, errors.New("the service account key holds no client_email and token_uri")
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, errors.New("the service account key holds no PEM-encoded private_key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("could not parse the private key: %v", err)
+		}
+	}
+	var isRSA bool
+	if key.key, isRSA = parsed.(*rsa.PrivateKey); !isRSA {
+		return nil, errors.New("the private key of the service account is not an RSA key")
+	}
+	return key, nil
+}
+
+// Token returns an access token of the service account.
+func (k *ServiceAccountKey) Token() (string, error) {
+	return k.cache.get(func() (string, time.Duration, error) {
+		assertion, err := k.assertion(time.Now())
+		if err != nil {
+			return "", 0, err
+		}
+		response, err := k.client.PostForm(k.TokenURI, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		})
+		if err != nil {
+			return "", 0, err
+		}
+		return decodeTokenResponse(response)
+	})
+}
+
+// assertion returns a JSON Web Token signed with the key of the service
+// account, which the token endpoint accepts for an hour.
+func (k *ServiceAccountKey) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": k.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   k.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// MetadataServer authorizes requests as the service account of the
+// workload, which is how Workload Identity authenticates pods on GKE.
+type MetadataServer struct {
+	client *http.Client
+	url    string
+	cache  tokenCache
+}
+
+// NewMetadataServer returns a TokenSource using the metadata server.
+func NewMetadataServer() *MetadataServer {
+	return &MetadataServer{client: &http.Client{Timeout: 30 * time.Second}, url: defaultMetadataURL}
+}
+
+// Token returns an access token of the service account of the workload.
+func (m *MetadataServer) Token() (string, error) {
+	return m.cache.get(func() (string, time.Duration, error) {
+		request, err := http.NewRequest(http.MethodGet, m.url, nil)
+		if err != nil {
+			return "", 0, err
+		}
+		request.Header.Set("Metadata-Flavor", "Google")
+		response, err := m.client.Do(request)
+		if err != nil {
+			return "", 0, fmt.Errorf("could not reach the metadata server: %v", err)
+		}
+		return decodeTokenResponse(response)
+	})
+}
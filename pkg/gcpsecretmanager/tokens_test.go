@@ -0,0 +1,121 @@
+package gcpsecretmanager
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServiceAccountKey(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchanges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		assertion := r.FormValue("assertion")
+		parts := strings.Split(assertion, ".")
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			t.Errorf("failed to decode signature: %v", err)
+		}
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&private.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Errorf("failed to decode claims: %v", err)
+		}
+		var claims map[string]interface{}
+		if err := json.Unmarshal(rawClaims, &claims); err != nil {
+			t.Errorf("failed to decode claims: %v", err)
+		}
+		if claims["iss"] != "mirror@ci.iam.gserviceaccount.com" || claims["scope"] != cloudPlatformScope {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	encoded, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "mirror@ci.iam.gserviceaccount.com",
+		"private_key_id": "id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded})),
+		"token_uri":      server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key.json")
+	if err := ioutil.WriteFile(path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := LoadServiceAccountKey(path)
+	if err != nil {
+		t.Fatalf("expected the key to be loaded, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		token, err := key.Token()
+		if err != nil {
+			t.Fatalf("expected an access token, got %v", err)
+		}
+		if token != "token" {
+			t.Errorf("expected the issued access token, got %q", token)
+		}
+	}
+	if exchanges != 1 {
+		t.Errorf("expected the access token to be reused until it expires, got %d exchanges", exchanges)
+	}
+}
+
+func TestMetadataServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token","expires_in":3600,"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	metadata := NewMetadataServer()
+	metadata.url = server.URL
+	token, err := metadata.Token()
+	if err != nil {
+		t.Fatalf("expected an access token, got %v", err)
+	}
+	if token != "token" {
+		t.Errorf("expected the issued access token, got %q", token)
+	}
+}
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// Notification is a failure notification about one mirroring rule.
+type Notification struct {
+	Rule    string `json:"rule"`
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+}
+
+// Notifier delivers notifications to the destinations routed for a rule.
+type Notifier struct {
+	// SlackToken authenticates posts to Slack channels
+	SlackToken string
+	// SMTPAddress is the host:port of the relay used to send mail
+	SMTPAddress string
+	// SMTPFrom is the sender of mailed notifications
+	SMTPFrom string
+
+	client   *http.Client
+	slackURL string
+}
+
+// NewNotifier returns a Notifier posting to Slack with the given token and
+// sending mail through the given unauthenticated SMTP relay. Either may be
+// empty, in which case notifications routed there fail.
+func NewNotifier(slackToken, smtpAddress, smtpFrom string) *Notifier {
+	return &Notifier{
+		SlackToken:  slackToken,
+		SMTPAddress: smtpAddress,
+		SMTPFrom:    smtpFrom,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		slackURL:    slackPostMessageURL,
+	}
+}
+
+// Notify sends the notification to every configured destination, returning
+// an aggregate of the errors for destinations that could not be reached.
+func (n *Notifier) Notify(routes config.Notifications, notification Notification) error {
+	var messages []string
+	if routes.SlackChannel != "" {
+		if err := n.slack(routes.SlackChannel, notification); err != nil {
+			messages = append(messages, fmt.Sprintf("slack channel %s: %v", routes.SlackChannel, err))
+		}
+	}
+	if routes.WebhookURL != "" {
+		if err := n.webhook(routes.WebhookURL, notification); err != nil {
+			messages = append(messages, fmt.Sprintf("webhook: %v", err))
+		}
+	}
+	if len(routes.Emails) > 0 {
+		if err := n.mail(routes.Emails, notification); err != nil {
+			messages = append(messages, fmt.Sprintf("email: %v", err))
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("failed to deliver notification: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+func (n *Notifier) slack(channel string, notification Notification) error {
+	if n.SlackToken == "" {
+		return errors.New("no Slack token is configured")
+	}
+	body, err := json.Marshal(map[string]string{
+		"channel": channel,
+		"text":    fmt.Sprintf("*%s*\n%s", notification.Subject, notification.Message),
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, n.slackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	request.Header.Set("Authorization", "Bearer "+n.SlackToken)
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// Slack reports most failures in the body of a 200 response
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("could not decode response (status %d): %v", response.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack API error: %s", result.Error)
+	}
+	return nil
+}
+
+func (n *Notifier) webhook(url string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	response, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+func (n *Notifier) mail(to []string, notification Notification) error {
+	if n.SMTPAddress == "" || n.SMTPFrom == "" {
+		return errors.New("no SMTP relay is configured")
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n",
+		n.SMTPFrom, strings.Join(to, ", "), notification.Subject, notification.Message)
+	return smtp.SendMail(n.SMTPAddress, nil, n.SMTPFrom, to, []byte(message))
+}
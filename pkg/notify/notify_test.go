@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestNotify(t *testing.T) {
+	notification := Notification{Rule: "(a/b -> c/d)", Subject: "subject", Message: "message"}
+
+	var webhookReceived Notification
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&webhookReceived); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+	}))
+	defer webhook.Close()
+
+	var slackReceived map[string]string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			w.Write([]byte(`{"ok":false,"error":"not_authed"}`))
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&slackReceived); err != nil {
+			t.Errorf("failed to decode slack payload: %v", err)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer slack.Close()
+
+	var testCases = []struct {
+		name        string
+		token       string
+		routes      config.Notifications
+		expectedErr bool
+	}{
+		{
+			name:   "webhook receives the notification",
+			routes: config.Notifications{WebhookURL: webhook.URL},
+		},
+		{
+			name:   "slack receives the notification",
+			token:  "token",
+			routes: config.Notifications{SlackChannel: "#team"},
+		},
+		{
+			name:        "slack rejects a bad token",
+			token:       "wrong",
+			routes:      config.Notifications{SlackChannel: "#team"},
+			expectedErr: true,
+		},
+		{
+			name:        "slack without a token fails",
+			routes:      config.Notifications{SlackChannel: "#team"},
+			expectedErr: true,
+		},
+		{
+			name:        "email without a relay fails",
+			routes:      config.Notifications{Emails: []string{"team@example.com"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			webhookReceived, slackReceived = Notification{}, nil
+			notifier := NewNotifier(testCase.token, "", "")
+			notifier.slackURL = slack.URL
+			err := notifier.Notify(testCase.routes, notification)
+			if err == nil && testCase.expectedErr {
+				t.Errorf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if testCase.expectedErr {
+				return
+			}
+			if testCase.routes.WebhookURL != "" && !reflect.DeepEqual(webhookReceived, notification) {
+				t.Errorf("%s: webhook received %v, expected %v", testCase.name, webhookReceived, notification)
+			}
+			if testCase.routes.SlackChannel != "" && slackReceived["channel"] != testCase.routes.SlackChannel {
+				t.Errorf("%s: slack received %v, expected a post to %s", testCase.name, slackReceived, testCase.routes.SlackChannel)
+			}
+		})
+	}
+}
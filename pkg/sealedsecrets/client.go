@@ -0,0 +1,75 @@
+package sealedsecrets
+
+import (
+	"crypto/rsa"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/rest"
+)
+
+// Client seals secret data with the sealing key of a cluster and writes
+// SealedSecrets to it.
+type Client struct {
+	client rest.Interface
+	key    *rsa.PublicKey
+}
+
+// NewClient returns a Client for the cluster that seals data with the
+// public key of its sealing key.
+func NewClient(clusterConfig *rest.Config, key *rsa.PublicKey) (*Client, error) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	restConfig := *clusterConfig
+	restConfig.GroupVersion = &SchemeGroupVersion
+	restConfig.APIPath = "/apis"
+	restConfig.ContentType = runtime.ContentTypeJSON
+	restConfig.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
+	client, err := rest.RESTClientFor(&restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{client: client, key: key}, nil
+}
+
+// Seal encrypts every value of the data for the secret with the name in
+// the namespace.
+func (c *Client) Seal(namespace, name string, data map[string][]byte) (map[string]string, error) {
+	encrypted := make(map[string]string, len(data))
+	for key, value := range data {
+		sealed, err := Seal(c.key, namespace, name, value)
+		if err != nil {
+			return nil, fmt.Errorf("could not seal %s: %v", key, err)
+		}
+		encrypted[key] = sealed
+	}
+	return encrypted, nil
+}
+
+// Fetch returns the SealedSecret with the name in the namespace.
+func (c *Client) Fetch(namespace, name string) (*SealedSecret, error) {
+	sealed := &SealedSecret{}
+	if err := c.client.Get().Namespace(namespace).Resource(Resource).Name(name).Do().Into(sealed); err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
+
+// Store creates the SealedSecret, or replaces it if it was fetched from
+// the cluster, returning it as stored.
+func (c *Client) Store(sealed *SealedSecret) (*SealedSecret, error) {
+	sealed = sealed.DeepCopy()
+	sealed.APIVersion, sealed.Kind = SchemeGroupVersion.String(), "SealedSecret"
+	request := c.client.Post().Namespace(sealed.Namespace).Resource(Resource)
+	if sealed.ResourceVersion != "" {
+		request = c.client.Put().Namespace(sealed.Namespace).Resource(Resource).Name(sealed.Name)
+	}
+	stored := &SealedSecret{}
+	if err := request.Body(sealed).Do().Into(stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
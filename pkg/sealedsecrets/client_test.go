@@ -0,0 +1,86 @@
+package sealedsecrets
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	stored := map[string]*SealedSecret{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		const prefix = "/apis/bitnami.com/v1alpha1/namespaces/ci/sealedsecrets"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == prefix+"/registry":
+			sealed, found := stored["registry"]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound})
+				return
+			}
+			json.NewEncoder(w).Encode(sealed)
+		case (r.Method == http.MethodPost && r.URL.Path == prefix) || (r.Method == http.MethodPut && r.URL.Path == prefix+"/registry"):
+			sealed := &SealedSecret{}
+			if err := json.NewDecoder(r.Body).Decode(sealed); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			if sealed.APIVersion != "bitnami.com/v1alpha1" || sealed.Kind != "SealedSecret" {
+				t.Errorf("expected the kind of the object to be sent, got %s %s", sealed.APIVersion, sealed.Kind)
+			}
+			sealed.ResourceVersion += "1"
+			stored[sealed.Name] = sealed
+			json.NewEncoder(w).Encode(sealed)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&rest.Config{Host: server.URL}, &key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := client.Fetch("ci", "registry"); !errors.IsNotFound(err) {
+		t.Fatalf("expected the SealedSecret to be missing, got %v", err)
+	}
+	encrypted, err := client.Seal("ci", "registry", map[string][]byte{"password": []byte("secret")})
+	if err != nil {
+		t.Fatalf("expected the data to be sealed, got %v", err)
+	}
+	if value, err := unseal(t, key, "ci/registry", encrypted["password"]); err != nil || string(value) != "secret" {
+		t.Errorf("expected the data to be sealed for the secret, got %q and %v", value, err)
+	}
+	created, err := client.Store(&SealedSecret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "registry"},
+		Spec:       SealedSecretSpec{EncryptedData: encrypted},
+	})
+	if err != nil {
+		t.Fatalf("expected the SealedSecret to be created, got %v", err)
+	}
+	fetched, err := client.Fetch("ci", "registry")
+	if err != nil {
+		t.Fatalf("expected the SealedSecret to be fetched, got %v", err)
+	}
+	if fetched.ResourceVersion != created.ResourceVersion || fetched.Spec.EncryptedData["password"] != encrypted["password"] {
+		t.Errorf("expected the created SealedSecret to be fetched, got %+v", fetched)
+	}
+	updated, err := client.Store(fetched)
+	if err != nil {
+		t.Fatalf("expected the SealedSecret to be replaced, got %v", err)
+	}
+	if updated.ResourceVersion != "11" {
+		t.Errorf("expected the fetched SealedSecret to be replaced, got version %q", updated.ResourceVersion)
+	}
+}
@@ -0,0 +1,83 @@
+package sealedsecrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// sessionKeyBytes is the size of the AES-256 key every value is
+// encrypted with
+const sessionKeyBytes = 32
+
+// LoadCertificate reads the public key of the sealing key of the
+// cluster from a PEM-encoded certificate, as fetched by
+// `kubeseal --fetch-cert`.
+func LoadCertificate(path string) (*rsa.PublicKey, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("the file holds no PEM-encoded certificate")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the certificate: %v", err)
+	}
+	key, isRSA := certificate.PublicKey.(*rsa.PublicKey)
+	if !isRSA {
+		return nil, errors.New("the certificate does not hold an RSA public key")
+	}
+	return key, nil
+}
+
+// Seal encrypts the value for the secret with the name in the namespace,
+// returning it encoded as it is held by a SealedSecret. Values are
+// sealed in the strict scope, so the sealed-secrets controller only
+// decrypts them into a secret of that name in that namespace.
+func Seal(key *rsa.PublicKey, namespace, name string, value []byte) (string, error) {
+	sealed, err := hybridEncrypt(rand.Reader, key, value, []byte(namespace+"/"+name))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// hybridEncrypt encrypts the plaintext the way the sealed-secrets
+// controller expects: with a random session key, which is encrypted
+// with the public key, labelled with the scope, and prepended with its
+// length. Every session key is used once, so the nonce is always zero.
+func hybridEncrypt(random io.Reader, key *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, sessionKeyBytes)
+	if _, err := io.ReadFull(random, sessionKey); err != nil {
+		return nil, fmt.Errorf("could not generate a session key: %v", err)
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), random, key, sessionKey, label)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt the session key: %v", err)
+	}
+	ciphertext := make([]byte, 2, 2+len(encryptedKey)+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(encryptedKey)))
+	ciphertext = append(ciphertext, encryptedKey...)
+	return aead.Seal(ciphertext, make([]byte, aead.NonceSize()), plaintext, nil), nil
+}
@@ -0,0 +1,111 @@
+package sealedsecrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// unseal decrypts a sealed value the way the sealed-secrets controller does.
+func unseal(t *testing.T, key *rsa.PrivateKey, label, sealed string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatalf("sealed value is not base64: %v", err)
+	}
+	keyLength := int(binary.BigEndian.Uint16(ciphertext))
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, ciphertext[2:2+keyLength], []byte(label))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		t.Fatalf("session key is not an AES key: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("could not set up GCM: %v", err)
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[2+keyLength:], nil)
+}
+
+func TestSeal(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	sealed, err := Seal(&key.PublicKey, "ci", "registry", []byte("password"))
+	if err != nil {
+		t.Fatalf("expected the value to be sealed, got %v", err)
+	}
+	value, err := unseal(t, key, "ci/registry", sealed)
+	if err != nil {
+		t.Fatalf("expected the value to be unsealed, got %v", err)
+	}
+	if string(value) != "password" {
+		t.Errorf("expected the sealed value to be unsealed, got %q", value)
+	}
+	if _, err := unseal(t, key, "other/registry", sealed); err == nil {
+		t.Error("expected the value not to be unsealed for another secret")
+	}
+	again, err := Seal(&key.PublicKey, "ci", "registry", []byte("password"))
+	if err != nil {
+		t.Fatalf("expected the value to be sealed, got %v", err)
+	}
+	if again == sealed {
+		t.Error("expected every sealing to use a new session key")
+	}
+}
+
+func TestLoadCertificate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sealed-secret"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "sealedsecrets")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	loaded, err := LoadCertificate(path)
+	if err != nil {
+		t.Fatalf("expected the certificate to be loaded, got %v", err)
+	}
+	if loaded.N.Cmp(key.PublicKey.N) != 0 || loaded.E != key.PublicKey.E {
+		t.Error("expected the public key of the certificate to be loaded")
+	}
+
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	if _, err := LoadCertificate(path); err == nil {
+		t.Error("expected files without a certificate to be refused")
+	}
+}
@@ -0,0 +1,87 @@
+package sealedsecrets
+
+import (
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group of SealedSecrets.
+const GroupName = "bitnami.com"
+
+// Resource is the plural name SealedSecrets are served under.
+const Resource = "sealedsecrets"
+
+// SchemeGroupVersion is the version of the API SealedSecrets are
+// written with.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+var (
+	// SchemeBuilder registers the types of the API with a scheme.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme registers the types of the API with the scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &SealedSecret{})
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
+
+// SealedSecret holds secret data encrypted for the sealed-secrets
+// controller of a cluster, which decrypts it into a Secret of the same
+// name and namespace.
+type SealedSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SealedSecretSpec `json:"spec"`
+}
+
+// SealedSecretSpec holds the encrypted data and the metadata of the
+// Secret that is created from it.
+type SealedSecretSpec struct {
+	// Template is the metadata and type of the Secret
+	Template SecretTemplateSpec `json:"template,omitempty"`
+
+	// EncryptedData maps keys of the Secret to their sealed values
+	EncryptedData map[string]string `json:"encryptedData"`
+}
+
+// SecretTemplateSpec is the metadata and type of the Secret that is
+// created from a SealedSecret.
+type SecretTemplateSpec struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Type coreapi.SecretType `json:"type,omitempty"`
+}
+
+// DeepCopyInto copies the object into out.
+func (in *SealedSecret) DeepCopyInto(out *SealedSecret) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.Template.ObjectMeta.DeepCopyInto(&out.Spec.Template.ObjectMeta)
+	if in.Spec.EncryptedData != nil {
+		out.Spec.EncryptedData = make(map[string]string, len(in.Spec.EncryptedData))
+		for key, value := range in.Spec.EncryptedData {
+			out.Spec.EncryptedData[key] = value
+		}
+	}
+}
+
+// DeepCopy copies the object.
+func (in *SealedSecret) DeepCopy() *SealedSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(SealedSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the object.
+func (in *SealedSecret) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
@@ -0,0 +1,175 @@
+package secretsmanager
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSTSURL is the global endpoint of the AWS Security Token Service
+	defaultSTSURL = "https://sts.amazonaws.com"
+	// credentialExpiryMargin is how long before temporary credentials
+	// expire to exchange the web identity token for new ones.
+	credentialExpiryMargin = 5 * time.Minute
+)
+
+// Credentials authenticate requests to AWS.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only set for temporary credentials
+	SessionToken string
+}
+
+// CredentialSource provides the credentials to sign a request with.
+type CredentialSource interface {
+	Credentials() (Credentials, error)
+}
+
+// StaticCredentials are long-lived credentials of an IAM user.
+type StaticCredentials Credentials
+
+// Credentials returns the static credentials.
+func (c StaticCredentials) Credentials() (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// LoadCredentialsFile reads the static credentials of a profile from a
+// file in the format of the shared AWS credentials file, e.g.
+// ~/.aws/credentials.
+func LoadCredentialsFile(path, profile string) (StaticCredentials, error) {
+	var credentials StaticCredentials
+	file, err := os.Open(path)
+	if err != nil {
+		return credentials, err
+	}
+	defer file.Close()
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		case section != profile:
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "aws_access_key_id":
+			credentials.AccessKeyID = value
+		case "aws_secret_access_key":
+			credentials.SecretAccessKey = value
+		case "aws_session_token":
+			credentials.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return credentials, err
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return credentials, fmt.Errorf("profile %s in %s holds no aws_access_key_id and aws_secret_access_key", profile, path)
+	}
+	return credentials, nil
+}
+
+// WebIdentityCredentials are temporary credentials of an IAM role,
+// obtained by exchanging a web identity token with the AWS Security
+// Token Service. This is how IAM roles for service accounts (IRSA)
+// authenticate pods, whose projected service account token is the web
+// identity token. The token file is re-read for every exchange, as the
+// kubelet rotates it.
+type WebIdentityCredentials struct {
+	// RoleARN is the role to assume
+	RoleARN string
+	// TokenFile is the path of the web identity token
+	TokenFile string
+	// SessionName identifies the controller in the audit trail of AWS
+	SessionName string
+
+	client *http.Client
+	stsURL string
+
+	lock        sync.Mutex
+	credentials Credentials
+	expiration  time.Time
+}
+
+// NewWebIdentityCredentials returns credentials for the role that are
+// exchanged for the token in the file, using the regional endpoint of
+// the Security Token Service if a region is given.
+func NewWebIdentityCredentials(roleARN, tokenFile, sessionName, region string) *WebIdentityCredentials {
+	stsURL := defaultSTSURL
+	if region != "" {
+		stsURL = fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+	}
+	return &WebIdentityCredentials{
+		RoleARN:     roleARN,
+		TokenFile:   tokenFile,
+		SessionName: sessionName,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		stsURL:      stsURL,
+	}
+}
+
+// Credentials returns the temporary credentials of the role, assuming it
+// again once they are about to expire.
+func (c *WebIdentityCredentials) Credentials() (Credentials, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if time.Now().Add(credentialExpiryMargin).Before(c.expiration) {
+		return c.credentials, nil
+	}
+	token, err := ioutil.ReadFile(c.TokenFile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("could not read the web identity token: %v", err)
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {c.RoleARN},
+		"RoleSessionName":  {c.SessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	response, err := c.client.PostForm(c.stsURL, query)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("could not assume role %s: %v", c.RoleARN, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("could not assume role %s: unexpected status %s", c.RoleARN, response.Status)
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(response.Body).Decode(&result); err != nil {
+		return Credentials{}, fmt.Errorf("could not decode the credentials of role %s: %v", c.RoleARN, err)
+	}
+	c.credentials = Credentials{
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+	}
+	c.expiration = result.Credentials.Expiration
+	return c.credentials, nil
+}
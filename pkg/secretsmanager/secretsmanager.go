@@ -0,0 +1,116 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+// ValueKey is the key under which secrets that do not hold a JSON object
+// are mirrored.
+const ValueKey = "value"
+
+// Client reads secrets from AWS Secrets Manager.
+type Client struct {
+	credentials CredentialSource
+	client      *http.Client
+	// endpoint returns the URL of the service for a region in a partition
+	endpoint func(partition, region string) string
+}
+
+// NewClient returns a Client that authenticates with the credentials
+// from the source.
+func NewClient(credentials CredentialSource) *Client {
+	return &Client{
+		credentials: credentials,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		endpoint:    endpoint,
+	}
+}
+
+// endpoint returns the URL of the service in the region, whose domain
+// depends on the partition.
+func endpoint(partition, region string) string {
+	domain := "amazonaws.com"
+	if partition == "aws-cn" {
+		domain = "amazonaws.com.cn"
+	}
+	return fmt.Sprintf("https://secretsmanager.%s.%s", region, domain)
+}
+
+// Read returns the data of the current version of the secret with the
+// ARN of the location and the ID of that version, or no data if the
+// secret does not exist. Secrets holding a JSON object are mirrored key
+// by key, encoding values that are not strings as JSON, while any other
+// secret is mirrored under ValueKey.
+func (c *Client) Read(location config.SecretLocation) (map[string][]byte, string, error) {
+	credentials, err := c.credentials.Credentials()
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": location.AWSSecretsManager.ARN})
+	if err != nil {
+		return nil, "", err
+	}
+	region := location.AWSSecretsManager.Region()
+	request, err := http.NewRequest(http.MethodPost, c.endpoint(location.AWSSecretsManager.Partition(), region)+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sign(request, body, credentials, "secretsmanager", region, time.Now())
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&failure); err != nil {
+			return nil, "", fmt.Errorf("unexpected status %s", response.Status)
+		}
+		// the type may be prefixed with the namespace of the service
+		if strings.HasSuffix(failure.Type, "ResourceNotFoundException") {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("unexpected status %s: %s: %s", response.Status, failure.Type, failure.Message)
+	}
+	var result struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+		VersionID    string  `json:"VersionId"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("could not decode response: %v", err)
+	}
+	if result.SecretString == nil {
+		return map[string][]byte{ValueKey: result.SecretBinary}, result.VersionID, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(*result.SecretString), &values); err != nil || values == nil {
+		return map[string][]byte{ValueKey: []byte(*result.SecretString)}, result.VersionID, nil
+	}
+	data := map[string][]byte{}
+	for key, value := range values {
+		if s, ok := value.(string); ok {
+			data[key] = []byte(s)
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not encode %s: %v", key, err)
+		}
+		data[key] = raw
+	}
+	return data, result.VersionID, nil
+}
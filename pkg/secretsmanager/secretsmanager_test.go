@@ -0,0 +1,176 @@
+package secretsmanager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRead(t *testing.T) {
+	var region string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"__type":"UnrecognizedClientException","message":"invalid token"}`))
+			return
+		}
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var request struct{ SecretId string }
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		switch {
+		case strings.HasSuffix(request.SecretId, ":secret:ci/registry"):
+			w.Write([]byte(`{"SecretString":"{\"user\":\"ci\",\"port\":5000}","VersionId":"v2"}`))
+		case strings.HasSuffix(request.SecretId, ":secret:ci/token"):
+			w.Write([]byte(`{"SecretString":"token","VersionId":"v1"}`))
+		case strings.HasSuffix(request.SecretId, ":secret:ci/binary"):
+			w.Write([]byte(`{"SecretBinary":"AAE=","VersionId":"v1"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	var testCases = []struct {
+		name            string
+		arn             string
+		credentials     StaticCredentials
+		expectedData    map[string][]byte
+		expectedVersion string
+		expectedErr     bool
+	}{
+		{
+			name:            "JSON object is mirrored by key",
+			arn:             "arn:aws:secretsmanager:eu-west-1:123456789012:secret:ci/registry",
+			expectedData:    map[string][]byte{"user": []byte("ci"), "port": []byte("5000")},
+			expectedVersion: "v2",
+		},
+		{
+			name:            "string is mirrored as a value",
+			arn:             "arn:aws:secretsmanager:eu-west-1:123456789012:secret:ci/token",
+			expectedData:    map[string][]byte{ValueKey: []byte("token")},
+			expectedVersion: "v1",
+		},
+		{
+			name:            "binary is mirrored as a value",
+			arn:             "arn:aws:secretsmanager:eu-west-1:123456789012:secret:ci/binary",
+			expectedData:    map[string][]byte{ValueKey: {0, 1}},
+			expectedVersion: "v1",
+		},
+		{
+			name: "unknown secret is missing",
+			arn:  "arn:aws:secretsmanager:eu-west-1:123456789012:secret:ci/unknown",
+		},
+		{
+			name:        "rejected credentials fail",
+			arn:         "arn:aws:secretsmanager:eu-west-1:123456789012:secret:ci/registry",
+			credentials: StaticCredentials{AccessKeyID: "other", SecretAccessKey: "secret"},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			credentials := testCase.credentials
+			if credentials.AccessKeyID == "" {
+				credentials = StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}
+			}
+			client := NewClient(credentials)
+			client.endpoint = func(partition, r string) string {
+				region = r
+				return server.URL
+			}
+			data, version, err := client.Read(config.SecretLocation{AWSSecretsManager: config.AWSSecretsManagerLocation{ARN: testCase.arn}})
+			if err == nil && testCase.expectedErr {
+				t.Errorf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !reflect.DeepEqual(data, testCase.expectedData) {
+				t.Errorf("%s: expected data %v, got %v", testCase.name, testCase.expectedData, data)
+			}
+			if version != testCase.expectedVersion {
+				t.Errorf("%s: expected version %q, got %q", testCase.name, testCase.expectedVersion, version)
+			}
+			if region != "eu-west-1" {
+				t.Errorf("%s: expected the region of the secret to be used, got %q", testCase.name, region)
+			}
+		})
+	}
+}
+
+func TestLoadCredentialsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+	content := "[other]\naws_access_key_id = OTHER\n\n[default]\n# comment\naws_access_key_id = AKID\naws_secret_access_key = secret\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := LoadCredentialsFile(path, "default")
+	if err != nil {
+		t.Fatalf("expected the credentials to be loaded, got %v", err)
+	}
+	if expected := (StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}); credentials != expected {
+		t.Errorf("expected %+v, got %+v", expected, credentials)
+	}
+	if _, err := LoadCredentialsFile(path, "other"); err == nil {
+		t.Error("expected a profile without a secret access key to be rejected")
+	}
+}
+
+func TestWebIdentityCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exchanges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		if r.FormValue("Action") != "AssumeRoleWithWebIdentity" || r.FormValue("WebIdentityToken") != "jwt" || r.FormValue("RoleArn") != "arn:aws:iam::123456789012:role/mirror" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>ASIA</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>session</SessionToken>
+<Expiration>2999-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer server.Close()
+
+	source := NewWebIdentityCredentials("arn:aws:iam::123456789012:role/mirror", tokenFile, "mirror", "")
+	source.stsURL = server.URL
+	for i := 0; i < 2; i++ {
+		credentials, err := source.Credentials()
+		if err != nil {
+			t.Fatalf("expected the role to be assumed, got %v", err)
+		}
+		if expected := (Credentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "session"}); credentials != expected {
+			t.Errorf("expected %+v, got %+v", expected, credentials)
+		}
+	}
+	if exchanges != 1 {
+		t.Errorf("expected the credentials to be reused until they expire, got %d exchanges", exchanges)
+	}
+}
@@ -0,0 +1,131 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+func init() {
+	Register("filter", newFilter)
+	Register("rename", newRename)
+	Register("template", newTemplate)
+}
+
+// filter keeps only the included keys, or drops the excluded keys.
+type filter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func newFilter(config json.RawMessage) (Transform, error) {
+	f := &filter{}
+	if err := decode(config, f); err != nil {
+		return nil, err
+	}
+	if (len(f.Include) == 0) == (len(f.Exclude) == 0) {
+		return nil, errors.New("exactly one of include or exclude must be set")
+	}
+	return f, nil
+}
+
+func (f *filter) Transform(data map[string][]byte) (map[string][]byte, error) {
+	if len(f.Include) != 0 {
+		filtered := map[string][]byte{}
+		for _, key := range f.Include {
+			if value, present := data[key]; present {
+				filtered[key] = value
+			}
+		}
+		return filtered, nil
+	}
+	filtered := copyData(data)
+	for _, key := range f.Exclude {
+		delete(filtered, key)
+	}
+	return filtered, nil
+}
+
+// rename moves values to new keys.
+type rename struct {
+	// Keys maps source keys to the keys they are renamed to
+	Keys map[string]string `json:"keys"`
+}
+
+func newRename(config json.RawMessage) (Transform, error) {
+	r := &rename{}
+	if err := decode(config, r); err != nil {
+		return nil, err
+	}
+	if len(r.Keys) == 0 {
+		return nil, errors.New("keys must not be empty")
+	}
+	targets := map[string]string{}
+	for from, to := range r.Keys {
+		if len(to) == 0 {
+			return nil, fmt.Errorf("key %q must not be renamed to an empty key", from)
+		}
+		if other, duplicate := targets[to]; duplicate {
+			return nil, fmt.Errorf("keys %q and %q are both renamed to %q", other, from, to)
+		}
+		targets[to] = from
+	}
+	return r, nil
+}
+
+func (r *rename) Transform(data map[string][]byte) (map[string][]byte, error) {
+	renamed := copyData(data)
+	for from := range r.Keys {
+		delete(renamed, from)
+	}
+	for from, to := range r.Keys {
+		if value, present := data[from]; present {
+			if _, clash := renamed[to]; clash {
+				return nil, fmt.Errorf("renaming %q would overwrite key %q", from, to)
+			}
+			renamed[to] = value
+		}
+	}
+	return renamed, nil
+}
+
+// templated renders a key from a Go template, which is executed with
+// the source data as a map of strings.
+type templated struct {
+	Key      string `json:"key"`
+	Template string `json:"template"`
+
+	template *template.Template
+}
+
+func newTemplate(config json.RawMessage) (Transform, error) {
+	t := &templated{}
+	if err := decode(config, t); err != nil {
+		return nil, err
+	}
+	if len(t.Key) == 0 {
+		return nil, errors.New("key must not be empty")
+	}
+	parsed, err := template.New(t.Key).Option("missingkey=error").Parse(t.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+	t.template = parsed
+	return t, nil
+}
+
+func (t *templated) Transform(data map[string][]byte) (map[string][]byte, error) {
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		values[key] = string(value)
+	}
+	var rendered bytes.Buffer
+	if err := t.template.Execute(&rendered, values); err != nil {
+		return nil, fmt.Errorf("failed to render key %q: %v", t.Key, err)
+	}
+	transformed := copyData(data)
+	transformed[t.Key] = rendered.Bytes()
+	return transformed, nil
+}
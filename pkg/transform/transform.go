@@ -0,0 +1,121 @@
+// Package transform holds the transforms that rules may apply to the data
+// mirrored from a source before it is written to the target.
+//
+// A transform is registered under a name with a Factory, which builds it
+// from the configuration given for it in a rule:
+//
+//	transforms:
+//	- name: rename
+//	  config:
+//	    keys:
+//	      prod_token: token
+//
+// Implementations must honor the following contract, which the
+// transformtest package checks:
+//
+//   - Transform must not modify the data it is given, as it is shared with
+//     the informer cache; it returns new data instead.
+//   - Transform must be deterministic: the same input yields the same output,
+//     otherwise targets are rewritten on every reconciliation.
+//   - Transform must be safe to call concurrently.
+//   - Factories must reject invalid configuration, as they are called when
+//     the configuration is validated; Transform should only fail because
+//     of the data it is given.
+//   - Transform should return an IgnoredError along with the data to write
+//     when it fails but is configured to ignore failures, so that the
+//     controller can surface the failure.
+//   - Transforms are built once per configuration load and reused, so any
+//     files they read are only read again when the configuration changes.
+//
+// Downstream forks can compile in their own transforms by registering them
+// from an init function in a package imported by the controller binary.
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Transform changes the data mirrored from a source.
+type Transform interface {
+	// Transform returns the data to write to the target.
+	Transform(data map[string][]byte) (map[string][]byte, error)
+}
+
+// Func adapts a function to the Transform interface.
+type Func func(data map[string][]byte) (map[string][]byte, error)
+
+// Transform calls f.
+func (f Func) Transform(data map[string][]byte) (map[string][]byte, error) {
+	return f(data)
+}
+
+// Factory builds a transform from its configuration, which is
+// empty if the rule configures none.
+type Factory func(config json.RawMessage) (Transform, error)
+
+var (
+	lock      sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a transform available to rules under the name.
+// It panics if the name is already taken.
+func Register(name string, factory Factory) {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, registered := factories[name]; registered {
+		panic(fmt.Sprintf("transform %q is registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// New builds the transform registered under the name.
+func New(name string, config json.RawMessage) (Transform, error) {
+	lock.RLock()
+	factory, registered := factories[name]
+	lock.RUnlock()
+	if !registered {
+		return nil, fmt.Errorf("unknown transform %q, must be one of %v", name, Names())
+	}
+	transform, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration for transform %q: %v", name, err)
+	}
+	return transform, nil
+}
+
+// Names lists the registered transforms.
+func Names() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// copyData returns a shallow copy of data that may be modified
+// without touching the original.
+func copyData(data map[string][]byte) map[string][]byte {
+	copied := make(map[string][]byte, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied
+}
+
+// decode strictly unmarshals configuration, which may be empty.
+func decode(config json.RawMessage, into interface{}) error {
+	if len(config) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(into)
+}
@@ -0,0 +1,171 @@
+package transform_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform/transformtest"
+)
+
+var inputs = []map[string][]byte{
+	{},
+	{"prod_token": []byte("secret"), "user": []byte("ci"), "url": []byte("https://example.com")},
+	{"token": []byte("clash"), "prod_token": []byte("secret")},
+}
+
+func TestBuiltinConformance(t *testing.T) {
+	server, caFile := tokenizer(t)
+	defer server.Close()
+	defer os.RemoveAll(filepath.Dir(caFile))
+	configs := map[string]string{
+		"filter":   `{"include":["user","url"]}`,
+		"rename":   `{"keys":{"prod_token":"token"}}`,
+		"template": `{"key":"login","template":"{{ index . \"user\" }}"}`,
+		"webhook":  fmt.Sprintf(`{"url":%q,"caFile":%q}`, server.URL, caFile),
+	}
+	for _, name := range transform.Names() {
+		config, configured := configs[name]
+		if !configured {
+			t.Errorf("no conformance configuration for transform %q", name)
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			tr, err := transform.New(name, json.RawMessage(config))
+			if err != nil {
+				t.Fatalf("failed to build transform: %v", err)
+			}
+			transformtest.Conformance(t, tr, inputs...)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		transform   string
+		config      string
+		expectedErr bool
+	}{
+		{
+			name:        "unknown transforms are rejected",
+			transform:   "tokenize",
+			expectedErr: true,
+		},
+		{
+			name:        "filter requires keys",
+			transform:   "filter",
+			expectedErr: true,
+		},
+		{
+			name:        "filter cannot both include and exclude",
+			transform:   "filter",
+			config:      `{"include":["a"],"exclude":["b"]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "unknown fields are rejected",
+			transform:   "filter",
+			config:      `{"includes":["a"]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "rename cannot merge keys",
+			transform:   "rename",
+			config:      `{"keys":{"a":"c","b":"c"}}`,
+			expectedErr: true,
+		},
+		{
+			name:        "templates must parse",
+			transform:   "template",
+			config:      `{"key":"a","template":"{{ .user"}`,
+			expectedErr: true,
+		},
+		{
+			name:      "valid configuration builds",
+			transform: "rename",
+			config:    `{"keys":{"a":"b"}}`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := transform.New(testCase.transform, json.RawMessage(testCase.config))
+			if err == nil && testCase.expectedErr {
+				t.Errorf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+		})
+	}
+}
+
+func TestBuiltinTransforms(t *testing.T) {
+	data := map[string][]byte{"prod_token": []byte("secret"), "user": []byte("ci")}
+	var testCases = []struct {
+		name        string
+		transform   string
+		config      string
+		expected    map[string][]byte
+		expectedErr bool
+	}{
+		{
+			name:      "filter includes keys",
+			transform: "filter",
+			config:    `{"include":["user","missing"]}`,
+			expected:  map[string][]byte{"user": []byte("ci")},
+		},
+		{
+			name:      "filter excludes keys",
+			transform: "filter",
+			config:    `{"exclude":["user"]}`,
+			expected:  map[string][]byte{"prod_token": []byte("secret")},
+		},
+		{
+			name:      "rename moves keys",
+			transform: "rename",
+			config:    `{"keys":{"prod_token":"token","missing":"other"}}`,
+			expected:  map[string][]byte{"token": []byte("secret"), "user": []byte("ci")},
+		},
+		{
+			name:        "rename refuses to overwrite keys",
+			transform:   "rename",
+			config:      `{"keys":{"prod_token":"user"}}`,
+			expectedErr: true,
+		},
+		{
+			name:      "template renders a key",
+			transform: "template",
+			config:    `{"key":"auth","template":"{{ .user }}:{{ .prod_token }}"}`,
+			expected:  map[string][]byte{"prod_token": []byte("secret"), "user": []byte("ci"), "auth": []byte("ci:secret")},
+		},
+		{
+			name:        "template fails on missing keys",
+			transform:   "template",
+			config:      `{"key":"auth","template":"{{ .password }}"}`,
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tr, err := transform.New(testCase.transform, json.RawMessage(testCase.config))
+			if err != nil {
+				t.Fatalf("%s: failed to build transform: %v", testCase.name, err)
+			}
+			actual, err := tr.Transform(data)
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !testCase.expectedErr && !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("%s: expected %q, got %q", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
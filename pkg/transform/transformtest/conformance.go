@@ -0,0 +1,60 @@
+// Package transformtest checks that transforms honor the contract
+// documented in the transform package.
+package transformtest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
+)
+
+// Conformance runs the transform against every input and fails the test
+// if the transform modifies its input, is not deterministic or is not safe
+// to call concurrently. Inputs for which the transform fails are expected
+// to fail consistently.
+func Conformance(t *testing.T, tr transform.Transform, inputs ...map[string][]byte) {
+	for i, input := range inputs {
+		original := copyData(input)
+		first, firstErr := tr.Transform(input)
+		if !reflect.DeepEqual(input, original) {
+			t.Errorf("input %d: transform modified its input: expected %q, got %q", i, original, input)
+		}
+		second, secondErr := tr.Transform(copyData(original))
+		if (firstErr == nil) != (secondErr == nil) {
+			t.Errorf("input %d: transform is not deterministic: failed with %v, then with %v", i, firstErr, secondErr)
+			continue
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("input %d: transform is not deterministic: returned %q, then %q", i, first, second)
+		}
+
+		var wg sync.WaitGroup
+		results := make([]map[string][]byte, 8)
+		for j := range results {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				results[j], _ = tr.Transform(input)
+			}(j)
+		}
+		wg.Wait()
+		for j, result := range results {
+			if !reflect.DeepEqual(result, first) {
+				t.Errorf("input %d: concurrent call %d returned %q, expected %q", i, j, result, first)
+			}
+		}
+	}
+}
+
+func copyData(data map[string][]byte) map[string][]byte {
+	if data == nil {
+		return nil
+	}
+	copied := make(map[string][]byte, len(data))
+	for key, value := range data {
+		copied[key] = append([]byte(nil), value...)
+	}
+	return copied
+}
@@ -0,0 +1,190 @@
+package transform
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// FailurePolicyFail blocks the write when the webhook fails
+	FailurePolicyFail = "Fail"
+	// FailurePolicyIgnore mirrors the data unchanged when the webhook fails
+	FailurePolicyIgnore = "Ignore"
+
+	// WebhookName is the name the webhook transform is registered under
+	WebhookName = "webhook"
+
+	defaultWebhookTimeout = 10 * time.Second
+	// maxWebhookResponseSize bounds the response read from the webhook:
+	// secrets hold at most 1MiB, which JSON inflates by a third as
+	// values are base64-encoded
+	maxWebhookResponseSize = 3 << 20
+)
+
+// IgnoredError is returned along with the data to write by transforms
+// that failed but whose failure policy ignores failures, so that the
+// failure can still be surfaced.
+type IgnoredError struct {
+	Err error
+}
+
+func (e *IgnoredError) Error() string {
+	return fmt.Sprintf("ignored failure: %v", e.Err)
+}
+
+func init() {
+	Register(WebhookName, newWebhook)
+}
+
+// WebhookPayload is POSTed to the webhook and expected in its response.
+type WebhookPayload struct {
+	Data map[string][]byte `json:"data"`
+}
+
+// webhook delegates the transform to an external HTTPS service, which
+// receives the data as a WebhookPayload and responds with the data to
+// write in the same format. The service must be deterministic.
+type webhook struct {
+	URL string `json:"url"`
+	// Timeout bounds each call, e.g. 5s; defaults to 10s
+	Timeout string `json:"timeout,omitempty"`
+	// FailurePolicy is Fail (the default) or Ignore
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// CAFile holds the certificates that verify the service,
+	// defaulting to the system roots
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile hold the client certificate presented
+	// to the service, if it requires mutual TLS
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+
+	client *http.Client
+}
+
+// WebhookFiles returns the fields of the configuration of a webhook
+// transform that name files, which the controller reads from its own
+// filesystem and presents to the webhook.
+func WebhookFiles(config json.RawMessage) []string {
+	w := &webhook{}
+	if len(config) == 0 || json.Unmarshal(config, w) != nil {
+		return nil
+	}
+	var fields []string
+	for _, field := range []struct {
+		name, value string
+	}{
+		{name: "caFile", value: w.CAFile},
+		{name: "certFile", value: w.CertFile},
+		{name: "keyFile", value: w.KeyFile},
+	} {
+		if len(field.value) != 0 {
+			fields = append(fields, field.name)
+		}
+	}
+	return fields
+}
+
+func newWebhook(config json.RawMessage) (Transform, error) {
+	w := &webhook{}
+	if err := decode(config, w); err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(w.URL); err != nil || !u.IsAbs() || u.Scheme != "https" {
+		return nil, errors.New("url must be an absolute HTTPS URL")
+	}
+	timeout := defaultWebhookTimeout
+	if len(w.Timeout) != 0 {
+		parsed, err := time.ParseDuration(w.Timeout)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("timeout must be a positive duration, not %q", w.Timeout)
+		}
+		timeout = parsed
+	}
+	switch w.FailurePolicy {
+	case "":
+		w.FailurePolicy = FailurePolicyFail
+	case FailurePolicyFail, FailurePolicyIgnore:
+	default:
+		return nil, fmt.Errorf("failurePolicy must be %s or %s, not %q", FailurePolicyFail, FailurePolicyIgnore, w.FailurePolicy)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(w.CAFile) != 0 {
+		raw, err := ioutil.ReadFile(w.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read caFile: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw) {
+			return nil, errors.New("caFile holds no certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (len(w.CertFile) == 0) != (len(w.KeyFile) == 0) {
+		return nil, errors.New("certFile and keyFile must be set together")
+	}
+	if len(w.CertFile) != 0 {
+		certificate, err := tls.LoadX509KeyPair(w.CertFile, w.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	w.client = &http.Client{
+		Timeout: timeout,
+		// transforms are rebuilt when the configuration is reloaded,
+		// so idle connections of the previous client must expire
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment, IdleConnTimeout: 90 * time.Second},
+	}
+	return w, nil
+}
+
+func (w *webhook) Transform(data map[string][]byte) (map[string][]byte, error) {
+	transformed, err := w.call(data)
+	if err != nil {
+		if w.FailurePolicy == FailurePolicyIgnore {
+			return data, &IgnoredError{Err: err}
+		}
+		return nil, err
+	}
+	return transformed, nil
+}
+
+func (w *webhook) call(data map[string][]byte) (map[string][]byte, error) {
+	body, err := json.Marshal(WebhookPayload{Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize webhook request: %v", err)
+	}
+	response, err := w.client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to call webhook: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("webhook responded with %s", response.Status)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(response.Body, maxWebhookResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook response: %v", err)
+	}
+	if len(raw) > maxWebhookResponseSize {
+		return nil, fmt.Errorf("webhook response exceeds %d bytes", maxWebhookResponseSize)
+	}
+	var payload WebhookPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook response: %v", err)
+	}
+	if payload.Data == nil {
+		return nil, errors.New("webhook responded without data")
+	}
+	return payload.Data, nil
+}
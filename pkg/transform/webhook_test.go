@@ -0,0 +1,151 @@
+package transform_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/transform"
+)
+
+// tokenizer serves a webhook that upper-cases values, failing for
+// data with a "fail" key, hanging for data with a "hang" key and
+// responding without end for data with a "flood" key. It returns the
+// server and a file holding its CA.
+func tokenizer(t *testing.T) (*httptest.Server, string) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload transform.WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, fail := payload.Data["fail"]; fail {
+			http.Error(w, "failing as requested", http.StatusInternalServerError)
+			return
+		}
+		if _, hang := payload.Data["hang"]; hang {
+			time.Sleep(time.Second)
+		}
+		if _, flood := payload.Data["flood"]; flood {
+			w.Write([]byte(`{"data":{"flood":"`))
+			for i := 0; i < 4<<10; i++ {
+				w.Write(bytes.Repeat([]byte("A"), 1<<10))
+			}
+			return
+		}
+		tokenized := map[string][]byte{}
+		for key, value := range payload.Data {
+			tokenized[key] = bytes.ToUpper(value)
+		}
+		json.NewEncoder(w).Encode(transform.WebhookPayload{Data: tokenized})
+	}))
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+	return server, caFile
+}
+
+func TestWebhook(t *testing.T) {
+	server, caFile := tokenizer(t)
+	defer server.Close()
+	defer os.RemoveAll(filepath.Dir(caFile))
+
+	var testCases = []struct {
+		name        string
+		config      string
+		data        map[string][]byte
+		expected    map[string][]byte
+		expectedErr bool
+		ignored     bool
+	}{
+		{
+			name:     "the webhook response is used",
+			config:   fmt.Sprintf(`{"url":%q,"caFile":%q}`, server.URL, caFile),
+			data:     map[string][]byte{"token": []byte("secret")},
+			expected: map[string][]byte{"token": []byte("SECRET")},
+		},
+		{
+			name:        "an untrusted webhook fails",
+			config:      fmt.Sprintf(`{"url":%q}`, server.URL),
+			data:        map[string][]byte{"token": []byte("secret")},
+			expectedErr: true,
+		},
+		{
+			name:        "webhook failures block the write by default",
+			config:      fmt.Sprintf(`{"url":%q,"caFile":%q}`, server.URL, caFile),
+			data:        map[string][]byte{"fail": []byte("yes")},
+			expectedErr: true,
+		},
+		{
+			name:     "webhook failures can be ignored",
+			config:   fmt.Sprintf(`{"url":%q,"caFile":%q,"failurePolicy":"Ignore"}`, server.URL, caFile),
+			data:     map[string][]byte{"fail": []byte("yes")},
+			expected: map[string][]byte{"fail": []byte("yes")},
+			ignored:  true,
+		},
+		{
+			name:        "oversized responses fail",
+			config:      fmt.Sprintf(`{"url":%q,"caFile":%q}`, server.URL, caFile),
+			data:        map[string][]byte{"flood": []byte("yes")},
+			expectedErr: true,
+		},
+		{
+			name:        "slow webhooks time out",
+			config:      fmt.Sprintf(`{"url":%q,"caFile":%q,"timeout":"100ms"}`, server.URL, caFile),
+			data:        map[string][]byte{"hang": []byte("yes")},
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tr, err := transform.New("webhook", json.RawMessage(testCase.config))
+			if err != nil {
+				t.Fatalf("%s: failed to build transform: %v", testCase.name, err)
+			}
+			actual, err := tr.Transform(testCase.data)
+			if _, ignored := err.(*transform.IgnoredError); ignored != testCase.ignored {
+				t.Fatalf("%s: expected the failure to be ignored: %v, got %v", testCase.name, testCase.ignored, err)
+			} else if ignored {
+				err = nil
+			}
+			if err == nil && testCase.expectedErr {
+				t.Fatalf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Fatalf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !testCase.expectedErr && !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("%s: expected %q, got %q", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWebhookConfiguration(t *testing.T) {
+	for _, config := range []string{
+		`{"url":"http://tokenizer.example.com"}`,
+		`{"url":"https://tokenizer.example.com","timeout":"soon"}`,
+		`{"url":"https://tokenizer.example.com","failurePolicy":"Retry"}`,
+		`{"url":"https://tokenizer.example.com","certFile":"/etc/tls/tls.crt"}`,
+		`{"url":"https://tokenizer.example.com","caFile":"/does/not/exist"}`,
+	} {
+		if _, err := transform.New("webhook", json.RawMessage(config)); err == nil {
+			t.Errorf("expected configuration %s to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+const (
+	// renewalRetryInterval is how long to wait before retrying to renew
+	// the token after a failure.
+	renewalRetryInterval = time.Minute
+
+	// managedByKey is the custom metadata identifying the controller as
+	// the writer of a secret
+	managedByKey   = "managed-by"
+	managedByValue = "ci-secret-mirroring-controller"
+	// sourceKey is the custom metadata recording the source of a secret
+	sourceKey = "mirror-source"
+)
+
+// Client reads secrets from KV version 2 secrets engines of Vault.
+type Client struct {
+	// Address is the URL of the Vault server, e.g. https://vault.example.com
+	Address string
+	// Token authenticates requests to the server
+	Token string
+
+	client *http.Client
+	logger *logrus.Entry
+}
+
+// NewClient returns a Client for the Vault server at the address
+// that authenticates with the given token.
+func NewClient(address, token string) *Client {
+	return &Client{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		logger:  logrus.WithField("provider", config.ProviderVault),
+	}
+}
+
+// Read returns the data of the latest version of the secret at the
+// location and that version, or no data if the secret does not exist or
+// its latest version was deleted. Values that are not strings are
+// encoded as JSON.
+func (c *Client) Read(location config.SecretLocation) (map[string][]byte, string, error) {
+	mount, path := location.Vault.MountAndPath()
+	var result struct {
+		Data struct {
+			Data     map[string]interface{} `json:"data"`
+			Metadata struct {
+				Version int `json:"version"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+	found, err := c.do(http.MethodGet, fmt.Sprintf("/v1/%s/data/%s", mount, path), nil, &result)
+	if err != nil || !found || result.Data.Data == nil {
+		return nil, "", err
+	}
+	data := map[string][]byte{}
+	for key, value := range result.Data.Data {
+		if s, ok := value.(string); ok {
+			data[key] = []byte(s)
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not encode %s: %v", key, err)
+		}
+		data[key] = raw
+	}
+	return data, strconv.Itoa(result.Data.Metadata.Version), nil
+}
+
+// Target returns the data of the latest version of the secret at the
+// location like Read, along with whether the controller wrote it.
+func (c *Client) Target(location config.SecretLocation) (map[string][]byte, string, bool, error) {
+	data, version, err := c.Read(location)
+	if err != nil || data == nil {
+		return nil, "", false, err
+	}
+	mount, path := location.Vault.MountAndPath()
+	var result struct {
+		Data struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		} `json:"data"`
+	}
+	if _, err := c.do(http.MethodGet, fmt.Sprintf("/v1/%s/metadata/%s", mount, path), nil, &result); err != nil {
+		return nil, "", false, fmt.Errorf("could not read metadata: %v", err)
+	}
+	return data, version, result.Data.CustomMetadata[managedByKey] == managedByValue, nil
+}
+
+// Write writes the data as a new version of the secret at the location
+// and records the controller and the source as its writer in the custom
+// metadata of the secret, returning the version written. Vault holds
+// strings, so values must be valid UTF-8.
+func (c *Client) Write(location config.SecretLocation, data map[string][]byte, source string) (string, error) {
+	values := map[string]string{}
+	for key, value := range data {
+		if !utf8.Valid(value) {
+			return "", fmt.Errorf("the value of %s is not valid UTF-8, which Vault cannot hold", key)
+		}
+		values[key] = string(value)
+	}
+	mount, path := location.Vault.MountAndPath()
+	var result struct {
+		Data struct {
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+	found, err := c.do(http.MethodPost, fmt.Sprintf("/v1/%s/data/%s", mount, path), map[string]interface{}{"data": values}, &result)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("no KV version 2 secrets engine is mounted at %s", mount)
+	}
+	metadata := map[string]interface{}{"custom_metadata": map[string]string{managedByKey: managedByValue, sourceKey: source}}
+	if _, err := c.do(http.MethodPost, fmt.Sprintf("/v1/%s/metadata/%s", mount, path), metadata, nil); err != nil {
+		return "", fmt.Errorf("could not record the writer in the metadata: %v", err)
+	}
+	return strconv.Itoa(result.Data.Version), nil
+}
+
+// RenewToken renews the token, returning for how long it remains valid
+// and whether it can be renewed again.
+func (c *Client) RenewToken() (time.Duration, bool, error) {
+	var result struct {
+		Auth struct {
+			LeaseDuration int  `json:"lease_duration"`
+			Renewable     bool `json:"renewable"`
+		} `json:"auth"`
+	}
+	found, err := c.do(http.MethodPost, "/v1/auth/token/renew-self", nil, &result)
+	if err != nil {
+		return 0, false, err
+	}
+	if !found {
+		return 0, false, fmt.Errorf("token renewal is not supported by the server")
+	}
+	return time.Duration(result.Auth.LeaseDuration) * time.Second, result.Auth.Renewable, nil
+}
+
+// Run renews the token whenever half of its lifetime has passed, until
+// the stop channel is closed or the token can no longer be renewed.
+// Tokens that do not expire are never renewed.
+func (c *Client) Run(stop <-chan struct{}) {
+	for {
+		wait := renewalRetryInterval
+		ttl, renewable, err := c.RenewToken()
+		switch {
+		case err != nil:
+			c.logger.WithError(err).Warn("failed to renew the Vault token")
+		case !renewable || ttl == 0:
+			c.logger.Info("the Vault token is not renewable, no longer renewing it")
+			return
+		default:
+			c.logger.Debugf("renewed the Vault token for %s", ttl)
+			wait = ttl / 2
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// do sends a request with the body encoded as JSON, if any, to the
+// server and decodes the response into the result, if any, returning
+// false if the server found nothing at the path.
+func (c *Client) do(method, path string, body, result interface{}) (bool, error) {
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return false, err
+		}
+	}
+	request, err := http.NewRequest(method, c.Address+path, bytes.NewReader(encoded))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("X-Vault-Token", c.Token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		var failure struct {
+			Errors []string `json:"errors"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&failure); err == nil && len(failure.Errors) > 0 {
+			return false, fmt.Errorf("unexpected status %s: %s", response.Status, strings.Join(failure.Errors, "; "))
+		}
+		return false, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	// writing metadata is answered without a body
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return true, nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return false, fmt.Errorf("could not decode response: %v", err)
+	}
+	return true, nil
+}
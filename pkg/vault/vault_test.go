@@ -0,0 +1,170 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/ci-secret-mirroring-controller/pkg/controller/config"
+)
+
+func TestRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/ci/registry":
+			w.Write([]byte(`{"data":{"data":{"user":"ci","port":5000},"metadata":{"version":3}}}`))
+		case "/v1/secret/data/ci/deleted":
+			w.Write([]byte(`{"data":{"data":null,"metadata":{"version":2}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	var testCases = []struct {
+		name            string
+		token           string
+		path            string
+		expectedData    map[string][]byte
+		expectedVersion string
+		expectedErr     bool
+	}{
+		{
+			name:            "secret is read",
+			token:           "token",
+			path:            "secret/ci/registry",
+			expectedData:    map[string][]byte{"user": []byte("ci"), "port": []byte("5000")},
+			expectedVersion: "3",
+		},
+		{
+			name:  "deleted secret is missing",
+			token: "token",
+			path:  "secret/ci/deleted",
+		},
+		{
+			name:  "unknown secret is missing",
+			token: "token",
+			path:  "secret/ci/unknown",
+		},
+		{
+			name:        "denied request fails",
+			token:       "wrong",
+			path:        "secret/ci/registry",
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient(server.URL+"/", testCase.token)
+			data, version, err := client.Read(config.SecretLocation{Vault: config.VaultLocation{Path: testCase.path}})
+			if err == nil && testCase.expectedErr {
+				t.Errorf("%s: expected an error but got none", testCase.name)
+			}
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+			}
+			if !reflect.DeepEqual(data, testCase.expectedData) {
+				t.Errorf("%s: expected data %v, got %v", testCase.name, testCase.expectedData, data)
+			}
+			if version != testCase.expectedVersion {
+				t.Errorf("%s: expected version %q, got %q", testCase.name, testCase.expectedVersion, version)
+			}
+		})
+	}
+}
+
+func TestRenewToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/auth/token/renew-self" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"auth":{"lease_duration":3600,"renewable":true}}`))
+	}))
+	defer server.Close()
+
+	ttl, renewable, err := NewClient(server.URL, "token").RenewToken()
+	if err != nil {
+		t.Fatalf("expected the token to be renewed, got %v", err)
+	}
+	if ttl != time.Hour || !renewable {
+		t.Errorf("expected a renewable token valid for an hour, got %s and %v", ttl, renewable)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	stored := map[string]map[string]interface{}{}
+	metadata := map[string]map[string]interface{}{}
+	version := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+		}
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+			version++
+			stored[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")] = body["data"].(map[string]interface{})
+			fmt.Fprintf(w, `{"data":{"version":%d}}`, version)
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+			metadata[strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/")] = body
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+			data, found := stored[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data, "metadata": map[string]int{"version": version}}})
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": metadata[strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/")]})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token")
+	location := config.SecretLocation{Vault: config.VaultLocation{Path: "secret/ci/published"}}
+	if data, _, _, err := client.Target(location); err != nil || data != nil {
+		t.Fatalf("expected the target to be missing, got %v and %v", data, err)
+	}
+	written, err := client.Write(location, map[string][]byte{"token": []byte("value")}, "ci/token")
+	if err != nil {
+		t.Fatalf("expected the secret to be written, got %v", err)
+	}
+	if written != "1" {
+		t.Errorf("expected the first version to be written, got %q", written)
+	}
+	data, targetVersion, managed, err := client.Target(location)
+	if err != nil {
+		t.Fatalf("expected the target to be read, got %v", err)
+	}
+	if !reflect.DeepEqual(data, map[string][]byte{"token": []byte("value")}) || targetVersion != "1" || !managed {
+		t.Errorf("expected the written version to be managed by the controller, got %v, %q and %v", data, targetVersion, managed)
+	}
+	if source := metadata["ci/published"]["custom_metadata"].(map[string]interface{})[sourceKey]; source != "ci/token" {
+		t.Errorf("expected the source to be recorded, got %v", source)
+	}
+
+	if _, err := client.Write(location, map[string][]byte{"binary": {0xff}}, "ci/token"); err == nil {
+		t.Error("expected values that are not UTF-8 to be refused")
+	}
+	if _, err := client.Write(config.SecretLocation{Vault: config.VaultLocation{Path: "missing/ci/published"}}, map[string][]byte{"token": []byte("value")}, "ci/token"); err == nil {
+		t.Error("expected writes to an engine that is not mounted to fail")
+	}
+}
@@ -0,0 +1,26 @@
+// Package version holds the version of the controller, which is
+// set at build time.
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Name is the name the controller identifies itself with.
+const Name = "ci-secret-mirroring-controller"
+
+// Version is set at build time with
+// -ldflags "-X github.com/openshift/ci-secret-mirroring-controller/pkg/version.Version=..."
+var Version = "unknown"
+
+// UserAgent builds the User-Agent the controller sends to API servers,
+// naming the controller, its version and platform. A non-empty suffix
+// is appended to tell apart multiple instances of the controller.
+func UserAgent(suffix string) string {
+	userAgent := fmt.Sprintf("%s/%s (%s/%s)", Name, Version, runtime.GOOS, runtime.GOARCH)
+	if suffix != "" {
+		userAgent = fmt.Sprintf("%s %s", userAgent, suffix)
+	}
+	return userAgent
+}